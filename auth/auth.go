@@ -46,7 +46,7 @@ type User struct {
 	IdcardIssueDate      string    `json:"idcard_issue_date"`
 	IdcardIssuePlaceName string    `json:"idcard_issue_place_name"`
 	IdcardIssuePlaceCode string    `json:"idcard_issue_place_code"`
-	DateOfBirth          string    `json:"idcard_issue_date"`
+	DateOfBirth          string    `json:"date_of_birth"`
 	CreateAt             time.Time `json:"-"`
 	UpdateAt             time.Time `json:"-"`
 }
@@ -1,9 +1,19 @@
 package auth
 
 import (
+	"os"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/ThomasNguyenGitHub/go/cache"
 )
 
+func TestMain(m *testing.M) {
+	SetCache(&memoryCache{values: map[string]string{}})
+	os.Exit(m.Run())
+}
+
 // --------------------------------------------------
 // For testing purposes only!
 // --------------------------------------------------
@@ -19,6 +29,41 @@ func (t testAuthenticator) HashedPassword() string {
 	return t.Hash
 }
 
+// memoryCache implements the methods of cache.Cacher used for the tokens.
+type memoryCache struct {
+	cache.Cacher
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func (c *memoryCache) PutString(key, value string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = value
+	return "OK", nil
+}
+
+func (c *memoryCache) GetString(key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.values[key]
+	if !ok {
+		return "", cache.ErrNil
+	}
+	return value, nil
+}
+
+func (c *memoryCache) Delete(keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		delete(c.values, key)
+	}
+	return nil
+}
+
+func (c *memoryCache) Expire(string, time.Duration) error { return nil }
+
 //--------------------------------------------------
 
 //func TestSetCache(t *testing.T) {
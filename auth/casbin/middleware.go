@@ -24,6 +24,11 @@ const (
 	// CasbinEnforcerContextKey holds the key to retrieve the active casbin
 	// Enforcer.
 	CasbinEnforcerContextKey contextKey = "CasbinEnforcer"
+
+	// CasbinRolesContextKey holds the key to store the roles of the subject
+	// in context, as a []string, e.g. the roles resolved from the directory
+	// groups of the user by auth.GroupResolver.
+	CasbinRolesContextKey contextKey = "CasbinRoles"
 )
 
 var (
@@ -69,3 +74,35 @@ func NewEnforcer(
 		}
 	}
 }
+
+// NewRoleEnforcer checks whether any of the roles stored in context with
+// CasbinRolesContextKey is authorized to do the specified action on the
+// given object. The roles are the subjects of the policy. As for
+// NewEnforcer, the generated casbin Enforcer is stored in the context with
+// CasbinEnforcer as the key.
+func NewRoleEnforcer(object interface{}, action string) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			casbinModel := ctx.Value(CasbinModelContextKey)
+			casbinPolicy := ctx.Value(CasbinPolicyContextKey)
+			enforcer, err := stdcasbin.NewEnforcer(casbinModel, casbinPolicy)
+			if err != nil {
+				return nil, err
+			}
+
+			ctx = context.WithValue(ctx, CasbinEnforcerContextKey, enforcer)
+			roles, _ := ctx.Value(CasbinRolesContextKey).([]string)
+			for _, role := range roles {
+				ok, err := enforcer.Enforce(role, object, action)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					return next(ctx, request)
+				}
+			}
+
+			return nil, ErrUnauthorized
+		}
+	}
+}
@@ -54,3 +54,26 @@ func TestFileBaseContext(t *testing.T) {
 		t.Fatalf("Enforcer returned error: %s", err)
 	}
 }
+
+func TestRoleEnforcer(t *testing.T) {
+	e := func(ctx context.Context, i interface{}) (interface{}, error) { return ctx, nil }
+	ctx := context.WithValue(context.Background(), CasbinModelContextKey, "testdata/basic_model.conf")
+	ctx = context.WithValue(ctx, CasbinPolicyContextKey, "testdata/basic_policy.csv")
+
+	// positive case, any of the roles is authorized
+	middleware := NewRoleEnforcer("data2", "write")(e)
+	_, err := middleware(context.WithValue(ctx, CasbinRolesContextKey, []string{"alice", "bob"}), struct{}{})
+	if err != nil {
+		t.Fatalf("Enforcer returned error: %s", err)
+	}
+
+	// negative cases
+	_, err = middleware(context.WithValue(ctx, CasbinRolesContextKey, []string{"alice"}), struct{}{})
+	if err != ErrUnauthorized {
+		t.Fatalf("Enforcer should return ErrUnauthorized, got %v", err)
+	}
+	_, err = middleware(ctx, struct{}{})
+	if err != ErrUnauthorized {
+		t.Fatalf("Enforcer should return ErrUnauthorized without roles, got %v", err)
+	}
+}
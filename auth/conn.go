@@ -11,6 +11,8 @@ import (
 type Conn struct {
 	Conn   *ldap.Conn
 	Config *Config
+	// PasswordPolicy is the password policy response control returned by the last Bind, if any
+	PasswordPolicy *ldap.ControlBeheraPasswordPolicy
 }
 
 // Connect returns an open connection to an Active Directory server or an error if one occurred.
@@ -61,19 +63,36 @@ func (c *Config) Connect() (*Conn, error) {
 
 // Bind authenticates the connection with the given userPrincipalName and password
 // and returns the result or an error if one occurred.
+// Invalid credentials return false without an error. Expired passwords, passwords that must be changed,
+// locked, disabled or expired accounts return a *PolicyError wrapping ErrPasswordExpired, ErrMustChange,
+// ErrAccountLocked, ErrAccountDisabled or ErrAccountExpired.
 func (c *Conn) Bind(upn, password string) (bool, error) {
+	c.PasswordPolicy = nil
 	if password == "" {
 		return false, nil
 	}
 
-	err := c.Conn.Bind(upn, password)
+	req := ldap.NewSimpleBindRequest(upn, password, []ldap.Control{ldap.NewControlBeheraPasswordPolicy()})
+	result, err := c.Conn.SimpleBind(req)
+	if result != nil {
+		if ctrl, ok := ldap.FindControl(result.Controls, ldap.ControlTypeBeheraPasswordPolicy).(*ldap.ControlBeheraPasswordPolicy); ok {
+			c.PasswordPolicy = ctrl
+		}
+	}
+	perr := beheraError(c.PasswordPolicy)
 	if err != nil {
-		if e, ok := err.(*ldap.Error); ok {
-			if e.ResultCode == ldap.LDAPResultInvalidCredentials {
-				return false, nil
-			}
+		if perr == nil {
+			perr = passwordError(err)
+		}
+		if perr == nil {
+			return false, fmt.Errorf("Bind error (%s): %v", upn, err)
+		}
+	}
+	if perr != nil {
+		if errors.Is(perr, ErrInvalidCredentials) {
+			return false, nil
 		}
-		return false, fmt.Errorf("Bind error (%s): %v", upn, err)
+		return false, fmt.Errorf("Bind error (%s): %w", upn, perr)
 	}
 
 	return true, nil
@@ -1,7 +1,6 @@
 package auth
 
 import (
-	"errors"
 	"fmt"
 
 	ldap "github.com/ThomasNguyenGitHub/go/ldap"
@@ -22,14 +21,25 @@ func (c *Conn) ModifyDNPassword(dn, newPasswd string) error {
 
 	err = c.Conn.Modify(req)
 	if err != nil {
-		return fmt.Errorf("Password error: Unable to modify password: %v", err)
+		return modifyPasswordError(err)
 	}
 
 	return nil
 }
 
+// modifyPasswordError returns the error of a failed password modification,
+// wrapping a *PolicyError when the server rejected the password.
+func modifyPasswordError(err error) error {
+	if perr := passwordError(err); perr != nil {
+		return fmt.Errorf("Password error: Unable to modify password: %w", perr)
+	}
+	return fmt.Errorf("Password error: Unable to modify password: %v", err)
+}
+
 // UpdatePassword checks if the given credentials are valid and updates the password if they are,
 // or returns an error if one occurred. UpdatePassword is used for users resetting their own password.
+// Password policy failures, such as a new password not meeting the complexity requirements or an
+// old password that must be changed, are reported by the password policy errors (see PolicyError).
 func UpdatePassword(config *Config, username, oldPasswd, newPasswd string) error {
 	utf16 := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
 	oldEncoded, err := utf16.NewEncoder().String(fmt.Sprintf(`"%s"`, oldPasswd))
@@ -59,7 +69,7 @@ func UpdatePassword(config *Config, username, oldPasswd, newPasswd string) error
 		return err
 	}
 	if !status {
-		return errors.New("Password error: credentials not valid")
+		return fmt.Errorf("Password error: %w", ErrInvalidCredentials)
 	}
 
 	dn, err := conn.GetDN("userPrincipalName", upn)
@@ -73,7 +83,7 @@ func UpdatePassword(config *Config, username, oldPasswd, newPasswd string) error
 
 	err = conn.Conn.Modify(req)
 	if err != nil {
-		return fmt.Errorf("Password error: Unable to modify password: %v", err)
+		return modifyPasswordError(err)
 	}
 
 	return nil
@@ -65,7 +65,7 @@ func TestConnModifyDNPassword(t *testing.T) {
 	}
 
 	//authenticate 1
-	status, err = Authenticate(config, testConfig.PasswordUPN, "Random123!")
+	status, err = LDAPAutht(config, testConfig.PasswordUPN, "Random123!")
 	if err != nil {
 		t.Fatal("Authenticate 1: Expected err to be nil but got:", err)
 	}
@@ -80,7 +80,7 @@ func TestConnModifyDNPassword(t *testing.T) {
 	}
 
 	//authenticate 2
-	status, err = Authenticate(config, testConfig.PasswordUPN, "Random321!")
+	status, err = LDAPAutht(config, testConfig.PasswordUPN, "Random321!")
 	if err != nil {
 		t.Fatal("Authenticate 2: Expected err to be nil but got:", err)
 	}
@@ -148,7 +148,7 @@ func TestUpdatePassword(t *testing.T) {
 		t.Fatal("Valid password: Expected err to be nil but got:", err)
 	}
 
-	status, err = Authenticate(config, testConfig.PasswordUPN, randPass)
+	status, err = LDAPAutht(config, testConfig.PasswordUPN, randPass)
 	if err != nil {
 		t.Fatal("Authenticate: Expected err to be nil but got:", err)
 	}
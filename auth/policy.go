@@ -0,0 +1,302 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	ldap "github.com/ThomasNguyenGitHub/go/ldap"
+)
+
+// Password policy errors returned by Bind, UpdatePassword and ModifyDNPassword.
+// They are wrapped in a *PolicyError and can be checked with errors.Is.
+var (
+	ErrInvalidCredentials = errors.New("credentials not valid")
+	ErrPasswordExpired    = errors.New("password expired")
+	ErrMustChange         = errors.New("password must be changed")
+	ErrAccountLocked      = errors.New("account locked")
+	ErrAccountDisabled    = errors.New("account disabled")
+	ErrAccountExpired     = errors.New("account expired")
+	ErrPolicyViolation    = errors.New("password does not meet the password policy")
+)
+
+// PolicyError is a password policy error reported by the server.
+type PolicyError struct {
+	// Err is one of the Err* password policy errors
+	Err error
+	// Code is the Active Directory data code or the Behera password policy error
+	Code string
+	// Cause is the error returned by the server
+	Cause error
+}
+
+func (e *PolicyError) Error() string {
+	if e.Cause == nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%v: %v", e.Err, e.Cause)
+}
+
+// Unwrap returns the password policy error and the error returned by the server.
+func (e *PolicyError) Unwrap() []error {
+	if e.Cause == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Err, e.Cause}
+}
+
+var (
+	// adDataCode matches the data field of Active Directory diagnostic messages such as
+	// "80090308: LdapErr: DSID-0C09044E, comment: AcceptSecurityContext error, data 773, v4563"
+	adDataCode = regexp.MustCompile(`data ([0-9a-fA-F]+)`)
+	// adWin32Code matches the Win32 error prefixing Active Directory diagnostic messages such as
+	// "0000052D: Constraint violation - check password restrictions, data 0"
+	adWin32Code = regexp.MustCompile(`^([0-9a-fA-F]{8}):`)
+)
+
+// adDataErrors maps the data codes of Active Directory logon failures to password policy errors
+var adDataErrors = map[string]error{
+	"525": ErrInvalidCredentials,
+	"52e": ErrInvalidCredentials,
+	"530": ErrAccountLocked, // not permitted to logon at this time
+	"531": ErrAccountLocked, // not permitted to logon at this workstation
+	"532": ErrPasswordExpired,
+	"533": ErrAccountDisabled,
+	"701": ErrAccountExpired,
+	"773": ErrMustChange,
+	"775": ErrAccountLocked,
+}
+
+// adWin32Errors maps the Win32 errors of Active Directory password changes to password policy errors
+var adWin32Errors = map[uint64]error{
+	0x56:  ErrInvalidCredentials, // ERROR_INVALID_PASSWORD: wrong old password
+	0x52D: ErrPolicyViolation,    // ERROR_PASSWORD_RESTRICTION
+	0x775: ErrAccountLocked,      // ERROR_ACCOUNT_LOCKED_OUT
+}
+
+// passwordError returns the *PolicyError describing err, or nil if err is not a password policy error.
+func passwordError(err error) *PolicyError {
+	var e *ldap.Error
+	if !errors.As(err, &e) || e.Err == nil {
+		return nil
+	}
+	msg := e.Err.Error()
+
+	if e.ResultCode == ldap.LDAPResultInvalidCredentials {
+		if m := adDataCode.FindStringSubmatch(msg); m != nil {
+			code := strings.ToLower(strings.TrimLeft(m[1], "0"))
+			if perr, ok := adDataErrors[code]; ok {
+				return &PolicyError{Err: perr, Code: code, Cause: err}
+			}
+		}
+	}
+
+	if m := adWin32Code.FindStringSubmatch(msg); m != nil {
+		code, _ := strconv.ParseUint(m[1], 16, 32)
+		if perr, ok := adWin32Errors[code]; ok {
+			return &PolicyError{Err: perr, Code: strings.ToUpper(strconv.FormatUint(code, 16)), Cause: err}
+		}
+	}
+
+	switch e.ResultCode {
+	case ldap.LDAPResultInvalidCredentials:
+		return &PolicyError{Err: ErrInvalidCredentials, Cause: err}
+	case ldap.LDAPResultConstraintViolation:
+		return &PolicyError{Err: ErrPolicyViolation, Cause: err}
+	}
+	return nil
+}
+
+// beheraError returns the *PolicyError describing the error of a Behera password policy response control,
+// or nil if the control holds no error.
+func beheraError(c *ldap.ControlBeheraPasswordPolicy) *PolicyError {
+	if c == nil || c.Error < 0 {
+		return nil
+	}
+	perr := ErrPolicyViolation
+	switch c.Error {
+	case 0: // passwordExpired
+		perr = ErrPasswordExpired
+	case 1: // accountLocked
+		perr = ErrAccountLocked
+	case 2: // changeAfterReset
+		perr = ErrMustChange
+	}
+	return &PolicyError{Err: perr, Code: c.ErrorString, Cause: errors.New(c.ErrorString)}
+}
+
+// PasswordStatus describes the password of an account.
+type PasswordStatus struct {
+	// LastSet is the time the password was last set, zero if the password must be changed
+	LastSet time.Time
+	// Expires is the time the password expires, zero if the password never expires
+	Expires time.Time
+	// MustChange is true if the password must be changed at the next logon
+	MustChange bool
+}
+
+// Expired returns true if the password expired or must be changed.
+func (s *PasswordStatus) Expired() bool {
+	return s.MustChange || (!s.Expires.IsZero() && !s.Expires.After(time.Now()))
+}
+
+// DaysUntilExpiry returns the number of days, rounded up, until the password expires,
+// 0 if it expired or must be changed and -1 if it never expires.
+func (s *PasswordStatus) DaysUntilExpiry() int {
+	if s.Expired() {
+		return 0
+	}
+	if s.Expires.IsZero() {
+		return -1
+	}
+	return int(math.Ceil(time.Until(s.Expires).Hours() / 24))
+}
+
+// passwordStatusAttrs are the attributes read by PasswordStatus.
+// msDS-UserPasswordExpiryTimeComputed is constructed by Active Directory and must be requested explicitly.
+var passwordStatusAttrs = []string{"pwdLastSet", "msDS-UserPasswordExpiryTimeComputed", "userAccountControl"}
+
+// PasswordStatus returns the password status of the given user or an error if one occurred.
+// The expiry is read from msDS-UserPasswordExpiryTimeComputed when available, then from the
+// password policy control of the last Bind, then computed from the maxPwdAge of the domain.
+func (c *Conn) PasswordStatus(dn string) (*PasswordStatus, error) {
+	search := ldap.NewSearchRequest(
+		dn,
+		ldap.ScopeBaseObject,
+		ldap.DerefAlways,
+		1,
+		0,
+		false,
+		"(objectClass=*)",
+		passwordStatusAttrs,
+		nil,
+	)
+	result, err := c.Conn.Search(search)
+	if err != nil {
+		return nil, fmt.Errorf(`Password error: Unable to read password status of "%s": %v`, dn, err)
+	}
+	if len(result.Entries) == 0 {
+		return nil, fmt.Errorf(`Password error: Unable to read password status of "%s": no entries returned`, dn)
+	}
+	entry := result.Entries[0]
+
+	status := &PasswordStatus{}
+	if v := entry.GetAttributeValue("pwdLastSet"); v != "" {
+		if status.LastSet, err = ldap.ParseFileTime(v); err != nil {
+			return nil, fmt.Errorf("Password error: %v", err)
+		}
+		status.MustChange = v == "0"
+	}
+
+	uac, _ := strconv.ParseInt(entry.GetAttributeValue("userAccountControl"), 10, 64)
+	if ldap.UserAccountControl(uac).Has(ldap.UACDontExpirePassword) || status.MustChange {
+		return status, nil
+	}
+
+	if v := entry.GetAttributeValue("msDS-UserPasswordExpiryTimeComputed"); v != "" {
+		if status.Expires, err = ldap.ParseFileTime(v); err != nil {
+			return nil, fmt.Errorf("Password error: %v", err)
+		}
+		return status, nil
+	}
+
+	if c.PasswordPolicy != nil && c.PasswordPolicy.Expire >= 0 {
+		status.Expires = time.Now().Add(time.Duration(c.PasswordPolicy.Expire) * time.Second)
+		return status, nil
+	}
+
+	if !status.LastSet.IsZero() {
+		maxAge, err := c.maxPasswordAge()
+		if err != nil {
+			return nil, err
+		}
+		if maxAge > 0 {
+			status.Expires = status.LastSet.Add(maxAge)
+		}
+	}
+	return status, nil
+}
+
+// maxPasswordAge returns the maxPwdAge of the domain of BaseDN, 0 if passwords never expire.
+func (c *Conn) maxPasswordAge() (time.Duration, error) {
+	var dcs []string
+	for _, v := range strings.Split(c.Config.BaseDN, ",") {
+		if trimmed := strings.TrimSpace(v); strings.HasPrefix(strings.ToLower(trimmed), "dc=") {
+			dcs = append(dcs, trimmed)
+		}
+	}
+	if len(dcs) == 0 {
+		return 0, errors.New("Configuration error: invalid BaseDN")
+	}
+
+	search := ldap.NewSearchRequest(
+		strings.Join(dcs, ","),
+		ldap.ScopeBaseObject,
+		ldap.DerefAlways,
+		1,
+		0,
+		false,
+		"(objectClass=*)",
+		[]string{"maxPwdAge"},
+		nil,
+	)
+	result, err := c.Conn.Search(search)
+	if err != nil {
+		return 0, fmt.Errorf("Password error: Unable to read maxPwdAge: %v", err)
+	}
+	if len(result.Entries) == 0 {
+		return 0, nil
+	}
+
+	// maxPwdAge is a negative number of 100 nanoseconds intervals, math.MinInt64 meaning never
+	v := result.Entries[0].GetAttributeValue("maxPwdAge")
+	if v == "" {
+		return 0, nil
+	}
+	age, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Password error: invalid maxPwdAge %q", v)
+	}
+	if age == math.MinInt64 || age == 0 {
+		return 0, nil
+	}
+	if age < 0 {
+		age = -age
+	}
+	return time.Duration(age) * 100, nil
+}
+
+// GetPasswordStatus checks if the given credentials are valid and returns the password status of the user,
+// or returns an error if one occurred. Expired passwords and locked accounts are reported
+// by the password policy errors since the user cannot bind.
+func GetPasswordStatus(config *Config, username, password string) (*PasswordStatus, error) {
+	upn, err := config.UPN(username)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := config.Connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Conn.Close()
+
+	status, err := conn.Bind(upn, password)
+	if err != nil {
+		return nil, err
+	}
+	if !status {
+		return nil, fmt.Errorf("Password error: %w", ErrInvalidCredentials)
+	}
+
+	dn, err := conn.GetDN("userPrincipalName", upn)
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.PasswordStatus(dn)
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	ldap "github.com/ThomasNguyenGitHub/go/ldap"
+	"github.com/ThomasNguyenGitHub/go/ldap/ldaptest"
+)
+
+func newPolicyTestServer(t *testing.T) (*ldaptest.Server, *Config) {
+	t.Helper()
+	s := ldaptest.NewUnstartedServer()
+	s.MinPasswordLength = 8
+
+	now := time.Now()
+	maxPwdAge := -int64(42 * 24 * time.Hour / 100)
+	entries := []struct {
+		dn    string
+		attrs map[string][]string
+	}{
+		{"dc=example,dc=com", map[string][]string{"objectClass": {"domain"}, "maxPwdAge": {strconv.FormatInt(maxPwdAge, 10)}}},
+		{"cn=alice,dc=example,dc=com", map[string][]string{
+			"objectClass":                         {"user"},
+			"userPrincipalName":                   {"alice@example.com"},
+			"userPassword":                        {"Password1!"},
+			"userAccountControl":                  {"512"},
+			"pwdLastSet":                          {ldap.FormatFileTime(now.Add(-37 * 24 * time.Hour))},
+			"msDS-UserPasswordExpiryTimeComputed": {ldap.FormatFileTime(now.Add(5 * 24 * time.Hour))},
+		}},
+		{"cn=bob,dc=example,dc=com", map[string][]string{
+			"objectClass":       {"user"},
+			"userPrincipalName": {"bob@example.com"},
+			"userPassword":      {"Password1!"},
+			"pwdLastSet":        {"0"},
+		}},
+		{"cn=carol,dc=example,dc=com", map[string][]string{
+			"objectClass":       {"user"},
+			"userPrincipalName": {"carol@example.com"},
+			"userPassword":      {"Password1!"},
+			"pwdLastSet":        {ldap.FormatFileTime(now.Add(-10 * 24 * time.Hour))},
+		}},
+		{"cn=dave,dc=example,dc=com", map[string][]string{
+			"objectClass":        {"user"},
+			"userPrincipalName":  {"dave@example.com"},
+			"userPassword":       {"Password1!"},
+			"userAccountControl": {"66048"},
+			"pwdLastSet":         {ldap.FormatFileTime(now.Add(-100 * 24 * time.Hour))},
+		}},
+		{"cn=erin,dc=example,dc=com", map[string][]string{
+			"objectClass":                         {"user"},
+			"userPrincipalName":                   {"erin@example.com"},
+			"userPassword":                        {"Password1!"},
+			"msDS-UserPasswordExpiryTimeComputed": {ldap.FormatFileTime(now.Add(-time.Hour))},
+		}},
+		{"cn=locked,dc=example,dc=com", map[string][]string{
+			"objectClass":       {"user"},
+			"userPrincipalName": {"locked@example.com"},
+			"userPassword":      {"Password1!"},
+			"lockoutTime":       {ldap.FormatFileTime(now)},
+		}},
+		{"cn=disabled,dc=example,dc=com", map[string][]string{
+			"objectClass":        {"user"},
+			"userPrincipalName":  {"disabled@example.com"},
+			"userPassword":       {"Password1!"},
+			"userAccountControl": {"514"},
+		}},
+		{"cn=expired,dc=example,dc=com", map[string][]string{
+			"objectClass":       {"user"},
+			"userPrincipalName": {"expired@example.com"},
+			"userPassword":      {"Password1!"},
+			"accountExpires":    {ldap.FormatFileTime(now.Add(-24 * time.Hour))},
+		}},
+	}
+	for _, e := range entries {
+		if err := s.AddEntry(e.dn, e.attrs); err != nil {
+			s.Close()
+			t.Fatal("Error seeding server:", err)
+		}
+	}
+	s.Start()
+	t.Cleanup(s.Close)
+
+	return s, &Config{Server: s.Host(), Port: s.Port(), BaseDN: "dc=example,dc=com", Security: SecurityNone}
+}
+
+func TestBindPolicyErrors(t *testing.T) {
+	_, config := newPolicyTestServer(t)
+
+	if status, err := LDAPAutht(config, "alice", "invalid_password"); status || err != nil {
+		t.Errorf("Invalid credentials: Expected false, nil but got %v, %v", status, err)
+	}
+	if status, err := LDAPAutht(config, "alice", "Password1!"); !status || err != nil {
+		t.Errorf("Valid credentials: Expected true, nil but got %v, %v", status, err)
+	}
+
+	for username, expected := range map[string]error{
+		"bob":      ErrMustChange,
+		"erin":     ErrPasswordExpired,
+		"locked":   ErrAccountLocked,
+		"disabled": ErrAccountDisabled,
+		"expired":  ErrAccountExpired,
+	} {
+		status, err := LDAPAutht(config, username, "Password1!")
+		if status || !errors.Is(err, expected) {
+			t.Errorf("%s: Expected %v but got %v, %v", username, expected, status, err)
+		}
+		var perr *PolicyError
+		if !errors.As(err, &perr) || perr.Code == "" {
+			t.Errorf("%s: Expected a *PolicyError with a code but got %#v", username, err)
+		}
+	}
+}
+
+func TestPasswordStatus(t *testing.T) {
+	_, config := newPolicyTestServer(t)
+
+	for username, expected := range map[string]int{
+		"alice": 5,  // msDS-UserPasswordExpiryTimeComputed
+		"carol": 32, // maxPwdAge of the domain
+		"dave":  -1, // DontExpirePassword
+	} {
+		status, err := GetPasswordStatus(config, username, "Password1!")
+		if err != nil {
+			t.Errorf("%s: Expected err to be nil but got: %v", username, err)
+			continue
+		}
+		if status.Expired() || status.LastSet.IsZero() {
+			t.Errorf("%s: Unexpected status %+v", username, status)
+		}
+		if days := status.DaysUntilExpiry(); days != expected {
+			t.Errorf("%s: Expected %d days until expiry but got %d", username, expected, days)
+		}
+	}
+
+	if _, err := GetPasswordStatus(config, "bob", "Password1!"); !errors.Is(err, ErrMustChange) {
+		t.Error("Must change: Expected ErrMustChange but got:", err)
+	}
+	if _, err := GetPasswordStatus(config, "alice", "invalid_password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Error("Invalid credentials: Expected ErrInvalidCredentials but got:", err)
+	}
+
+	if days := (&PasswordStatus{MustChange: true}).DaysUntilExpiry(); days != 0 {
+		t.Error("Must change: Expected 0 days until expiry but got", days)
+	}
+}
+
+func TestUpdatePasswordPolicy(t *testing.T) {
+	s, config := newPolicyTestServer(t)
+
+	if err := UpdatePassword(config, "alice", "invalid_password", "Password2!"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Error("Invalid password: Expected ErrInvalidCredentials but got:", err)
+	}
+	if err := UpdatePassword(config, "alice", "Password1!", "short"); !errors.Is(err, ErrPolicyViolation) {
+		t.Error("Short password: Expected ErrPolicyViolation but got:", err)
+	}
+	if err := UpdatePassword(config, "bob", "Password1!", "Password2!"); !errors.Is(err, ErrMustChange) {
+		t.Error("Must change: Expected ErrMustChange but got:", err)
+	}
+
+	if err := UpdatePassword(config, "alice", "Password1!", "Password2!"); err != nil {
+		t.Fatal("Valid password: Expected err to be nil but got:", err)
+	}
+	if status, err := LDAPAutht(config, "alice", "Password2!"); !status || err != nil {
+		t.Errorf("New password: Expected true, nil but got %v, %v", status, err)
+	}
+	if pwdLastSet, err := ldap.ParseFileTime(s.Entry("cn=alice,dc=example,dc=com").GetAttributeValue("pwdLastSet")); err != nil || time.Since(pwdLastSet) > time.Minute {
+		t.Error("Expected pwdLastSet to be updated but got:", pwdLastSet, err)
+	}
+
+	conn, err := config.Connect()
+	if err != nil {
+		t.Fatal("Error connecting to server:", err)
+	}
+	defer conn.Conn.Close()
+
+	if err := conn.ModifyDNPassword("cn=bob,dc=example,dc=com", "short"); !errors.Is(err, ErrPolicyViolation) {
+		t.Error("ModifyDNPassword short password: Expected ErrPolicyViolation but got:", err)
+	}
+	if err := conn.ModifyDNPassword("cn=bob,dc=example,dc=com", "Password3!"); err != nil {
+		t.Fatal("ModifyDNPassword: Expected err to be nil but got:", err)
+	}
+	if status, err := LDAPAutht(config, "bob", "Password3!"); !status || err != nil {
+		t.Errorf("Reset password: Expected true, nil but got %v, %v", status, err)
+	}
+}
@@ -0,0 +1,329 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ThomasNguyenGitHub/go/cache"
+	ldap "github.com/ThomasNguyenGitHub/go/ldap"
+	"github.com/ThomasNguyenGitHub/go/log"
+)
+
+// DefaultGroupCacheTTL is the time resolved groups are cached when GroupResolver.TTL is not set.
+const DefaultGroupCacheTTL = 5 * time.Minute
+
+// groupFilterChunk is the number of values combined in one (|...) search filter.
+const groupFilterChunk = 50
+
+// GroupResolver resolves the transitive group membership of directory objects
+// and maps the groups to application roles.
+//
+// On Active Directory the groups are read from the tokenGroups constructed attribute
+// with a single search. Other directories are walked breadth first, one search per nesting level,
+// following member and uniqueMember and the memberOf attributes returned along the way.
+type GroupResolver struct {
+	// Cache stores the resolved groups of each object, nothing is cached when nil
+	Cache cache.Cacher
+	// TTL is the time resolved groups are cached, DefaultGroupCacheTTL when zero
+	TTL time.Duration
+	// GroupRoles maps groups, referenced by DN or cn, to application roles
+	GroupRoles map[string][]string
+	// MaxDepth limits the nesting levels walked when tokenGroups is not available, 0 meaning unlimited
+	MaxDepth int
+	// Logger logs the cache errors, the standard logger when nil
+	Logger *log.Logger
+}
+
+// NewGroupResolver returns a GroupResolver caching groups in c for ttl and mapping them with roles.
+func NewGroupResolver(c cache.Cacher, ttl time.Duration, roles map[string][]string) *GroupResolver {
+	return &GroupResolver{Cache: c, TTL: ttl, GroupRoles: roles}
+}
+
+// Groups returns the DNs of the groups the object with the given DN is a member of,
+// directly or through nested groups, or an error if one occurred.
+// The cache being an optimization, its errors are logged rather than returned.
+func (r *GroupResolver) Groups(c *Conn, dn string) ([]string, error) {
+	key := getGroupsCacheKey(dn)
+	if r.Cache != nil {
+		var groups []string
+		if err := r.Cache.GetMarshaled(key, &groups); err == nil && groups != nil {
+			return groups, nil
+		}
+	}
+
+	groups, err := r.resolve(c, dn)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Cache != nil {
+		r.store(key, groups)
+	}
+	return groups, nil
+}
+
+// store caches the groups, logging the errors. The groups are removed when
+// their TTL cannot be set, so as not to be cached forever.
+func (r *GroupResolver) store(key string, groups []string) {
+	logger := r.Logger
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+	if _, err := r.Cache.PutMarshaled(key, groups); err != nil {
+		logger.WithError(err).WithField("key", key).Warn("Unable to cache the groups")
+		return
+	}
+	// Cacher.Expire takes a number of seconds
+	if err := r.Cache.Expire(key, r.ttl()/time.Second); err != nil {
+		logger.WithError(err).WithField("key", key).Warn("Unable to set the TTL of the cached groups")
+		if err := r.Cache.Delete(key); err != nil {
+			logger.WithError(err).WithField("key", key).Warn("Unable to remove the cached groups")
+		}
+	}
+}
+
+// Roles returns the application roles of the object with the given DN, or an error if one occurred.
+func (r *GroupResolver) Roles(c *Conn, dn string) ([]string, error) {
+	groups, err := r.Groups(c, dn)
+	if err != nil {
+		return nil, err
+	}
+	return r.MapRoles(groups), nil
+}
+
+// MapRoles returns the roles mapped to the given groups, without duplicates.
+// Groups match the keys of GroupRoles by DN or by cn, ignoring case.
+func (r *GroupResolver) MapRoles(groups []string) []string {
+	var roles []string
+	seen := map[string]bool{}
+	for _, group := range groups {
+		cn := groupCN(group)
+		for key, mapped := range r.GroupRoles {
+			if !strings.EqualFold(key, group) && !strings.EqualFold(key, cn) {
+				continue
+			}
+			for _, role := range mapped {
+				if !seen[role] {
+					seen[role] = true
+					roles = append(roles, role)
+				}
+			}
+		}
+	}
+	return roles
+}
+
+// Invalidate removes the cached groups of the object with the given DN.
+func (r *GroupResolver) Invalidate(dn string) error {
+	if r.Cache == nil {
+		return nil
+	}
+	return r.Cache.Delete(getGroupsCacheKey(dn))
+}
+
+func (r *GroupResolver) ttl() time.Duration {
+	if r.TTL > 0 {
+		return r.TTL
+	}
+	return DefaultGroupCacheTTL
+}
+
+func (r *GroupResolver) resolve(c *Conn, dn string) ([]string, error) {
+	entry, err := c.baseEntry(dn, []string{"tokenGroups", "memberOf"})
+	if err != nil {
+		return nil, err
+	}
+
+	if tokenGroups := entryAttribute(entry, "tokenGroups"); tokenGroups != nil {
+		return c.groupsBySID(tokenGroups.ByteValues)
+	}
+	return c.nestedGroups(entry, r.MaxDepth)
+}
+
+// baseEntry returns the entry with the given DN holding the given attributes.
+func (c *Conn) baseEntry(dn string, attrs []string) (*ldap.Entry, error) {
+	search := ldap.NewSearchRequest(
+		dn,
+		ldap.ScopeBaseObject,
+		ldap.DerefAlways,
+		1,
+		0,
+		false,
+		"(objectClass=*)",
+		attrs,
+		nil,
+	)
+	result, err := c.Conn.Search(search)
+	if err != nil {
+		return nil, fmt.Errorf(`Search error "%s": %v`, dn, err)
+	}
+	if len(result.Entries) == 0 {
+		return nil, fmt.Errorf(`Search error "%s": no entries returned`, dn)
+	}
+	return result.Entries[0], nil
+}
+
+// groupsBySID returns the DNs of the groups with the given binary objectSid values.
+// Groups outside of BaseDN, such as the builtin groups, are not returned.
+func (c *Conn) groupsBySID(sids [][]byte) ([]string, error) {
+	values := make([]string, len(sids))
+	for i, sid := range sids {
+		values[i] = string(sid)
+	}
+	entries, err := c.searchAny("objectSid", values, nil)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		groups = append(groups, entry.DN)
+	}
+	return groups, nil
+}
+
+// nestedGroups walks the groups of entry breadth first, up to maxDepth levels when positive.
+func (c *Conn) nestedGroups(entry *ldap.Entry, maxDepth int) ([]string, error) {
+	groups := []string{}
+	visited := map[string]bool{normalizeGroupDN(entry.DN): true}
+	frontier := []*ldap.Entry{entry}
+
+	for depth := 1; len(frontier) > 0 && (maxDepth <= 0 || depth <= maxDepth); depth++ {
+		var (
+			members []string
+			parents []string
+		)
+		for _, e := range frontier {
+			members = append(members, e.DN)
+			parents = append(parents, e.GetAttributeValues("memberOf")...)
+		}
+
+		found, err := c.searchAny("member", members, []string{"memberOf"})
+		if err != nil {
+			return nil, err
+		}
+		unique, err := c.searchAny("uniqueMember", members, []string{"memberOf"})
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, unique...)
+
+		frontier = nil
+		for _, e := range found {
+			if key := normalizeGroupDN(e.DN); !visited[key] {
+				visited[key] = true
+				groups = append(groups, e.DN)
+				frontier = append(frontier, e)
+			}
+		}
+		// groups only known through memberOf, such as groups of another naming context
+		for _, parent := range parents {
+			if key := normalizeGroupDN(parent); !visited[key] {
+				visited[key] = true
+				groups = append(groups, parent)
+				frontier = append(frontier, &ldap.Entry{DN: parent})
+			}
+		}
+	}
+	return groups, nil
+}
+
+// searchAny returns the entries whose attr holds any of the given values,
+// combining the values in (|...) filters of groupFilterChunk values.
+func (c *Conn) searchAny(attr string, values []string, attrs []string) ([]*ldap.Entry, error) {
+	if len(attrs) == 0 {
+		attrs = []string{""}
+	}
+	var entries []*ldap.Entry
+	for len(values) > 0 {
+		n := len(values)
+		if n > groupFilterChunk {
+			n = groupFilterChunk
+		}
+		var filter strings.Builder
+		filter.WriteString("(|")
+		for _, value := range values[:n] {
+			fmt.Fprintf(&filter, "(%s=%s)", attr, ldap.EscapeFilter(value))
+		}
+		filter.WriteString(")")
+		values = values[n:]
+
+		found, err := c.Search(filter.String(), attrs, 0)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, found...)
+	}
+	return entries, nil
+}
+
+// entryAttribute returns the named attribute of the entry, ignoring case, or nil.
+func entryAttribute(entry *ldap.Entry, name string) *ldap.EntryAttribute {
+	for _, attr := range entry.Attributes {
+		if strings.EqualFold(attr.Name, name) {
+			return attr
+		}
+	}
+	return nil
+}
+
+// normalizeGroupDN returns the form of dn used to compare DNs.
+func normalizeGroupDN(dn string) string {
+	if parsed, err := ldap.ParseDN(dn); err == nil {
+		return strings.ToLower(parsed.String())
+	}
+	return strings.ToLower(dn)
+}
+
+// groupCN returns the value of the first RDN of dn, usually the cn of a group.
+func groupCN(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 || len(parsed.RDNs[0].Attributes) == 0 {
+		return dn
+	}
+	return parsed.RDNs[0].Attributes[0].Value
+}
+
+// getGroupsCacheKey returns the resolved groups cache key.
+func getGroupsCacheKey(dn string) string {
+	return fmt.Sprintf("ldapGroups:%s", normalizeGroupDN(dn))
+}
+
+// AuthenticateRoles checks if the given credentials are valid, or returns an error if one occurred.
+// username may be either the sAMAccountName or the userPrincipalName.
+// entry is the *ldap.Entry that holds the DN and any request attributes of the user.
+// roles are the application roles of the groups of the user, resolved by resolver.
+func AuthenticateRoles(config *Config, username, password string, attrs []string, resolver *GroupResolver) (status bool, entry *ldap.Entry, roles []string, err error) {
+	upn, err := config.UPN(username)
+	if err != nil {
+		return false, nil, nil, err
+	}
+
+	conn, err := config.Connect()
+	if err != nil {
+		return false, nil, nil, err
+	}
+	defer conn.Conn.Close()
+
+	//bind
+	status, err = conn.Bind(upn, password)
+	if err != nil {
+		return false, nil, nil, err
+	}
+	if !status {
+		return false, nil, nil, nil
+	}
+
+	//get entry
+	entry, err = conn.GetAttributes("userPrincipalName", upn, attrs)
+	if err != nil {
+		return false, nil, nil, err
+	}
+
+	roles, err = resolver.Roles(conn, entry.DN)
+	if err != nil {
+		return false, nil, nil, err
+	}
+
+	return status, entry, roles, nil
+}
@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ThomasNguyenGitHub/go/cache"
+	ldap "github.com/ThomasNguyenGitHub/go/ldap"
+	"github.com/ThomasNguyenGitHub/go/ldap/ldaptest"
+	"github.com/ThomasNguyenGitHub/go/log"
+)
+
+// testCache is an in-memory cache.Cacher implementing the methods used by GroupResolver.
+type testCache struct {
+	cache.Cacher
+	values  map[string]string
+	expires map[string]time.Duration
+}
+
+func newTestCache() *testCache {
+	return &testCache{values: map[string]string{}, expires: map[string]time.Duration{}}
+}
+
+func (c *testCache) PutMarshaled(key string, value interface{}) (interface{}, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	c.values[key] = string(b)
+	return "OK", nil
+}
+
+func (c *testCache) GetMarshaled(key string, v interface{}) error {
+	value, ok := c.values[key]
+	if !ok {
+		return cache.ErrNil
+	}
+	return json.Unmarshal([]byte(value), v)
+}
+
+func (c *testCache) Expire(key string, seconds time.Duration) error {
+	if _, ok := c.values[key]; !ok {
+		return errors.New("key does not exist")
+	}
+	c.expires[key] = seconds
+	return nil
+}
+
+func (c *testCache) Delete(keys ...string) error {
+	for _, key := range keys {
+		delete(c.values, key)
+	}
+	return nil
+}
+
+func newGroupTestServer(t *testing.T, withSIDs bool) (*ldaptest.Server, *Config) {
+	t.Helper()
+	s := ldaptest.NewUnstartedServer()
+
+	// Active Directory has no uniqueMember
+	uniqueMember := "uniqueMember"
+	if withSIDs {
+		uniqueMember = "member"
+	}
+	entries := []struct {
+		dn    string
+		attrs map[string][]string
+	}{
+		{"dc=example,dc=com", map[string][]string{"objectClass": {"domain"}}},
+		{"cn=alice,dc=example,dc=com", map[string][]string{
+			"objectClass":       {"user"},
+			"userPrincipalName": {"alice@example.com"},
+			"userPassword":      {"Password1!"},
+		}},
+		{"cn=Developers,dc=example,dc=com", map[string][]string{"objectClass": {"group"}, "member": {"cn=alice,dc=example,dc=com"}}},
+		{"cn=Engineering,dc=example,dc=com", map[string][]string{"objectClass": {"group"}, "member": {"cn=Developers,dc=example,dc=com"}}},
+		{"cn=Staff,dc=example,dc=com", map[string][]string{"objectClass": {"groupOfUniqueNames"}, uniqueMember: {"cn=Engineering,dc=example,dc=com"}}},
+		// a membership cycle
+		{"cn=Everyone,dc=example,dc=com", map[string][]string{"objectClass": {"group"}, "member": {"cn=Staff,dc=example,dc=com", "cn=Everyone,dc=example,dc=com"}}},
+		{"cn=Admins,dc=example,dc=com", map[string][]string{"objectClass": {"group"}}},
+	}
+	for i, e := range entries {
+		if withSIDs && i > 0 {
+			sid, _ := ldap.ParseSID("S-1-5-21-1004336348-1177238915-682003330-" + string(rune('0'+i)))
+			e.attrs["objectSid"] = []string{string(sid.Bytes())}
+		}
+		if err := s.AddEntry(e.dn, e.attrs); err != nil {
+			s.Close()
+			t.Fatal("Error seeding server:", err)
+		}
+	}
+	s.Start()
+	t.Cleanup(s.Close)
+
+	return s, &Config{Server: s.Host(), Port: s.Port(), BaseDN: "dc=example,dc=com", Security: SecurityNone}
+}
+
+func TestGroupResolverGroups(t *testing.T) {
+	expected := []string{
+		"cn=Developers,dc=example,dc=com",
+		"cn=Engineering,dc=example,dc=com",
+		"cn=Everyone,dc=example,dc=com",
+		"cn=Staff,dc=example,dc=com",
+	}
+
+	for name, withSIDs := range map[string]bool{"tokenGroups": true, "member": false} {
+		_, config := newGroupTestServer(t, withSIDs)
+		conn, err := config.Connect()
+		if err != nil {
+			t.Fatal("Error connecting to server:", err)
+		}
+		defer conn.Conn.Close()
+
+		groups, err := (&GroupResolver{}).Groups(conn, "cn=alice,dc=example,dc=com")
+		if err != nil {
+			t.Fatalf("%s: Expected err to be nil but got: %v", name, err)
+		}
+		sort.Strings(groups)
+		if !reflect.DeepEqual(groups, expected) {
+			t.Errorf("%s: Expected groups %v but got %v", name, expected, groups)
+		}
+
+		limited, err := (&GroupResolver{MaxDepth: 2}).Groups(conn, "cn=alice,dc=example,dc=com")
+		if err != nil {
+			t.Fatalf("%s: Expected err to be nil but got: %v", name, err)
+		}
+		if !withSIDs && len(limited) != 2 {
+			t.Errorf("%s: Expected 2 groups with MaxDepth 2 but got %v", name, limited)
+		}
+	}
+}
+
+func TestGroupResolverCache(t *testing.T) {
+	s, config := newGroupTestServer(t, false)
+	conn, err := config.Connect()
+	if err != nil {
+		t.Fatal("Error connecting to server:", err)
+	}
+	defer conn.Conn.Close()
+
+	c := newTestCache()
+	resolver := NewGroupResolver(c, time.Minute, nil)
+	alice := "cn=alice,dc=example,dc=com"
+
+	groups, err := resolver.Groups(conn, alice)
+	if err != nil {
+		t.Fatal("Expected err to be nil but got:", err)
+	}
+	if expires := c.expires[getGroupsCacheKey(alice)]; expires != 60 {
+		t.Errorf("Expected the groups to be cached for 60 seconds but got %d", expires)
+	}
+
+	// cached groups do not see directory changes until invalidated
+	if err := s.AddEntry("cn=New,dc=example,dc=com", map[string][]string{"member": {alice}}); err != nil {
+		t.Fatal(err)
+	}
+	if cached, err := resolver.Groups(conn, alice); err != nil || !reflect.DeepEqual(cached, groups) {
+		t.Errorf("Expected cached groups %v but got %v, %v", groups, cached, err)
+	}
+	if err := resolver.Invalidate(alice); err != nil {
+		t.Fatal(err)
+	}
+	if fresh, err := resolver.Groups(conn, alice); err != nil || len(fresh) != len(groups)+1 {
+		t.Errorf("Expected %d groups after invalidation but got %v, %v", len(groups)+1, fresh, err)
+	}
+}
+
+// failingCache is a testCache failing to cache or to expire the values.
+type failingCache struct {
+	*testCache
+	failPut bool
+}
+
+func (c *failingCache) PutMarshaled(key string, value interface{}) (interface{}, error) {
+	if c.failPut {
+		return nil, errors.New("connection refused")
+	}
+	return c.testCache.PutMarshaled(key, value)
+}
+
+func (c *failingCache) Expire(string, time.Duration) error {
+	return errors.New("connection refused")
+}
+
+func TestGroupResolverCacheErrors(t *testing.T) {
+	_, config := newGroupTestServer(t, false)
+	conn, err := config.Connect()
+	if err != nil {
+		t.Fatal("Error connecting to server:", err)
+	}
+	defer conn.Conn.Close()
+
+	logger, buf := log.New(), &bytes.Buffer{}
+	logger.SetOutput(buf)
+	logger.SetLevel(log.WarnLevel)
+	alice := "cn=alice,dc=example,dc=com"
+	for _, failPut := range []bool{true, false} {
+		c := &failingCache{testCache: newTestCache(), failPut: failPut}
+		resolver := NewGroupResolver(c, time.Minute, nil)
+		resolver.Logger = logger
+
+		buf.Reset()
+		groups, err := resolver.Groups(conn, alice)
+		if err != nil || len(groups) == 0 {
+			t.Errorf("Expected the groups despite the cache errors but got %v, %v", groups, err)
+		}
+		if !strings.Contains(buf.String(), "connection refused") {
+			t.Errorf("Expected the cache error to be logged but got %q", buf)
+		}
+		if _, ok := c.values[getGroupsCacheKey(alice)]; ok {
+			t.Error("Expected the groups without TTL not to be cached")
+		}
+	}
+}
+
+func TestAuthenticateRoles(t *testing.T) {
+	_, config := newGroupTestServer(t, true)
+	resolver := NewGroupResolver(newTestCache(), 0, map[string][]string{
+		"Developers":                       {"developer"},
+		"CN=Staff,DC=example,DC=com":       {"staff", "developer"},
+		"cn=Admins,dc=example,dc=com":      {ADMIN},
+		"cn=Engineering,dc=example,dc=com": {},
+	})
+
+	status, entry, roles, err := AuthenticateRoles(config, "alice", "Password1!", []string{"cn"}, resolver)
+	if err != nil || !status {
+		t.Fatalf("Expected true, nil but got %v, %v", status, err)
+	}
+	if entry.GetAttributeValue("cn") != "alice" {
+		t.Errorf("Unexpected entry %v", entry)
+	}
+	sort.Strings(roles)
+	if expected := []string{"developer", "staff"}; !reflect.DeepEqual(roles, expected) {
+		t.Errorf("Expected roles %v but got %v", expected, roles)
+	}
+
+	if status, _, roles, err := AuthenticateRoles(config, "alice", "invalid_password", nil, resolver); status || roles != nil || err != nil {
+		t.Errorf("Invalid credentials: Expected false, nil, nil but got %v, %v, %v", status, roles, err)
+	}
+}
@@ -76,3 +76,14 @@ func (c *Conn) GetDN(attr, value string) (string, error) {
 func (c *Conn) GetAttributes(attr, value string, attrs []string) (*ldap.Entry, error) {
 	return c.SearchOne(fmt.Sprintf("(%s=%s)", ldap.EscapeFilter(attr), ldap.EscapeFilter(value)), attrs)
 }
+
+// GetObject searches the object with the given attribute value and unmarshals it into v,
+// requesting the attributes named by the `ldap` tags of v. See ldap.Entry.Unmarshal.
+// attr and value are sanitized.
+func (c *Conn) GetObject(attr, value string, v interface{}) error {
+	entry, err := c.GetAttributes(attr, value, ldap.StructAttributes(v))
+	if err != nil {
+		return err
+	}
+	return entry.Unmarshal(v)
+}
@@ -0,0 +1,70 @@
+// Package correlation propagates the request IDs correlating the logs of a
+// request across services.
+//
+// The servers and subscribers of the transport packages read the request ID
+// of the incoming requests, generate one when it is missing, store it in the
+// context and echo it in their responses. Their clients and publishers
+// forward the ID of the context. The ID is added to the entries logged with
+// log.WithContext(ctx) as log.FieldKeyRequestID.
+package correlation
+
+import (
+	"context"
+
+	"github.com/ThomasNguyenGitHub/go/friendlyid"
+	"github.com/ThomasNguyenGitHub/go/log"
+)
+
+const (
+	// HeaderRequestID is the HTTP header holding the request ID.
+	HeaderRequestID = "X-Request-Id"
+	// MetadataKeyRequestID is the gRPC metadata, AMQP header and NATS
+	// header holding the request ID.
+	MetadataKeyRequestID = "x-request-id"
+)
+
+// NewID generates the IDs of the requests without one, friendlyid.New by
+// default.
+var NewID = func() string {
+	id, _ := friendlyid.New()
+	return id
+}
+
+type contextKey struct{}
+
+func init() {
+	log.RegisterContextExtractor(Fields)
+}
+
+// ContextWithID returns a copy of ctx carrying the request ID id.
+func ContextWithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// IDFromContext returns the request ID carried by ctx, or an empty string.
+func IDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Ensure returns a copy of ctx carrying the request ID id, the incoming ID
+// of a request. When id is empty, the ID already carried by ctx is kept, or
+// a new ID is generated with NewID.
+func Ensure(ctx context.Context, id string) (context.Context, string) {
+	if id == "" {
+		if id = IDFromContext(ctx); id != "" {
+			return ctx, id
+		}
+		id = NewID()
+	}
+	return ContextWithID(ctx, id), id
+}
+
+// Fields returns the request ID carried by ctx as log fields. It is
+// registered as a log context extractor.
+func Fields(ctx context.Context) log.Fields {
+	if id := IDFromContext(ctx); id != "" {
+		return log.Fields{log.FieldKeyRequestID: id}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package correlation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ThomasNguyenGitHub/go/log"
+)
+
+func TestEnsure(t *testing.T) {
+	ctx, id := Ensure(context.Background(), "")
+	if len(id) != 21 || IDFromContext(ctx) != id {
+		t.Fatalf("Expected a generated ID but got %q", id)
+	}
+	if ctx2, id2 := Ensure(ctx, ""); id2 != id || ctx2 != ctx {
+		t.Errorf("Expected the ID of the context to be kept but got %q", id2)
+	}
+	if ctx, id := Ensure(ctx, "incoming"); id != "incoming" || IDFromContext(ctx) != "incoming" {
+		t.Errorf("Expected the incoming ID but got %q", id)
+	}
+}
+
+func TestLogFields(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := log.New()
+	logger.Out = buf
+	logger.Level = log.InfoLevel
+	logger.Formatter = &log.JSONFormatter{DisableTimestamp: true}
+
+	logger.WithContext(ContextWithID(context.Background(), "req-1")).Info("paid")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry[log.FieldKeyRequestID] != "req-1" {
+		t.Errorf("Expected the request ID but got %v", entry)
+	}
+}
@@ -0,0 +1,94 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+)
+
+// Typed is an endpoint with a typed request and response. It is converted
+// to an Endpoint with its Endpoint method, and an Endpoint is converted to a
+// Typed with TypedEndpoint, so that the Middlewares apply to both.
+type Typed[Req, Resp any] func(ctx context.Context, request Req) (response Resp, err error)
+
+// TypedMiddleware is a chainable behavior modifier for typed endpoints.
+type TypedMiddleware[Req, Resp any] func(Typed[Req, Resp]) Typed[Req, Resp]
+
+// TypeError is returned by the adapters between Endpoint and Typed when a
+// request or a response is not of the expected type, instead of panicking.
+type TypeError struct {
+	// Kind is either "request" or "response"
+	Kind     string
+	Expected string
+	Got      interface{}
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("endpoint: expected %s of type %s but got %T", e.Kind, e.Expected, e.Got)
+}
+
+// Endpoint returns e as an Endpoint. The requests that are not of type Req
+// fail with a *TypeError.
+func (e Typed[Req, Resp]) Endpoint() Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(Req)
+		if !ok && request != nil {
+			return nil, &TypeError{Kind: "request", Expected: typeName[Req](), Got: request}
+		}
+		// a nil request is the zero Req
+		return e(ctx, req)
+	}
+}
+
+// With returns e wrapped by the middlewares, the first being the outermost
+// as with Chain.
+func (e Typed[Req, Resp]) With(middlewares ...Middleware) Typed[Req, Resp] {
+	if len(middlewares) == 0 {
+		return e
+	}
+	return TypedEndpoint[Req, Resp](Chain(middlewares[0], middlewares[1:]...)(e.Endpoint()))
+}
+
+// TypedEndpoint returns e as a Typed. The responses that are not of type
+// Resp fail with a *TypeError.
+func TypedEndpoint[Req, Resp any](e Endpoint) Typed[Req, Resp] {
+	return func(ctx context.Context, request Req) (Resp, error) {
+		var resp Resp
+		response, err := e(ctx, request)
+		if err != nil {
+			if r, ok := response.(Resp); ok {
+				// such as the responses implementing Failer
+				resp = r
+			}
+			return resp, err
+		}
+		if response == nil {
+			return resp, nil
+		}
+		resp, ok := response.(Resp)
+		if !ok {
+			return resp, &TypeError{Kind: "response", Expected: typeName[Resp](), Got: response}
+		}
+		return resp, nil
+	}
+}
+
+// Adapt returns m as a TypedMiddleware.
+func Adapt[Req, Resp any](m Middleware) TypedMiddleware[Req, Resp] {
+	return func(next Typed[Req, Resp]) Typed[Req, Resp] {
+		return next.With(m)
+	}
+}
+
+// TypedChain is like Chain for typed middlewares.
+func TypedChain[Req, Resp any](outer TypedMiddleware[Req, Resp], others ...TypedMiddleware[Req, Resp]) TypedMiddleware[Req, Resp] {
+	return func(next Typed[Req, Resp]) Typed[Req, Resp] {
+		for i := len(others) - 1; i >= 0; i-- { // reverse
+			next = others[i](next)
+		}
+		return outer(next)
+	}
+}
+
+func typeName[T any]() string {
+	return fmt.Sprintf("%T", (*T)(nil))[1:]
+}
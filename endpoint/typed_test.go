@@ -0,0 +1,84 @@
+package endpoint_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ThomasNguyenGitHub/go/endpoint"
+)
+
+type greetRequest struct{ Name string }
+
+type greetResponse struct{ Greeting string }
+
+func greet(_ context.Context, req greetRequest) (*greetResponse, error) {
+	if req.Name == "" {
+		return nil, errors.New("no name")
+	}
+	return &greetResponse{"hello " + req.Name}, nil
+}
+
+func TestTypedEndpoint(t *testing.T) {
+	e := endpoint.Typed[greetRequest, *greetResponse](greet).Endpoint()
+
+	response, err := e(context.Background(), greetRequest{"alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := response.(*greetResponse); resp.Greeting != "hello alice" {
+		t.Errorf("Unexpected greeting %q", resp.Greeting)
+	}
+
+	_, err = e(context.Background(), "alice")
+	var typeErr *endpoint.TypeError
+	if !errors.As(err, &typeErr) || typeErr.Kind != "request" {
+		t.Fatalf("Expected a request TypeError but got %v", err)
+	}
+	if !strings.Contains(err.Error(), "endpoint_test.greetRequest") || !strings.Contains(err.Error(), "string") {
+		t.Errorf("Unexpected error %q", err)
+	}
+
+	if _, err := e(context.Background(), nil); err == nil || err.Error() != "no name" {
+		t.Errorf("Expected the zero request to be passed but got %v", err)
+	}
+
+	typed := endpoint.TypedEndpoint[greetRequest, *greetResponse](func(context.Context, interface{}) (interface{}, error) {
+		return "hello", nil
+	})
+	if _, err := typed(context.Background(), greetRequest{}); !errors.As(err, &typeErr) || typeErr.Kind != "response" {
+		t.Errorf("Expected a response TypeError but got %v", err)
+	}
+}
+
+func TestTypedMiddleware(t *testing.T) {
+	var calls []string
+	annotate := func(s string) endpoint.Middleware {
+		return func(next endpoint.Endpoint) endpoint.Endpoint {
+			return func(ctx context.Context, request interface{}) (interface{}, error) {
+				calls = append(calls, s)
+				return next(ctx, request)
+			}
+		}
+	}
+
+	e := endpoint.Typed[greetRequest, *greetResponse](greet).With(annotate("first"), annotate("second"))
+	e = endpoint.TypedChain(
+		endpoint.Adapt[greetRequest, *greetResponse](annotate("outer")),
+		func(next endpoint.Typed[greetRequest, *greetResponse]) endpoint.Typed[greetRequest, *greetResponse] {
+			return func(ctx context.Context, req greetRequest) (*greetResponse, error) {
+				calls = append(calls, "typed")
+				return next(ctx, req)
+			}
+		},
+	)(e)
+
+	resp, err := e(context.Background(), greetRequest{"bob"})
+	if err != nil || resp.Greeting != "hello bob" {
+		t.Fatalf("Unexpected response %v, %v", resp, err)
+	}
+	if got := strings.Join(calls, ","); got != "outer,typed,first,second" {
+		t.Errorf("Unexpected middleware order %s", got)
+	}
+}
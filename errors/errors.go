@@ -14,13 +14,31 @@ type Error struct {
 	Code   int32  `json:"code"`
 	Detail string `json:"detail"`
 	Status string `json:"status"`
+	// Metadata holds additional information on the error
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Cause is the error wrapped by the error, see Wrap. It is encoded as
+	// an Error by Error and MarshalJSON when ExposeCauses is set, so that
+	// the cause chain survives the JSON round trip.
+	Cause error `json:"-"`
 }
 
+// Error returns the JSON form of the error, with its cause chain when
+// ExposeCauses is set, as the error may reach the clients through a plain
+// text body. The causes are walked with Chain to log them.
 func (e *Error) Error() string {
-	b, _ := json.Marshal(e)
+	b, _ := json.Marshal(e.toJSON(ExposeCauses))
 	return string(b)
 }
 
+// StatusCode returns the HTTP status code of the error, its Code when it is
+// an error status code, 500 otherwise.
+func (e *Error) StatusCode() int {
+	if e.Code < 400 || e.Code > 599 {
+		return http.StatusInternalServerError
+	}
+	return int(e.Code)
+}
+
 // New generates a custom error.
 func New(id, detail string, code int32) error {
 	return &Error{
@@ -116,8 +134,8 @@ func Conflict(a ...interface{}) error {
 func InternalServerError(a ...interface{}) error {
 	return &Error{
 		Id:     "500",
-		Code:   502,
+		Code:   500,
 		Detail: fmt.Sprintf("%s", a...),
-		Status: http.StatusText(502),
+		Status: http.StatusText(500),
 	}
 }
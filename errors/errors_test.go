@@ -45,3 +45,10 @@ func TestErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestInternalServerError(t *testing.T) {
+	e := InternalServerError("boom").(*Error)
+	if e.Code != http.StatusInternalServerError || e.StatusCode() != http.StatusInternalServerError || e.Status != http.StatusText(http.StatusInternalServerError) {
+		t.Errorf("Expected a 500 error but got %v", e)
+	}
+}
@@ -0,0 +1,154 @@
+package errors
+
+import (
+	"net/http"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// metadataHTTPStatus is the ErrorInfo metadata holding the Code of the
+// errors, the gRPC codes being coarser than the HTTP status codes.
+const metadataHTTPStatus = "http_status"
+
+// grpcCodes maps the HTTP status codes to the gRPC codes.
+var grpcCodes = map[int]codes.Code{
+	http.StatusOK:                  codes.OK,
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusRequestTimeout:      codes.DeadlineExceeded,
+	http.StatusConflict:            codes.AlreadyExists,
+	http.StatusPreconditionFailed:  codes.FailedPrecondition,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	499:                            codes.Canceled,
+	http.StatusInternalServerError: codes.Internal,
+	http.StatusNotImplemented:      codes.Unimplemented,
+	http.StatusBadGateway:          codes.Unavailable,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+	http.StatusGatewayTimeout:      codes.DeadlineExceeded,
+}
+
+// httpStatuses maps the gRPC codes to the HTTP status codes, as documented
+// by google.rpc.Code.
+var httpStatuses = map[codes.Code]int{
+	codes.OK:                 http.StatusOK,
+	codes.Canceled:           499,
+	codes.Unknown:            http.StatusInternalServerError,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Internal:           http.StatusInternalServerError,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DataLoss:           http.StatusInternalServerError,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+}
+
+// GRPCCode returns the gRPC code of an HTTP status code.
+func GRPCCode(httpStatus int) codes.Code {
+	if c, ok := grpcCodes[httpStatus]; ok {
+		return c
+	}
+	switch {
+	case httpStatus >= 200 && httpStatus < 300:
+		return codes.OK
+	case httpStatus >= 400 && httpStatus < 500:
+		return codes.FailedPrecondition
+	}
+	return codes.Unknown
+}
+
+// HTTPStatus returns the HTTP status code of a gRPC code.
+func HTTPStatus(c codes.Code) int {
+	if s, ok := httpStatuses[c]; ok {
+		return s
+	}
+	return http.StatusInternalServerError
+}
+
+// GRPCStatus returns the gRPC status of the error, so that it is returned
+// as is by the gRPC servers. The Id and the Metadata of the error are sent
+// as an ErrorInfo detail, and its cause as a DebugInfo detail when
+// ExposeCauses is set.
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(GRPCCode(int(e.Code)), e.Detail)
+	metadata := map[string]string{metadataHTTPStatus: strconv.Itoa(int(e.Code))}
+	for k, v := range e.Metadata {
+		metadata[k] = v
+	}
+	info := &errdetails.ErrorInfo{Reason: e.Id, Metadata: metadata}
+	var (
+		detailed *status.Status
+		err      error
+	)
+	if e.Cause != nil && ExposeCauses {
+		detailed, err = st.WithDetails(info, &errdetails.DebugInfo{Detail: e.Cause.Error()})
+	} else {
+		detailed, err = st.WithDetails(info)
+	}
+	if err != nil {
+		return st
+	}
+	return detailed
+}
+
+// FromGRPCStatus returns the *Error of a gRPC status, as sent by
+// Error.GRPCStatus.
+func FromGRPCStatus(st *status.Status) *Error {
+	code := HTTPStatus(st.Code())
+	e := &Error{
+		Id:     strconv.Itoa(code),
+		Code:   int32(code),
+		Detail: st.Message(),
+		Status: http.StatusText(code),
+	}
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			if d.Reason != "" {
+				e.Id = d.Reason
+			}
+			for k, v := range d.Metadata {
+				if k != metadataHTTPStatus {
+					e.Metadata = setMetadata(e.Metadata, k, v)
+				} else if code, err := strconv.Atoi(v); err == nil {
+					e.Code, e.Status = int32(code), http.StatusText(code)
+				}
+			}
+		case *errdetails.DebugInfo:
+			e.Cause = &Error{Detail: d.Detail}
+		}
+	}
+	return e
+}
+
+// FromGRPCError returns the *Error of an error returned by a gRPC client, or
+// err itself when it is not a gRPC status error.
+func FromGRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	return FromGRPCStatus(st)
+}
+
+func setMetadata(metadata map[string]string, k, v string) map[string]string {
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	metadata[k] = v
+	return metadata
+}
@@ -0,0 +1,61 @@
+package errors
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCStatus(t *testing.T) {
+	err := Wrap(errors.New("timeout"), "insufficient_funds", "balance 10 < 100", 422).(*Error).WithMetadata("account", "123")
+
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.FailedPrecondition || st.Message() != "balance 10 < 100" {
+		t.Fatalf("Unexpected status %v", st)
+	}
+
+	e := FromGRPCError(st.Err()).(*Error)
+	if e.Id != "insufficient_funds" || e.Code != 422 || e.Status != "Unprocessable Entity" || e.Detail != "balance 10 < 100" {
+		t.Errorf("Unexpected error %+v", e)
+	}
+	if len(e.Metadata) != 1 || e.Metadata["account"] != "123" {
+		t.Errorf("Unexpected metadata %v", e.Metadata)
+	}
+	if e.Cause != nil {
+		t.Errorf("Expected the cause to be kept server side but got %v", e.Cause)
+	}
+
+	exposeCauses(t)
+	e = FromGRPCError(err.GRPCStatus().Err()).(*Error)
+	if e.Cause == nil || e.Cause.Error() == "" || e.Cause.(*Error).Detail != "timeout" {
+		t.Errorf("Expected the cause but got %v", e.Cause)
+	}
+
+	e = FromGRPCError(status.Error(codes.NotFound, "no account")).(*Error)
+	if e.Id != "404" || e.Code != 404 || e.Detail != "no account" {
+		t.Errorf("Unexpected error %+v", e)
+	}
+	if err := errors.New("plain"); FromGRPCError(err) != err {
+		t.Error("Expected the errors without status to be returned as is")
+	}
+}
+
+func TestCodes(t *testing.T) {
+	for httpStatus, code := range map[int]codes.Code{
+		400: codes.InvalidArgument,
+		401: codes.Unauthenticated,
+		422: codes.FailedPrecondition,
+		503: codes.Unavailable,
+		201: codes.OK,
+		599: codes.Unknown,
+	} {
+		if c := GRPCCode(httpStatus); c != code {
+			t.Errorf("Expected %s for %d but got %s", code, httpStatus, c)
+		}
+	}
+	if s := HTTPStatus(codes.DeadlineExceeded); s != 504 {
+		t.Errorf("Expected 504 but got %d", s)
+	}
+}
@@ -0,0 +1,150 @@
+package errors
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+)
+
+// Wrap generates a custom error caused by err.
+func Wrap(err error, id, detail string, code int32) error {
+	return &Error{
+		Id:     id,
+		Code:   code,
+		Detail: detail,
+		Status: http.StatusText(int(code)),
+		Cause:  err,
+	}
+}
+
+// Unwrap returns the cause of the error, so that the errors package of the
+// standard library walks the cause chain.
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// Is reports whether the error matches target, an *Error with the same Id
+// and, when the target has a Code, the same Code. It allows checking an
+// error against a sentinel with errors.Is whatever its Detail.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	return e.Id == t.Id && (t.Code == 0 || e.Code == t.Code)
+}
+
+// WithMetadata returns a copy of the error with the metadata key set to
+// value.
+func (e *Error) WithMetadata(key, value string) *Error {
+	c := *e
+	c.Metadata = make(map[string]string, len(e.Metadata)+1)
+	for k, v := range e.Metadata {
+		c.Metadata[k] = v
+	}
+	c.Metadata[key] = value
+	return &c
+}
+
+// ExposeCauses encodes the cause chain of the errors in their message and
+// their JSON form, in the problem details of transport/http and in the DebugInfo of their
+// gRPC status. The causes may reveal internal details, such as queries or
+// addresses, so they are kept server side by default: set it only when the
+// callers are trusted, such as the services of a same system.
+var ExposeCauses = false
+
+// errorJSON is the JSON form of an Error.
+type errorJSON struct {
+	Id       string            `json:"id"`
+	Code     int32             `json:"code"`
+	Detail   string            `json:"detail"`
+	Status   string            `json:"status"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Cause    *errorJSON        `json:"cause,omitempty"`
+}
+
+// MarshalJSON encodes the error, with its cause chain when ExposeCauses is
+// set.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.toJSON(ExposeCauses))
+}
+
+// UnmarshalJSON decodes the error and its cause chain.
+func (e *Error) UnmarshalJSON(b []byte) error {
+	var v errorJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*e = *v.toError()
+	return nil
+}
+
+// toJSON returns the JSON form of the error, with its cause chain when
+// causes is set.
+func (e *Error) toJSON(causes bool) *errorJSON {
+	v := &errorJSON{Id: e.Id, Code: e.Code, Detail: e.Detail, Status: e.Status, Metadata: e.Metadata}
+	if causes {
+		v.Cause = causeJSON(e.Cause)
+	}
+	return v
+}
+
+// causeJSON returns the JSON form of the cause chain of err, the message of
+// the errors that are not an *Error being their Detail.
+func causeJSON(err error) *errorJSON {
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(*Error); ok {
+		return e.toJSON(true)
+	}
+	return &errorJSON{Detail: err.Error(), Cause: causeJSON(stderrors.Unwrap(err))}
+}
+
+func (v *errorJSON) toError() *Error {
+	e := &Error{Id: v.Id, Code: v.Code, Detail: v.Detail, Status: v.Status, Metadata: v.Metadata}
+	if v.Cause != nil {
+		e.Cause = v.Cause.toError()
+	}
+	return e
+}
+
+// Cause returns the deepest cause of err, err itself when it has no cause.
+func Cause(err error) error {
+	for err != nil {
+		cause := stderrors.Unwrap(err)
+		if cause == nil {
+			return err
+		}
+		err = cause
+	}
+	return nil
+}
+
+// Chain returns err and its causes, outermost first.
+func Chain(err error) []error {
+	var chain []error
+	for ; err != nil; err = stderrors.Unwrap(err) {
+		chain = append(chain, err)
+	}
+	return chain
+}
+
+// FromError returns err as an *Error: the first *Error of its chain, or an
+// internal server error caused by err.
+func FromError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if stderrors.As(err, &e) {
+		return e
+	}
+	return &Error{
+		Id:     "500",
+		Code:   http.StatusInternalServerError,
+		Detail: err.Error(),
+		Status: http.StatusText(http.StatusInternalServerError),
+		Cause:  err,
+	}
+}
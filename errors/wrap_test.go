@@ -0,0 +1,88 @@
+package errors
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+var errInsufficientFunds = New("insufficient_funds", "", 422)
+
+func TestWrap(t *testing.T) {
+	err := Wrap(io.ErrUnexpectedEOF, "core.read", "unable to read the balance", 502)
+	err = fmt.Errorf("payment: %w", Wrap(err, "insufficient_funds", "balance 10 < 100", 422))
+
+	if !stderrors.Is(err, errInsufficientFunds) {
+		t.Error("Expected the error to match the sentinel")
+	}
+	if !stderrors.Is(err, &Error{Id: "core.read"}) {
+		t.Error("Expected the cause to match without code")
+	}
+	if stderrors.Is(err, New("insufficient_funds", "", 400)) {
+		t.Error("Expected a sentinel with another code not to match")
+	}
+	if !stderrors.Is(err, io.ErrUnexpectedEOF) {
+		t.Error("Expected the root cause to match")
+	}
+
+	var e *Error
+	if !stderrors.As(err, &e) || e.Detail != "balance 10 < 100" {
+		t.Errorf("Unexpected error %v", e)
+	}
+	if cause := Cause(err); cause != io.ErrUnexpectedEOF {
+		t.Errorf("Expected the root cause but got %v", cause)
+	}
+	if chain := Chain(err); len(chain) != 4 {
+		t.Errorf("Expected a chain of 4 errors but got %v", chain)
+	}
+	if FromError(err) != e {
+		t.Error("Expected FromError to return the first Error of the chain")
+	}
+	if e := FromError(io.EOF); e.Code != 500 || e.Cause != io.EOF {
+		t.Errorf("Unexpected error %v", e)
+	}
+}
+
+func TestErrorJSON(t *testing.T) {
+	err := Wrap(fmt.Errorf("query: %w", io.ErrUnexpectedEOF), "core.read", "unable to read", 502).(*Error)
+	err = err.WithMetadata("account", "123")
+
+	pe := Parse(err.Error())
+	if pe.Id != "core.read" || pe.Code != 502 || pe.Metadata["account"] != "123" || pe.Cause != nil {
+		t.Errorf("Unexpected error %+v", pe)
+	}
+	if b, _ := json.Marshal(err); strings.Contains(string(b), "cause") {
+		t.Errorf("Expected the cause to be kept server side but got %s", b)
+	}
+	if s := fmt.Errorf("payment: %w", err).Error(); strings.Contains(s, "EOF") {
+		t.Errorf("Expected the message of the wrapped error to keep the cause server side but got %s", s)
+	}
+
+	exposeCauses(t)
+	chain := Chain(Parse(err.Error()))
+	if len(chain) != 3 || chain[1].(*Error).Detail != "query: unexpected EOF" || chain[2].(*Error).Detail != "unexpected EOF" {
+		t.Errorf("Expected the cause chain to survive the round trip but got %v", chain)
+	}
+	var je Error
+	if b, _ := json.Marshal(err); json.Unmarshal(b, &je) != nil || len(Chain(&je)) != 3 {
+		t.Errorf("Expected the exposed cause chain in %s", b)
+	}
+
+	b, _ := json.Marshal(New("test", "detail", 400))
+	if string(b) != `{"id":"test","code":400,"detail":"detail","status":"Bad Request"}` {
+		t.Errorf("Unexpected JSON %s", b)
+	}
+	e := New("test", "", 400).(*Error)
+	if e.WithMetadata("k", "v"); e.Metadata != nil {
+		t.Error("Expected WithMetadata to return a copy")
+	}
+}
+
+// exposeCauses sets ExposeCauses until the end of the test.
+func exposeCauses(t *testing.T) {
+	ExposeCauses = true
+	t.Cleanup(func() { ExposeCauses = false })
+}
@@ -0,0 +1,362 @@
+// Package idempotency de-duplicates the requests retried by clients or
+// redelivered by brokers, replaying the response of the first request to
+// the requests with the same idempotency key.
+//
+// The key of a request is read from the context, where it is stored by
+// transport/http.PopulateIdempotencyKey from the Idempotency-Key header, or
+// from a field of the decoded request, see KeyField. The first request with
+// a key locks it in the cache, and its response is stored with a TTL. The
+// requests with the same key get ErrInProgress while it is locked, the
+// stored response once it is stored, and ErrKeyReused when their request
+// differs from the first one.
+//
+// The keys are scoped by the caller, so that the keys chosen by different
+// users do not collide and a user cannot replay the response of another.
+// The scope is read from the context, where
+// transport/http.PopulateIdempotencyKey stores the user ID header, or from
+// the request, see ScopeFunc.
+package idempotency
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ThomasNguyenGitHub/go/cache"
+	"github.com/ThomasNguyenGitHub/go/endpoint"
+	"github.com/ThomasNguyenGitHub/go/errors"
+)
+
+// Defaults of the Idempotency options
+const (
+	DefaultPrefix      = "idempotency:"
+	DefaultTTL         = 24 * time.Hour
+	DefaultLockTimeout = 30 * time.Second
+)
+
+// Errors of the duplicate requests
+var (
+	// ErrInProgress is returned while the first request with the key is
+	// being processed.
+	ErrInProgress = &errors.Error{
+		Id:     "idempotency_in_progress",
+		Code:   http.StatusConflict,
+		Detail: "a request with the same idempotency key is being processed",
+		Status: http.StatusText(http.StatusConflict),
+	}
+	// ErrKeyReused is returned when the key was used with another request.
+	ErrKeyReused = &errors.Error{
+		Id:     "idempotency_key_reused",
+		Code:   http.StatusUnprocessableEntity,
+		Detail: "the idempotency key was used with another request",
+		Status: http.StatusText(http.StatusUnprocessableEntity),
+	}
+)
+
+type (
+	contextKey      struct{}
+	scopeContextKey struct{}
+)
+
+// ContextWithKey returns a copy of ctx carrying the idempotency key.
+func ContextWithKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// KeyFromContext returns the idempotency key carried by ctx.
+func KeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(contextKey{}).(string)
+	return key
+}
+
+// ContextWithScope returns a copy of ctx carrying the scope of the
+// idempotency key, such as the ID of the user.
+func ContextWithScope(ctx context.Context, scope string) context.Context {
+	return context.WithValue(ctx, scopeContextKey{}, scope)
+}
+
+// ScopeFromContext returns the scope of the idempotency key carried by ctx.
+func ScopeFromContext(ctx context.Context) string {
+	scope, _ := ctx.Value(scopeContextKey{}).(string)
+	return scope
+}
+
+// record is the stored outcome of the first request with a key.
+type record struct {
+	Fingerprint string          `json:"fingerprint"`
+	Response    json.RawMessage `json:"response,omitempty"`
+	Err         *errors.Error   `json:"error,omitempty"`
+}
+
+// Idempotency de-duplicates the requests of endpoints.
+type Idempotency struct {
+	cache       cache.Cacher
+	prefix      string
+	ttl         time.Duration
+	lockTimeout time.Duration
+	key         func(ctx context.Context, request interface{}) string
+	scope       func(ctx context.Context, request interface{}) string
+	fingerprint func(request interface{}) (string, error)
+	decode      func(response []byte) (interface{}, error)
+}
+
+// Option sets an optional parameter for Idempotency.
+type Option func(*Idempotency)
+
+// Prefix sets the prefix of the cache keys, DefaultPrefix by default. The
+// endpoints sharing a cache are given their own prefix, so that their keys
+// do not collide.
+func Prefix(prefix string) Option {
+	return func(i *Idempotency) { i.prefix = prefix }
+}
+
+// TTL sets how long the responses are stored, DefaultTTL by default.
+func TTL(ttl time.Duration) Option {
+	return func(i *Idempotency) { i.ttl = ttl }
+}
+
+// LockTimeout sets how long a key stays locked when its first request does
+// not complete, DefaultLockTimeout by default. It is longer than the
+// processing of the requests.
+func LockTimeout(timeout time.Duration) Option {
+	return func(i *Idempotency) { i.lockTimeout = timeout }
+}
+
+// KeyFunc sets the function returning the key of the requests whose context
+// carries none. By default, only the key of the context is used.
+func KeyFunc(key func(ctx context.Context, request interface{}) string) Option {
+	return func(i *Idempotency) { i.key = key }
+}
+
+// KeyField reads the key of the requests whose context carries none from
+// their field name: the key of a map, or the field of a struct with that
+// JSON name. It suits the messages of the amqp and nats transports.
+func KeyField(name string) Option {
+	return KeyFunc(func(_ context.Context, request interface{}) string {
+		return fieldValue(reflect.ValueOf(request), name)
+	})
+}
+
+// ScopeFunc sets the function returning the scope of the keys of the
+// requests whose context carries none, such as the ID of the user read from
+// a message. By default, only the scope of the context is used.
+func ScopeFunc(scope func(ctx context.Context, request interface{}) string) Option {
+	return func(i *Idempotency) { i.scope = scope }
+}
+
+// Fingerprint sets the function fingerprinting the requests, the SHA-256 of
+// their JSON encoding by default.
+func Fingerprint(fingerprint func(request interface{}) (string, error)) Option {
+	return func(i *Idempotency) { i.fingerprint = fingerprint }
+}
+
+// Decode sets the function decoding the replayed responses from their JSON
+// encoding. By default, they are replayed as json.RawMessage, which the
+// JSON response encoders write as is. See DecodeAs.
+func Decode(decode func(response []byte) (interface{}, error)) Option {
+	return func(i *Idempotency) { i.decode = decode }
+}
+
+// DecodeAs returns a Decode function replaying the responses as Resp.
+func DecodeAs[Resp any]() func(response []byte) (interface{}, error) {
+	return func(response []byte) (interface{}, error) {
+		var resp Resp
+		err := json.Unmarshal(response, &resp)
+		return resp, err
+	}
+}
+
+// New returns an Idempotency storing the responses in c.
+func New(c cache.Cacher, options ...Option) *Idempotency {
+	i := &Idempotency{
+		cache:       c,
+		prefix:      DefaultPrefix,
+		ttl:         DefaultTTL,
+		lockTimeout: DefaultLockTimeout,
+		fingerprint: jsonFingerprint,
+		decode: func(response []byte) (interface{}, error) {
+			return json.RawMessage(response), nil
+		},
+	}
+	for _, option := range options {
+		option(i)
+	}
+	return i
+}
+
+// Middleware returns an endpoint.Middleware de-duplicating the requests
+// with an idempotency key. The responses and the *errors.Error with a
+// status code below 500 are stored, so that they are replayed. The other
+// errors, and the responses of an endpoint.Failer which failed, are not
+// stored, so that the request can be retried.
+func (i *Idempotency) Middleware() endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			key := KeyFromContext(ctx)
+			if key == "" && i.key != nil {
+				key = i.key(ctx, request)
+			}
+			if key == "" {
+				return next(ctx, request)
+			}
+			scope := ScopeFromContext(ctx)
+			if scope == "" && i.scope != nil {
+				scope = i.scope(ctx, request)
+			}
+			key = scopedKey(scope, key)
+
+			fingerprint, err := i.fingerprint(request)
+			if err != nil {
+				return nil, fmt.Errorf("idempotency: fingerprinting the request: %w", err)
+			}
+			if r, err := i.stored(key); err != nil || r != nil {
+				return i.replay(r, fingerprint, err)
+			}
+
+			token := newToken()
+			locked, err := i.cache.Lock(i.lockKey(key), token, int(i.lockTimeout/time.Millisecond))
+			if err != nil {
+				return nil, fmt.Errorf("idempotency: locking the key: %w", err)
+			}
+			if !locked {
+				return nil, ErrInProgress
+			}
+			defer i.cache.Unlock(i.lockKey(key), token)
+
+			// the first request may have completed before the lock
+			if r, err := i.stored(key); err != nil || r != nil {
+				return i.replay(r, fingerprint, err)
+			}
+
+			response, err := next(ctx, request)
+			i.store(key, fingerprint, response, err)
+			return response, err
+		}
+	}
+}
+
+// stored returns the stored outcome of the request with key, or nil.
+func (i *Idempotency) stored(key string) (*record, error) {
+	var r record
+	if err := i.cache.GetMarshaled(i.prefix+key, &r); err != nil {
+		if stderrors.Is(err, cache.ErrNil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("idempotency: reading the response: %w", err)
+	}
+	if r.Fingerprint == "" {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+// replay returns the outcome of r to the request with fingerprint, or err
+// when reading it failed.
+func (i *Idempotency) replay(r *record, fingerprint string, err error) (interface{}, error) {
+	switch {
+	case err != nil:
+		return nil, err
+	case r.Fingerprint != fingerprint:
+		return nil, ErrKeyReused
+	case r.Err != nil:
+		return nil, r.Err
+	}
+	response, err := i.decode(r.Response)
+	if err != nil {
+		return nil, fmt.Errorf("idempotency: decoding the response: %w", err)
+	}
+	return response, nil
+}
+
+// store stores the outcome of the request with key, when it is final.
+func (i *Idempotency) store(key, fingerprint string, response interface{}, err error) {
+	r := record{Fingerprint: fingerprint}
+	if err != nil {
+		var e *errors.Error
+		if !stderrors.As(err, &e) || e.StatusCode() >= http.StatusInternalServerError {
+			return
+		}
+		r.Err = e
+	} else {
+		if f, ok := response.(endpoint.Failer); ok && f.Failed() != nil {
+			return
+		}
+		b, err := json.Marshal(response)
+		if err != nil {
+			return
+		}
+		r.Response = b
+	}
+	if _, err := i.cache.PutMarshaled(i.prefix+key, r); err != nil {
+		return
+	}
+	// Cacher.Expire takes a number of seconds. A record without TTL would
+	// be replayed forever, it is removed instead.
+	if err := i.cache.Expire(i.prefix+key, time.Duration(i.ttl/time.Second)); err != nil {
+		i.cache.Delete(i.prefix + key)
+	}
+}
+
+// scopedKey returns the key in scope. The scope is escaped, so that its
+// separator from the key is unambiguous.
+func scopedKey(scope, key string) string {
+	return url.QueryEscape(scope) + ":" + key
+}
+
+func (i *Idempotency) lockKey(key string) string {
+	return i.prefix + "lock:" + key
+}
+
+// jsonFingerprint returns the SHA-256 of the JSON encoding of request.
+func jsonFingerprint(request interface{}) (string, error) {
+	b, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// fieldValue returns the string of the field name of the map or struct v.
+func fieldValue(v reflect.Value, name string) string {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() == reflect.String {
+			if f := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key())); f.IsValid() {
+				return fmt.Sprint(f.Interface())
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for n := 0; n < t.NumField(); n++ {
+			sf := t.Field(n)
+			jsonName, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+			if sf.IsExported() && (jsonName == name || jsonName == "" && sf.Name == name) {
+				return fmt.Sprint(v.Field(n).Interface())
+			}
+		}
+	}
+	return ""
+}
+
+// newToken returns the random value of a lock.
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,245 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ThomasNguyenGitHub/go/cache"
+	"github.com/ThomasNguyenGitHub/go/errors"
+)
+
+// memoryCache implements the methods of cache.Cacher used by Idempotency.
+type memoryCache struct {
+	cache.Cacher
+	mu      sync.Mutex
+	values  map[string]string
+	expires map[string]time.Duration
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{values: map[string]string{}, expires: map[string]time.Duration{}}
+}
+
+func (c *memoryCache) PutMarshaled(key string, value interface{}) (interface{}, error) {
+	b, err := json.Marshal(value)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = string(b)
+	return "OK", err
+}
+
+func (c *memoryCache) GetMarshaled(key string, v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.values[key]
+	if !ok {
+		return cache.ErrNil
+	}
+	return json.Unmarshal([]byte(s), v)
+}
+
+func (c *memoryCache) Expire(key string, seconds time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expires[key] = seconds
+	return nil
+}
+
+func (c *memoryCache) Delete(keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		delete(c.values, key)
+	}
+	return nil
+}
+
+func (c *memoryCache) Lock(key, value string, _ int) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.values[key]; ok {
+		return false, nil
+	}
+	c.values[key] = value
+	return true, nil
+}
+
+func (c *memoryCache) Unlock(key, value string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.values[key] != value {
+		return cache.ErrCantUnlock
+	}
+	delete(c.values, key)
+	return nil
+}
+
+type transfer struct {
+	Key    string `json:"idempotency_key"`
+	Amount int    `json:"amount"`
+}
+
+type receipt struct {
+	ID string `json:"id"`
+}
+
+func TestMiddleware(t *testing.T) {
+	var calls int32
+	c := newMemoryCache()
+	e := New(c, TTL(time.Hour), Decode(DecodeAs[receipt]())).Middleware()(func(_ context.Context, request interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return receipt{ID: "r1"}, nil
+	})
+
+	ctx := ContextWithKey(context.Background(), "k1")
+	for n := 0; n < 2; n++ {
+		response, err := e(ctx, transfer{Amount: 100})
+		if err != nil || response != (receipt{ID: "r1"}) {
+			t.Fatalf("Unexpected response %v %v", response, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected the retry to be replayed but the endpoint was called %d times", calls)
+	}
+	if c.expires[DefaultPrefix+":k1"] != 3600 {
+		t.Errorf("Expected a TTL of 3600 seconds but got %v", c.expires)
+	}
+
+	if _, err := e(ctx, transfer{Amount: 200}); !stderrors.Is(err, ErrKeyReused) {
+		t.Errorf("Expected ErrKeyReused but got %v", err)
+	}
+	if _, err := e(context.Background(), transfer{Amount: 100}); err != nil || calls != 2 {
+		t.Errorf("Expected the requests without key to be served but got %v", err)
+	}
+}
+
+func TestMiddlewareInProgress(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	e := New(newMemoryCache(), KeyField("idempotency_key")).Middleware()(func(context.Context, interface{}) (interface{}, error) {
+		close(started)
+		<-release
+		return receipt{ID: "r1"}, nil
+	})
+
+	done := make(chan error)
+	go func() {
+		_, err := e(context.Background(), &transfer{Key: "k1", Amount: 100})
+		done <- err
+	}()
+	<-started
+	if _, err := e(context.Background(), &transfer{Key: "k1", Amount: 100}); !stderrors.Is(err, ErrInProgress) {
+		t.Errorf("Expected ErrInProgress but got %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := e(context.Background(), &transfer{Key: "k1", Amount: 100})
+	if err != nil || string(response.(json.RawMessage)) != `{"id":"r1"}` {
+		t.Errorf("Expected the stored response but got %v %v", response, err)
+	}
+}
+
+func TestMiddlewareErrors(t *testing.T) {
+	var calls int32
+	fail := errors.New("insufficient_funds", "balance 10 < 100", 422)
+	e := New(newMemoryCache()).Middleware()(func(context.Context, interface{}) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return nil, errors.InternalServerError("timeout")
+		}
+		return nil, fail
+	})
+
+	ctx := ContextWithKey(context.Background(), "k1")
+	for _, expected := range []string{"500", "insufficient_funds", "insufficient_funds"} {
+		_, err := e(ctx, transfer{Amount: 100})
+		if e, ok := err.(*errors.Error); !ok || e.Id != expected {
+			t.Errorf("Expected the error %s but got %v", expected, err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected the server error to be retried and the client error to be replayed but got %d calls", calls)
+	}
+}
+
+func TestMiddlewareScope(t *testing.T) {
+	var calls int32
+	e := New(newMemoryCache(), Decode(DecodeAs[receipt]()), ScopeFunc(func(_ context.Context, request interface{}) string {
+		return "service"
+	})).Middleware()(func(context.Context, interface{}) (interface{}, error) {
+		return receipt{ID: string(rune('0' + atomic.AddInt32(&calls, 1)))}, nil
+	})
+
+	alice := ContextWithScope(ContextWithKey(context.Background(), "k1"), "alice")
+	bob := ContextWithScope(ContextWithKey(context.Background(), "k1"), "bob")
+	for _, tc := range []struct {
+		ctx      context.Context
+		expected string
+	}{
+		{alice, "1"},
+		// the same key of another user is another request
+		{bob, "2"},
+		{alice, "1"},
+		// the scope of the context takes precedence over ScopeFunc
+		{ContextWithKey(context.Background(), "k1"), "3"},
+	} {
+		response, err := e(tc.ctx, transfer{Amount: 100})
+		if err != nil || response != (receipt{ID: tc.expected}) {
+			t.Errorf("Expected %s but got %v %v", tc.expected, response, err)
+		}
+	}
+}
+
+// expireFailingCache fails to set the TTL of the keys.
+type expireFailingCache struct {
+	*memoryCache
+}
+
+func (c expireFailingCache) Expire(string, time.Duration) error {
+	return stderrors.New("connection reset")
+}
+
+func TestMiddlewareExpireFailure(t *testing.T) {
+	var calls int32
+	c := expireFailingCache{newMemoryCache()}
+	e := New(c).Middleware()(func(context.Context, interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return receipt{ID: "r1"}, nil
+	})
+
+	ctx := ContextWithKey(context.Background(), "k1")
+	for n := 0; n < 2; n++ {
+		if _, err := e(ctx, transfer{Amount: 100}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the response is not kept without TTL
+	if calls != 2 || len(c.values) != 0 {
+		t.Errorf("Expected the record without TTL to be removed but got %d calls and %v", calls, c.values)
+	}
+}
+
+func TestKeyField(t *testing.T) {
+	for _, tc := range []struct {
+		request interface{}
+		field   string
+	}{
+		{&transfer{Key: "k1"}, "idempotency_key"},
+		{map[string]interface{}{"idempotency_key": "k1"}, "idempotency_key"},
+		{struct{ IdempotencyKey string }{"k1"}, "IdempotencyKey"},
+	} {
+		if v := fieldValue(reflect.ValueOf(tc.request), tc.field); v != "k1" {
+			t.Errorf("Expected the key of %v but got %q", tc.request, v)
+		}
+	}
+	if v := fieldValue(reflect.ValueOf((*transfer)(nil)), "idempotency_key"); v != "" {
+		t.Errorf("Expected no key but got %q", v)
+	}
+}
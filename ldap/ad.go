@@ -0,0 +1,221 @@
+package ldap
+
+import (
+	"encoding/binary"
+	enchex "encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GUID is an Active Directory objectGUID in its binary form
+type GUID [16]byte
+
+// ParseGUID parses the string form of a GUID, with or without braces
+func ParseGUID(s string) (GUID, error) {
+	var g GUID
+	b, err := enchex.DecodeString(strings.Replace(strings.Trim(s, "{}"), "-", "", -1))
+	if err != nil || len(b) != len(g) {
+		return g, fmt.Errorf("ldap: invalid GUID %q", s)
+	}
+	// the first three groups are stored little endian
+	g[0], g[1], g[2], g[3] = b[3], b[2], b[1], b[0]
+	g[4], g[5] = b[5], b[4]
+	g[6], g[7] = b[7], b[6]
+	copy(g[8:], b[8:])
+	return g, nil
+}
+
+// String returns the GUID in the form displayed by Active Directory tools
+func (g GUID) String() string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(g[0:4]),
+		binary.LittleEndian.Uint16(g[4:6]),
+		binary.LittleEndian.Uint16(g[6:8]),
+		g[8:10],
+		g[10:])
+}
+
+// FilterValue returns the GUID escaped for use in a search filter, e.g. "(objectGUID=" + g.FilterValue() + ")"
+func (g GUID) FilterValue() string {
+	return EscapeFilter(string(g[:]))
+}
+
+// SID is a Windows security identifier such as an objectSid
+type SID struct {
+	// Revision is the revision level, always 1
+	Revision byte
+	// Authority is the identifier authority
+	Authority uint64
+	// SubAuthorities are the sub authorities, the last one being the relative identifier
+	SubAuthorities []uint32
+}
+
+// DecodeSID decodes the binary form of a SID
+func DecodeSID(b []byte) (*SID, error) {
+	if len(b) < 8 || len(b) != 8+4*int(b[1]) {
+		return nil, fmt.Errorf("ldap: invalid SID of %d bytes", len(b))
+	}
+	sid := &SID{Revision: b[0]}
+	for _, c := range b[2:8] {
+		sid.Authority = sid.Authority<<8 | uint64(c)
+	}
+	for i := 8; i < len(b); i += 4 {
+		sid.SubAuthorities = append(sid.SubAuthorities, binary.LittleEndian.Uint32(b[i:]))
+	}
+	return sid, nil
+}
+
+// ParseSID parses the string form of a SID such as "S-1-5-21-1004336348-1177238915-682003330-512"
+func ParseSID(s string) (*SID, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) < 3 || !strings.EqualFold(parts[0], "S") {
+		return nil, fmt.Errorf("ldap: invalid SID %q", s)
+	}
+	revision, err := strconv.ParseUint(parts[1], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: invalid SID %q", s)
+	}
+	authority, err := strconv.ParseUint(parts[2], 10, 48)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: invalid SID %q", s)
+	}
+	sid := &SID{Revision: byte(revision), Authority: authority}
+	for _, part := range parts[3:] {
+		sub, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("ldap: invalid SID %q", s)
+		}
+		sid.SubAuthorities = append(sid.SubAuthorities, uint32(sub))
+	}
+	return sid, nil
+}
+
+// Bytes returns the binary form of the SID
+func (s *SID) Bytes() []byte {
+	b := make([]byte, 8+4*len(s.SubAuthorities))
+	b[0] = s.Revision
+	b[1] = byte(len(s.SubAuthorities))
+	for i := 0; i < 6; i++ {
+		b[7-i] = byte(s.Authority >> (8 * uint(i)))
+	}
+	for i, sub := range s.SubAuthorities {
+		binary.LittleEndian.PutUint32(b[8+4*i:], sub)
+	}
+	return b
+}
+
+// RID returns the relative identifier, the last sub authority
+func (s *SID) RID() uint32 {
+	if len(s.SubAuthorities) == 0 {
+		return 0
+	}
+	return s.SubAuthorities[len(s.SubAuthorities)-1]
+}
+
+// String returns the SID in the "S-1-5-21-..." form
+func (s *SID) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "S-%d-%d", s.Revision, s.Authority)
+	for _, sub := range s.SubAuthorities {
+		fmt.Fprintf(&sb, "-%d", sub)
+	}
+	return sb.String()
+}
+
+// fileTimeEpochOffset is the number of 100ns intervals between 1601-01-01 and 1970-01-01
+const fileTimeEpochOffset = 116444736000000000
+
+// ParseFileTime parses a Windows FILETIME value such as pwdLastSet or accountExpires.
+// The values 0 and 0x7FFFFFFFFFFFFFFF mean "never" and are returned as the zero time.
+func ParseFileTime(s string) (time.Time, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ldap: invalid FILETIME %q", s)
+	}
+	if n == 0 || n == math.MaxInt64 {
+		return time.Time{}, nil
+	}
+	n -= fileTimeEpochOffset
+	return time.Unix(n/1e7, (n%1e7)*100).UTC(), nil
+}
+
+// FormatFileTime returns the Windows FILETIME value of t, 0 for the zero time
+func FormatFileTime(t time.Time) string {
+	if t.IsZero() {
+		return "0"
+	}
+	return strconv.FormatInt(t.Unix()*1e7+int64(t.Nanosecond()/100)+fileTimeEpochOffset, 10)
+}
+
+// generalizedTimeFormats are the layouts of the GeneralizedTime syntax accepted by ParseGeneralizedTime
+var generalizedTimeFormats = []string{
+	"20060102150405Z0700",
+	"20060102150405.0Z0700",
+	"20060102150405.00Z0700",
+	"20060102150405.000Z0700",
+	"20060102150405.000000Z0700",
+	"200601021504Z0700",
+	"2006010215Z0700",
+}
+
+// ParseGeneralizedTime parses a GeneralizedTime value such as whenCreated or createTimestamp
+func ParseGeneralizedTime(s string) (time.Time, error) {
+	for _, layout := range generalizedTimeFormats {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("ldap: invalid GeneralizedTime %q", s)
+}
+
+// FormatGeneralizedTime returns t in the GeneralizedTime syntax used by Active Directory
+func FormatGeneralizedTime(t time.Time) string {
+	return t.UTC().Format("20060102150405.0Z")
+}
+
+// UserAccountControl holds the flags of the Active Directory userAccountControl attribute
+type UserAccountControl uint32
+
+// userAccountControl flags - https://docs.microsoft.com/en-us/troubleshoot/windows-server/identity/useraccountcontrol-manipulate-account-properties
+const (
+	UACScript                       UserAccountControl = 0x0001
+	UACAccountDisable               UserAccountControl = 0x0002
+	UACHomeDirRequired              UserAccountControl = 0x0008
+	UACLockout                      UserAccountControl = 0x0010
+	UACPasswordNotRequired          UserAccountControl = 0x0020
+	UACPasswordCantChange           UserAccountControl = 0x0040
+	UACEncryptedTextPasswordAllowed UserAccountControl = 0x0080
+	UACTempDuplicateAccount         UserAccountControl = 0x0100
+	UACNormalAccount                UserAccountControl = 0x0200
+	UACInterdomainTrustAccount      UserAccountControl = 0x0800
+	UACWorkstationTrustAccount      UserAccountControl = 0x1000
+	UACServerTrustAccount           UserAccountControl = 0x2000
+	UACDontExpirePassword           UserAccountControl = 0x10000
+	UACMNSLogonAccount              UserAccountControl = 0x20000
+	UACSmartcardRequired            UserAccountControl = 0x40000
+	UACTrustedForDelegation         UserAccountControl = 0x80000
+	UACNotDelegated                 UserAccountControl = 0x100000
+	UACUseDESKeyOnly                UserAccountControl = 0x200000
+	UACDontRequirePreauth           UserAccountControl = 0x400000
+	UACPasswordExpired              UserAccountControl = 0x800000
+	UACTrustedToAuthForDelegation   UserAccountControl = 0x1000000
+	UACPartialSecretsAccount        UserAccountControl = 0x04000000
+)
+
+// Has reports whether all the given flags are set
+func (u UserAccountControl) Has(flags UserAccountControl) bool {
+	return u&flags == flags
+}
+
+// Disabled reports whether the account is disabled
+func (u UserAccountControl) Disabled() bool {
+	return u.Has(UACAccountDisable)
+}
+
+// String returns the decimal value, as stored in the directory
+func (u UserAccountControl) String() string {
+	return strconv.FormatUint(uint64(u), 10)
+}
@@ -23,8 +23,50 @@ const (
 	ControlTypeMicrosoftNotification = "1.2.840.113556.1.4.528"
 	// ControlTypeMicrosoftShowDeleted - https://msdn.microsoft.com/en-us/library/aa366989(v=vs.85).aspx
 	ControlTypeMicrosoftShowDeleted = "1.2.840.113556.1.4.417"
+	// ControlTypeDirSync - https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/2213a7f2-0a36-483c-b2a4-8574d53aa1e3
+	ControlTypeDirSync = "1.2.840.113556.1.4.841"
+
+	// ControlTypeSyncRequest - https://tools.ietf.org/html/rfc4533#section-2.2
+	ControlTypeSyncRequest = "1.3.6.1.4.1.4203.1.9.1.1"
+	// ControlTypeSyncState - https://tools.ietf.org/html/rfc4533#section-2.3
+	ControlTypeSyncState = "1.3.6.1.4.1.4203.1.9.1.2"
+	// ControlTypeSyncDone - https://tools.ietf.org/html/rfc4533#section-2.4
+	ControlTypeSyncDone = "1.3.6.1.4.1.4203.1.9.1.3"
 )
 
+// Flags of the DirSync control
+const (
+	DirSyncObjectSecurity      = 0x00000001
+	DirSyncAncestorsFirstOrder = 0x00000800
+	DirSyncPublicDataOnly      = 0x00002000
+	DirSyncIncrementalValues   = 0x80000000
+)
+
+// DirSyncDefaultMaxAttrCount is the maximum number of attribute bytes requested by NewControlDirSync
+const DirSyncDefaultMaxAttrCount = 1000
+
+// Modes of the Sync Request control
+const (
+	SyncRequestModeRefreshOnly       = 1
+	SyncRequestModeRefreshAndPersist = 3
+)
+
+// States of the Sync State control
+const (
+	SyncStatePresent = 0
+	SyncStateAdd     = 1
+	SyncStateModify  = 2
+	SyncStateDelete  = 3
+)
+
+// SyncStateMap contains human readable descriptions of the Sync State control states
+var SyncStateMap = map[int64]string{
+	SyncStatePresent: "present",
+	SyncStateAdd:     "add",
+	SyncStateModify:  "modify",
+	SyncStateDelete:  "delete",
+}
+
 // ControlTypeMap maps controls to text descriptions
 var ControlTypeMap = map[string]string{
 	ControlTypePaging:                "Paging",
@@ -32,6 +74,10 @@ var ControlTypeMap = map[string]string{
 	ControlTypeManageDsaIT:           "Manage DSA IT",
 	ControlTypeMicrosoftNotification: "Change Notification - Microsoft",
 	ControlTypeMicrosoftShowDeleted:  "Show Deleted Objects - Microsoft",
+	ControlTypeDirSync:               "DirSync - Microsoft",
+	ControlTypeSyncRequest:           "Sync Request",
+	ControlTypeSyncState:             "Sync State",
+	ControlTypeSyncDone:              "Sync Done",
 }
 
 // Control defines an interface controls provide to encode and describe themselves
@@ -305,6 +351,239 @@ func NewControlMicrosoftShowDeleted() *ControlMicrosoftShowDeleted {
 	return &ControlMicrosoftShowDeleted{}
 }
 
+// ControlDirSync implements the Active Directory DirSync control described in
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/2213a7f2-0a36-483c-b2a4-8574d53aa1e3
+//
+// The same value layout is used in requests and responses. In a response Flags is
+// non-zero when more results are available, see MoreResults.
+type ControlDirSync struct {
+	// Criticality indicates if this control is required, Active Directory requires it to be set
+	Criticality bool
+	// Flags are the DirSync* request flags, or the more results flag of a response
+	Flags int64
+	// MaxAttrCount is the maximum number of attribute bytes to return
+	MaxAttrCount int64
+	// Cookie is an opaque value returned by the server to track the synchronization state
+	Cookie []byte
+}
+
+// GetControlType returns the OID
+func (c *ControlDirSync) GetControlType() string {
+	return ControlTypeDirSync
+}
+
+// Encode returns the ber packet representation
+func (c *ControlDirSync) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeDirSync, "Control Type ("+ControlTypeMap[ControlTypeDirSync]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+
+	p2 := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (DirSync)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "DirSync Control Value")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, c.Flags, "Flags"))
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, c.MaxAttrCount, "MaxAttrCount"))
+	seq.AppendChild(encodeCookie(c.Cookie))
+	p2.AppendChild(seq)
+
+	packet.AppendChild(p2)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlDirSync) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Flags: %d  MaxAttrCount: %d  Cookie: %q",
+		ControlTypeMap[ControlTypeDirSync],
+		ControlTypeDirSync,
+		c.Criticality,
+		c.Flags,
+		c.MaxAttrCount,
+		c.Cookie)
+}
+
+// SetCookie stores the given cookie in the DirSync control
+func (c *ControlDirSync) SetCookie(cookie []byte) {
+	c.Cookie = cookie
+}
+
+// MoreResults reports whether a DirSync response control indicates that more changes are available
+func (c *ControlDirSync) MoreResults() bool {
+	return c.Flags != 0
+}
+
+// NewControlDirSync returns a critical DirSync control with the given flags and cookie
+func NewControlDirSync(flags int64, cookie []byte) *ControlDirSync {
+	return &ControlDirSync{
+		Criticality:  true,
+		Flags:        flags,
+		MaxAttrCount: DirSyncDefaultMaxAttrCount,
+		Cookie:       cookie,
+	}
+}
+
+// ControlSyncRequest implements the Sync Request control described in https://tools.ietf.org/html/rfc4533#section-2.2
+type ControlSyncRequest struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// Mode is SyncRequestModeRefreshOnly or SyncRequestModeRefreshAndPersist
+	Mode int64
+	// Cookie is the synchronization state returned by a previous operation
+	Cookie []byte
+	// ReloadHint asks the server to return the full content when the cookie cannot be used
+	ReloadHint bool
+}
+
+// GetControlType returns the OID
+func (c *ControlSyncRequest) GetControlType() string {
+	return ControlTypeSyncRequest
+}
+
+// Encode returns the ber packet representation
+func (c *ControlSyncRequest) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeSyncRequest, "Control Type ("+ControlTypeMap[ControlTypeSyncRequest]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+
+	p2 := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Sync Request)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Sync Request Value")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, c.Mode, "Mode"))
+	if c.Cookie != nil {
+		seq.AppendChild(encodeCookie(c.Cookie))
+	}
+	if c.ReloadHint {
+		seq.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.ReloadHint, "Reload Hint"))
+	}
+	p2.AppendChild(seq)
+
+	packet.AppendChild(p2)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlSyncRequest) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Mode: %d  Cookie: %q  ReloadHint: %t",
+		ControlTypeMap[ControlTypeSyncRequest],
+		ControlTypeSyncRequest,
+		c.Criticality,
+		c.Mode,
+		c.Cookie,
+		c.ReloadHint)
+}
+
+// SetCookie stores the given cookie in the Sync Request control
+func (c *ControlSyncRequest) SetCookie(cookie []byte) {
+	c.Cookie = cookie
+}
+
+// NewControlSyncRequest returns a critical Sync Request control
+func NewControlSyncRequest(mode int64, cookie []byte, reloadHint bool) *ControlSyncRequest {
+	return &ControlSyncRequest{
+		Criticality: true,
+		Mode:        mode,
+		Cookie:      cookie,
+		ReloadHint:  reloadHint,
+	}
+}
+
+// ControlSyncState implements the Sync State control described in https://tools.ietf.org/html/rfc4533#section-2.3
+// It is returned with each entry of a synchronization operation.
+type ControlSyncState struct {
+	// State is one of the SyncState* values
+	State int64
+	// EntryUUID is the 16 byte UUID of the entry
+	EntryUUID []byte
+	// Cookie is an optional synchronization state
+	Cookie []byte
+}
+
+// GetControlType returns the OID
+func (c *ControlSyncState) GetControlType() string {
+	return ControlTypeSyncState
+}
+
+// Encode returns the ber packet representation
+func (c *ControlSyncState) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeSyncState, "Control Type ("+ControlTypeMap[ControlTypeSyncState]+")"))
+
+	p2 := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Sync State)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Sync State Value")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, c.State, "State"))
+	uuid := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Entry UUID")
+	uuid.Value = c.EntryUUID
+	uuid.Data.Write(c.EntryUUID)
+	seq.AppendChild(uuid)
+	if c.Cookie != nil {
+		seq.AppendChild(encodeCookie(c.Cookie))
+	}
+	p2.AppendChild(seq)
+
+	packet.AppendChild(p2)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlSyncState) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  State: %s  EntryUUID: %x  Cookie: %q",
+		ControlTypeMap[ControlTypeSyncState],
+		ControlTypeSyncState,
+		false,
+		SyncStateMap[c.State],
+		c.EntryUUID,
+		c.Cookie)
+}
+
+// ControlSyncDone implements the Sync Done control described in https://tools.ietf.org/html/rfc4533#section-2.4
+// It is returned with the search result done message of a synchronization operation.
+type ControlSyncDone struct {
+	// Cookie is the new synchronization state
+	Cookie []byte
+	// RefreshDeletes is true when deleted entries were returned with the delete state,
+	// and false when entries that were not returned as present must be considered deleted
+	RefreshDeletes bool
+}
+
+// GetControlType returns the OID
+func (c *ControlSyncDone) GetControlType() string {
+	return ControlTypeSyncDone
+}
+
+// Encode returns the ber packet representation
+func (c *ControlSyncDone) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeSyncDone, "Control Type ("+ControlTypeMap[ControlTypeSyncDone]+")"))
+
+	p2 := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Sync Done)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Sync Done Value")
+	if c.Cookie != nil {
+		seq.AppendChild(encodeCookie(c.Cookie))
+	}
+	if c.RefreshDeletes {
+		seq.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.RefreshDeletes, "Refresh Deletes"))
+	}
+	p2.AppendChild(seq)
+
+	packet.AppendChild(p2)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlSyncDone) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Cookie: %q  RefreshDeletes: %t",
+		ControlTypeMap[ControlTypeSyncDone],
+		ControlTypeSyncDone,
+		false,
+		c.Cookie,
+		c.RefreshDeletes)
+}
+
 // FindControl returns the first control of the given type in the list, or nil
 func FindControl(controls []Control, controlType string) Control {
 	for _, c := range controls {
@@ -386,8 +665,12 @@ func DecodeControl(packet *ber.Packet) (Control, error) {
 		value.Children[1].Value = c.Cookie
 		return c, nil
 	case ControlTypeBeheraPasswordPolicy:
-		value.Description += " (Password Policy - Behera)"
 		c := NewControlBeheraPasswordPolicy()
+		if value == nil {
+			// the request control has no value
+			return c, nil
+		}
+		value.Description += " (Password Policy - Behera)"
 		if value.Value != nil {
 			valueChildren, err := ber.DecodePacketErr(value.Data.Bytes())
 			if err != nil {
@@ -404,34 +687,27 @@ func DecodeControl(packet *ber.Packet) (Control, error) {
 			if child.Tag == 0 {
 				//Warning
 				warningPacket := child.Children[0]
-				packet, err := ber.DecodePacketErr(warningPacket.Data.Bytes())
+				// the warning choice is a context specific INTEGER, its content octets are not a BER packet
+				val, err := ber.ParseInt64(warningPacket.Data.Bytes())
 				if err != nil {
 					return nil, fmt.Errorf("failed to decode data bytes: %s", err)
 				}
-				val, ok := packet.Value.(int64)
-				if ok {
-					if warningPacket.Tag == 0 {
-						//timeBeforeExpiration
-						c.Expire = val
-						warningPacket.Value = c.Expire
-					} else if warningPacket.Tag == 1 {
-						//graceAuthNsRemaining
-						c.Grace = val
-						warningPacket.Value = c.Grace
-					}
+				if warningPacket.Tag == 0 {
+					//timeBeforeExpiration
+					c.Expire = val
+					warningPacket.Value = c.Expire
+				} else if warningPacket.Tag == 1 {
+					//graceAuthNsRemaining
+					c.Grace = val
+					warningPacket.Value = c.Grace
 				}
 			} else if child.Tag == 1 {
 				// Error
-				packet, err := ber.DecodePacketErr(child.Data.Bytes())
-				if err != nil {
-					return nil, fmt.Errorf("failed to decode data bytes: %s", err)
-				}
-				val, ok := packet.Value.(int8)
-				if !ok {
-					// what to do?
-					val = -1
+				bs := child.Data.Bytes()
+				if len(bs) != 1 || bs[0] > 8 {
+					return nil, fmt.Errorf("failed to decode data bytes: %s", "invalid PasswordPolicyResponse enum value")
 				}
-				c.Error = val
+				c.Error = int8(bs[0])
 				child.Value = c.Error
 				c.ErrorString = BeheraPasswordPolicyErrorMap[c.Error]
 			}
@@ -456,6 +732,70 @@ func DecodeControl(packet *ber.Packet) (Control, error) {
 		return NewControlMicrosoftNotification(), nil
 	case ControlTypeMicrosoftShowDeleted:
 		return NewControlMicrosoftShowDeleted(), nil
+	case ControlTypeDirSync:
+		value.Description += " (DirSync)"
+		sequence, err := decodeControlValue(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(sequence.Children) != 3 {
+			return nil, fmt.Errorf("invalid DirSync control value")
+		}
+		c := &ControlDirSync{Criticality: Criticality}
+		c.Flags, _ = sequence.Children[0].Value.(int64)
+		c.MaxAttrCount, _ = sequence.Children[1].Value.(int64)
+		c.Cookie = decodeCookie(sequence.Children[2])
+		return c, nil
+	case ControlTypeSyncRequest:
+		value.Description += " (Sync Request)"
+		sequence, err := decodeControlValue(value)
+		if err != nil {
+			return nil, err
+		}
+		c := &ControlSyncRequest{Criticality: Criticality}
+		for _, child := range sequence.Children {
+			switch child.Tag {
+			case ber.TagEnumerated:
+				c.Mode, _ = child.Value.(int64)
+			case ber.TagOctetString:
+				c.Cookie = decodeCookie(child)
+			case ber.TagBoolean:
+				c.ReloadHint, _ = child.Value.(bool)
+			}
+		}
+		return c, nil
+	case ControlTypeSyncState:
+		value.Description += " (Sync State)"
+		sequence, err := decodeControlValue(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(sequence.Children) < 2 {
+			return nil, fmt.Errorf("invalid Sync State control value")
+		}
+		c := new(ControlSyncState)
+		c.State, _ = sequence.Children[0].Value.(int64)
+		c.EntryUUID = decodeCookie(sequence.Children[1])
+		if len(sequence.Children) > 2 {
+			c.Cookie = decodeCookie(sequence.Children[2])
+		}
+		return c, nil
+	case ControlTypeSyncDone:
+		value.Description += " (Sync Done)"
+		sequence, err := decodeControlValue(value)
+		if err != nil {
+			return nil, err
+		}
+		c := new(ControlSyncDone)
+		for _, child := range sequence.Children {
+			switch child.Tag {
+			case ber.TagOctetString:
+				c.Cookie = decodeCookie(child)
+			case ber.TagBoolean:
+				c.RefreshDeletes, _ = child.Value.(bool)
+			}
+		}
+		return c, nil
 	default:
 		c := new(ControlString)
 		c.ControlType = ControlType
@@ -490,6 +830,39 @@ func NewControlBeheraPasswordPolicy() *ControlBeheraPasswordPolicy {
 	}
 }
 
+// decodeControlValue returns the sequence held by a control value, decoding it from the
+// value bytes when the packet was read from the wire
+func decodeControlValue(value *ber.Packet) (*ber.Packet, error) {
+	if value == nil {
+		return nil, fmt.Errorf("missing control value")
+	}
+	if value.Value != nil || len(value.Children) == 0 {
+		valueChildren, err := ber.DecodePacketErr(value.Data.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode data bytes: %s", err)
+		}
+		value.Data.Truncate(0)
+		value.Value = nil
+		value.AppendChild(valueChildren)
+	}
+	return value.Children[0], nil
+}
+
+// encodeCookie returns an octet string packet holding the given bytes
+func encodeCookie(cookie []byte) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Cookie")
+	packet.Value = cookie
+	packet.Data.Write(cookie)
+	return packet
+}
+
+// decodeCookie returns the bytes of an octet string packet
+func decodeCookie(packet *ber.Packet) []byte {
+	data := packet.Data.Bytes()
+	packet.Value = data
+	return data
+}
+
 func encodeControls(controls []Control) *ber.Packet {
 	packet := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
 	for _, control := range controls {
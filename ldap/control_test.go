@@ -28,6 +28,79 @@ func TestControlMicrosoftShowDeleted(t *testing.T) {
 	runControlTest(t, NewControlMicrosoftShowDeleted())
 }
 
+func TestControlBeheraPasswordPolicy(t *testing.T) {
+	runControlTest(t, NewControlBeheraPasswordPolicy())
+}
+
+func TestControlDirSync(t *testing.T) {
+	runControlTest(t, NewControlDirSync(DirSyncIncrementalValues, nil))
+	runControlTest(t, NewControlDirSync(DirSyncObjectSecurity|DirSyncAncestorsFirstOrder, []byte{0x01, 0x00, 0xff}))
+	runControlTest(t, &ControlDirSync{Flags: 1, Cookie: []byte("cookie")})
+}
+
+func TestControlSync(t *testing.T) {
+	runControlTest(t, NewControlSyncRequest(SyncRequestModeRefreshOnly, nil, false))
+	runControlTest(t, NewControlSyncRequest(SyncRequestModeRefreshAndPersist, []byte("rid=001,csn=1"), true))
+	runControlTest(t, &ControlSyncState{State: SyncStateAdd, EntryUUID: make([]byte, 16)})
+	runControlTest(t, &ControlSyncState{State: SyncStateDelete, EntryUUID: make([]byte, 16), Cookie: []byte("c")})
+	runControlTest(t, &ControlSyncDone{})
+	runControlTest(t, &ControlSyncDone{Cookie: []byte("c"), RefreshDeletes: true})
+}
+
+func TestDecodeControlDirSyncResponse(t *testing.T) {
+	encoded := (&ControlDirSync{Flags: 1, MaxAttrCount: 0, Cookie: []byte{0xde, 0xad}}).Encode().Bytes()
+	pkt, err := ber.DecodePacketErr(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	control, err := DecodeControl(pkt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirSync, ok := control.(*ControlDirSync)
+	if !ok {
+		t.Fatalf("expected *ControlDirSync, got %T", control)
+	}
+	if !dirSync.MoreResults() || !bytes.Equal(dirSync.Cookie, []byte{0xde, 0xad}) {
+		t.Errorf("unexpected control %s", dirSync)
+	}
+}
+
+func TestDecodeControlBeheraPasswordPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		value  []byte
+		expire int64
+		grace  int64
+		err    int8
+	}{
+		// warning: timeBeforeExpiration 3600
+		{value: []byte{0x30, 0x06, 0xa0, 0x04, 0x80, 0x02, 0x0e, 0x10}, expire: 3600, grace: -1, err: -1},
+		// warning: graceAuthNsRemaining 2
+		{value: []byte{0x30, 0x05, 0xa0, 0x03, 0x81, 0x01, 0x02}, expire: -1, grace: 2, err: -1},
+		// error: changeAfterReset
+		{value: []byte{0x30, 0x03, 0x81, 0x01, 0x02}, expire: -1, grace: -1, err: 2},
+	} {
+		packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+		packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeBeheraPasswordPolicy, "Control Type"))
+		packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(tc.value), "Control Value"))
+		pkt, err := ber.DecodePacketErr(packet.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		control, err := DecodeControl(pkt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ppolicy, ok := control.(*ControlBeheraPasswordPolicy)
+		if !ok {
+			t.Fatalf("expected *ControlBeheraPasswordPolicy, got %T", control)
+		}
+		if ppolicy.Expire != tc.expire || ppolicy.Grace != tc.grace || ppolicy.Error != tc.err {
+			t.Errorf("unexpected control %s", ppolicy)
+		}
+	}
+}
+
 func TestControlString(t *testing.T) {
 	runControlTest(t, NewControlString("x", true, "y"))
 	runControlTest(t, NewControlString("x", true, ""))
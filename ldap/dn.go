@@ -172,6 +172,45 @@ func (d *DN) AncestorOf(other *DN) bool {
 	return true
 }
 
+// String returns the DN in the string representation of https://tools.ietf.org/html/rfc4514
+func (d *DN) String() string {
+	rdns := make([]string, len(d.RDNs))
+	for i, rdn := range d.RDNs {
+		rdns[i] = rdn.String()
+	}
+	return strings.Join(rdns, ",")
+}
+
+// String returns the RDN in the string representation of https://tools.ietf.org/html/rfc4514
+func (r *RelativeDN) String() string {
+	attrs := make([]string, len(r.Attributes))
+	for i, attr := range r.Attributes {
+		attrs[i] = attr.String()
+	}
+	return strings.Join(attrs, "+")
+}
+
+// String returns the attribute type and value with the value escaped as required by https://tools.ietf.org/html/rfc4514#section-2.4
+func (a *AttributeTypeAndValue) String() string {
+	var sb strings.Builder
+	sb.WriteString(a.Type)
+	sb.WriteByte('=')
+	for i := 0; i < len(a.Value); i++ {
+		c := a.Value[i]
+		switch {
+		case c == 0:
+			sb.WriteString("\\00")
+			continue
+		case c == '"' || c == '+' || c == ',' || c == ';' || c == '<' || c == '>' || c == '\\',
+			(c == ' ' || c == '#') && i == 0,
+			c == ' ' && i == len(a.Value)-1:
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
 // Equal returns true if the RelativeDNs are equal as defined by rfc4517 4.2.15 (distinguishedNameMatch).
 // Relative distinguished names are the same if and only if they have the same number of AttributeTypeAndValues
 // and each attribute of the first RDN is the same as the attribute of the second RDN with the same attribute type.
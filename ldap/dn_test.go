@@ -207,3 +207,26 @@ func TestDNAncestor(t *testing.T) {
 		}
 	}
 }
+
+func TestDNString(t *testing.T) {
+	testcases := map[string]string{
+		"cn=Jim\\2C \\22Hasse Hö\\22 Hansson!,dc=dummy,dc=com": "cn=Jim\\, \\\"Hasse Hö\\\" Hansson!,dc=dummy,dc=com",
+		"OU=Sales+CN=J. Smith,DC=example,DC=net":               "OU=Sales+CN=J. Smith,DC=example,DC=net",
+		"cn=\\ leading and trailing\\ ,dc=com":                 "cn=\\ leading and trailing\\ ,dc=com",
+		"cn=\\#hash,dc=com":                                    "cn=\\#hash,dc=com",
+	}
+	for input, expected := range testcases {
+		dn, err := ParseDN(input)
+		if err != nil {
+			t.Errorf("%q: %s", input, err)
+			continue
+		}
+		if s := dn.String(); s != expected {
+			t.Errorf("%q: expected %q, got %q", input, expected, s)
+		}
+		reparsed, err := ParseDN(dn.String())
+		if err != nil || !reparsed.Equal(dn) {
+			t.Errorf("%q: string form does not parse back to the same DN", input)
+		}
+	}
+}
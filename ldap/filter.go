@@ -176,7 +176,8 @@ func DecompileFilter(packet *ber.Packet) (_ string, err error) {
 			case MatchingRuleAssertionMatchValue:
 				value = ber.DecodeString(child.Data.Bytes())
 			case MatchingRuleAssertionDNAttributes:
-				dnAttributes = child.Value.(bool)
+				// context-specific booleans are not decoded when read from the wire
+				dnAttributes = child.Data.Len() > 0 && child.Data.Bytes()[0] != 0
 			}
 		}
 
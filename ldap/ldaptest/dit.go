@@ -0,0 +1,166 @@
+package ldaptest
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/ThomasNguyenGitHub/go/ldap"
+)
+
+// node is a single entry of the in-memory directory information tree.
+type node struct {
+	// dn is the distinguished name as given when the entry was created
+	dn string
+	// key is the normalized form of dn used for lookups
+	key string
+	// parent is the normalized DN of the superior entry
+	parent string
+	// seq orders entries by creation so results are deterministic
+	seq uint64
+	// attrs hold the attributes of the entry in creation order
+	attrs []*ldap.EntryAttribute
+}
+
+// attr returns the attribute with the given name, matched case-insensitively, or nil.
+func (n *node) attr(name string) *ldap.EntryAttribute {
+	for _, a := range n.attrs {
+		if strings.EqualFold(a.Name, name) {
+			return a
+		}
+	}
+	return nil
+}
+
+// values returns the values of the named attribute, or nil.
+func (n *node) values(name string) []string {
+	if a := n.attr(name); a != nil {
+		return a.Values
+	}
+	return nil
+}
+
+// setValues replaces the values of the named attribute, removing it when vals is empty.
+func (n *node) setValues(name string, vals []string) {
+	for i, a := range n.attrs {
+		if strings.EqualFold(a.Name, name) {
+			if len(vals) == 0 {
+				n.attrs = append(n.attrs[:i], n.attrs[i+1:]...)
+				return
+			}
+			n.attrs[i] = ldap.NewEntryAttribute(a.Name, vals)
+			return
+		}
+	}
+	if len(vals) > 0 {
+		n.attrs = append(n.attrs, ldap.NewEntryAttribute(name, vals))
+	}
+}
+
+// hasValue reports whether the named attribute holds value, compared case-insensitively.
+func (n *node) hasValue(name, value string) bool {
+	for _, v := range n.values(name) {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// clone returns a deep copy of the node.
+func (n *node) clone() *node {
+	c := *n
+	c.attrs = make([]*ldap.EntryAttribute, len(n.attrs))
+	for i, a := range n.attrs {
+		c.attrs[i] = ldap.NewEntryAttribute(a.Name, append([]string(nil), a.Values...))
+	}
+	return &c
+}
+
+// entry converts the node into an *ldap.Entry holding the selected attributes.
+func (n *node) entry(attributes []string, typesOnly bool) *ldap.Entry {
+	e := &ldap.Entry{DN: n.dn}
+	for _, a := range n.attrs {
+		if !selected(a.Name, attributes) {
+			continue
+		}
+		if typesOnly {
+			e.Attributes = append(e.Attributes, ldap.NewEntryAttribute(a.Name, nil))
+			continue
+		}
+		e.Attributes = append(e.Attributes, ldap.NewEntryAttribute(a.Name, append([]string(nil), a.Values...)))
+	}
+	return e
+}
+
+// selected reports whether the attribute is part of the requested attribute list.
+// An empty list or "*" selects all attributes, "1.1" selects none.
+func selected(name string, attributes []string) bool {
+	if len(attributes) == 0 {
+		return true
+	}
+	for _, attr := range attributes {
+		if attr == "*" || strings.EqualFold(attr, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeDN returns the normalized form of dn together with the normalized form of its parent.
+// Attribute types and values are compared case-insensitively, as Active Directory does.
+func normalizeDN(dn string) (key, parent string, err error) {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return "", "", err
+	}
+	rdns := make([]string, len(parsed.RDNs))
+	for i, rdn := range parsed.RDNs {
+		rdns[i] = strings.ToLower(formatRDN(rdn))
+	}
+	if len(rdns) == 0 {
+		return "", "", nil
+	}
+	return strings.Join(rdns, ","), strings.Join(rdns[1:], ","), nil
+}
+
+// parseRDN parses a single relative distinguished name such as "cn=John Doe".
+func parseRDN(rdn string) (*ldap.RelativeDN, error) {
+	parsed, err := ldap.ParseDN(rdn)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed.RDNs) != 1 {
+		return nil, errors.New("ldaptest: expected a single RDN")
+	}
+	return parsed.RDNs[0], nil
+}
+
+// formatRDN returns the string representation of rdn with attributes sorted by type.
+func formatRDN(rdn *ldap.RelativeDN) string {
+	avas := make([]string, len(rdn.Attributes))
+	for i, ava := range rdn.Attributes {
+		avas[i] = ava.Type + "=" + escapeDNValue(ava.Value)
+	}
+	sort.Strings(avas)
+	return strings.Join(avas, "+")
+}
+
+// escapeDNValue escapes an attribute value for use in a DN string as defined in
+// https://tools.ietf.org/html/rfc4514#section-2.4
+func escapeDNValue(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == ',' || c == '+' || c == '"' || c == '\\' || c == '<' || c == '>' || c == ';' || c == '=':
+			b.WriteByte('\\')
+		case i == 0 && (c == ' ' || c == '#'):
+			b.WriteByte('\\')
+		case i == len(value)-1 && c == ' ':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
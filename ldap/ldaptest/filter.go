@@ -0,0 +1,235 @@
+package ldaptest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ThomasNguyenGitHub/go/ldap"
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// Matching rules understood by extensible match filters
+const (
+	// MatchingRuleBitAnd - https://docs.microsoft.com/en-us/windows/win32/adsi/search-filter-syntax
+	MatchingRuleBitAnd = "1.2.840.113556.1.4.803"
+	// MatchingRuleBitOr - https://docs.microsoft.com/en-us/windows/win32/adsi/search-filter-syntax
+	MatchingRuleBitOr = "1.2.840.113556.1.4.804"
+	// MatchingRuleInChain - https://docs.microsoft.com/en-us/windows/win32/adsi/search-filter-syntax
+	MatchingRuleInChain = "1.2.840.113556.1.4.1941"
+)
+
+// matcher evaluates a compiled search filter against directory entries.
+type matcher struct {
+	filter *ber.Packet
+	// lookup resolves a DN to an entry, used by LDAP_MATCHING_RULE_IN_CHAIN
+	lookup func(dn string) *node
+}
+
+// newMatcher compiles the string representation of a filter.
+func newMatcher(filter string, lookup func(dn string) *node) (*matcher, error) {
+	packet, err := ldap.CompileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	return &matcher{filter: packet, lookup: lookup}, nil
+}
+
+// Match reports whether the entry satisfies the filter.
+func (m *matcher) Match(n *node) (bool, error) {
+	return m.match(m.filter, n)
+}
+
+func (m *matcher) match(f *ber.Packet, n *node) (bool, error) {
+	switch f.Tag {
+	case ldap.FilterAnd:
+		for _, child := range f.Children {
+			ok, err := m.match(child, n)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case ldap.FilterOr:
+		for _, child := range f.Children {
+			ok, err := m.match(child, n)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	case ldap.FilterNot:
+		if len(f.Children) != 1 {
+			return false, fmt.Errorf("ldaptest: invalid not filter")
+		}
+		ok, err := m.match(f.Children[0], n)
+		return !ok, err
+	case ldap.FilterPresent:
+		return len(n.values(decodeString(f))) > 0, nil
+	case ldap.FilterEqualityMatch, ldap.FilterApproxMatch:
+		attr, value := decodeString(f.Children[0]), decodeString(f.Children[1])
+		return n.hasValue(attr, value), nil
+	case ldap.FilterGreaterOrEqual, ldap.FilterLessOrEqual:
+		attr, value := decodeString(f.Children[0]), decodeString(f.Children[1])
+		for _, v := range n.values(attr) {
+			c := compareValues(v, value)
+			if (f.Tag == ldap.FilterGreaterOrEqual && c >= 0) || (f.Tag == ldap.FilterLessOrEqual && c <= 0) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case ldap.FilterSubstrings:
+		attr := decodeString(f.Children[0])
+		for _, v := range n.values(attr) {
+			if matchSubstrings(strings.ToLower(v), f.Children[1].Children) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case ldap.FilterExtensibleMatch:
+		return m.matchExtensible(f, n)
+	default:
+		return false, fmt.Errorf("ldaptest: unsupported filter %d", f.Tag)
+	}
+}
+
+func (m *matcher) matchExtensible(f *ber.Packet, n *node) (bool, error) {
+	var rule, attr, value string
+	dnAttributes := false
+	for _, child := range f.Children {
+		switch child.Tag {
+		case ldap.MatchingRuleAssertionMatchingRule:
+			rule = decodeString(child)
+		case ldap.MatchingRuleAssertionType:
+			attr = decodeString(child)
+		case ldap.MatchingRuleAssertionMatchValue:
+			value = decodeString(child)
+		case ldap.MatchingRuleAssertionDNAttributes:
+			dnAttributes = child.Data.Len() > 0 && child.Data.Bytes()[0] != 0
+		}
+	}
+
+	var values []string
+	if attr == "" {
+		for _, a := range n.attrs {
+			values = append(values, a.Values...)
+		}
+	} else {
+		values = n.values(attr)
+	}
+	if dnAttributes {
+		if parsed, err := ldap.ParseDN(n.dn); err == nil {
+			for _, rdn := range parsed.RDNs {
+				for _, ava := range rdn.Attributes {
+					if attr == "" || strings.EqualFold(ava.Type, attr) {
+						values = append(values, ava.Value)
+					}
+				}
+			}
+		}
+	}
+
+	switch rule {
+	case MatchingRuleBitAnd, MatchingRuleBitOr:
+		want, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("ldaptest: invalid bitwise assertion value %q", value)
+		}
+		for _, v := range values {
+			got, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				continue
+			}
+			if (rule == MatchingRuleBitAnd && got&want == want) || (rule == MatchingRuleBitOr && got&want != 0) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case MatchingRuleInChain:
+		if attr == "" {
+			return false, fmt.Errorf("ldaptest: %s requires an attribute", MatchingRuleInChain)
+		}
+		target, _, err := normalizeDN(value)
+		if err != nil {
+			return false, nil
+		}
+		return m.inChain(n, attr, target, map[string]bool{}), nil
+	case "":
+		for _, v := range values {
+			if strings.EqualFold(v, value) {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("ldaptest: unsupported matching rule %s", rule)
+	}
+}
+
+// inChain walks the DN-valued attribute attr starting at n and reports whether target is reachable.
+func (m *matcher) inChain(n *node, attr, target string, visited map[string]bool) bool {
+	if visited[n.key] {
+		return false
+	}
+	visited[n.key] = true
+	for _, v := range n.values(attr) {
+		key, _, err := normalizeDN(v)
+		if err != nil {
+			continue
+		}
+		if key == target {
+			return true
+		}
+		if next := m.lookup(key); next != nil && m.inChain(next, attr, target, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSubstrings reports whether value, already lowered, matches the initial, any and final substrings.
+func matchSubstrings(value string, parts []*ber.Packet) bool {
+	for _, part := range parts {
+		s := strings.ToLower(decodeString(part))
+		switch part.Tag {
+		case ldap.FilterSubstringsInitial:
+			if !strings.HasPrefix(value, s) {
+				return false
+			}
+			value = value[len(s):]
+		case ldap.FilterSubstringsAny:
+			i := strings.Index(value, s)
+			if i < 0 {
+				return false
+			}
+			value = value[i+len(s):]
+		case ldap.FilterSubstringsFinal:
+			if !strings.HasSuffix(value, s) {
+				return false
+			}
+			value = ""
+		}
+	}
+	return true
+}
+
+// compareValues compares two values numerically when both are integers, and
+// case-insensitively otherwise. Generalized time values order correctly as strings.
+func compareValues(a, b string) int {
+	if x, err := strconv.ParseInt(a, 10, 64); err == nil {
+		if y, err := strconv.ParseInt(b, 10, 64); err == nil {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+func decodeString(p *ber.Packet) string {
+	return ber.DecodeString(p.Data.Bytes())
+}
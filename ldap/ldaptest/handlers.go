@@ -0,0 +1,783 @@
+package ldaptest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ThomasNguyenGitHub/go/ldap"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const (
+	// passwordModifyOID - https://tools.ietf.org/html/rfc3062
+	passwordModifyOID = "1.3.6.1.4.1.4203.1.11.1"
+	// passwordAttribute holds the clear text password checked by binds
+	passwordAttribute = "userPassword"
+	// unicodePwdAttribute is the write-only Active Directory password attribute
+	unicodePwdAttribute = "unicodePwd"
+)
+
+var errInvalidRequest = errors.New("invalid request")
+
+// cursor holds the remaining entries of a paged search.
+type cursor struct {
+	owner   *conn
+	entries []*ldap.Entry
+}
+
+func (c *conn) bind(op *ber.Packet) error {
+	c.boundDN = ""
+	if len(op.Children) < 3 {
+		return ldap.NewError(ldap.LDAPResultProtocolError, errInvalidRequest)
+	}
+	if version, _ := op.Children[0].Value.(int64); version != 3 {
+		return ldap.NewError(ldap.LDAPResultProtocolError, fmt.Errorf("unsupported protocol version %d", version))
+	}
+	auth := op.Children[2]
+	if auth.ClassType != ber.ClassContext || auth.Tag != 0 {
+		return ldap.NewError(ldap.LDAPResultAuthMethodNotSupported, errors.New("only simple authentication is supported"))
+	}
+	name, password := decodeString(op.Children[1]), decodeString(auth)
+	if name == "" && password == "" {
+		return nil
+	}
+	if password == "" {
+		return ldap.NewError(ldap.LDAPResultUnwillingToPerform, errors.New("unauthenticated bind is not allowed"))
+	}
+
+	c.server.mu.RLock()
+	defer c.server.mu.RUnlock()
+	n := c.server.bindEntry(name)
+	switch {
+	case n == nil:
+		return logonError(adUserNotFound)
+	case !n.hasPassword(password):
+		return logonError(adInvalidCredentials)
+	}
+	if code := n.accountError(time.Now()); code != "" {
+		return logonError(code)
+	}
+	c.boundDN = n.key
+	return nil
+}
+
+// Active Directory logon failure codes, reported in the data field of the diagnostic message
+const (
+	adUserNotFound       = "525"
+	adInvalidCredentials = "52e"
+	adPasswordExpired    = "532"
+	adAccountDisabled    = "533"
+	adAccountExpired     = "701"
+	adPasswordMustChange = "773"
+	adAccountLocked      = "775"
+)
+
+// logonError returns an invalid credentials error formatted like the ones of Active Directory.
+func logonError(code string) error {
+	return ldap.NewError(ldap.LDAPResultInvalidCredentials,
+		fmt.Errorf("80090308: LdapErr: DSID-0C09044E, comment: AcceptSecurityContext error, data %s, v4563", code))
+}
+
+// accountError returns the Active Directory logon failure code for the account state of the entry:
+// disabled by userAccountControl, locked by lockoutTime, expired by accountExpires,
+// a pwdLastSet of 0 or an msDS-UserPasswordExpiryTimeComputed in the past.
+func (n *node) accountError(now time.Time) string {
+	uac, _ := strconv.ParseUint(n.attrValue("userAccountControl"), 10, 32)
+	expired := func(attr string) bool {
+		t, err := ldap.ParseFileTime(n.attrValue(attr))
+		return err == nil && !t.IsZero() && t.Before(now)
+	}
+	switch {
+	case ldap.UserAccountControl(uac).Disabled():
+		return adAccountDisabled
+	case n.attrValue("lockoutTime") != "" && n.attrValue("lockoutTime") != "0":
+		return adAccountLocked
+	case expired("accountExpires"):
+		return adAccountExpired
+	case n.attrValue("pwdLastSet") == "0":
+		return adPasswordMustChange
+	case expired("msDS-UserPasswordExpiryTimeComputed"):
+		return adPasswordExpired
+	}
+	return ""
+}
+
+// attrValue returns the first value of the attribute, or "".
+func (n *node) attrValue(name string) string {
+	if vals := n.values(name); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+// bindEntry resolves a bind name, which is either a DN or a userPrincipalName. The caller must hold s.mu.
+func (s *Server) bindEntry(name string) *node {
+	if key, _, err := normalizeDN(name); err == nil && key != "" {
+		if n, ok := s.entries[key]; ok {
+			return n
+		}
+	}
+	for _, n := range s.entries {
+		if n.hasValue("userPrincipalName", name) {
+			return n
+		}
+	}
+	return nil
+}
+
+// hasPassword reports whether password is one of the entry's clear text passwords.
+func (n *node) hasPassword(password string) bool {
+	for _, v := range n.values(passwordAttribute) {
+		if v == password {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *conn) search(msgID int64, op *ber.Packet, controls []ldap.Control) {
+	if len(op.Children) < 8 {
+		c.writeResult(msgID, ldap.ApplicationSearchResultDone, ldap.NewError(ldap.LDAPResultProtocolError, errInvalidRequest))
+		return
+	}
+	req := &ldap.SearchRequest{
+		BaseDN:    decodeString(op.Children[0]),
+		TypesOnly: op.Children[5].Value == true,
+	}
+	scope, _ := op.Children[1].Value.(int64)
+	sizeLimit, _ := op.Children[3].Value.(int64)
+	req.Scope, req.SizeLimit = int(scope), int(sizeLimit)
+	for _, attr := range op.Children[7].Children {
+		req.Attributes = append(req.Attributes, decodeString(attr))
+	}
+	filter, err := ldap.DecompileFilter(op.Children[6])
+	if err != nil {
+		c.writeResult(msgID, ldap.ApplicationSearchResultDone, ldap.NewError(ldap.LDAPResultProtocolError, err))
+		return
+	}
+	req.Filter = filter
+
+	var entries []*ldap.Entry
+	var responseControls []ldap.Control
+	if control := ldap.FindControl(controls, ldap.ControlTypePaging); control != nil {
+		paging := control.(*ldap.ControlPaging)
+		entries, paging, err = c.server.page(c, req, paging)
+		if paging != nil {
+			responseControls = append(responseControls, paging)
+		}
+	} else {
+		entries, err = c.server.search(req)
+	}
+
+	if err == nil && req.SizeLimit > 0 && len(entries) > req.SizeLimit {
+		entries = entries[:req.SizeLimit]
+		err = ldap.NewError(ldap.LDAPResultSizeLimitExceeded, errors.New("size limit exceeded"))
+	}
+	for _, e := range entries {
+		c.write(msgID, encodeEntry(e), nil)
+	}
+	c.writeResultControls(msgID, ldap.ApplicationSearchResultDone, err, responseControls)
+}
+
+// search returns the entries matching the request, with only the requested attributes.
+func (s *Server) search(req *ldap.SearchRequest) ([]*ldap.Entry, error) {
+	base, _, err := normalizeDN(req.BaseDN)
+	if err != nil {
+		return nil, ldap.NewError(ldap.LDAPResultInvalidDNSyntax, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	m, err := newMatcher(req.Filter, s.lookup)
+	if err != nil {
+		return nil, ldap.NewError(ldap.LDAPResultProtocolError, err)
+	}
+
+	if base == "" && req.Scope == ldap.ScopeBaseObject {
+		root := s.rootDSE()
+		if ok, _ := m.Match(root); !ok {
+			return nil, nil
+		}
+		return []*ldap.Entry{root.entry(req.Attributes, req.TypesOnly)}, nil
+	}
+	if _, ok := s.entries[base]; !ok && base != "" {
+		return nil, s.noSuchObject(base, req.BaseDN)
+	}
+
+	var nodes []*node
+	for _, n := range s.entries {
+		if !inScope(n, base, req.Scope) {
+			continue
+		}
+		ok, err := m.Match(n)
+		if err != nil {
+			return nil, ldap.NewError(ldap.LDAPResultOther, err)
+		}
+		if ok {
+			nodes = append(nodes, n)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].seq < nodes[j].seq })
+
+	entries := make([]*ldap.Entry, len(nodes))
+	for i, n := range nodes {
+		entries[i] = n.entry(req.Attributes, req.TypesOnly)
+		if req.Scope == ldap.ScopeBaseObject && requested(tokenGroupsAttribute, req.Attributes) && len(n.values("objectSid")) > 0 {
+			entries[i].Attributes = append(entries[i].Attributes, ldap.NewEntryAttribute(tokenGroupsAttribute, s.tokenGroups(m, n)))
+		}
+	}
+	return entries, nil
+}
+
+// tokenGroupsAttribute is the Active Directory constructed attribute holding the objectSid
+// of every group an object belongs to, directly or through nested groups.
+// Like Active Directory, it is only returned for security principals, entries with an objectSid,
+// by base object searches requesting it explicitly.
+const tokenGroupsAttribute = "tokenGroups"
+
+// requested reports whether the attribute is explicitly part of the requested attribute list.
+func requested(name string, attributes []string) bool {
+	for _, attr := range attributes {
+		if strings.EqualFold(attr, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenGroups returns the objectSid of the groups n is a transitive member of.
+func (s *Server) tokenGroups(m *matcher, n *node) []string {
+	var groups []*node
+	for _, g := range s.entries {
+		if len(g.values("objectSid")) > 0 && m.inChain(g, "member", n.key, map[string]bool{}) {
+			groups = append(groups, g)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].seq < groups[j].seq })
+
+	var sids []string
+	for _, g := range groups {
+		sids = append(sids, g.values("objectSid")...)
+	}
+	return sids
+}
+
+// page serves one page of a paged search as described in https://www.ietf.org/rfc/rfc2696.txt
+func (s *Server) page(c *conn, req *ldap.SearchRequest, paging *ldap.ControlPaging) ([]*ldap.Entry, *ldap.ControlPaging, error) {
+	var entries []*ldap.Entry
+	if len(paging.Cookie) == 0 {
+		all, err := s.search(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = all
+	} else {
+		s.mu.Lock()
+		cur, ok := s.cursors[string(paging.Cookie)]
+		delete(s.cursors, string(paging.Cookie))
+		s.mu.Unlock()
+		if !ok || cur.owner != c {
+			return nil, nil, ldap.NewError(ldap.LDAPResultUnwillingToPerform, errors.New("invalid paged results cookie"))
+		}
+		if paging.PagingSize == 0 {
+			// a page size of zero abandons the paged search
+			return nil, &ldap.ControlPaging{}, nil
+		}
+		entries = cur.entries
+	}
+
+	if paging.PagingSize == 0 || len(entries) <= int(paging.PagingSize) {
+		return entries, &ldap.ControlPaging{}, nil
+	}
+
+	s.mu.Lock()
+	s.cookies++
+	cookie := strconv.FormatUint(s.cookies, 10)
+	s.cursors[cookie] = &cursor{owner: c, entries: entries[paging.PagingSize:]}
+	s.mu.Unlock()
+	return entries[:paging.PagingSize], &ldap.ControlPaging{PagingSize: uint32(len(entries)), Cookie: []byte(cookie)}, nil
+}
+
+// dropCursors forgets the paged searches of a closed connection.
+func (s *Server) dropCursors(c *conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for cookie, cur := range s.cursors {
+		if cur.owner == c {
+			delete(s.cursors, cookie)
+		}
+	}
+}
+
+// rootDSE returns the root DSE entry described in https://tools.ietf.org/html/rfc4512#section-5.1
+// The caller must hold s.mu.
+func (s *Server) rootDSE() *node {
+	n := &node{}
+	var contexts []*node
+	for _, e := range s.entries {
+		if _, ok := s.entries[e.parent]; !ok {
+			contexts = append(contexts, e)
+		}
+	}
+	sort.Slice(contexts, func(i, j int) bool { return contexts[i].seq < contexts[j].seq })
+	var namingContexts []string
+	for _, e := range contexts {
+		namingContexts = append(namingContexts, e.dn)
+	}
+	n.setValues("objectClass", []string{"top"})
+	n.setValues("namingContexts", namingContexts)
+	n.setValues("supportedLDAPVersion", []string{"3"})
+	n.setValues("supportedControl", []string{ldap.ControlTypePaging})
+	n.setValues("supportedExtension", []string{passwordModifyOID})
+	return n
+}
+
+// inScope reports whether the entry is within the search scope rooted at base.
+func inScope(n *node, base string, scope int) bool {
+	switch scope {
+	case ldap.ScopeBaseObject:
+		return n.key == base
+	case ldap.ScopeSingleLevel:
+		return n.parent == base
+	default:
+		return n.key == base || base == "" || strings.HasSuffix(n.key, ","+base)
+	}
+}
+
+// noSuchObject returns a noSuchObject error with the closest existing superior as matched DN.
+// The caller must hold s.mu.
+func (s *Server) noSuchObject(key, dn string) error {
+	matched := ""
+	for parts := strings.Split(key, ","); len(parts) > 0; parts = parts[1:] {
+		if n, ok := s.entries[strings.Join(parts, ",")]; ok {
+			matched = n.dn
+			break
+		}
+	}
+	return &ldap.Error{
+		ResultCode: ldap.LDAPResultNoSuchObject,
+		MatchedDN:  matched,
+		Err:        fmt.Errorf("no such object %q", dn),
+	}
+}
+
+// encodeEntry returns the SearchResultEntry protocol operation for e.
+func encodeEntry(e *ldap.Entry) *ber.Packet {
+	pkt := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	pkt.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.DN, "Object Name"))
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for _, a := range e.Attributes {
+		attributes.AppendChild(encodeAttribute(a.Name, a.Values))
+	}
+	pkt.AppendChild(attributes)
+	return pkt
+}
+
+func (c *conn) add(op *ber.Packet) error {
+	if len(op.Children) < 2 {
+		return ldap.NewError(ldap.LDAPResultProtocolError, errInvalidRequest)
+	}
+	var attrs []*ldap.EntryAttribute
+	for _, child := range op.Children[1].Children {
+		a, err := decodeAttribute(child)
+		if err != nil {
+			return err
+		}
+		attrs = append(attrs, ldap.NewEntryAttribute(a.Type, a.Vals))
+	}
+	return c.server.addEntry(decodeString(op.Children[0]), attrs, true)
+}
+
+func (c *conn) modify(op *ber.Packet) error {
+	if len(op.Children) < 2 {
+		return ldap.NewError(ldap.LDAPResultProtocolError, errInvalidRequest)
+	}
+	dn := decodeString(op.Children[0])
+	key, _, err := normalizeDN(dn)
+	if err != nil {
+		return ldap.NewError(ldap.LDAPResultInvalidDNSyntax, err)
+	}
+
+	s := c.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.entries[key]
+	if !ok {
+		return s.noSuchObject(key, dn)
+	}
+
+	// changes are applied to a copy so that a failing change leaves the entry untouched
+	updated := n.clone()
+	for _, change := range op.Children[1].Children {
+		if len(change.Children) < 2 {
+			return ldap.NewError(ldap.LDAPResultProtocolError, errInvalidRequest)
+		}
+		operation, _ := change.Children[0].Value.(int64)
+		attr, err := decodeAttribute(change.Children[1])
+		if err != nil {
+			return err
+		}
+		if err := s.applyChange(updated, uint(operation), attr); err != nil {
+			return err
+		}
+	}
+	s.entries[key] = updated
+	return nil
+}
+
+// applyChange applies a single modification to the entry.
+func (s *Server) applyChange(n *node, operation uint, attr ldap.PartialAttribute) error {
+	if strings.EqualFold(attr.Type, unicodePwdAttribute) {
+		return s.applyUnicodePwd(n, operation, attr.Vals)
+	}
+
+	current := n.values(attr.Type)
+	switch operation {
+	case ldap.AddAttribute:
+		for _, v := range attr.Vals {
+			if n.hasValue(attr.Type, v) {
+				return ldap.NewError(ldap.LDAPResultAttributeOrValueExists, fmt.Errorf("value %q of %s already exists", v, attr.Type))
+			}
+			current = append(current, v)
+		}
+		n.setValues(attr.Type, current)
+	case ldap.DeleteAttribute:
+		if len(current) == 0 {
+			return ldap.NewError(ldap.LDAPResultNoSuchAttribute, fmt.Errorf("no such attribute %s", attr.Type))
+		}
+		if len(attr.Vals) == 0 {
+			n.setValues(attr.Type, nil)
+			return nil
+		}
+		for _, v := range attr.Vals {
+			if !n.hasValue(attr.Type, v) {
+				return ldap.NewError(ldap.LDAPResultNoSuchAttribute, fmt.Errorf("no such value %q of %s", v, attr.Type))
+			}
+			current = removeValue(current, v)
+		}
+		n.setValues(attr.Type, current)
+	case ldap.ReplaceAttribute:
+		n.setValues(attr.Type, attr.Vals)
+	case ldap.IncrementAttribute:
+		if len(current) == 0 {
+			return ldap.NewError(ldap.LDAPResultNoSuchAttribute, fmt.Errorf("no such attribute %s", attr.Type))
+		}
+		if len(attr.Vals) != 1 {
+			return ldap.NewError(ldap.LDAPResultProtocolError, errors.New("increment requires a single value"))
+		}
+		delta, err := strconv.ParseInt(attr.Vals[0], 10, 64)
+		if err != nil {
+			return ldap.NewError(ldap.LDAPResultInvalidAttributeSyntax, err)
+		}
+		incremented := make([]string, len(current))
+		for i, v := range current {
+			x, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return ldap.NewError(ldap.LDAPResultConstraintViolation, fmt.Errorf("%s is not an integer", attr.Type))
+			}
+			incremented[i] = strconv.FormatInt(x+delta, 10)
+		}
+		n.setValues(attr.Type, incremented)
+	default:
+		return ldap.NewError(ldap.LDAPResultProtocolError, fmt.Errorf("unknown modify operation %d", operation))
+	}
+	return nil
+}
+
+// applyUnicodePwd emulates Active Directory password changes: the quoted UTF-16LE
+// values of unicodePwd are decoded and stored as the entry's clear text password.
+// Deleting the old value and adding a new one changes the password, replacing it resets the password.
+// New passwords shorter than MinPasswordLength are rejected and setting a password updates pwdLastSet.
+func (s *Server) applyUnicodePwd(n *node, operation uint, vals []string) error {
+	decoded := make([]string, len(vals))
+	for i, v := range vals {
+		pwd, err := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewDecoder().String(v)
+		if err != nil || len(pwd) < 2 || pwd[0] != '"' || pwd[len(pwd)-1] != '"' {
+			return ldap.NewError(ldap.LDAPResultConstraintViolation, errors.New("0000052D: unicodePwd must be a quoted UTF-16LE string"))
+		}
+		decoded[i] = pwd[1 : len(pwd)-1]
+	}
+
+	switch operation {
+	case ldap.DeleteAttribute:
+		for _, v := range decoded {
+			if !n.hasPassword(v) {
+				return ldap.NewError(ldap.LDAPResultConstraintViolation, errors.New("00000056: old password does not match"))
+			}
+		}
+		n.setValues(passwordAttribute, nil)
+	case ldap.AddAttribute, ldap.ReplaceAttribute:
+		for _, v := range decoded {
+			if len(v) < s.MinPasswordLength {
+				return ldap.NewError(ldap.LDAPResultConstraintViolation, errors.New("0000052D: Constraint violation - check password restrictions, data 0"))
+			}
+		}
+		n.setValues(passwordAttribute, decoded)
+		n.setValues("pwdLastSet", []string{ldap.FormatFileTime(time.Now())})
+	default:
+		return ldap.NewError(ldap.LDAPResultUnwillingToPerform, errors.New("unsupported unicodePwd operation"))
+	}
+	return nil
+}
+
+func removeValue(vals []string, value string) []string {
+	out := vals[:0:0]
+	for _, v := range vals {
+		if !strings.EqualFold(v, value) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// encodeAttribute returns the PartialAttribute packet for the given type and values.
+func encodeAttribute(attrType string, vals []string) *ber.Packet {
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+	seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, attrType, "Type"))
+	set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "AttributeValue")
+	for _, v := range vals {
+		set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "Vals"))
+	}
+	seq.AppendChild(set)
+	return seq
+}
+
+// decodeAttribute reads an Attribute or PartialAttribute packet.
+func decodeAttribute(p *ber.Packet) (ldap.PartialAttribute, error) {
+	if len(p.Children) < 2 {
+		return ldap.PartialAttribute{}, ldap.NewError(ldap.LDAPResultProtocolError, errInvalidRequest)
+	}
+	a := ldap.PartialAttribute{Type: decodeString(p.Children[0])}
+	for _, v := range p.Children[1].Children {
+		a.Vals = append(a.Vals, decodeString(v))
+	}
+	return a, nil
+}
+
+func (c *conn) del(op *ber.Packet) error {
+	dn := decodeString(op)
+	key, _, err := normalizeDN(dn)
+	if err != nil {
+		return ldap.NewError(ldap.LDAPResultInvalidDNSyntax, err)
+	}
+
+	s := c.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[key]; !ok {
+		return s.noSuchObject(key, dn)
+	}
+	for _, n := range s.entries {
+		if n.parent == key {
+			return ldap.NewError(ldap.LDAPResultNotAllowedOnNonLeaf, fmt.Errorf("entry %q has subordinates", dn))
+		}
+	}
+	delete(s.entries, key)
+	return nil
+}
+
+func (c *conn) modifyDN(op *ber.Packet) error {
+	if len(op.Children) < 3 {
+		return ldap.NewError(ldap.LDAPResultProtocolError, errInvalidRequest)
+	}
+	dn := decodeString(op.Children[0])
+	rdn, err := parseRDN(decodeString(op.Children[1]))
+	if err != nil {
+		return ldap.NewError(ldap.LDAPResultInvalidDNSyntax, err)
+	}
+	deleteOldRDN := op.Children[2].Value == true
+
+	key, parent, err := normalizeDN(dn)
+	if err != nil {
+		return ldap.NewError(ldap.LDAPResultInvalidDNSyntax, err)
+	}
+
+	s := c.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.entries[key]
+	if !ok {
+		return s.noSuchObject(key, dn)
+	}
+
+	parentDN := ""
+	if p, ok := s.entries[parent]; ok {
+		parentDN = p.dn
+	} else if parsed, err := ldap.ParseDN(n.dn); err == nil {
+		rdns := make([]string, 0, len(parsed.RDNs))
+		for _, r := range parsed.RDNs[1:] {
+			rdns = append(rdns, formatRDN(r))
+		}
+		parentDN = strings.Join(rdns, ",")
+	}
+	if len(op.Children) > 3 {
+		newSuperior := decodeString(op.Children[3])
+		newParent, _, err := normalizeDN(newSuperior)
+		if err != nil {
+			return ldap.NewError(ldap.LDAPResultInvalidDNSyntax, err)
+		}
+		p, ok := s.entries[newParent]
+		if !ok {
+			return s.noSuchObject(newParent, newSuperior)
+		}
+		if p.key == key || strings.HasSuffix(p.key, ","+key) {
+			return ldap.NewError(ldap.LDAPResultUnwillingToPerform, errors.New("cannot move an entry below itself"))
+		}
+		parentDN = p.dn
+	}
+
+	newDN := formatRDN(rdn)
+	if parentDN != "" {
+		newDN += "," + parentDN
+	}
+	newKey, newParentKey, err := normalizeDN(newDN)
+	if err != nil {
+		return ldap.NewError(ldap.LDAPResultInvalidDNSyntax, err)
+	}
+	if _, ok := s.entries[newKey]; ok && newKey != key {
+		return ldap.NewError(ldap.LDAPResultEntryAlreadyExists, fmt.Errorf("entry %q already exists", newDN))
+	}
+
+	updated := n.clone()
+	if deleteOldRDN {
+		old, _ := ldap.ParseDN(n.dn)
+		for _, ava := range old.RDNs[0].Attributes {
+			updated.setValues(ava.Type, removeValue(updated.values(ava.Type), ava.Value))
+		}
+	}
+	addRDNValues(updated, rdn)
+	updated.dn, updated.key, updated.parent = newDN, newKey, newParentKey
+	delete(s.entries, key)
+	s.entries[newKey] = updated
+
+	// move the subordinate entries along with the renamed entry
+	for k, child := range s.entries {
+		if !strings.HasSuffix(k, ","+key) {
+			continue
+		}
+		parsed, err := ldap.ParseDN(child.dn)
+		if err != nil {
+			continue
+		}
+		depth := strings.Count(k, ",") - strings.Count(key, ",")
+		rdns := make([]string, 0, depth+1)
+		for _, r := range parsed.RDNs[:depth] {
+			rdns = append(rdns, formatRDN(r))
+		}
+		moved := child.clone()
+		moved.dn = strings.Join(append(rdns, newDN), ",")
+		moved.key, moved.parent, _ = normalizeDN(moved.dn)
+		delete(s.entries, k)
+		s.entries[moved.key] = moved
+	}
+	return nil
+}
+
+func (c *conn) compare(op *ber.Packet) error {
+	if len(op.Children) < 2 || len(op.Children[1].Children) < 2 {
+		return ldap.NewError(ldap.LDAPResultProtocolError, errInvalidRequest)
+	}
+	dn := decodeString(op.Children[0])
+	key, _, err := normalizeDN(dn)
+	if err != nil {
+		return ldap.NewError(ldap.LDAPResultInvalidDNSyntax, err)
+	}
+	attr, value := decodeString(op.Children[1].Children[0]), decodeString(op.Children[1].Children[1])
+
+	s := c.server
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n, ok := s.entries[key]
+	if !ok {
+		return s.noSuchObject(key, dn)
+	}
+	if n.hasValue(attr, value) {
+		return ldap.NewError(ldap.LDAPResultCompareTrue, errors.New(""))
+	}
+	return ldap.NewError(ldap.LDAPResultCompareFalse, errors.New(""))
+}
+
+func (c *conn) extended(msgID int64, op *ber.Packet) {
+	name := ""
+	if len(op.Children) > 0 {
+		name = decodeString(op.Children[0])
+	}
+	if name != passwordModifyOID {
+		c.writeResult(msgID, ldap.ApplicationExtendedResponse,
+			ldap.NewError(ldap.LDAPResultProtocolError, fmt.Errorf("unsupported extended operation %q", name)))
+		return
+	}
+
+	var identity, oldPassword, newPassword string
+	if len(op.Children) > 1 {
+		value, err := ber.DecodePacketErr(op.Children[1].Data.Bytes())
+		if err != nil {
+			c.writeResult(msgID, ldap.ApplicationExtendedResponse, ldap.NewError(ldap.LDAPResultProtocolError, err))
+			return
+		}
+		for _, child := range value.Children {
+			switch child.Tag {
+			case 0:
+				identity = decodeString(child)
+			case 1:
+				oldPassword = decodeString(child)
+			case 2:
+				newPassword = decodeString(child)
+			}
+		}
+	}
+
+	generated := newPassword == ""
+	if generated {
+		b := make([]byte, 8)
+		rand.Read(b)
+		newPassword = hex.EncodeToString(b)
+	}
+	if err := c.server.passwordModify(c.boundDN, identity, oldPassword, newPassword); err != nil {
+		c.writeResult(msgID, ldap.ApplicationExtendedResponse, err)
+		return
+	}
+	if !generated {
+		c.writeResult(msgID, ldap.ApplicationExtendedResponse, nil)
+		return
+	}
+
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Password Modify Response")
+	seq.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, newPassword, "Generated Password"))
+	value := ber.Encode(ber.ClassContext, ber.TypePrimitive, 11, nil, "Response Value")
+	value.Data.Write(seq.Bytes())
+	c.writeResult(msgID, ldap.ApplicationExtendedResponse, nil, value)
+}
+
+// passwordModify changes the password of the entry named by identity, or of the bound entry when empty.
+func (s *Server) passwordModify(boundDN, identity, oldPassword, newPassword string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n *node
+	switch {
+	case identity != "":
+		n = s.bindEntry(strings.TrimPrefix(identity, "dn:"))
+	case boundDN != "":
+		n = s.entries[boundDN]
+	default:
+		return ldap.NewError(ldap.LDAPResultUnwillingToPerform, errors.New("anonymous password modify is not allowed"))
+	}
+	if n == nil {
+		return ldap.NewError(ldap.LDAPResultNoSuchObject, fmt.Errorf("no such user %q", identity))
+	}
+	if oldPassword != "" && !n.hasPassword(oldPassword) {
+		return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("old password does not match"))
+	}
+	updated := n.clone()
+	updated.setValues(passwordAttribute, []string{newPassword})
+	s.entries[n.key] = updated
+	return nil
+}
@@ -0,0 +1,366 @@
+// Package ldaptest provides an in-memory LDAP v3 server for tests.
+//
+// The server keeps its directory in memory and understands bind, search
+// (filters, scopes, size limits and the paged results control), add, modify,
+// delete, modify DN, compare and the password modify extended operation.
+// It emulates a few Active Directory behaviours used by the auth package:
+// binding with a userPrincipalName, the logon failure codes of disabled, locked
+// and expired accounts, changing passwords through unicodePwd, the tokenGroups
+// constructed attribute and the LDAP_MATCHING_RULE_IN_CHAIN and bitwise
+// matching rules. There is no
+// access control: every bound or anonymous client may read and write.
+package ldaptest
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/ThomasNguyenGitHub/go/ldap"
+	"github.com/ThomasNguyenGitHub/go/ldap/ldif"
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// Server is an LDAP server listening on a system-chosen port on the local
+// loopback interface, for use in end-to-end tests.
+type Server struct {
+	// URL is the ldap:// address of the server, suitable for ldap.DialURL
+	URL string
+	// Listener accepts the client connections
+	Listener net.Listener
+	// MinPasswordLength is the minimum length of passwords set through unicodePwd
+	MinPasswordLength int
+
+	mu      sync.RWMutex
+	entries map[string]*node
+	seq     uint64
+	cursors map[string]*cursor
+	cookies uint64
+
+	connsMu sync.Mutex
+	conns   map[net.Conn]struct{}
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// NewServer starts and returns a new Server with an empty directory.
+// The caller should call Close when finished, to shut it down.
+func NewServer() *Server {
+	s := NewUnstartedServer()
+	s.Start()
+	return s
+}
+
+// NewUnstartedServer returns a new Server but doesn't start it.
+// After seeding the directory, the caller should call Start.
+func NewUnstartedServer() *Server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("ldaptest: failed to listen on a port: %v", err))
+	}
+	return &Server{
+		Listener: l,
+		entries:  map[string]*node{},
+		cursors:  map[string]*cursor{},
+		conns:    map[net.Conn]struct{}{},
+	}
+}
+
+// Start starts the server accepting connections.
+func (s *Server) Start() {
+	if s.URL != "" {
+		panic("ldaptest: Server already started")
+	}
+	s.URL = "ldap://" + s.Listener.Addr().String()
+	s.wg.Add(1)
+	go s.serve()
+}
+
+// Host returns the host the server listens on.
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Listener.Addr().String())
+	return host
+}
+
+// Port returns the port the server listens on.
+func (s *Server) Port() int {
+	_, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return p
+}
+
+// Close shuts down the server and closes all client connections.
+func (s *Server) Close() {
+	s.connsMu.Lock()
+	if s.closed {
+		s.connsMu.Unlock()
+		return
+	}
+	s.closed = true
+	s.Listener.Close()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.connsMu.Unlock()
+	s.wg.Wait()
+}
+
+// AddEntry stores an entry in the directory. Unlike an LDAP add request the
+// parent entry does not need to exist, which allows seeding naming contexts.
+func (s *Server) AddEntry(dn string, attributes map[string][]string) error {
+	e := ldap.NewEntry(dn, attributes)
+	return s.addEntry(e.DN, e.Attributes, false)
+}
+
+// Entry returns a copy of the entry with the given DN, or nil if it does not exist.
+func (s *Server) Entry(dn string) *ldap.Entry {
+	key, _, err := normalizeDN(dn)
+	if err != nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	return n.entry(nil, false)
+}
+
+// LoadLDIF adds the entries read from r to the directory. The LDIF may hold
+// content records or change records of type add.
+func (s *Server) LoadLDIF(r io.Reader) error {
+	records, err := ldif.Parse(r)
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		e := rec.Entry
+		if e == nil {
+			if rec.Add == nil {
+				return fmt.Errorf("ldaptest: unsupported change record for %s", rec.DN())
+			}
+			e = &ldap.Entry{DN: rec.Add.DN}
+			for _, attr := range rec.Add.Attributes {
+				e.Attributes = append(e.Attributes, ldap.NewEntryAttribute(attr.Type, attr.Vals))
+			}
+		}
+		if err := s.addEntry(e.DN, e.Attributes, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addEntry stores a new entry. When strict is set the parent entry must exist.
+func (s *Server) addEntry(dn string, attrs []*ldap.EntryAttribute, strict bool) error {
+	key, parent, err := normalizeDN(dn)
+	if err != nil || key == "" {
+		return ldap.NewError(ldap.LDAPResultInvalidDNSyntax, fmt.Errorf("invalid DN %q", dn))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[key]; ok {
+		return ldap.NewError(ldap.LDAPResultEntryAlreadyExists, fmt.Errorf("entry %q already exists", dn))
+	}
+	if _, ok := s.entries[parent]; strict && parent != "" && !ok {
+		return s.noSuchObject(parent, dn)
+	}
+
+	s.seq++
+	n := &node{dn: dn, key: key, parent: parent, seq: s.seq}
+	for _, a := range attrs {
+		n.setValues(a.Name, append(n.values(a.Name), a.Values...))
+	}
+	// the naming attributes are always present on the entry
+	parsed, _ := ldap.ParseDN(dn)
+	addRDNValues(n, parsed.RDNs[0])
+	s.entries[key] = n
+	return nil
+}
+
+// addRDNValues adds the attribute values of rdn to the entry unless already present.
+func addRDNValues(n *node, rdn *ldap.RelativeDN) {
+	for _, ava := range rdn.Attributes {
+		if !n.hasValue(ava.Type, ava.Value) {
+			n.setValues(ava.Type, append(n.values(ava.Type), ava.Value))
+		}
+	}
+}
+
+// lookup returns the entry with the given normalized DN. The caller must hold s.mu.
+func (s *Server) lookup(key string) *node {
+	return s.entries[key]
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.Listener.Accept()
+		if err != nil {
+			return
+		}
+		s.connsMu.Lock()
+		if s.closed {
+			s.connsMu.Unlock()
+			c.Close()
+			return
+		}
+		s.conns[c] = struct{}{}
+		s.connsMu.Unlock()
+
+		s.wg.Add(1)
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(nc net.Conn) {
+	defer s.wg.Done()
+	c := &conn{server: s, nc: nc}
+	defer func() {
+		nc.Close()
+		s.connsMu.Lock()
+		delete(s.conns, nc)
+		s.connsMu.Unlock()
+		s.dropCursors(c)
+	}()
+
+	for {
+		packet, err := ber.ReadPacket(nc)
+		if err != nil {
+			return
+		}
+		if !c.dispatch(packet) {
+			return
+		}
+	}
+}
+
+// conn holds the state of a single client connection.
+type conn struct {
+	server *Server
+	nc     net.Conn
+	// boundDN is the normalized DN of the bound entry, empty when anonymous
+	boundDN string
+}
+
+// dispatch handles one request and reports whether the connection should stay open.
+func (c *conn) dispatch(packet *ber.Packet) bool {
+	if len(packet.Children) < 2 {
+		return false
+	}
+	msgID, ok := packet.Children[0].Value.(int64)
+	if !ok {
+		return false
+	}
+	op := packet.Children[1]
+
+	var controls []ldap.Control
+	if len(packet.Children) == 3 {
+		for _, child := range packet.Children[2].Children {
+			control, err := ldap.DecodeControl(child)
+			if err != nil {
+				c.writeResult(msgID, responseTag(op.Tag), ldap.NewError(ldap.LDAPResultProtocolError, err))
+				return true
+			}
+			controls = append(controls, control)
+		}
+	}
+	if err := checkCriticalControls(controls); err != nil {
+		c.writeResult(msgID, responseTag(op.Tag), err)
+		return true
+	}
+
+	switch op.Tag {
+	case ldap.ApplicationUnbindRequest:
+		return false
+	case ldap.ApplicationAbandonRequest:
+		// requests are answered synchronously, there is nothing to abandon
+	case ldap.ApplicationBindRequest:
+		c.writeResult(msgID, ldap.ApplicationBindResponse, c.bind(op))
+	case ldap.ApplicationSearchRequest:
+		c.search(msgID, op, controls)
+	case ldap.ApplicationAddRequest:
+		c.writeResult(msgID, ldap.ApplicationAddResponse, c.add(op))
+	case ldap.ApplicationModifyRequest:
+		c.writeResult(msgID, ldap.ApplicationModifyResponse, c.modify(op))
+	case ldap.ApplicationDelRequest:
+		c.writeResult(msgID, ldap.ApplicationDelResponse, c.del(op))
+	case ldap.ApplicationModifyDNRequest:
+		c.writeResult(msgID, ldap.ApplicationModifyDNResponse, c.modifyDN(op))
+	case ldap.ApplicationCompareRequest:
+		c.writeResult(msgID, ldap.ApplicationCompareResponse, c.compare(op))
+	case ldap.ApplicationExtendedRequest:
+		c.extended(msgID, op)
+	default:
+		c.writeResult(msgID, ldap.ApplicationExtendedResponse,
+			ldap.NewError(ldap.LDAPResultProtocolError, fmt.Errorf("unsupported operation %d", op.Tag)))
+	}
+	return true
+}
+
+// checkCriticalControls rejects requests carrying critical controls the server does not implement.
+func checkCriticalControls(controls []ldap.Control) error {
+	for _, control := range controls {
+		if c, ok := control.(*ldap.ControlString); ok && c.Criticality {
+			return ldap.NewError(ldap.LDAPResultUnavailableCriticalExtension,
+				fmt.Errorf("unsupported critical control %s", c.ControlType))
+		}
+	}
+	return nil
+}
+
+// responseTag returns the response application tag for a request tag.
+func responseTag(request ber.Tag) ber.Tag {
+	switch request {
+	case ldap.ApplicationSearchRequest:
+		return ldap.ApplicationSearchResultDone
+	case ldap.ApplicationExtendedRequest:
+		return ldap.ApplicationExtendedResponse
+	default:
+		return request + 1
+	}
+}
+
+// writeResult sends an LDAPResult for err, which is a success when err is nil.
+func (c *conn) writeResult(msgID int64, tag ber.Tag, err error, extra ...*ber.Packet) {
+	c.writeResultControls(msgID, tag, err, nil, extra...)
+}
+
+// writeResultControls sends an LDAPResult for err followed by the given response controls.
+func (c *conn) writeResultControls(msgID int64, tag ber.Tag, err error, controls []ldap.Control, extra ...*ber.Packet) {
+	code, matchedDN, message := uint16(ldap.LDAPResultSuccess), "", ""
+	if err != nil {
+		code, message = ldap.LDAPResultOther, err.Error()
+		if e, ok := err.(*ldap.Error); ok {
+			code, matchedDN, message = e.ResultCode, e.MatchedDN, e.Err.Error()
+		}
+	}
+
+	pkt := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, ldap.ApplicationMap[uint8(tag)])
+	pkt.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	pkt.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, matchedDN, "Matched DN"))
+	pkt.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, message, "Diagnostic Message"))
+	for _, p := range extra {
+		pkt.AppendChild(p)
+	}
+	c.write(msgID, pkt, controls)
+}
+
+// write sends a protocol operation in an LDAPMessage envelope.
+func (c *conn) write(msgID int64, op *ber.Packet, controls []ldap.Control) {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, msgID, "MessageID"))
+	envelope.AppendChild(op)
+	if len(controls) > 0 {
+		packet := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
+		for _, control := range controls {
+			packet.AppendChild(control.Encode())
+		}
+		envelope.AppendChild(packet)
+	}
+	c.nc.Write(envelope.Bytes())
+}
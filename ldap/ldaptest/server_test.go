@@ -0,0 +1,311 @@
+package ldaptest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ThomasNguyenGitHub/go/ldap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const testLDIF = `version: 1
+
+# naming context
+dn: dc=example,dc=com
+objectClass: top
+objectClass: domain
+dc: example
+
+dn: ou=Users,dc=example,dc=com
+objectClass: organizationalUnit
+ou: Users
+
+dn: cn=admin,dc=example,dc=com
+objectClass: person
+cn: admin
+userPassword: secret
+
+dn: cn=John Doe,ou=Users,dc=example,dc=com
+objectClass: person
+objectClass: user
+cn: John Doe
+sn: Doe
+userPrincipalName: jdoe@example.com
+userAccountControl: 66048
+userPassword: Password1!
+description:: Sm9obiBEb2UncyBhY2NvdW50
+mail: jdoe@exam
+ ple.com
+
+dn: cn=Jane Roe,ou=Users,dc=example,dc=com
+objectClass: person
+objectClass: user
+cn: Jane Roe
+sn: Roe
+userPrincipalName: jroe@example.com
+userAccountControl: 514
+
+dn: cn=Staff,dc=example,dc=com
+objectClass: group
+cn: Staff
+member: cn=Admins,dc=example,dc=com
+
+dn: cn=Admins,dc=example,dc=com
+objectClass: group
+cn: Admins
+member: cn=John Doe,ou=Users,dc=example,dc=com
+`
+
+func newTestServer(t *testing.T) (*Server, *ldap.Conn) {
+	t.Helper()
+	s := NewUnstartedServer()
+	if err := s.LoadLDIF(strings.NewReader(testLDIF)); err != nil {
+		s.Close()
+		t.Fatalf("LoadLDIF: %v", err)
+	}
+	s.Start()
+	l, err := ldap.DialURL(s.URL)
+	if err != nil {
+		s.Close()
+		t.Fatalf("DialURL: %v", err)
+	}
+	t.Cleanup(func() {
+		l.Close()
+		s.Close()
+	})
+	return s, l
+}
+
+func searchDNs(t *testing.T, l *ldap.Conn, base string, scope int, filter string) []string {
+	t.Helper()
+	sr, err := l.Search(ldap.NewSearchRequest(base, scope, ldap.NeverDerefAliases, 0, 0, false, filter, []string{"cn"}, nil))
+	if err != nil {
+		t.Fatalf("Search %s: %v", filter, err)
+	}
+	dns := []string{}
+	for _, e := range sr.Entries {
+		dns = append(dns, e.DN)
+	}
+	return dns
+}
+
+func TestBind(t *testing.T) {
+	_, l := newTestServer(t)
+
+	if err := l.Bind("cn=admin,dc=example,dc=com", "secret"); err != nil {
+		t.Errorf("bind with DN: %v", err)
+	}
+	if err := l.Bind("jdoe@example.com", "Password1!"); err != nil {
+		t.Errorf("bind with userPrincipalName: %v", err)
+	}
+	if err := l.Bind("cn=admin,dc=example,dc=com", "wrong"); !ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		t.Errorf("expected invalid credentials, got %v", err)
+	}
+	if err := l.Bind("cn=nobody,dc=example,dc=com", "secret"); !ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		t.Errorf("expected invalid credentials, got %v", err)
+	}
+}
+
+func TestSearchFiltersAndScopes(t *testing.T) {
+	_, l := newTestServer(t)
+
+	john := "cn=John Doe,ou=Users,dc=example,dc=com"
+	jane := "cn=Jane Roe,ou=Users,dc=example,dc=com"
+	tests := []struct {
+		base   string
+		scope  int
+		filter string
+		want   []string
+	}{
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(objectClass=person)", []string{"cn=admin,dc=example,dc=com", john, jane}},
+		{"dc=example,dc=com", ldap.ScopeSingleLevel, "(objectClass=*)", []string{"ou=Users,dc=example,dc=com", "cn=admin,dc=example,dc=com", "cn=Staff,dc=example,dc=com", "cn=Admins,dc=example,dc=com"}},
+		{"OU=users,DC=Example,DC=com", ldap.ScopeBaseObject, "(objectClass=*)", []string{"ou=Users,dc=example,dc=com"}},
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(&(objectClass=user)(!(sn=Doe)))", []string{jane}},
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(|(sn=roe)(mail=jdoe@example.com))", []string{john, jane}},
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(cn=J*n* *oe)", []string{john, jane}},
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(userAccountControl>=1000)", []string{john}},
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(userAccountControl:1.2.840.113556.1.4.803:=2)", []string{jane}},
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(description=John Doe's account)", []string{john}},
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, fmt.Sprintf("(member:%s:=%s)", MatchingRuleInChain, john), []string{"cn=Staff,dc=example,dc=com", "cn=Admins,dc=example,dc=com"}},
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(ou:dn:=Users)", []string{"ou=Users,dc=example,dc=com", john, jane}},
+	}
+	for _, tt := range tests {
+		if got := searchDNs(t, l, tt.base, tt.scope, tt.filter); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+
+	_, err := l.Search(ldap.NewSearchRequest("ou=missing,dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	if e, ok := err.(*ldap.Error); !ok || e.ResultCode != ldap.LDAPResultNoSuchObject || e.MatchedDN != "dc=example,dc=com" {
+		t.Errorf("expected no such object with matched DN, got %v", err)
+	}
+}
+
+func TestTokenGroups(t *testing.T) {
+	_, l := newTestServer(t)
+	john := "cn=John Doe,ou=Users,dc=example,dc=com"
+	for i, dn := range []string{john, "cn=Admins,dc=example,dc=com", "cn=Staff,dc=example,dc=com"} {
+		req := ldap.NewModifyRequest(dn, nil)
+		req.Add("objectSid", []string{fmt.Sprintf("S-1-5-21-%d", i)})
+		if err := l.Modify(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tokenGroups := func(scope int) []string {
+		sr, err := l.Search(ldap.NewSearchRequest(john, scope, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"tokenGroups"}, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sr.Entries[0].GetAttributeValues("tokenGroups")
+	}
+	if got, want := tokenGroups(ldap.ScopeBaseObject), []string{"S-1-5-21-2", "S-1-5-21-1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got tokenGroups %v, want %v", got, want)
+	}
+	if got := tokenGroups(ldap.ScopeWholeSubtree); len(got) != 0 {
+		t.Errorf("expected no tokenGroups for a subtree search, got %v", got)
+	}
+}
+
+func TestSearchAttributesAndLimits(t *testing.T) {
+	_, l := newTestServer(t)
+
+	sr, err := l.Search(ldap.NewSearchRequest("dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		"(userPrincipalName=jdoe@example.com)", []string{"sn", "MAIL"}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sr.Entries) != 1 || len(sr.Entries[0].Attributes) != 2 || sr.Entries[0].GetAttributeValue("mail") != "jdoe@example.com" {
+		t.Errorf("unexpected entries %v", sr.Entries)
+	}
+
+	_, err = l.Search(ldap.NewSearchRequest("dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 1, 0, false,
+		"(objectClass=user)", nil, nil))
+	if !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		t.Errorf("expected size limit exceeded, got %v", err)
+	}
+
+	sr, err = l.SearchWithPaging(ldap.NewSearchRequest("dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"1.1"}, nil), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sr.Entries) != 7 {
+		t.Errorf("paged search returned %d entries, want 7", len(sr.Entries))
+	}
+
+	sr, err = l.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"namingContexts"}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sr.Entries[0].GetAttributeValues("namingContexts"); !reflect.DeepEqual(got, []string{"dc=example,dc=com"}) {
+		t.Errorf("namingContexts = %v", got)
+	}
+}
+
+func TestUpdateOperations(t *testing.T) {
+	s, l := newTestServer(t)
+
+	add := ldap.NewAddRequest("cn=New User,ou=Users,dc=example,dc=com", nil)
+	add.Attribute("objectClass", []string{"person"})
+	add.Attribute("sn", []string{"User"})
+	if err := l.Add(add); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := l.Add(add); !ldap.IsErrorWithCode(err, ldap.LDAPResultEntryAlreadyExists) {
+		t.Errorf("expected entry already exists, got %v", err)
+	}
+	orphan := ldap.NewAddRequest("cn=Orphan,ou=Missing,dc=example,dc=com", nil)
+	if err := l.Add(orphan); !ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+		t.Errorf("expected no such object, got %v", err)
+	}
+
+	modify := ldap.NewModifyRequest("cn=New User,ou=Users,dc=example,dc=com", nil)
+	modify.Add("mail", []string{"new@example.com"})
+	modify.Replace("sn", []string{"Changed"})
+	modify.Add("uidNumber", []string{"1000"})
+	modify.Increment("uidNumber", "5")
+	if err := l.Modify(modify); err != nil {
+		t.Fatalf("Modify: %v", err)
+	}
+	e := s.Entry("cn=new user,ou=users,dc=example,dc=com")
+	if e.GetAttributeValue("sn") != "Changed" || e.GetAttributeValue("mail") != "new@example.com" || e.GetAttributeValue("uidNumber") != "1005" {
+		t.Errorf("unexpected entry after modify: %+v", e)
+	}
+
+	failing := ldap.NewModifyRequest("cn=New User,ou=Users,dc=example,dc=com", nil)
+	failing.Replace("sn", []string{"Ignored"})
+	failing.Delete("telephoneNumber", nil)
+	if err := l.Modify(failing); !ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchAttribute) {
+		t.Errorf("expected no such attribute, got %v", err)
+	}
+	if got := s.Entry("cn=New User,ou=Users,dc=example,dc=com").GetAttributeValue("sn"); got != "Changed" {
+		t.Errorf("failed modify changed sn to %q", got)
+	}
+
+	if err := l.ModifyDN(ldap.NewModifyDNRequest("ou=Users,dc=example,dc=com", "ou=People", true, "")); err != nil {
+		t.Fatalf("ModifyDN: %v", err)
+	}
+	if s.Entry("cn=New User,ou=People,dc=example,dc=com") == nil {
+		t.Error("subordinate entry was not moved")
+	}
+	if got := s.Entry("ou=People,dc=example,dc=com").GetAttributeValues("ou"); !reflect.DeepEqual(got, []string{"People"}) {
+		t.Errorf("ou = %v, want [People]", got)
+	}
+
+	if err := l.Del(ldap.NewDelRequest("ou=People,dc=example,dc=com", nil)); !ldap.IsErrorWithCode(err, ldap.LDAPResultNotAllowedOnNonLeaf) {
+		t.Errorf("expected not allowed on non leaf, got %v", err)
+	}
+	if err := l.Del(ldap.NewDelRequest("cn=New User,ou=People,dc=example,dc=com", nil)); err != nil {
+		t.Fatalf("Del: %v", err)
+	}
+	if s.Entry("cn=New User,ou=People,dc=example,dc=com") != nil {
+		t.Error("entry was not deleted")
+	}
+
+	ok, err := l.Compare("cn=Jane Roe,ou=People,dc=example,dc=com", "sn", "roe")
+	if err != nil || !ok {
+		t.Errorf("Compare = %v, %v", ok, err)
+	}
+}
+
+func TestPasswordChanges(t *testing.T) {
+	_, l := newTestServer(t)
+
+	if err := l.Bind("jdoe@example.com", "Password1!"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.PasswordModify(ldap.NewPasswordModifyRequest("", "Password1!", "Password2!")); err != nil {
+		t.Fatalf("PasswordModify: %v", err)
+	}
+	result, err := l.PasswordModify(ldap.NewPasswordModifyRequest("cn=admin,dc=example,dc=com", "", ""))
+	if err != nil || result.GeneratedPassword == "" {
+		t.Fatalf("PasswordModify with generated password = %+v, %v", result, err)
+	}
+	if err := l.Bind("cn=admin,dc=example,dc=com", result.GeneratedPassword); err != nil {
+		t.Errorf("bind with generated password: %v", err)
+	}
+
+	encode := func(password string) string {
+		encoded, _ := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder().String(`"` + password + `"`)
+		return encoded
+	}
+	change := ldap.NewModifyRequest("cn=John Doe,ou=Users,dc=example,dc=com", nil)
+	change.Delete("unicodePwd", []string{encode("wrong")})
+	change.Add("unicodePwd", []string{encode("Password3!")})
+	if err := l.Modify(change); !ldap.IsErrorWithCode(err, ldap.LDAPResultConstraintViolation) {
+		t.Errorf("expected constraint violation, got %v", err)
+	}
+	change = ldap.NewModifyRequest("cn=John Doe,ou=Users,dc=example,dc=com", nil)
+	change.Delete("unicodePwd", []string{encode("Password2!")})
+	change.Add("unicodePwd", []string{encode("Password3!")})
+	if err := l.Modify(change); err != nil {
+		t.Fatalf("unicodePwd change: %v", err)
+	}
+	if err := l.Bind("jdoe@example.com", "Password3!"); err != nil {
+		t.Errorf("bind with changed password: %v", err)
+	}
+}
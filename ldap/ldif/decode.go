@@ -0,0 +1,400 @@
+package ldif
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ThomasNguyenGitHub/go/ldap"
+)
+
+// maxLineLength bounds the length of a single unfolded line, large enough for inline photos
+const maxLineLength = 16 << 20
+
+// ParseError reports a malformed LDIF record
+type ParseError struct {
+	// Line is the line number the error was found at
+	Line int
+	// Err is the underlying error
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("ldif: line %d: %v", e.Line, e.Err)
+}
+
+// line is a logical line, with continuation lines unfolded
+type line struct {
+	text string
+	no   int
+}
+
+// Decoder reads LDIF records from an input stream.
+type Decoder struct {
+	// ReadURL returns the content of a value given as a URL ("jpegPhoto:< file:///photo.jpg").
+	// URL values are rejected with ErrURLValue when nil, the default, as reading them from
+	// untrusted input would disclose local files. Set it to ReadFileURL to read file URLs.
+	ReadURL func(u *url.URL) ([]byte, error)
+
+	scanner *bufio.Scanner
+	lineNo  int
+	started bool
+	change  *bool
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineLength)
+	return &Decoder{scanner: scanner}
+}
+
+// Parse reads all records from r.
+func Parse(r io.Reader) ([]*Record, error) {
+	d := NewDecoder(r)
+	var records []*Record
+	for {
+		rec, err := d.Decode()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+}
+
+// ParseString reads all records from the given LDIF content.
+func ParseString(s string) ([]*Record, error) {
+	return Parse(strings.NewReader(s))
+}
+
+// Decode returns the next record, or io.EOF when there are no more records.
+func (d *Decoder) Decode() (*Record, error) {
+	lines, err := d.readRecord()
+	if err != nil {
+		return nil, err
+	}
+	if !d.started {
+		d.started = true
+		if strings.HasPrefix(strings.ToLower(lines[0].text), "version:") {
+			if v := strings.TrimSpace(lines[0].text[len("version:"):]); v != "1" {
+				return nil, &ParseError{Line: lines[0].no, Err: fmt.Errorf("unsupported version %q", v)}
+			}
+			if lines = lines[1:]; len(lines) == 0 {
+				return d.Decode()
+			}
+		}
+	}
+
+	rec, err := d.parseRecord(lines)
+	if err != nil {
+		return nil, err
+	}
+	if d.change == nil {
+		change := rec.IsChange()
+		d.change = &change
+	} else if *d.change != rec.IsChange() {
+		return nil, &ParseError{Line: lines[0].no, Err: ErrMixedRecords}
+	}
+	return rec, nil
+}
+
+// readRecord returns the unfolded lines of the next record, skipping comments.
+func (d *Decoder) readRecord() ([]line, error) {
+	var lines []line
+	comment := false
+	for d.scanner.Scan() {
+		d.lineNo++
+		text := strings.TrimSuffix(d.scanner.Text(), "\r")
+		switch {
+		case text == "":
+			if len(lines) > 0 {
+				return lines, nil
+			}
+			comment = false
+		case text[0] == ' ':
+			if comment {
+				continue
+			}
+			if len(lines) == 0 {
+				return nil, &ParseError{Line: d.lineNo, Err: errors.New("unexpected continuation line")}
+			}
+			lines[len(lines)-1].text += text[1:]
+		case text[0] == '#':
+			comment = true
+		default:
+			comment = false
+			lines = append(lines, line{text: text, no: d.lineNo})
+		}
+	}
+	if err := d.scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, io.EOF
+	}
+	return lines, nil
+}
+
+func (d *Decoder) parseRecord(lines []line) (*Record, error) {
+	name, value, err := d.parseLine(lines[0])
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(name, "dn") {
+		return nil, &ParseError{Line: lines[0].no, Err: errors.New("record does not start with dn")}
+	}
+	dn, dnLine := string(value), lines[0].no
+	lines = lines[1:]
+
+	var controls []ldap.Control
+	for len(lines) > 0 && strings.HasPrefix(strings.ToLower(lines[0].text), "control:") {
+		control, err := d.parseControl(lines[0])
+		if err != nil {
+			return nil, err
+		}
+		controls = append(controls, control)
+		lines = lines[1:]
+	}
+
+	changeType, changeTypeLine := "", dnLine
+	if len(lines) > 0 {
+		name, value, err := d.parseLine(lines[0])
+		if err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(name, "changetype") {
+			changeType, changeTypeLine = strings.ToLower(string(value)), lines[0].no
+			lines = lines[1:]
+		}
+	}
+	if changeType == "" && len(controls) > 0 {
+		return nil, &ParseError{Line: dnLine, Err: errors.New("controls are only allowed in change records")}
+	}
+
+	switch changeType {
+	case "":
+		attrs, err := d.parseAttributes(lines)
+		if err != nil {
+			return nil, err
+		}
+		return &Record{Entry: &ldap.Entry{DN: dn, Attributes: attrs}}, nil
+	case ChangeTypeAdd:
+		attrs, err := d.parseAttributes(lines)
+		if err != nil {
+			return nil, err
+		}
+		req := ldap.NewAddRequest(dn, controls)
+		for _, attr := range attrs {
+			req.Attribute(attr.Name, attr.Values)
+		}
+		return &Record{Add: req}, nil
+	case ChangeTypeDelete:
+		if len(lines) > 0 {
+			return nil, &ParseError{Line: lines[0].no, Err: errors.New("unexpected line in delete record")}
+		}
+		return &Record{Del: ldap.NewDelRequest(dn, controls)}, nil
+	case ChangeTypeModify:
+		req, err := d.parseModify(dn, controls, lines)
+		if err != nil {
+			return nil, err
+		}
+		return &Record{Modify: req}, nil
+	case ChangeTypeModDN, ChangeTypeModRDN:
+		req, err := d.parseModifyDN(dn, lines)
+		if err != nil {
+			return nil, err
+		}
+		return &Record{ModifyDN: req}, nil
+	default:
+		return nil, &ParseError{Line: changeTypeLine, Err: fmt.Errorf("unknown changetype %q", changeType)}
+	}
+}
+
+// parseAttributes collects attribute values, merging repeated attribute descriptions.
+func (d *Decoder) parseAttributes(lines []line) ([]*ldap.EntryAttribute, error) {
+	var attrs []*ldap.EntryAttribute
+	index := map[string]*ldap.EntryAttribute{}
+	for _, l := range lines {
+		name, value, err := d.parseLine(l)
+		if err != nil {
+			return nil, err
+		}
+		key := strings.ToLower(name)
+		attr, ok := index[key]
+		if !ok {
+			attr = &ldap.EntryAttribute{Name: name}
+			index[key] = attr
+			attrs = append(attrs, attr)
+		}
+		attr.Values = append(attr.Values, string(value))
+		attr.ByteValues = append(attr.ByteValues, value)
+	}
+	return attrs, nil
+}
+
+func (d *Decoder) parseModify(dn string, controls []ldap.Control, lines []line) (*ldap.ModifyRequest, error) {
+	req := ldap.NewModifyRequest(dn, controls)
+	for len(lines) > 0 {
+		op, attr, err := d.parseLine(lines[0])
+		if err != nil {
+			return nil, err
+		}
+		start := lines[0].no
+		lines = lines[1:]
+
+		var vals []string
+		for len(lines) > 0 && lines[0].text != "-" {
+			name, value, err := d.parseLine(lines[0])
+			if err != nil {
+				return nil, err
+			}
+			if !strings.EqualFold(name, string(attr)) {
+				return nil, &ParseError{Line: lines[0].no, Err: fmt.Errorf("expected a value of %s, got %s", attr, name)}
+			}
+			vals = append(vals, string(value))
+			lines = lines[1:]
+		}
+		if len(lines) > 0 {
+			// skip the "-" separator
+			lines = lines[1:]
+		}
+
+		switch strings.ToLower(op) {
+		case "add":
+			req.Add(string(attr), vals)
+		case "delete":
+			req.Delete(string(attr), vals)
+		case "replace":
+			req.Replace(string(attr), vals)
+		case "increment":
+			if len(vals) != 1 {
+				return nil, &ParseError{Line: start, Err: errors.New("increment requires exactly one value")}
+			}
+			req.Increment(string(attr), vals[0])
+		default:
+			return nil, &ParseError{Line: start, Err: fmt.Errorf("unknown modify operation %q", op)}
+		}
+	}
+	return req, nil
+}
+
+func (d *Decoder) parseModifyDN(dn string, lines []line) (*ldap.ModifyDNRequest, error) {
+	req := &ldap.ModifyDNRequest{DN: dn}
+	seen := map[string]bool{}
+	for _, l := range lines {
+		name, value, err := d.parseLine(l)
+		if err != nil {
+			return nil, err
+		}
+		name = strings.ToLower(name)
+		switch name {
+		case "newrdn":
+			req.NewRDN = string(value)
+		case "deleteoldrdn":
+			switch string(value) {
+			case "0":
+				req.DeleteOldRDN = false
+			case "1":
+				req.DeleteOldRDN = true
+			default:
+				return nil, &ParseError{Line: l.no, Err: fmt.Errorf("invalid deleteoldrdn value %q", value)}
+			}
+		case "newsuperior":
+			req.NewSuperior = string(value)
+		default:
+			return nil, &ParseError{Line: l.no, Err: fmt.Errorf("unexpected %s in moddn record", name)}
+		}
+		seen[name] = true
+	}
+	if !seen["newrdn"] || !seen["deleteoldrdn"] {
+		return nil, &ParseError{Line: d.lineNo, Err: errors.New("moddn record requires newrdn and deleteoldrdn")}
+	}
+	return req, nil
+}
+
+// parseControl parses "control: oid [criticality] [value-spec]".
+func (d *Decoder) parseControl(l line) (ldap.Control, error) {
+	spec := strings.TrimLeft(l.text[len("control:"):], " ")
+	var value []byte
+	if i := strings.IndexByte(spec, ':'); i >= 0 {
+		var err error
+		if value, err = d.parseValue(l, spec[i+1:]); err != nil {
+			return nil, err
+		}
+		spec = spec[:i]
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, &ParseError{Line: l.no, Err: errors.New("invalid control")}
+	}
+	criticality := false
+	if len(fields) == 2 {
+		switch fields[1] {
+		case "true":
+			criticality = true
+		case "false":
+		default:
+			return nil, &ParseError{Line: l.no, Err: fmt.Errorf("invalid control criticality %q", fields[1])}
+		}
+	}
+	return ldap.NewControlString(fields[0], criticality, string(value)), nil
+}
+
+// parseLine splits an attrval-spec into the attribute description and its value.
+func (d *Decoder) parseLine(l line) (string, []byte, error) {
+	i := strings.IndexByte(l.text, ':')
+	if i <= 0 {
+		return "", nil, &ParseError{Line: l.no, Err: errors.New("missing attribute separator")}
+	}
+	value, err := d.parseValue(l, l.text[i+1:])
+	if err != nil {
+		return "", nil, err
+	}
+	return l.text[:i], value, nil
+}
+
+// parseValue decodes a value-spec: ": value", ":: base64" or ":< url", without the first colon.
+func (d *Decoder) parseValue(l line, spec string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(spec, ":"):
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(spec[1:]))
+		if err != nil {
+			return nil, &ParseError{Line: l.no, Err: fmt.Errorf("invalid base64 value: %v", err)}
+		}
+		return value, nil
+	case strings.HasPrefix(spec, "<"):
+		if d.ReadURL == nil {
+			return nil, &ParseError{Line: l.no, Err: ErrURLValue}
+		}
+		u, err := url.Parse(strings.TrimSpace(spec[1:]))
+		if err != nil {
+			return nil, &ParseError{Line: l.no, Err: err}
+		}
+		value, err := d.ReadURL(u)
+		if err != nil {
+			return nil, &ParseError{Line: l.no, Err: err}
+		}
+		return value, nil
+	default:
+		return []byte(strings.TrimLeft(spec, " ")), nil
+	}
+}
+
+// ReadFileURL reads the content of a file:// URL from the local file system,
+// rejecting the other schemes.
+func ReadFileURL(u *url.URL) ([]byte, error) {
+	if u.Scheme != "file" {
+		return nil, fmt.Errorf("unsupported URL scheme %s", strconv.Quote(u.Scheme))
+	}
+	return ioutil.ReadFile(u.Path)
+}
@@ -0,0 +1,202 @@
+package ldif
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/ThomasNguyenGitHub/go/ldap"
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// DefaultFoldWidth is the line length recommended by https://tools.ietf.org/html/rfc2849
+const DefaultFoldWidth = 76
+
+// modifyOperations maps the Change operations to their LDIF keywords
+var modifyOperations = map[uint]string{
+	ldap.AddAttribute:       "add",
+	ldap.DeleteAttribute:    "delete",
+	ldap.ReplaceAttribute:   "replace",
+	ldap.IncrementAttribute: "increment",
+}
+
+// Encoder writes LDIF records to an output stream.
+type Encoder struct {
+	// FoldWidth is the maximum length of a written line, lines are not folded when zero or less
+	FoldWidth int
+
+	w       *bufio.Writer
+	records int
+}
+
+// NewEncoder returns a new encoder that writes to w, folding lines at DefaultFoldWidth.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{FoldWidth: DefaultFoldWidth, w: bufio.NewWriter(w)}
+}
+
+// Marshal returns the LDIF representation of the entries of a search result.
+func Marshal(result *ldap.SearchResult) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).EncodeSearchResult(result); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeSearchResult writes the entries of a search result as content records.
+func (e *Encoder) EncodeSearchResult(result *ldap.SearchResult) error {
+	for _, entry := range result.Entries {
+		e.writeEntry(entry)
+	}
+	return e.w.Flush()
+}
+
+// EncodeEntry writes a content record for the entry.
+func (e *Encoder) EncodeEntry(entry *ldap.Entry) error {
+	e.writeEntry(entry)
+	return e.w.Flush()
+}
+
+// Encode writes the record.
+func (e *Encoder) Encode(r *Record) error {
+	switch {
+	case r.Entry != nil:
+		e.writeEntry(r.Entry)
+	case r.Add != nil:
+		e.begin(r.Add.DN, r.Add.Controls, ChangeTypeAdd)
+		for _, attr := range r.Add.Attributes {
+			for _, v := range attr.Vals {
+				e.writeValue(attr.Type, []byte(v))
+			}
+		}
+	case r.Del != nil:
+		e.begin(r.Del.DN, r.Del.Controls, ChangeTypeDelete)
+	case r.Modify != nil:
+		e.begin(r.Modify.DN, r.Modify.Controls, ChangeTypeModify)
+		for _, change := range r.Modify.Changes {
+			e.writeValue(modifyOperations[change.Operation], []byte(change.Modification.Type))
+			for _, v := range change.Modification.Vals {
+				e.writeValue(change.Modification.Type, []byte(v))
+			}
+			e.w.WriteString("-\n")
+		}
+	case r.ModifyDN != nil:
+		e.begin(r.ModifyDN.DN, nil, ChangeTypeModDN)
+		e.writeValue("newrdn", []byte(r.ModifyDN.NewRDN))
+		deleteOldRDN := "0"
+		if r.ModifyDN.DeleteOldRDN {
+			deleteOldRDN = "1"
+		}
+		e.writeValue("deleteoldrdn", []byte(deleteOldRDN))
+		if r.ModifyDN.NewSuperior != "" {
+			e.writeValue("newsuperior", []byte(r.ModifyDN.NewSuperior))
+		}
+	default:
+		return errors.New("ldif: empty record")
+	}
+	return e.w.Flush()
+}
+
+func (e *Encoder) writeEntry(entry *ldap.Entry) {
+	e.begin(entry.DN, nil, "")
+	for _, attr := range entry.Attributes {
+		for i, v := range attr.Values {
+			value := []byte(v)
+			if len(attr.ByteValues) == len(attr.Values) {
+				value = attr.ByteValues[i]
+			}
+			e.writeValue(attr.Name, value)
+		}
+	}
+}
+
+// begin writes the version on the first record, the record separator and the record header.
+func (e *Encoder) begin(dn string, controls []ldap.Control, changeType string) {
+	if e.records == 0 {
+		e.w.WriteString("version: 1\n")
+	}
+	e.records++
+	e.w.WriteString("\n")
+	e.writeValue("dn", []byte(dn))
+	for _, control := range controls {
+		e.writeControl(control)
+	}
+	if changeType != "" {
+		e.writeValue("changetype", []byte(changeType))
+	}
+}
+
+// writeControl writes a control line from the BER encoding of the control.
+func (e *Encoder) writeControl(control ldap.Control) {
+	spec := control.GetControlType()
+	var value []byte
+	if packet := control.Encode(); packet != nil {
+		for _, child := range packet.Children[1:] {
+			if critical, ok := child.Value.(bool); ok {
+				if critical {
+					spec += " true"
+				}
+				continue
+			}
+			if child.Tag == ber.TagOctetString {
+				value = child.Data.Bytes()
+			}
+		}
+	}
+	switch {
+	case value == nil:
+		e.writeLine("control: " + spec)
+	case isSafe(value):
+		e.writeLine("control: " + spec + ": " + string(value))
+	default:
+		e.writeLine("control: " + spec + ":: " + base64.StdEncoding.EncodeToString(value))
+	}
+}
+
+// writeValue writes an attrval-spec, base64 encoding values that are not safe strings.
+func (e *Encoder) writeValue(name string, value []byte) {
+	if isSafe(value) {
+		e.writeLine(name + ": " + string(value))
+		return
+	}
+	e.writeLine(name + ":: " + base64.StdEncoding.EncodeToString(value))
+}
+
+// writeLine writes a line, folding it at FoldWidth.
+func (e *Encoder) writeLine(s string) {
+	if e.FoldWidth > 1 && len(s) > e.FoldWidth {
+		e.w.WriteString(s[:e.FoldWidth])
+		for s = s[e.FoldWidth:]; len(s) > e.FoldWidth-1; s = s[e.FoldWidth-1:] {
+			e.w.WriteString("\n ")
+			e.w.WriteString(s[:e.FoldWidth-1])
+		}
+		if len(s) > 0 {
+			e.w.WriteString("\n ")
+		}
+	}
+	e.w.WriteString(s)
+	e.w.WriteString("\n")
+}
+
+// isSafe reports whether the value is a SAFE-STRING that can be written without base64 encoding.
+func isSafe(value []byte) bool {
+	if len(value) == 0 {
+		return true
+	}
+	if value[0] == ' ' || value[0] == ':' || value[0] == '<' || value[len(value)-1] == ' ' {
+		return false
+	}
+	return !strings.ContainsAny(string(value), "\x00\r\n") && !containsNonASCII(value)
+}
+
+func containsNonASCII(value []byte) bool {
+	for _, c := range value {
+		if c > 0x7f {
+			return true
+		}
+	}
+	return false
+}
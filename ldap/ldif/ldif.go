@@ -0,0 +1,96 @@
+// Package ldif reads and writes the LDAP Data Interchange Format as defined in
+// https://tools.ietf.org/html/rfc2849
+//
+// Content records are returned as *ldap.Entry values and change records as the
+// request types of the ldap package, so they can be sent to a server as is.
+package ldif
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ThomasNguyenGitHub/go/ldap"
+)
+
+// Change types of change records
+const (
+	ChangeTypeAdd    = "add"
+	ChangeTypeDelete = "delete"
+	ChangeTypeModify = "modify"
+	ChangeTypeModDN  = "moddn"
+	ChangeTypeModRDN = "modrdn"
+)
+
+// ErrMixedRecords is returned when a file holds both content and change records.
+var ErrMixedRecords = errors.New("ldif: content and change records cannot be mixed")
+
+// ErrURLValue is returned for the values given as a URL when the decoder has no ReadURL.
+var ErrURLValue = errors.New("ldif: URL values are not enabled")
+
+// Record is a single LDIF record. Exactly one of the fields is set.
+type Record struct {
+	// Entry holds a content record
+	Entry *ldap.Entry
+	// Add holds a change record with changetype add
+	Add *ldap.AddRequest
+	// Del holds a change record with changetype delete
+	Del *ldap.DelRequest
+	// Modify holds a change record with changetype modify
+	Modify *ldap.ModifyRequest
+	// ModifyDN holds a change record with changetype moddn or modrdn
+	ModifyDN *ldap.ModifyDNRequest
+}
+
+// DN returns the distinguished name the record applies to.
+func (r *Record) DN() string {
+	switch {
+	case r.Entry != nil:
+		return r.Entry.DN
+	case r.Add != nil:
+		return r.Add.DN
+	case r.Del != nil:
+		return r.Del.DN
+	case r.Modify != nil:
+		return r.Modify.DN
+	case r.ModifyDN != nil:
+		return r.ModifyDN.DN
+	}
+	return ""
+}
+
+// IsChange reports whether the record is a change record.
+func (r *Record) IsChange() bool {
+	return r.Entry == nil
+}
+
+// AddRequest returns an AddRequest creating the given entry.
+func AddRequest(e *ldap.Entry, controls []ldap.Control) *ldap.AddRequest {
+	req := ldap.NewAddRequest(e.DN, controls)
+	for _, attr := range e.Attributes {
+		req.Attribute(attr.Name, attr.Values)
+	}
+	return req
+}
+
+// Apply sends the records to the server in order. Content records are added as new entries.
+func Apply(client ldap.Client, records []*Record) error {
+	for _, r := range records {
+		var err error
+		switch {
+		case r.Entry != nil:
+			err = client.Add(AddRequest(r.Entry, nil))
+		case r.Add != nil:
+			err = client.Add(r.Add)
+		case r.Del != nil:
+			err = client.Del(r.Del)
+		case r.Modify != nil:
+			err = client.Modify(r.Modify)
+		case r.ModifyDN != nil:
+			err = client.ModifyDN(r.ModifyDN)
+		}
+		if err != nil {
+			return fmt.Errorf("ldif: %s: %v", r.DN(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,291 @@
+package ldif
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ThomasNguyenGitHub/go/ldap"
+)
+
+const contentLDIF = `version: 1
+
+# the organization
+dn: dc=example,dc=com
+objectClass: top
+objectClass: domain
+dc: example
+
+dn: cn=Barbara Jensen,dc=example,
+ dc=com
+objectClass: person
+cn: Barbara Jensen
+cn: Babs
+description:: V2hhdCBhIGNhcmVmdWwgcmVhZGVyIHlvdSBhcmUh
+#  a folded
+  comment
+jpegPhoto:< file:///tmp/babs.jpg
+sn: Jensen
+`
+
+func TestParseContentRecords(t *testing.T) {
+	d := NewDecoder(strings.NewReader(contentLDIF))
+	d.ReadURL = func(u *url.URL) ([]byte, error) {
+		if u.Path != "/tmp/babs.jpg" {
+			t.Errorf("unexpected URL %s", u)
+		}
+		return []byte{0xff, 0xd8, 0xff}, nil
+	}
+
+	var records []*Record
+	for {
+		rec, err := d.Decode()
+		if err != nil {
+			if err != io.EOF {
+				t.Fatal(err)
+			}
+			break
+		}
+		records = append(records, rec)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	e := records[1].Entry
+	if e == nil || records[1].IsChange() {
+		t.Fatal("expected a content record")
+	}
+	if e.DN != "cn=Barbara Jensen,dc=example,dc=com" {
+		t.Errorf("unexpected DN %q", e.DN)
+	}
+	if got := e.GetAttributeValues("cn"); !reflect.DeepEqual(got, []string{"Barbara Jensen", "Babs"}) {
+		t.Errorf("unexpected cn %v", got)
+	}
+	if got := e.GetAttributeValue("description"); got != "What a careful reader you are!" {
+		t.Errorf("unexpected description %q", got)
+	}
+	if got := e.GetRawAttributeValue("jpegPhoto"); !bytes.Equal(got, []byte{0xff, 0xd8, 0xff}) {
+		t.Errorf("unexpected jpegPhoto %v", got)
+	}
+	if got := e.GetAttributeValue("sn"); got != "Jensen" {
+		t.Errorf("unexpected sn %q, the folded comment was not skipped", got)
+	}
+}
+
+func TestParseChangeRecords(t *testing.T) {
+	records, err := ParseString(`version: 1
+
+dn: cn=Fiona Jensen,ou=Marketing,dc=example,dc=com
+changetype: add
+objectClass: person
+cn: Fiona Jensen
+
+dn: cn=Robert Jensen,ou=Marketing,dc=example,dc=com
+changetype: delete
+
+dn: cn=Paula Jensen,ou=Product Development,dc=example,dc=com
+control: 1.2.840.113556.1.4.805 true
+changetype: modify
+add: postaladdress
+postaladdress: 123 Anystreet $ Sunnyvale, CA $ 94086
+-
+delete: description
+-
+replace: telephonenumber
+telephonenumber: +1 408 555 1234
+telephonenumber: +1 408 555 5678
+-
+
+dn: cn=Paul Jensen,ou=Product Development,dc=example,dc=com
+changetype: modrdn
+newrdn: cn=Paula Jensen
+deleteoldrdn: 1
+newsuperior: ou=Sales,dc=example,dc=com
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("expected 4 records, got %d", len(records))
+	}
+
+	add := records[0].Add
+	if add == nil || len(add.Attributes) != 2 || add.Attributes[1].Vals[0] != "Fiona Jensen" {
+		t.Errorf("unexpected add record %+v", records[0])
+	}
+	if del := records[1].Del; del == nil || del.DN != "cn=Robert Jensen,ou=Marketing,dc=example,dc=com" {
+		t.Errorf("unexpected delete record %+v", records[1])
+	}
+
+	mod := records[2].Modify
+	if mod == nil {
+		t.Fatalf("unexpected modify record %+v", records[2])
+	}
+	if len(mod.Controls) != 1 || mod.Controls[0].GetControlType() != "1.2.840.113556.1.4.805" {
+		t.Errorf("unexpected controls %v", mod.Controls)
+	}
+	ops := []uint{ldap.AddAttribute, ldap.DeleteAttribute, ldap.ReplaceAttribute}
+	if len(mod.Changes) != len(ops) {
+		t.Fatalf("expected %d changes, got %d", len(ops), len(mod.Changes))
+	}
+	for i, op := range ops {
+		if mod.Changes[i].Operation != op {
+			t.Errorf("change %d: expected operation %d, got %d", i, op, mod.Changes[i].Operation)
+		}
+	}
+	if vals := mod.Changes[2].Modification.Vals; len(vals) != 2 {
+		t.Errorf("unexpected replace values %v", vals)
+	}
+
+	moddn := records[3].ModifyDN
+	if moddn == nil || moddn.NewRDN != "cn=Paula Jensen" || !moddn.DeleteOldRDN || moddn.NewSuperior != "ou=Sales,dc=example,dc=com" {
+		t.Errorf("unexpected moddn record %+v", records[3])
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		ldif string
+		line int
+	}{
+		{"mixed records", "dn: dc=example\ndc: example\n\ndn: dc=other\nchangetype: delete\n", 4},
+		{"missing dn", "cn: foo\n", 1},
+		{"bad base64", "dn: dc=example\ndc:: !!!\n", 2},
+		{"unknown changetype", "dn: dc=example\nchangetype: rename\n", 2},
+		{"unsupported version", "version: 2\n\ndn: dc=example\n", 1},
+		{"incomplete moddn", "dn: dc=example\nchangetype: moddn\nnewrdn: dc=other\n", 3},
+		{"URL value", "dn: dc=example\njpegPhoto:< file:///etc/passwd\n", 2},
+	}
+	for _, tt := range tests {
+		_, err := ParseString(tt.ldif)
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("%s: expected a ParseError, got %v", tt.name, err)
+			continue
+		}
+		if perr.Line != tt.line {
+			t.Errorf("%s: expected an error on line %d, got %v", tt.name, tt.line, err)
+		}
+	}
+	if _, err := ParseString("dn: dc=example\n\ndn: dc=other\nchangetype: delete\n"); !errors.Is(err.(*ParseError).Err, ErrMixedRecords) {
+		t.Errorf("expected ErrMixedRecords, got %v", err)
+	}
+}
+
+func TestReadFileURL(t *testing.T) {
+	if _, err := ParseString("dn: dc=example\njpegPhoto:< file:///etc/passwd\n"); !errors.Is(err.(*ParseError).Err, ErrURLValue) {
+		t.Errorf("expected ErrURLValue, got %v", err)
+	}
+
+	name := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := os.WriteFile(name, []byte{0xff, 0xd8, 0xff}, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(name)}
+	d := NewDecoder(strings.NewReader("dn: dc=example\njpegPhoto:< " + u.String() + "\n"))
+	d.ReadURL = ReadFileURL
+	rec, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.Entry.GetRawAttributeValue("jpegPhoto"); !bytes.Equal(got, []byte{0xff, 0xd8, 0xff}) {
+		t.Errorf("unexpected jpegPhoto %v", got)
+	}
+
+	d = NewDecoder(strings.NewReader("dn: dc=example\njpegPhoto:< http://example.com/photo.jpg\n"))
+	d.ReadURL = ReadFileURL
+	if _, err := d.Decode(); err == nil {
+		t.Error("expected the http URL to be rejected")
+	}
+}
+
+func TestMarshalSearchResult(t *testing.T) {
+	entry := ldap.NewEntry("cn=Barbara Jensen,dc=example,dc=com", map[string][]string{
+		"cn":          {"Barbara Jensen"},
+		"description": {" leading space", strings.Repeat("long ", 30)},
+	})
+	guid := []byte{0x00, 0x9f, 0x10, 0x80}
+	entry.Attributes = append(entry.Attributes, &ldap.EntryAttribute{
+		Name:       "objectGUID",
+		Values:     []string{string(guid)},
+		ByteValues: [][]byte{guid},
+	})
+
+	data, err := Marshal(&ldap.SearchResult{Entries: []*ldap.Entry{entry}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range strings.Split(string(data), "\n") {
+		if len(l) > DefaultFoldWidth {
+			t.Errorf("line longer than %d: %q", DefaultFoldWidth, l)
+		}
+	}
+	if !strings.HasPrefix(string(data), "version: 1\n\ndn: cn=Barbara Jensen,dc=example,dc=com\n") {
+		t.Errorf("unexpected output\n%s", data)
+	}
+	if !strings.Contains(string(data), "description:: IGxlYWRpbmcgc3BhY2U=\n") {
+		t.Errorf("unsafe value was not base64 encoded\n%s", data)
+	}
+
+	records, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	got := records[0].Entry
+	if !reflect.DeepEqual(got.GetAttributeValues("description"), entry.GetAttributeValues("description")) {
+		t.Errorf("description did not round trip: %q", got.GetAttributeValues("description"))
+	}
+	if !bytes.Equal(got.GetRawAttributeValue("objectGUID"), guid) {
+		t.Errorf("objectGUID did not round trip: %v", got.GetRawAttributeValue("objectGUID"))
+	}
+}
+
+func TestEncodeChangeRecords(t *testing.T) {
+	mod := ldap.NewModifyRequest("cn=Paula Jensen,dc=example,dc=com", []ldap.Control{ldap.NewControlString("1.2.3.4", true, "value")})
+	mod.Add("mail", []string{"paula@example.com"})
+	mod.Delete("description", nil)
+	records := []*Record{
+		{Modify: mod},
+		{ModifyDN: ldap.NewModifyDNRequest("cn=Paul Jensen,dc=example,dc=com", "cn=Paula Jensen", true, "")},
+		{Del: ldap.NewDelRequest("cn=Robert Jensen,dc=example,dc=com", nil)},
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !strings.Contains(buf.String(), "control: 1.2.3.4 true: value\n") {
+		t.Errorf("control was not written\n%s", buf.String())
+	}
+
+	decoded, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(records) {
+		t.Fatalf("expected %d records, got %d", len(records), len(decoded))
+	}
+	if got := decoded[0].Modify; got == nil || len(got.Changes) != 2 || got.Changes[1].Operation != ldap.DeleteAttribute {
+		t.Errorf("unexpected modify record %+v", decoded[0])
+	}
+	if got := decoded[1].ModifyDN; got == nil || got.NewRDN != "cn=Paula Jensen" || !got.DeleteOldRDN {
+		t.Errorf("unexpected moddn record %+v", decoded[1])
+	}
+	if decoded[2].DN() != "cn=Robert Jensen,dc=example,dc=com" {
+		t.Errorf("unexpected delete record %+v", decoded[2])
+	}
+}
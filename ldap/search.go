@@ -65,6 +65,8 @@ type Entry struct {
 	DN string
 	// Attributes are the returned attributes for the entry
 	Attributes []*EntryAttribute
+	// Controls are the controls returned with the entry
+	Controls []Control
 }
 
 // GetAttributeValues returns the values for the named attribute, or an empty list
@@ -162,6 +164,16 @@ type SearchResult struct {
 	Referrals []string
 	// Controls are the returned controls
 	Controls []Control
+	// Intermediates are the intermediate responses received during the search
+	Intermediates []*IntermediateResponse
+}
+
+// IntermediateResponse holds an intermediate response message as described in https://tools.ietf.org/html/rfc4511#section-4.13
+type IntermediateResponse struct {
+	// Name is the OID of the response
+	Name string
+	// Value is the response value
+	Value []byte
 }
 
 // Print outputs a human-readable description
@@ -288,6 +300,7 @@ func (l *Conn) SearchWithPaging(searchRequest *SearchRequest, pagingSize uint32)
 		for _, control := range result.Controls {
 			searchResult.Controls = append(searchResult.Controls, control)
 		}
+		searchResult.Intermediates = append(searchResult.Intermediates, result.Intermediates...)
 
 		l.Debug.Printf("Looking for Paging Control...")
 		pagingResult := FindControl(result.Controls, ControlTypePaging)
@@ -347,6 +360,15 @@ func (l *Conn) Search(searchRequest *SearchRequest) (*SearchResult, error) {
 				}
 				entry.Attributes = append(entry.Attributes, attr)
 			}
+			if len(packet.Children) == 3 {
+				for _, child := range packet.Children[2].Children {
+					decodedChild, err := DecodeControl(child)
+					if err != nil {
+						return nil, fmt.Errorf("failed to decode child control: %s", err)
+					}
+					entry.Controls = append(entry.Controls, decodedChild)
+				}
+			}
 			result.Entries = append(result.Entries, entry)
 		case 5:
 			err := GetLDAPError(packet)
@@ -365,6 +387,17 @@ func (l *Conn) Search(searchRequest *SearchRequest) (*SearchResult, error) {
 			return result, nil
 		case 19:
 			result.Referrals = append(result.Referrals, packet.Children[1].Children[0].Value.(string))
+		case ApplicationIntermediateResponse:
+			response := new(IntermediateResponse)
+			for _, child := range packet.Children[1].Children {
+				switch child.Tag {
+				case 10:
+					response.Name = ber.DecodeString(child.Data.Bytes())
+				case 11:
+					response.Value = child.Data.Bytes()
+				}
+			}
+			result.Intermediates = append(result.Intermediates, response)
 		}
 	}
 }
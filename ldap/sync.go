@@ -0,0 +1,416 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// SyncInfoMessageOID is the name of the Sync Info intermediate response described in https://tools.ietf.org/html/rfc4533#section-2.5
+const SyncInfoMessageOID = "1.3.6.1.4.1.4203.1.9.1.4"
+
+// Choices of the Sync Info message
+const (
+	SyncInfoNewCookie      = 0
+	SyncInfoRefreshDelete  = 1
+	SyncInfoRefreshPresent = 2
+	SyncInfoSyncIDSet      = 3
+)
+
+// SyncInfo is a decoded Sync Info message
+type SyncInfo struct {
+	// Type is one of the SyncInfo* choices
+	Type int
+	// Cookie is the new synchronization state, if any
+	Cookie []byte
+	// RefreshDone indicates the end of a refresh phase
+	RefreshDone bool
+	// RefreshDeletes indicates that UUIDs of a syncIdSet are deleted entries rather than present ones
+	RefreshDeletes bool
+	// UUIDs are the entry UUIDs of a syncIdSet
+	UUIDs [][]byte
+}
+
+// DecodeSyncInfo decodes the value of a Sync Info intermediate response
+func DecodeSyncInfo(value []byte) (*SyncInfo, error) {
+	packet, err := ber.DecodePacketErr(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sync info: %s", err)
+	}
+	if packet.ClassType != ber.ClassContext || packet.Tag > SyncInfoSyncIDSet {
+		return nil, fmt.Errorf("invalid sync info choice %d", packet.Tag)
+	}
+	info := &SyncInfo{Type: int(packet.Tag), RefreshDone: true}
+	if packet.Tag == SyncInfoNewCookie {
+		info.Cookie = packet.Data.Bytes()
+		return info, nil
+	}
+	for _, child := range packet.Children {
+		switch child.Tag {
+		case ber.TagOctetString:
+			info.Cookie = child.Data.Bytes()
+		case ber.TagBoolean:
+			b, _ := child.Value.(bool)
+			if packet.Tag == SyncInfoSyncIDSet {
+				info.RefreshDeletes = b
+			} else {
+				info.RefreshDone = b
+			}
+		case ber.TagSet:
+			for _, uuid := range child.Children {
+				info.UUIDs = append(info.UUIDs, uuid.Data.Bytes())
+			}
+		}
+	}
+	return info, nil
+}
+
+// SyncMode selects the protocol a SyncWatcher uses
+type SyncMode int
+
+// Synchronization protocols
+const (
+	// SyncModeDirSync uses the Active Directory DirSync control
+	SyncModeDirSync SyncMode = iota
+	// SyncModeRFC4533 uses the content synchronization operation of https://tools.ietf.org/html/rfc4533 in refreshOnly mode
+	SyncModeRFC4533
+)
+
+// SyncEventType is the kind of change reported by a SyncEvent
+type SyncEventType int
+
+// Sync event types
+const (
+	SyncEntryAdded SyncEventType = iota + 1
+	SyncEntryModified
+	SyncEntryDeleted
+)
+
+func (t SyncEventType) String() string {
+	switch t {
+	case SyncEntryAdded:
+		return "added"
+	case SyncEntryModified:
+		return "modified"
+	case SyncEntryDeleted:
+		return "deleted"
+	}
+	return fmt.Sprintf("SyncEventType(%d)", int(t))
+}
+
+// SyncEvent describes a change of a directory entry
+type SyncEvent struct {
+	// Type is the kind of change
+	Type SyncEventType
+	// DN is the distinguished name of the entry. It is empty for deletions reported by UUID only
+	// of entries the watcher has not seen.
+	DN string
+	// ID is the objectGUID with DirSync, or the entryUUID with RFC 4533
+	ID []byte
+	// Entry holds the returned attributes. With DirSync only changed attributes are returned.
+	// It is nil for deletions reported by UUID only.
+	Entry *Entry
+}
+
+// CookieStore persists the synchronization state of a SyncWatcher
+type CookieStore interface {
+	// LoadCookie returns the stored cookie, or nil when there is none
+	LoadCookie(name string) ([]byte, error)
+	// SaveCookie stores the cookie
+	SaveCookie(name string, cookie []byte) error
+}
+
+// FileCookieStore stores cookies as files in a directory
+type FileCookieStore struct {
+	// Dir is the directory the cookies are stored in
+	Dir string
+}
+
+// LoadCookie returns the stored cookie, or nil when there is none
+func (s *FileCookieStore) LoadCookie(name string) ([]byte, error) {
+	cookie, err := ioutil.ReadFile(s.path(name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return cookie, err
+}
+
+// SaveCookie stores the cookie, replacing the file atomically
+func (s *FileCookieStore) SaveCookie(name string, cookie []byte) error {
+	tmp, err := ioutil.TempFile(s.Dir, ".cookie")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(cookie); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(name))
+}
+
+func (s *FileCookieStore) path(name string) string {
+	return filepath.Join(s.Dir, url.PathEscape(name)+".cookie")
+}
+
+// DefaultSyncInterval is the polling interval of SyncWatcher.Watch
+const DefaultSyncInterval = time.Minute
+
+// SyncWatcher polls a directory for changes below a base DN and reports them as events.
+// The synchronization cookie is saved after all events of a poll were handled, so a
+// failed handler causes the same changes to be reported again by the next poll.
+//
+// With DirSync, an entry is reported as added on the first synchronization or when its
+// objectGUID was not seen by the watcher since it was created, and as deleted when it has
+// isDeleted set. The search must be made on the root of a naming context by an account
+// with the replicating directory changes right.
+//
+// With RFC 4533, an entry is reported as added when its createTimestamp equals its
+// modifyTimestamp. Deletions signalled through the present phase are only detected for
+// entries seen by the watcher since it was created.
+type SyncWatcher struct {
+	// Name identifies the cookie in the store, it defaults to the base DN of the request
+	Name string
+	// Interval is the polling interval of Watch
+	Interval time.Duration
+	// DirSyncFlags are the flags of the DirSync control
+	DirSyncFlags int64
+
+	client  Client
+	mode    SyncMode
+	request *SearchRequest
+	store   CookieStore
+	known   map[string]string
+}
+
+// NewSyncWatcher returns a watcher reporting changes of the entries matched by the request
+func NewSyncWatcher(client Client, mode SyncMode, request *SearchRequest, store CookieStore) *SyncWatcher {
+	return &SyncWatcher{
+		Name:         request.BaseDN,
+		Interval:     DefaultSyncInterval,
+		DirSyncFlags: DirSyncIncrementalValues,
+		client:       client,
+		mode:         mode,
+		request:      request,
+		store:        store,
+		known:        map[string]string{},
+	}
+}
+
+// Watch calls Sync every Interval until the context is done or a synchronization fails
+func (w *SyncWatcher) Watch(ctx context.Context, handler func(SyncEvent) error) error {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		if err := w.Sync(handler); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync reports the changes since the last saved cookie to the handler and saves the new cookie
+func (w *SyncWatcher) Sync(handler func(SyncEvent) error) error {
+	cookie, err := w.store.LoadCookie(w.Name)
+	if err != nil {
+		return fmt.Errorf("ldap: failed to load sync cookie: %s", err)
+	}
+	if w.mode == SyncModeDirSync {
+		return w.dirSync(cookie, handler)
+	}
+	err = w.syncRefresh(cookie, handler)
+	if IsErrorWithCode(err, LDAPResultSyncRefreshRequired) && cookie != nil {
+		// the server cannot resume from the cookie, start over with a full refresh
+		return w.syncRefresh(nil, handler)
+	}
+	return err
+}
+
+func (w *SyncWatcher) dirSync(cookie []byte, handler func(SyncEvent) error) error {
+	initial := cookie == nil
+	control := NewControlDirSync(w.DirSyncFlags, cookie)
+	req := w.searchRequest(control, "isDeleted")
+	for {
+		result, err := w.client.Search(req)
+		if err != nil {
+			return err
+		}
+		for _, entry := range result.Entries {
+			id := entry.GetRawAttributeValue("objectGUID")
+			event := SyncEvent{Type: SyncEntryModified, DN: entry.DN, ID: id, Entry: entry}
+			_, known := w.known[string(id)]
+			switch {
+			case strings.EqualFold(entry.GetAttributeValue("isDeleted"), "TRUE"):
+				event.Type = SyncEntryDeleted
+			case initial || !known:
+				event.Type = SyncEntryAdded
+			}
+			if err := handler(event); err != nil {
+				return err
+			}
+			if event.Type == SyncEntryDeleted {
+				delete(w.known, string(id))
+			} else {
+				w.known[string(id)] = entry.DN
+			}
+		}
+
+		response, ok := FindControl(result.Controls, ControlTypeDirSync).(*ControlDirSync)
+		if !ok {
+			return NewError(ErrorUnexpectedResponse, errors.New("ldap: DirSync control not returned"))
+		}
+		if err := w.store.SaveCookie(w.Name, response.Cookie); err != nil {
+			return fmt.Errorf("ldap: failed to save sync cookie: %s", err)
+		}
+		if !response.MoreResults() {
+			return nil
+		}
+		control.SetCookie(response.Cookie)
+	}
+}
+
+func (w *SyncWatcher) syncRefresh(cookie []byte, handler func(SyncEvent) error) error {
+	req := w.searchRequest(NewControlSyncRequest(SyncRequestModeRefreshOnly, cookie, false), "createTimestamp", "modifyTimestamp")
+	result, err := w.client.Search(req)
+	if err != nil {
+		return err
+	}
+
+	present := map[string]bool{}
+	presentPhase := false
+	var deleted [][]byte
+	for _, response := range result.Intermediates {
+		if response.Name != SyncInfoMessageOID {
+			continue
+		}
+		info, err := DecodeSyncInfo(response.Value)
+		if err != nil {
+			return err
+		}
+		switch info.Type {
+		case SyncInfoRefreshPresent:
+			presentPhase = true
+		case SyncInfoSyncIDSet:
+			if info.RefreshDeletes {
+				deleted = append(deleted, info.UUIDs...)
+				continue
+			}
+			presentPhase = true
+			for _, uuid := range info.UUIDs {
+				present[string(uuid)] = true
+			}
+		}
+	}
+
+	for _, entry := range result.Entries {
+		state, ok := FindControl(entry.Controls, ControlTypeSyncState).(*ControlSyncState)
+		if !ok {
+			return NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: Sync State control not returned for %s", entry.DN))
+		}
+		id := string(state.EntryUUID)
+		event := SyncEvent{DN: entry.DN, ID: state.EntryUUID, Entry: entry}
+		switch state.State {
+		case SyncStatePresent:
+			presentPhase = true
+			present[id] = true
+			w.known[id] = entry.DN
+			continue
+		case SyncStateDelete:
+			event.Type = SyncEntryDeleted
+		case SyncStateModify:
+			event.Type = SyncEntryModified
+		default:
+			event.Type = w.addedOrModified(id, entry)
+		}
+		if err := handler(event); err != nil {
+			return err
+		}
+		if event.Type == SyncEntryDeleted {
+			delete(w.known, id)
+		} else {
+			present[id] = true
+			w.known[id] = entry.DN
+		}
+	}
+
+	done, _ := FindControl(result.Controls, ControlTypeSyncDone).(*ControlSyncDone)
+	if done != nil && !done.RefreshDeletes && presentPhase {
+		for id := range w.known {
+			if !present[id] {
+				deleted = append(deleted, []byte(id))
+			}
+		}
+	}
+	for _, uuid := range deleted {
+		if err := handler(SyncEvent{Type: SyncEntryDeleted, DN: w.known[string(uuid)], ID: uuid}); err != nil {
+			return err
+		}
+		delete(w.known, string(uuid))
+	}
+
+	if done != nil && done.Cookie != nil {
+		cookie = done.Cookie
+	}
+	if cookie == nil {
+		return nil
+	}
+	if err := w.store.SaveCookie(w.Name, cookie); err != nil {
+		return fmt.Errorf("ldap: failed to save sync cookie: %s", err)
+	}
+	return nil
+}
+
+// addedOrModified tells new entries from changed ones using their operational timestamps
+func (w *SyncWatcher) addedOrModified(id string, entry *Entry) SyncEventType {
+	created, modified := entry.GetAttributeValue("createTimestamp"), entry.GetAttributeValue("modifyTimestamp")
+	if created != "" && modified != "" {
+		if created == modified {
+			return SyncEntryAdded
+		}
+		return SyncEntryModified
+	}
+	if _, ok := w.known[id]; ok {
+		return SyncEntryModified
+	}
+	return SyncEntryAdded
+}
+
+// searchRequest returns a copy of the watched request with the control and the given attributes added
+func (w *SyncWatcher) searchRequest(control Control, attributes ...string) *SearchRequest {
+	req := *w.request
+	req.Controls = append(append([]Control(nil), w.request.Controls...), control)
+	if len(req.Attributes) == 0 {
+		req.Attributes = []string{"*"}
+	} else {
+		req.Attributes = append([]string(nil), req.Attributes...)
+	}
+	for _, name := range attributes {
+		found := false
+		for _, attr := range req.Attributes {
+			if strings.EqualFold(attr, name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			req.Attributes = append(req.Attributes, name)
+		}
+	}
+	return &req
+}
@@ -0,0 +1,190 @@
+package ldap
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// syncClient returns canned search results and records the requests it receives
+type syncClient struct {
+	Client
+	results  []*SearchResult
+	requests []*SearchRequest
+}
+
+func (c *syncClient) Search(req *SearchRequest) (*SearchResult, error) {
+	c.requests = append(c.requests, req)
+	if len(c.results) == 0 {
+		return nil, errors.New("unexpected search")
+	}
+	result := c.results[0]
+	c.results = c.results[1:]
+	return result, nil
+}
+
+func collectEvents(events *[]SyncEvent) func(SyncEvent) error {
+	return func(e SyncEvent) error {
+		*events = append(*events, e)
+		return nil
+	}
+}
+
+func TestSyncWatcherDirSync(t *testing.T) {
+	client := &syncClient{results: []*SearchResult{
+		{
+			Entries: []*Entry{
+				NewEntry("CN=alice,DC=example,DC=com", map[string][]string{"objectGUID": {"a"}, "whenCreated": {"20200101000000.0Z"}}),
+			},
+			Controls: []Control{&ControlDirSync{Flags: 1, Cookie: []byte("1")}},
+		},
+		{
+			Entries: []*Entry{
+				NewEntry("CN=bob,DC=example,DC=com", map[string][]string{"objectGUID": {"b"}, "whenCreated": {"20200101000000.0Z"}}),
+			},
+			Controls: []Control{&ControlDirSync{Cookie: []byte("2")}},
+		},
+		{
+			Entries: []*Entry{
+				NewEntry("CN=alice,DC=example,DC=com", map[string][]string{"objectGUID": {"a"}, "whenCreated": {"20200101000000.0Z"}, "mail": {"alice@example.com"}}),
+				NewEntry("CN=carol,DC=example,DC=com", map[string][]string{"objectGUID": {"c"}, "mail": {"carol@example.com"}}),
+				NewEntry("CN=bob\\0ADEL:b,CN=Deleted Objects,DC=example,DC=com", map[string][]string{"objectGUID": {"b"}, "isDeleted": {"TRUE"}}),
+			},
+			Controls: []Control{&ControlDirSync{Cookie: []byte("3")}},
+		},
+	}}
+	store := &FileCookieStore{Dir: t.TempDir()}
+	req := NewSearchRequest("DC=example,DC=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=user)", []string{"mail"}, nil)
+	w := NewSyncWatcher(client, SyncModeDirSync, req, store)
+
+	var events []SyncEvent
+	if err := w.Sync(collectEvents(&events)); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Type != SyncEntryAdded || events[1].Type != SyncEntryAdded {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if cookie := client.requests[1].Controls[0].(*ControlDirSync).Cookie; string(cookie) != "1" {
+		t.Errorf("more results were not requested with the returned cookie, got %q", cookie)
+	}
+	if got := client.requests[0].Attributes; len(got) != 2 || got[1] != "isDeleted" {
+		t.Errorf("unexpected attributes %v", got)
+	}
+	if len(req.Attributes) != 1 || len(req.Controls) != 0 {
+		t.Errorf("the watched request was modified: %+v", req)
+	}
+
+	events = nil
+	if err := w.Sync(collectEvents(&events)); err != nil {
+		t.Fatal(err)
+	}
+	if cookie := client.requests[2].Controls[0].(*ControlDirSync).Cookie; string(cookie) != "2" {
+		t.Errorf("expected the saved cookie, got %q", cookie)
+	}
+	if len(events) != 3 || events[0].Type != SyncEntryModified || events[1].Type != SyncEntryAdded ||
+		events[2].Type != SyncEntryDeleted || string(events[2].ID) != "b" {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if cookie, _ := store.LoadCookie(w.Name); string(cookie) != "3" {
+		t.Errorf("unexpected stored cookie %q", cookie)
+	}
+}
+
+func TestSyncWatcherHandlerErrorKeepsCookie(t *testing.T) {
+	client := &syncClient{results: []*SearchResult{{
+		Entries:  []*Entry{NewEntry("CN=alice,DC=example,DC=com", nil)},
+		Controls: []Control{&ControlDirSync{Cookie: []byte("1")}},
+	}}}
+	store := &FileCookieStore{Dir: t.TempDir()}
+	req := NewSearchRequest("DC=example,DC=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	w := NewSyncWatcher(client, SyncModeDirSync, req, store)
+
+	failure := errors.New("database unavailable")
+	if err := w.Sync(func(SyncEvent) error { return failure }); err != failure {
+		t.Fatalf("expected the handler error, got %v", err)
+	}
+	if cookie, _ := store.LoadCookie(w.Name); cookie != nil {
+		t.Errorf("cookie was saved after a failed handler: %q", cookie)
+	}
+}
+
+func syncEntry(dn string, state int64, uuid string, attributes map[string][]string) *Entry {
+	e := NewEntry(dn, attributes)
+	e.Controls = []Control{&ControlSyncState{State: state, EntryUUID: []byte(uuid)}}
+	return e
+}
+
+func TestSyncWatcherRFC4533(t *testing.T) {
+	timestamps := func(created, modified string) map[string][]string {
+		return map[string][]string{"createTimestamp": {created}, "modifyTimestamp": {modified}}
+	}
+	idSet := ber.Encode(ber.ClassContext, ber.TypeConstructed, SyncInfoSyncIDSet, nil, "syncIdSet")
+	idSet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, true, "refreshDeletes"))
+	uuids := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "syncUUIDs")
+	uuids.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "uuid-c", "syncUUID"))
+	idSet.AppendChild(uuids)
+
+	client := &syncClient{results: []*SearchResult{
+		{
+			Entries: []*Entry{
+				syncEntry("cn=a,dc=example,dc=com", SyncStateAdd, "uuid-a", timestamps("1", "1")),
+				syncEntry("cn=b,dc=example,dc=com", SyncStateAdd, "uuid-b", timestamps("1", "1")),
+				syncEntry("cn=c,dc=example,dc=com", SyncStateAdd, "uuid-c", timestamps("1", "1")),
+			},
+			Controls: []Control{&ControlSyncDone{Cookie: []byte("1")}},
+		},
+		{
+			// present phase: b is missing and therefore deleted
+			Entries: []*Entry{
+				syncEntry("cn=a,dc=example,dc=com", SyncStateAdd, "uuid-a", timestamps("1", "2")),
+				syncEntry("cn=c,dc=example,dc=com", SyncStatePresent, "uuid-c", nil),
+			},
+			Controls: []Control{&ControlSyncDone{Cookie: []byte("2")}},
+		},
+		{
+			// delete phase reported through a syncIdSet
+			Intermediates: []*IntermediateResponse{{Name: SyncInfoMessageOID, Value: idSet.Bytes()}},
+			Controls:      []Control{&ControlSyncDone{Cookie: []byte("3"), RefreshDeletes: true}},
+		},
+	}}
+	store := &FileCookieStore{Dir: t.TempDir()}
+	req := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	w := NewSyncWatcher(client, SyncModeRFC4533, req, store)
+
+	var events []SyncEvent
+	for i := 0; i < 3; i++ {
+		if err := w.Sync(collectEvents(&events)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []struct {
+		typ SyncEventType
+		dn  string
+	}{
+		{SyncEntryAdded, "cn=a,dc=example,dc=com"},
+		{SyncEntryAdded, "cn=b,dc=example,dc=com"},
+		{SyncEntryAdded, "cn=c,dc=example,dc=com"},
+		{SyncEntryModified, "cn=a,dc=example,dc=com"},
+		{SyncEntryDeleted, "cn=b,dc=example,dc=com"},
+		{SyncEntryDeleted, "cn=c,dc=example,dc=com"},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %+v", len(expected), events)
+	}
+	for i, e := range expected {
+		if events[i].Type != e.typ || events[i].DN != e.dn {
+			t.Errorf("event %d: expected %s %s, got %s %s", i, e.typ, e.dn, events[i].Type, events[i].DN)
+		}
+	}
+
+	sent := client.requests[2].Controls[0].(*ControlSyncRequest)
+	if sent.Mode != SyncRequestModeRefreshOnly || !bytes.Equal(sent.Cookie, []byte("2")) {
+		t.Errorf("unexpected sync request %s", sent)
+	}
+	if cookie, _ := store.LoadCookie(w.Name); string(cookie) != "3" {
+		t.Errorf("unexpected stored cookie %q", cookie)
+	}
+}
@@ -0,0 +1,392 @@
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	guidType  = reflect.TypeOf(GUID{})
+	sidType   = reflect.TypeOf(SID{})
+	dnType    = reflect.TypeOf(DN{})
+	bytesType = reflect.TypeOf([]byte(nil))
+)
+
+// structField is a struct field mapped to an attribute by an `ldap:"name,options"` tag.
+// The options are:
+//   - omitempty: the attribute is not modified when the field has its zero value
+//   - filetime: a time.Time is written as a Windows FILETIME instead of a GeneralizedTime
+//
+// A field tagged `ldap:"dn"` holds the DN of the entry, as a string or a DN.
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	fileTime  bool
+}
+
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("ldap")
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			for _, inner := range structFields(f.Type) {
+				inner.index = append([]int{i}, inner.index...)
+				fields = append(fields, inner)
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		field := structField{name: opts[0], index: f.Index}
+		if field.name == "" {
+			field.name = f.Name
+		}
+		for _, opt := range opts[1:] {
+			switch opt {
+			case "omitempty":
+				field.omitEmpty = true
+			case "filetime":
+				field.fileTime = true
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func structValue(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("ldap: expected a struct, got %T", v)
+	}
+	return rv, nil
+}
+
+// StructAttributes returns the attribute names mapped by the fields of the struct v,
+// for use as the attribute list of a search request
+func StructAttributes(v interface{}) []string {
+	rv, err := structValue(v)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, f := range structFields(rv.Type()) {
+		if !strings.EqualFold(f.name, "dn") {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// getAttribute returns the named attribute, attribute names being case insensitive
+func (e *Entry) getAttribute(name string) *EntryAttribute {
+	for _, attr := range e.Attributes {
+		if strings.EqualFold(attr.Name, name) {
+			return attr
+		}
+	}
+	return nil
+}
+
+// Unmarshal copies the attributes of the entry into the fields of the struct pointed to by v,
+// using the attribute names given by `ldap:"name"` tags or the field names.
+//
+// Fields may be strings, booleans, integers, []byte, time.Time, GUID, SID, DN, UserAccountControl,
+// pointers to these types or slices of them for multi-valued attributes. time.Time fields accept
+// both GeneralizedTime values such as whenCreated and Windows FILETIME values such as pwdLastSet.
+// Fields of attributes missing from the entry are left unchanged.
+func (e *Entry) Unmarshal(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("ldap: Unmarshal requires a non-nil pointer to a struct")
+	}
+	rv = rv.Elem()
+
+	for _, f := range structFields(rv.Type()) {
+		fv := rv.FieldByIndex(f.index)
+		if strings.EqualFold(f.name, "dn") {
+			if err := setValue(fv, []byte(e.DN)); err != nil {
+				return fmt.Errorf("ldap: cannot unmarshal dn: %s", err)
+			}
+			continue
+		}
+		attr := e.getAttribute(f.name)
+		if attr == nil {
+			continue
+		}
+		values := attr.ByteValues
+		if len(values) != len(attr.Values) {
+			values = make([][]byte, len(attr.Values))
+			for i, s := range attr.Values {
+				values[i] = []byte(s)
+			}
+		}
+		if err := setValues(fv, values); err != nil {
+			return fmt.Errorf("ldap: cannot unmarshal %s: %s", f.name, err)
+		}
+	}
+	return nil
+}
+
+func setValues(fv reflect.Value, values [][]byte) error {
+	if fv.Kind() == reflect.Slice && fv.Type() != bytesType {
+		slice := reflect.MakeSlice(fv.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return setValue(fv, values[0])
+}
+
+func setValue(fv reflect.Value, value []byte) error {
+	if fv.Kind() == reflect.Ptr {
+		elem := reflect.New(fv.Type().Elem())
+		if err := setValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		fv.Set(elem)
+		return nil
+	}
+
+	s := string(value)
+	switch fv.Type() {
+	case bytesType:
+		fv.SetBytes(append([]byte(nil), value...))
+		return nil
+	case timeType:
+		var (
+			t   time.Time
+			err error
+		)
+		if _, numErr := strconv.ParseInt(s, 10, 64); numErr == nil {
+			t, err = ParseFileTime(s)
+		} else {
+			t, err = ParseGeneralizedTime(s)
+		}
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	case guidType:
+		if len(value) != len(GUID{}) {
+			return fmt.Errorf("invalid GUID of %d bytes", len(value))
+		}
+		var g GUID
+		copy(g[:], value)
+		fv.Set(reflect.ValueOf(g))
+		return nil
+	case sidType:
+		sid, err := DecodeSID(value)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(*sid))
+		return nil
+	case dnType:
+		dn, err := ParseDN(s)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(*dn))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			// Active Directory stores 32 bit flags as signed integers
+			i, ierr := strconv.ParseInt(s, 10, fv.Type().Bits())
+			if ierr != nil {
+				return err
+			}
+			n = uint64(i) & (1<<uint(fv.Type().Bits()) - 1)
+		}
+		fv.SetUint(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
+
+// MarshalAddRequest returns an AddRequest creating an entry with the attributes of the struct v.
+// The fields are mapped as described for Entry.Unmarshal, fields without values are skipped.
+// When dn is empty, the DN is taken from the field tagged `ldap:"dn"`.
+func MarshalAddRequest(dn string, v interface{}, controls []Control) (*AddRequest, error) {
+	rv, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	attrs, dnValue, err := marshalFields(rv)
+	if err != nil {
+		return nil, err
+	}
+	if dn == "" {
+		dn = dnValue
+	}
+	req := NewAddRequest(dn, controls)
+	for _, attr := range attrs {
+		if len(attr.values) > 0 {
+			req.Attribute(attr.name, attr.values)
+		}
+	}
+	return req, nil
+}
+
+// MarshalModifyRequest returns a ModifyRequest replacing the attributes of an entry with the
+// fields of the struct v. Fields without values remove the attribute, unless tagged omitempty.
+// When dn is empty, the DN is taken from the field tagged `ldap:"dn"`.
+func MarshalModifyRequest(dn string, v interface{}, controls []Control) (*ModifyRequest, error) {
+	rv, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	attrs, dnValue, err := marshalFields(rv)
+	if err != nil {
+		return nil, err
+	}
+	if dn == "" {
+		dn = dnValue
+	}
+	req := NewModifyRequest(dn, controls)
+	for _, attr := range attrs {
+		req.Replace(attr.name, attr.values)
+	}
+	return req, nil
+}
+
+type marshaledAttribute struct {
+	name   string
+	values []string
+}
+
+func marshalFields(rv reflect.Value) ([]marshaledAttribute, string, error) {
+	var (
+		attrs []marshaledAttribute
+		dn    string
+	)
+	for _, f := range structFields(rv.Type()) {
+		fv := rv.FieldByIndex(f.index)
+		if f.omitEmpty && fv.IsZero() {
+			continue
+		}
+		values, err := fieldValues(fv, f.fileTime)
+		if err != nil {
+			return nil, "", fmt.Errorf("ldap: cannot marshal %s: %s", f.name, err)
+		}
+		if strings.EqualFold(f.name, "dn") {
+			if len(values) > 0 {
+				dn = values[0]
+			}
+			continue
+		}
+		attrs = append(attrs, marshaledAttribute{name: f.name, values: values})
+	}
+	return attrs, dn, nil
+}
+
+func fieldValues(fv reflect.Value, fileTime bool) ([]string, error) {
+	if fv.Kind() == reflect.Slice && fv.Type() != bytesType {
+		values := make([]string, 0, fv.Len())
+		for i := 0; i < fv.Len(); i++ {
+			value, ok, err := fieldValue(fv.Index(i), fileTime)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				values = append(values, value)
+			}
+		}
+		return values, nil
+	}
+	value, ok, err := fieldValue(fv, fileTime)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return []string{value}, nil
+}
+
+// fieldValue returns the attribute value of a field, ok is false when the field holds no value
+func fieldValue(fv reflect.Value, fileTime bool) (value string, ok bool, err error) {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return "", false, nil
+		}
+		fv = fv.Elem()
+	}
+
+	switch fv.Type() {
+	case bytesType:
+		return string(fv.Bytes()), fv.Len() > 0, nil
+	case timeType:
+		t := fv.Interface().(time.Time)
+		if t.IsZero() {
+			return "", false, nil
+		}
+		if fileTime {
+			return FormatFileTime(t), true, nil
+		}
+		return FormatGeneralizedTime(t), true, nil
+	case guidType:
+		g := fv.Interface().(GUID)
+		return string(g[:]), g != GUID{}, nil
+	case sidType:
+		sid := fv.Interface().(SID)
+		return string(sid.Bytes()), true, nil
+	case dnType:
+		dn := fv.Interface().(DN)
+		return dn.String(), len(dn.RDNs) > 0, nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		return fv.String(), fv.Len() > 0, nil
+	case reflect.Bool:
+		if fv.Bool() {
+			return "TRUE", true, nil
+		}
+		return "FALSE", true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(fv.Int(), 10), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(fv.Uint(), 10), true, nil
+	}
+	return "", false, fmt.Errorf("unsupported field type %s", fv.Type())
+}
@@ -0,0 +1,202 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testUser struct {
+	DN                 string             `ldap:"dn"`
+	Name               string             `ldap:"cn"`
+	Mail               *string            `ldap:"mail"`
+	Groups             []string           `ldap:"memberOf"`
+	Parent             DN                 `ldap:"manager,omitempty"`
+	GUID               GUID               `ldap:"objectGUID"`
+	SID                SID                `ldap:"objectSid"`
+	PwdLastSet         time.Time          `ldap:"pwdLastSet,filetime"`
+	WhenCreated        time.Time          `ldap:"whenCreated,omitempty"`
+	UserAccountControl UserAccountControl `ldap:"userAccountControl"`
+	LogonCount         int                `ldap:"logonCount"`
+	Enabled            bool               `ldap:"msDS-Enabled,omitempty"`
+	Photo              []byte             `ldap:"thumbnailPhoto,omitempty"`
+	Ignored            string             `ldap:"-"`
+	internal           string
+}
+
+func TestGUID(t *testing.T) {
+	g, err := ParseGUID("{6e0ad0ba-c0e3-4f2e-8b2c-6e6c70f8d91b}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := GUID{0xba, 0xd0, 0x0a, 0x6e, 0xe3, 0xc0, 0x2e, 0x4f, 0x8b, 0x2c, 0x6e, 0x6c, 0x70, 0xf8, 0xd9, 0x1b}
+	if g != expected {
+		t.Errorf("unexpected GUID bytes %x", g[:])
+	}
+	if s := g.String(); s != "6e0ad0ba-c0e3-4f2e-8b2c-6e6c70f8d91b" {
+		t.Errorf("unexpected GUID string %s", s)
+	}
+	if _, err := ParseGUID("6e0ad0ba"); err == nil {
+		t.Error("expected an error for a short GUID")
+	}
+}
+
+func TestSID(t *testing.T) {
+	sid, err := ParseSID("S-1-5-21-1004336348-1177238915-682003330-512")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeSID(sid.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := decoded.String(); s != "S-1-5-21-1004336348-1177238915-682003330-512" {
+		t.Errorf("unexpected SID %s", s)
+	}
+	if decoded.RID() != 512 {
+		t.Errorf("unexpected RID %d", decoded.RID())
+	}
+	if _, err := DecodeSID([]byte{1, 2, 0, 0, 0, 0, 0, 5}); err == nil {
+		t.Error("expected an error for a truncated SID")
+	}
+}
+
+func TestFileTime(t *testing.T) {
+	tm, err := ParseFileTime("132223104000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tm.Equal(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected time %s", tm)
+	}
+	if s := FormatFileTime(tm); s != "132223104000000000" {
+		t.Errorf("unexpected FILETIME %s", s)
+	}
+	for _, never := range []string{"0", "9223372036854775807"} {
+		if tm, err := ParseFileTime(never); err != nil || !tm.IsZero() {
+			t.Errorf("expected the zero time for %s, got %s, %v", never, tm, err)
+		}
+	}
+}
+
+func TestEntryUnmarshal(t *testing.T) {
+	guid, _ := ParseGUID("6e0ad0ba-c0e3-4f2e-8b2c-6e6c70f8d91b")
+	sid, _ := ParseSID("S-1-5-21-1004336348-1177238915-682003330-1105")
+	entry := NewEntry("CN=Alice,OU=Users,DC=example,DC=com", map[string][]string{
+		"CN":                 {"Alice"},
+		"mail":               {"alice@example.com"},
+		"memberOf":           {"CN=Admins,DC=example,DC=com", "CN=Users,DC=example,DC=com"},
+		"manager":            {"CN=Bob,OU=Users,DC=example,DC=com"},
+		"objectGUID":         {string(guid[:])},
+		"objectSid":          {string(sid.Bytes())},
+		"pwdLastSet":         {"132223104000000000"},
+		"whenCreated":        {"20191231120000.0Z"},
+		"userAccountControl": {"66050"},
+		"logonCount":         {"42"},
+		"msDS-Enabled":       {"TRUE"},
+	})
+
+	var u testUser
+	if err := entry.Unmarshal(&u); err != nil {
+		t.Fatal(err)
+	}
+	if u.DN != entry.DN || u.Name != "Alice" || u.Mail == nil || *u.Mail != "alice@example.com" {
+		t.Errorf("unexpected user %+v", u)
+	}
+	if !reflect.DeepEqual(u.Groups, entry.GetAttributeValues("memberOf")) {
+		t.Errorf("unexpected groups %v", u.Groups)
+	}
+	if u.Parent.String() != "CN=Bob,OU=Users,DC=example,DC=com" {
+		t.Errorf("unexpected manager %s", u.Parent.String())
+	}
+	if u.GUID != guid || u.SID.String() != sid.String() {
+		t.Errorf("unexpected identifiers %s %s", u.GUID, u.SID.String())
+	}
+	if !u.PwdLastSet.Equal(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)) || !u.WhenCreated.Equal(time.Date(2019, 12, 31, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected times %s %s", u.PwdLastSet, u.WhenCreated)
+	}
+	if !u.UserAccountControl.Has(UACNormalAccount|UACDontExpirePassword) || !u.UserAccountControl.Disabled() {
+		t.Errorf("unexpected userAccountControl %s", u.UserAccountControl)
+	}
+	if u.LogonCount != 42 || !u.Enabled {
+		t.Errorf("unexpected user %+v", u)
+	}
+
+	if err := entry.Unmarshal(u); err == nil {
+		t.Error("expected an error when unmarshaling into a non-pointer")
+	}
+	bad := NewEntry("CN=Alice", map[string][]string{"logonCount": {"many"}})
+	if err := bad.Unmarshal(&u); err == nil {
+		t.Error("expected an error for an invalid integer")
+	}
+}
+
+func TestMarshalRequests(t *testing.T) {
+	sid, _ := ParseSID("S-1-5-21-1-2-3-1105")
+	mail := "alice@example.com"
+	u := testUser{
+		DN:                 "CN=Alice,DC=example,DC=com",
+		Name:               "Alice",
+		Mail:               &mail,
+		Groups:             []string{"CN=Admins,DC=example,DC=com"},
+		SID:                *sid,
+		PwdLastSet:         time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		UserAccountControl: UACNormalAccount | UACAccountDisable,
+		Ignored:            "ignored",
+	}
+
+	add, err := MarshalAddRequest("", &u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if add.DN != u.DN {
+		t.Errorf("unexpected DN %s", add.DN)
+	}
+	got := map[string][]string{}
+	for _, attr := range add.Attributes {
+		got[attr.Type] = attr.Vals
+	}
+	expected := map[string][]string{
+		"cn":                 {"Alice"},
+		"mail":               {"alice@example.com"},
+		"memberOf":           {"CN=Admins,DC=example,DC=com"},
+		"objectSid":          {string(sid.Bytes())},
+		"pwdLastSet":         {"132223104000000000"},
+		"userAccountControl": {"514"},
+		"logonCount":         {"0"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected attributes\n got: %q\nwant: %q", got, expected)
+	}
+
+	u.Mail = nil
+	mod, err := MarshalModifyRequest("CN=Other,DC=example,DC=com", u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mod.DN != "CN=Other,DC=example,DC=com" {
+		t.Errorf("unexpected DN %s", mod.DN)
+	}
+	replaced := map[string][]string{}
+	for _, change := range mod.Changes {
+		if change.Operation != ReplaceAttribute {
+			t.Errorf("unexpected operation %d", change.Operation)
+		}
+		replaced[change.Modification.Type] = change.Modification.Vals
+	}
+	if vals, ok := replaced["mail"]; !ok || len(vals) != 0 {
+		t.Errorf("expected mail to be removed, got %v", vals)
+	}
+	for _, omitted := range []string{"manager", "whenCreated", "msDS-Enabled", "thumbnailPhoto", "Ignored", "internal"} {
+		if _, ok := replaced[omitted]; ok {
+			t.Errorf("%s should not be modified", omitted)
+		}
+	}
+}
+
+func TestStructAttributes(t *testing.T) {
+	expected := []string{"cn", "mail", "memberOf", "manager", "objectGUID", "objectSid", "pwdLastSet", "whenCreated", "userAccountControl", "logonCount", "msDS-Enabled", "thumbnailPhoto"}
+	if got := StructAttributes(&testUser{}); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected attributes %v", got)
+	}
+}
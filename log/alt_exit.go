@@ -25,6 +25,7 @@ package log
 import (
 	"fmt"
 	"os"
+	"sync"
 )
 
 var handlers = []func(){}
@@ -74,3 +75,45 @@ func RegisterExitHandler(handler func()) {
 func DeferExitHandler(handler func()) {
 	handlers = append([]func(){handler}, handlers...)
 }
+
+// Flusher is implemented by the outputs and hooks buffering entries.
+type Flusher interface {
+	Flush()
+}
+
+var (
+	flushersMu sync.Mutex
+	flushers   []Flusher
+	flushOnce  sync.Once
+)
+
+// RegisterExitFlusher adds f to the flushers flushed by a single exit handler,
+// until f is removed by UnregisterExitFlusher. Unlike the exit handlers, which
+// cannot be removed, it suits outputs closed before the program exits.
+func RegisterExitFlusher(f Flusher) {
+	flushOnce.Do(func() { RegisterExitHandler(flushExitFlushers) })
+	flushersMu.Lock()
+	defer flushersMu.Unlock()
+	flushers = append(flushers, f)
+}
+
+// UnregisterExitFlusher removes f from the flushers of the exit handler.
+func UnregisterExitFlusher(f Flusher) {
+	flushersMu.Lock()
+	defer flushersMu.Unlock()
+	for i, registered := range flushers {
+		if registered == f {
+			flushers = append(flushers[:i:i], flushers[i+1:]...)
+			return
+		}
+	}
+}
+
+func flushExitFlushers() {
+	flushersMu.Lock()
+	registered := append([]Flusher(nil), flushers...)
+	flushersMu.Unlock()
+	for _, f := range registered {
+		runHandler(f.Flush)
+	}
+}
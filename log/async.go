@@ -0,0 +1,256 @@
+package log
+
+import (
+	"io"
+	"sync"
+
+	"github.com/ThomasNguyenGitHub/go/metrics"
+)
+
+// DefaultAsyncBufferSize is the number of entries buffered by an AsyncWriter
+// when no size is given.
+const DefaultAsyncBufferSize = 1024
+
+// LevelWriter is implemented by outputs that handle entries according to
+// their level. Entries are written to a Logger.Out implementing LevelWriter
+// with WriteLevel instead of Write.
+type LevelWriter interface {
+	io.Writer
+	WriteLevel(level Level, p []byte) (n int, err error)
+}
+
+// OverflowPolicy tells an AsyncWriter what to do with an entry written while
+// its buffer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks the write until the buffer has room for the entry.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest drops the written entry.
+	OverflowDropNewest
+	// OverflowDropDebug makes room by dropping the oldest buffered entry of
+	// the least severe level, when that level is less severe than the
+	// written entry, and otherwise drops the written entry. Trace and debug
+	// entries are dropped first, errors last.
+	OverflowDropDebug
+)
+
+// AsyncWriterOption sets an optional parameter of an AsyncWriter.
+type AsyncWriterOption func(*AsyncWriter)
+
+// AsyncDroppedCounter sets the counter incremented for each dropped entry,
+// with the "level" label set to the level of the entry.
+func AsyncDroppedCounter(counter metrics.Counter) AsyncWriterOption {
+	return func(w *AsyncWriter) { w.dropped = counter }
+}
+
+// AsyncErrorHandler sets the function called with the errors returned by the
+// wrapped writer, which are ignored by default.
+func AsyncErrorHandler(handler func(err error)) AsyncWriterOption {
+	return func(w *AsyncWriter) { w.errorHandler = handler }
+}
+
+type asyncRecord struct {
+	level Level
+	p     []byte
+}
+
+// AsyncWriter is a Logger output buffering entries in a bounded ring buffer
+// that a dedicated goroutine writes to the wrapped writer, so that logging
+// does not wait for a slow output. Panic and fatal entries are written
+// before the write returns, and the buffer is flushed by the exit handlers.
+//
+//	logger.SetOutput(log.NewAsyncWriter(os.Stdout, 0, log.OverflowDropDebug))
+type AsyncWriter struct {
+	out    io.Writer
+	policy OverflowPolicy
+
+	dropped      metrics.Counter
+	errorHandler func(err error)
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	idle     *sync.Cond
+	ring     []asyncRecord
+	head     int
+	count    int
+	writing  bool
+	closed   bool
+	done     chan struct{}
+	drops    uint64
+}
+
+// NewAsyncWriter returns an AsyncWriter buffering up to size entries, or
+// DefaultAsyncBufferSize when size is not positive, before writing them to
+// out. The writer is flushed by Exit and the Fatal log functions.
+func NewAsyncWriter(out io.Writer, size int, policy OverflowPolicy, options ...AsyncWriterOption) *AsyncWriter {
+	if size <= 0 {
+		size = DefaultAsyncBufferSize
+	}
+	w := &AsyncWriter{
+		out:    out,
+		policy: policy,
+		ring:   make([]asyncRecord, size),
+		done:   make(chan struct{}),
+	}
+	w.notEmpty = sync.NewCond(&w.mu)
+	w.notFull = sync.NewCond(&w.mu)
+	w.idle = sync.NewCond(&w.mu)
+	for _, option := range options {
+		option(w)
+	}
+
+	go w.run()
+	RegisterExitFlusher(w)
+	return w
+}
+
+// Write buffers p as an info entry.
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(InfoLevel, p)
+}
+
+// WriteLevel buffers a copy of the entry p logged at level, applying the
+// overflow policy when the buffer is full. Once the writer is closed the
+// entries are written synchronously.
+func (w *AsyncWriter) WriteLevel(level Level, p []byte) (int, error) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return w.out.Write(p)
+	}
+
+	for w.count == len(w.ring) && w.policy == OverflowBlock && !w.closed {
+		w.notFull.Wait()
+	}
+	if w.closed {
+		w.mu.Unlock()
+		return w.out.Write(p)
+	}
+	if w.count == len(w.ring) && !w.evict(level) {
+		w.mu.Unlock()
+		w.drop(level)
+		return len(p), nil
+	}
+
+	record := asyncRecord{level: level, p: make([]byte, len(p))}
+	copy(record.p, p)
+	w.ring[(w.head+w.count)%len(w.ring)] = record
+	w.count++
+	w.notEmpty.Signal()
+	w.mu.Unlock()
+
+	if level <= FatalLevel {
+		w.Flush()
+	}
+	return len(p), nil
+}
+
+// evict removes the oldest buffered entry of the least severe level when the
+// policy allows it and that level is less severe than level. It must be
+// called with mu held.
+func (w *AsyncWriter) evict(level Level) bool {
+	if w.policy != OverflowDropDebug {
+		return false
+	}
+	victim := -1
+	for i := 0; i < w.count; i++ {
+		r := w.ring[(w.head+i)%len(w.ring)]
+		if r.level > level && (victim < 0 || r.level > w.ring[(w.head+victim)%len(w.ring)].level) {
+			victim = i
+		}
+	}
+	if victim < 0 {
+		return false
+	}
+	evicted := w.ring[(w.head+victim)%len(w.ring)].level
+	for i := victim; i < w.count-1; i++ {
+		w.ring[(w.head+i)%len(w.ring)] = w.ring[(w.head+i+1)%len(w.ring)]
+	}
+	w.count--
+	w.ring[(w.head+w.count)%len(w.ring)] = asyncRecord{}
+	w.drops++
+	if w.dropped != nil {
+		w.dropped.With("level", evicted.String()).Add(1)
+	}
+	return true
+}
+
+func (w *AsyncWriter) drop(level Level) {
+	w.mu.Lock()
+	w.drops++
+	w.mu.Unlock()
+	if w.dropped != nil {
+		w.dropped.With("level", level.String()).Add(1)
+	}
+}
+
+// Dropped returns the number of entries dropped since the writer was created.
+func (w *AsyncWriter) Dropped() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.drops
+}
+
+// Flush blocks until the buffered entries are written.
+func (w *AsyncWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for w.count > 0 || w.writing {
+		w.idle.Wait()
+	}
+}
+
+// Close flushes the buffered entries, stops the writer goroutine and removes
+// the writer from the exit flushers. The wrapped writer is not closed.
+func (w *AsyncWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.notEmpty.Broadcast()
+	w.notFull.Broadcast()
+	w.mu.Unlock()
+
+	<-w.done
+	UnregisterExitFlusher(w)
+	return nil
+}
+
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for {
+		for w.count == 0 && !w.closed {
+			w.notEmpty.Wait()
+		}
+		if w.count == 0 {
+			w.idle.Broadcast()
+			return
+		}
+
+		record := w.ring[w.head]
+		w.ring[w.head] = asyncRecord{}
+		w.head = (w.head + 1) % len(w.ring)
+		w.count--
+		w.writing = true
+		w.notFull.Signal()
+
+		w.mu.Unlock()
+		_, err := w.out.Write(record.p)
+		if err != nil && w.errorHandler != nil {
+			w.errorHandler(err)
+		}
+		w.mu.Lock()
+
+		w.writing = false
+		if w.count == 0 {
+			w.idle.Broadcast()
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ThomasNguyenGitHub/go/metrics"
+)
+
+// gatedWriter holds the writes until its gate is opened.
+type gatedWriter struct {
+	gate chan struct{}
+	mu   sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	<-w.gate
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *gatedWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+// levelCounter counts the dropped entries by level.
+type levelCounter struct {
+	counts map[string]float64
+	level  string
+}
+
+func (c *levelCounter) With(labelValues ...string) metrics.Counter {
+	return &levelCounter{counts: c.counts, level: labelValues[1]}
+}
+
+func (c *levelCounter) Add(delta float64) {
+	c.counts[c.level] += delta
+}
+
+func newAsyncTestLogger(policy OverflowPolicy, counter metrics.Counter) (*Logger, *AsyncWriter, *gatedWriter) {
+	out := &gatedWriter{gate: make(chan struct{})}
+	w := NewAsyncWriter(out, 2, policy, AsyncDroppedCounter(counter))
+	logger, _ := newContextTestLogger()
+	logger.Formatter = &TextFormatter{DisableTimestamp: true, DisableColors: true}
+	logger.Level = TraceLevel
+	logger.Out = w
+	return logger, w, out
+}
+
+// waitWriting waits for the writer goroutine to pick up an entry.
+func waitWriting(t *testing.T, w *AsyncWriter) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		w.mu.Lock()
+		writing := w.writing
+		w.mu.Unlock()
+		if writing {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("Expected the writer goroutine to be writing")
+}
+
+func TestAsyncWriterDropDebug(t *testing.T) {
+	counter := &levelCounter{counts: map[string]float64{}}
+	logger, w, out := newAsyncTestLogger(OverflowDropDebug, counter)
+	defer w.Close()
+
+	logger.Info("first")
+	waitWriting(t, w)
+	logger.Debug("debug")
+	logger.Warn("warn")
+	logger.Error("error") // evicts the debug entry
+	logger.Trace("trace") // dropped
+	close(out.gate)
+	w.Flush()
+
+	if got := out.String(); !strings.Contains(got, "first") || !strings.Contains(got, "warn") || !strings.Contains(got, "error") ||
+		strings.Contains(got, "debug") || strings.Contains(got, "trace") {
+		t.Errorf("Unexpected output %q", got)
+	}
+	if w.Dropped() != 2 || counter.counts["debug"] != 1 || counter.counts["trace"] != 1 {
+		t.Errorf("Expected one debug and one trace entry dropped but got %d, %v", w.Dropped(), counter.counts)
+	}
+}
+
+func TestAsyncWriterDropNewest(t *testing.T) {
+	counter := &levelCounter{counts: map[string]float64{}}
+	logger, w, out := newAsyncTestLogger(OverflowDropNewest, counter)
+	defer w.Close()
+
+	logger.Info("first")
+	waitWriting(t, w)
+	logger.Debug("second")
+	logger.Info("third")
+	logger.Error("dropped")
+	close(out.gate)
+	w.Flush()
+
+	if got := out.String(); strings.Contains(got, "dropped") || !strings.Contains(got, "second") {
+		t.Errorf("Unexpected output %q", got)
+	}
+	if counter.counts["error"] != 1 {
+		t.Errorf("Expected one error entry dropped but got %v", counter.counts)
+	}
+}
+
+func TestAsyncWriterBlock(t *testing.T) {
+	logger, w, out := newAsyncTestLogger(OverflowBlock, nil)
+
+	logger.Info("first")
+	waitWriting(t, w)
+	logger.Info("second")
+	logger.Info("third")
+
+	logged := make(chan struct{})
+	go func() {
+		logger.Info("fourth")
+		close(logged)
+	}()
+	select {
+	case <-logged:
+		t.Fatal("Expected the write to block while the buffer is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(out.gate)
+	<-logged
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); strings.Count(got, "level=info") != 4 || w.Dropped() != 0 {
+		t.Errorf("Unexpected output %q", got)
+	}
+
+	// closed writers write synchronously
+	logger.Info("closed")
+	if !strings.Contains(out.String(), "closed") {
+		t.Errorf("Expected the entry to be written after close")
+	}
+}
+
+func TestAsyncWriterExitFlusher(t *testing.T) {
+	registered := func(w *AsyncWriter) bool {
+		flushersMu.Lock()
+		defer flushersMu.Unlock()
+		for _, f := range flushers {
+			if f == w {
+				return true
+			}
+		}
+		return false
+	}
+
+	out := &gatedWriter{gate: make(chan struct{})}
+	close(out.gate)
+	w := NewAsyncWriter(out, 0, OverflowBlock)
+	w.Write([]byte("buffered\n"))
+	if !registered(w) {
+		t.Fatal("Expected the writer to be flushed on exit")
+	}
+	flushExitFlushers()
+	if out.String() != "buffered\n" {
+		t.Errorf("Expected the exit flushers to flush the writer but got %q", out.String())
+	}
+
+	w.Close()
+	if registered(w) {
+		t.Error("Expected the closed writer to be removed from the exit flushers")
+	}
+	w.Flush()
+}
@@ -0,0 +1,101 @@
+package log
+
+import (
+	"context"
+	"sync"
+)
+
+// Key names of the fields added from the context
+const (
+	FieldKeyRequestID = "request_id"
+	FieldKeyUserID    = "user_id"
+	FieldKeyChannel   = "channel"
+	FieldKeyDeviceID  = "device_id"
+	FieldKeyTraceID   = "trace_id"
+	FieldKeySpanID    = "span_id"
+)
+
+// ContextExtractor returns the fields to add to the entries logged with a
+// context, see WithContext. It returns nil when the context holds nothing of
+// interest.
+type ContextExtractor func(ctx context.Context) Fields
+
+var (
+	contextExtractorsMu sync.RWMutex
+	// extractors applied by every logger
+	contextExtractors []ContextExtractor
+)
+
+// RegisterContextExtractor adds an extractor applied by every logger. It is
+// meant to be called from the init function of the package owning the
+// context values, transport/http registers the fields of its RequestContext
+// and log/logtrace the IDs of the current span.
+func RegisterContextExtractor(extractor ContextExtractor) {
+	contextExtractorsMu.Lock()
+	defer contextExtractorsMu.Unlock()
+	contextExtractors = append(contextExtractors, extractor)
+}
+
+// AddContextExtractor adds an extractor applied to the entries of this
+// logger only, after the registered ones.
+func (logger *Logger) AddContextExtractor(extractor ContextExtractor) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	logger.ContextExtractors = append(logger.ContextExtractors, extractor)
+}
+
+// contextFields returns the fields extracted from ctx by the registered
+// extractors, by the extractors of the logger and then the fields attached
+// with ContextWithFields, later extractors overriding the fields of earlier
+// ones. The fields set explicitly on the context thus win over the extracted
+// ones.
+func contextFields(ctx context.Context, extractors []ContextExtractor) Fields {
+	contextExtractorsMu.RLock()
+	all := make([]ContextExtractor, 0, len(contextExtractors)+len(extractors)+1)
+	all = append(all, contextExtractors...)
+	contextExtractorsMu.RUnlock()
+	all = append(all, extractors...)
+	all = append(all, FieldsFromContext)
+
+	var fields Fields
+	for _, extractor := range all {
+		for k, v := range extractor(ctx) {
+			if fields == nil {
+				fields = make(Fields)
+			}
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+type fieldsContextKey struct{}
+
+// ContextWithFields returns a copy of ctx carrying the given fields in
+// addition to the fields already attached to ctx. The fields are added to
+// the entries logged with the returned context or any context derived from
+// it, e.g. by a middleware for the downstream log calls.
+func ContextWithFields(ctx context.Context, fields Fields) context.Context {
+	parent := FieldsFromContext(ctx)
+	data := make(Fields, len(parent)+len(fields))
+	for k, v := range parent {
+		data[k] = v
+	}
+	for k, v := range fields {
+		data[k] = v
+	}
+	return context.WithValue(ctx, fieldsContextKey{}, data)
+}
+
+// ContextWithField returns a copy of ctx carrying a single field, see
+// ContextWithFields.
+func ContextWithField(ctx context.Context, key string, value interface{}) context.Context {
+	return ContextWithFields(ctx, Fields{key: value})
+}
+
+// FieldsFromContext returns the fields attached to ctx by ContextWithFields.
+// The returned fields must not be modified.
+func FieldsFromContext(ctx context.Context) Fields {
+	fields, _ := ctx.Value(fieldsContextKey{}).(Fields)
+	return fields
+}
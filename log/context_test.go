@@ -0,0 +1,84 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func newContextTestLogger() (*Logger, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	logger := &Logger{
+		Out:          buf,
+		Formatter:    &JSONFormatter{DisableTimestamp: true},
+		Hooks:        make(LevelHooks),
+		Level:        InfoLevel,
+		ExitFunc:     func(int) {},
+		ReportCaller: false,
+	}
+	return logger, buf
+}
+
+func decodeEntry(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	t.Helper()
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &fields); err != nil {
+		t.Fatalf("Unexpected output %q: %v", buf.String(), err)
+	}
+	buf.Reset()
+	return fields
+}
+
+func TestContextWithFields(t *testing.T) {
+	logger, buf := newContextTestLogger()
+
+	ctx := ContextWithFields(context.Background(), Fields{FieldKeyRequestID: "req-1", FieldKeyUserID: "alice"})
+	child := ContextWithField(ctx, FieldKeyUserID, "bob")
+
+	logger.WithContext(child).WithField(FieldKeyChannel, "web").Info("hello")
+	fields := decodeEntry(t, buf)
+	for k, v := range map[string]string{FieldKeyRequestID: "req-1", FieldKeyUserID: "bob", FieldKeyChannel: "web"} {
+		if fields[k] != v {
+			t.Errorf("Expected %s to be %q but got %v", k, v, fields[k])
+		}
+	}
+
+	// the parent context is unchanged
+	if user := FieldsFromContext(ctx)[FieldKeyUserID]; user != "alice" {
+		t.Errorf("Expected the parent user to be alice but got %v", user)
+	}
+
+	// explicit fields win over context fields
+	logger.WithContext(ctx).WithField(FieldKeyRequestID, "explicit").Info("hello")
+	if fields := decodeEntry(t, buf); fields[FieldKeyRequestID] != "explicit" {
+		t.Errorf("Expected the explicit request_id but got %v", fields[FieldKeyRequestID])
+	}
+}
+
+func TestAddContextExtractor(t *testing.T) {
+	logger, buf := newContextTestLogger()
+	logger.AddContextExtractor(func(ctx context.Context) Fields {
+		return Fields{FieldKeyDeviceID: "device-1"}
+	})
+
+	logger.WithContext(context.Background()).Info("hello")
+	if fields := decodeEntry(t, buf); fields[FieldKeyDeviceID] != "device-1" {
+		t.Errorf("Expected device_id to be device-1 but got %v", fields[FieldKeyDeviceID])
+	}
+
+	// the fields attached to the context win over the extracted ones
+	logger.AddContextExtractor(func(ctx context.Context) Fields {
+		return Fields{FieldKeyRequestID: "extracted"}
+	})
+	logger.WithContext(ContextWithField(context.Background(), FieldKeyRequestID, "explicit")).Info("hello")
+	if fields := decodeEntry(t, buf); fields[FieldKeyRequestID] != "explicit" {
+		t.Errorf("Expected the request_id of the context but got %v", fields[FieldKeyRequestID])
+	}
+
+	// entries without a context are left alone
+	logger.Info("hello")
+	if fields := decodeEntry(t, buf); fields[FieldKeyDeviceID] != nil {
+		t.Errorf("Expected no device_id but got %v", fields[FieldKeyDeviceID])
+	}
+}
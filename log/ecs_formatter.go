@@ -0,0 +1,185 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ECSVersion is the version of the Elastic Common Schema the ECSFormatter
+// complies with.
+const ECSVersion = "8.11.0"
+
+// ecsTimestampFormat is the format of @timestamp, in UTC.
+const ecsTimestampFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// ecsFields maps the keys of the known fields to their ECS field.
+var ecsFields = map[string]string{
+	FieldKeyRequestID: "http.request.id",
+	FieldKeyUserID:    "user.id",
+	FieldKeyDeviceID:  "device.id",
+	FieldKeyTraceID:   "trace.id",
+	FieldKeySpanID:    "span.id",
+	FieldKeyLogger:    "log.logger",
+}
+
+// ECSFormatter formats logs into Elastic Common Schema JSON documents.
+//
+// The error field is rendered as the ECS error, with its type, message and
+// stack trace. The known fields, such as the request, user, trace and span
+// IDs, are mapped to their ECS field, the other fields being nested under
+// Namespace.
+type ECSFormatter struct {
+	// ServiceName, ServiceVersion and Environment are the service.name,
+	// service.version and service.environment of the documents
+	ServiceName    string
+	ServiceVersion string
+	Environment    string
+
+	// Namespace is the field the fields without ECS equivalent are nested
+	// under, "fields" by default
+	Namespace string
+
+	// StackTrace adds the stack of the caller of the logger to the errors
+	// that do not carry a stack trace
+	StackTrace bool
+
+	// DisableHTMLEscape allows disabling html escaping in output
+	DisableHTMLEscape bool
+}
+
+// Format renders a single log entry
+func (f *ECSFormatter) Format(entry *Entry) ([]byte, error) {
+	doc := map[string]interface{}{
+		"@timestamp": entry.Time.UTC().Format(ecsTimestampFormat),
+		"message":    entry.Message,
+	}
+	setPath(doc, "ecs.version", ECSVersion)
+	setPath(doc, "log.level", entry.Level.String())
+	setPath(doc, "log.syslog.severity.code", syslogSeverity(entry.Level))
+	setPath(doc, "log.syslog.severity.name", syslogSeverityName(entry.Level))
+
+	if entry.HasCaller() {
+		setPath(doc, "log.origin.file.name", entry.Caller.File)
+		setPath(doc, "log.origin.file.line", entry.Caller.Line)
+		setPath(doc, "log.origin.function", entry.Caller.Function)
+	}
+	for path, v := range map[string]string{
+		"service.name":        f.ServiceName,
+		"service.version":     f.ServiceVersion,
+		"service.environment": f.Environment,
+	} {
+		if v != "" {
+			setPath(doc, path, v)
+		}
+	}
+
+	namespace := f.Namespace
+	if namespace == "" {
+		namespace = "fields"
+	}
+	for k, v := range entry.Data {
+		if err, ok := v.(error); ok && k == ErrorKey {
+			typ, msg, stack := errorDetails(entry, err, f.StackTrace)
+			setPath(doc, "error.type", typ)
+			setPath(doc, "error.message", msg)
+			if stack != "" {
+				setPath(doc, "error.stack_trace", stack)
+			}
+			continue
+		}
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		if path, ok := ecsFields[k]; ok {
+			setPath(doc, path, v)
+		} else {
+			setPath(doc, namespace+"."+k, v)
+		}
+	}
+	if entry.err != "" {
+		setPath(doc, namespace+"."+FieldKeyLogrusError, entry.err)
+	}
+
+	return encodeDocument(entry, doc, f.DisableHTMLEscape)
+}
+
+// setPath sets the value at the dotted path of doc, creating the nested
+// objects. A value already at a prefix of the path is moved under the
+// empty key of the object replacing it.
+func setPath(doc map[string]interface{}, path string, v interface{}) {
+	keys := strings.Split(path, ".")
+	for _, k := range keys[:len(keys)-1] {
+		nested, ok := doc[k].(map[string]interface{})
+		if !ok {
+			nested = make(map[string]interface{})
+			if prev, exists := doc[k]; exists {
+				nested[""] = prev
+			}
+			doc[k] = nested
+		}
+		doc = nested
+	}
+	last := keys[len(keys)-1]
+	if nested, ok := doc[last].(map[string]interface{}); ok {
+		nested[""] = v
+		return
+	}
+	doc[last] = v
+}
+
+// errorDetails returns the type, message and stack trace of err, the error
+// of entry. The stack trace is the one of errors with a StackTrace method,
+// printed with the %+v verb, or the stack of the caller of the logger when
+// stack is set.
+func errorDetails(entry *Entry, err error, stack bool) (typ, msg, trace string) {
+	typ, msg = fmt.Sprintf("%T", err), err.Error()
+	// the errors of github.com/pkg/errors and the like
+	if m := reflect.ValueOf(err).MethodByName("StackTrace"); m.IsValid() && m.Type().NumIn() == 0 {
+		trace = fmt.Sprintf("%+v", err)
+	} else if stack {
+		trace = entry.callerStack()
+	}
+	return typ, msg, trace
+}
+
+// syslogSeverity returns the syslog severity code of level.
+func syslogSeverity(level Level) int {
+	switch level {
+	case PanicLevel:
+		return 0
+	case FatalLevel:
+		return 2
+	case ErrorLevel:
+		return 3
+	case WarnLevel:
+		return 4
+	case InfoLevel:
+		return 6
+	default:
+		return 7
+	}
+}
+
+func syslogSeverityName(level Level) string {
+	return [...]string{"Emergency", "Alert", "Critical", "Error", "Warning", "Notice", "Informational", "Debug"}[syslogSeverity(level)]
+}
+
+// encodeDocument encodes doc as a JSON line, in the buffer of entry when set.
+func encodeDocument(entry *Entry, doc interface{}, disableHTMLEscape bool) ([]byte, error) {
+	var b *bytes.Buffer
+	if entry.Buffer != nil {
+		b = entry.Buffer
+	} else {
+		b = &bytes.Buffer{}
+	}
+
+	encoder := json.NewEncoder(b)
+	encoder.SetEscapeHTML(!disableHTMLEscape)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to marshal fields to JSON, %w", err)
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,106 @@
+package log
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// lookup returns the value at the dotted path of doc.
+func lookup(doc map[string]interface{}, path string) interface{} {
+	keys := strings.Split(path, ".")
+	for _, k := range keys[:len(keys)-1] {
+		nested, ok := doc[k].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		doc = nested
+	}
+	return doc[keys[len(keys)-1]]
+}
+
+func TestECSFormatter(t *testing.T) {
+	logger, buf := newContextTestLogger()
+	logger.SetReportCaller(true)
+	logger.SetFormatter(&ECSFormatter{ServiceName: "payment", ServiceVersion: "1.2.0", Environment: "production"})
+
+	logger.WithFields(Fields{
+		ErrorKey:          errors.New("insufficient funds"),
+		FieldKeyRequestID: "req-1",
+		FieldKeyUserID:    "42",
+		"amount":          1000,
+		"order":           map[string]interface{}{"id": "o-1"},
+	}).Error("payment failed")
+	doc := decodeEntry(t, buf)
+
+	expected := map[string]interface{}{
+		"message":                  "payment failed",
+		"ecs.version":              ECSVersion,
+		"log.level":                "error",
+		"log.syslog.severity.code": float64(3),
+		"log.syslog.severity.name": "Error",
+		"service.name":             "payment",
+		"service.version":          "1.2.0",
+		"service.environment":      "production",
+		"error.type":               "*errors.errorString",
+		"error.message":            "insufficient funds",
+		"http.request.id":          "req-1",
+		"user.id":                  "42",
+		"fields.amount":            float64(1000),
+		"fields.order.id":          "o-1",
+	}
+	for path, v := range expected {
+		if got := lookup(doc, path); got != v {
+			t.Errorf("Expected %s to be %v but got %v", path, v, got)
+		}
+	}
+	if _, ok := doc["@timestamp"].(string); !ok {
+		t.Errorf("Expected a @timestamp but got %v", doc["@timestamp"])
+	}
+	// the frames of the log package are skipped, tests included
+	if file, _ := lookup(doc, "log.origin.file.name").(string); file == "" {
+		t.Error("Expected the caller file")
+	}
+	if line, _ := lookup(doc, "log.origin.file.line").(float64); line == 0 {
+		t.Error("Expected the caller line")
+	}
+	if lookup(doc, "error.stack_trace") != nil {
+		t.Error("Expected no stack trace for an error without one")
+	}
+
+	logger.SetFormatter(&ECSFormatter{Namespace: "labels", StackTrace: true})
+	logger.WithError(errors.New("boom")).WithField("order", "o-2").Warn("retrying")
+	doc = decodeEntry(t, buf)
+	if got := lookup(doc, "labels.order"); got != "o-2" {
+		t.Errorf("Expected the field under the namespace but got %v", got)
+	}
+	// the stack starts at the caller of the logger, the test being skipped
+	// with the log package
+	if stack, _ := lookup(doc, "error.stack_trace").(string); !strings.HasPrefix(stack, "testing.tRunner") || strings.Contains(stack, "Format") {
+		t.Errorf("Expected the stack of the caller but got %q", stack)
+	}
+	if doc["service"] != nil {
+		t.Errorf("Expected no service without name but got %v", doc["service"])
+	}
+}
+
+func TestSetPath(t *testing.T) {
+	doc := map[string]interface{}{}
+	setPath(doc, "a", 1)
+	setPath(doc, "a.b", 2)
+	setPath(doc, "c.d", 3)
+	setPath(doc, "c", 4)
+
+	if got := lookup(doc, "a."); got != 1 {
+		t.Errorf("Expected the previous value under the empty key but got %v", got)
+	}
+	if got := lookup(doc, "a.b"); got != 2 {
+		t.Errorf("Expected 2 but got %v", got)
+	}
+	if got := lookup(doc, "c."); got != 4 {
+		t.Errorf("Expected the value under the empty key but got %v", got)
+	}
+	if got := lookup(doc, "c.d"); got != 3 {
+		t.Errorf("Expected 3 but got %v", got)
+	}
+}
@@ -27,6 +27,7 @@ var (
 const (
 	maximumCallerDepth int = 25
 	knownLogrusFrames  int = 4
+	maximumStackDepth  int = 64
 )
 
 func init() {
@@ -69,6 +70,13 @@ type Entry struct {
 	// err may contain a field formatting error
 	err        string
 	ServerName string
+
+	// name of the named logger the entry was created by, see Logger.Named
+	name string
+
+	// stack holds the program counters of the goroutine logging an entry
+	// with an error, rendered by the formatters with a StackTrace option
+	stack []uintptr
 }
 
 func NewEntry(logger *Logger) *Entry {
@@ -84,7 +92,7 @@ func (entry *Entry) Dup() *Entry {
 	for k, v := range entry.Data {
 		data[k] = v
 	}
-	return &Entry{Logger: entry.Logger, Data: data, Time: entry.Time, Context: entry.Context, err: entry.err}
+	return &Entry{Logger: entry.Logger, Data: data, Time: entry.Time, Context: entry.Context, err: entry.err, name: entry.name}
 }
 
 // Returns the bytes representation of this entry from the formatter.
@@ -108,13 +116,15 @@ func (entry *Entry) WithError(err error) *Entry {
 	return entry.WithField(ErrorKey, err)
 }
 
-// Add a context to the Entry.
+// Add a context to the Entry. The fields extracted from the context by the
+// context extractors are added when the entry is logged, see
+// RegisterContextExtractor and ContextWithFields.
 func (entry *Entry) WithContext(ctx context.Context) *Entry {
 	dataCopy := make(Fields, len(entry.Data))
 	for k, v := range entry.Data {
 		dataCopy[k] = v
 	}
-	return &Entry{Logger: entry.Logger, Data: dataCopy, Time: entry.Time, err: entry.err, Context: ctx}
+	return &Entry{Logger: entry.Logger, Data: dataCopy, Time: entry.Time, err: entry.err, Context: ctx, name: entry.name}
 }
 
 // Add a single field to the Entry.
@@ -148,7 +158,7 @@ func (entry *Entry) WithFields(fields Fields) *Entry {
 			data[k] = v
 		}
 	}
-	return &Entry{Logger: entry.Logger, Data: data, Time: entry.Time, err: fieldErr, Context: entry.Context}
+	return &Entry{Logger: entry.Logger, Data: data, Time: entry.Time, err: fieldErr, Context: entry.Context, name: entry.name}
 }
 
 // Overrides the time of the Entry.
@@ -157,7 +167,7 @@ func (entry *Entry) WithTime(t time.Time) *Entry {
 	for k, v := range entry.Data {
 		dataCopy[k] = v
 	}
-	return &Entry{Logger: entry.Logger, Data: dataCopy, Time: t, err: entry.err, Context: entry.Context}
+	return &Entry{Logger: entry.Logger, Data: dataCopy, Time: t, err: entry.err, Context: entry.Context, name: entry.name}
 }
 
 // getPackageName reduces a fully qualified function name to the package name
@@ -203,8 +213,9 @@ func getCaller() *runtime.Frame {
 	for f, again := frames.Next(); again; f, again = frames.Next() {
 		pkg := getPackageName(f.Function)
 
-		// If the caller isn't part of this package, we're done
-		if pkg != logrusPackage {
+		// If the caller isn't part of this package, or of log/slog when
+		// logging through a SlogHandler, we're done
+		if pkg != logrusPackage && pkg != "log/slog" {
 			return &f //nolint:scopelint
 		}
 	}
@@ -213,6 +224,33 @@ func getCaller() *runtime.Frame {
 	return nil
 }
 
+// callerStack renders the stack of the goroutine which logged the entry, as
+// debug.Stack does, from the caller of the logger: the frames of this
+// package and of log/slog are skipped.
+func (entry *Entry) callerStack() string {
+	if len(entry.stack) == 0 {
+		return ""
+	}
+	pkg := reflect.TypeOf(Entry{}).PkgPath()
+	var (
+		b      strings.Builder
+		caller bool
+	)
+	frames := runtime.CallersFrames(entry.stack)
+	for f, more := frames.Next(); ; f, more = frames.Next() {
+		if name := getPackageName(f.Function); !caller && name != pkg && name != "log/slog" {
+			caller = true
+		}
+		if caller {
+			fmt.Fprintf(&b, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		}
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
 func (entry Entry) HasCaller() (has bool) {
 	return entry.Logger != nil &&
 		entry.Logger.ReportCaller &&
@@ -234,11 +272,30 @@ func (entry *Entry) log(level Level, msg string) {
 	newEntry.Logger.mu.Lock()
 	reportCaller := newEntry.Logger.ReportCaller
 	bufPool := newEntry.getBufferPool()
+	extractors := newEntry.Logger.ContextExtractors
+	sampler := newEntry.Logger.Sampler
 	newEntry.Logger.mu.Unlock()
 
+	if newEntry.Context != nil {
+		// fields set explicitly on the entry win over the context ones
+		for k, v := range contextFields(newEntry.Context, extractors) {
+			if _, ok := newEntry.Data[k]; !ok {
+				newEntry.Data[k] = v
+			}
+		}
+	}
+
+	if sampler != nil && level > FatalLevel && !sampler.Sample(newEntry) {
+		return
+	}
+
 	if reportCaller {
 		newEntry.Caller = getCaller()
 	}
+	if _, ok := newEntry.Data[ErrorKey].(error); ok {
+		newEntry.stack = make([]uintptr, maximumStackDepth)
+		newEntry.stack = newEntry.stack[:runtime.Callers(2, newEntry.stack)]
+	}
 
 	newEntry.fireHooks()
 	buffer = bufPool.Get()
@@ -292,7 +349,12 @@ func (entry *Entry) write() {
 		fmt.Fprintf(os.Stderr, "Failed to obtain reader, %v\n", err)
 		return
 	}
-	if _, err := entry.Logger.Out.Write(serialized); err != nil {
+	if lw, ok := entry.Logger.Out.(LevelWriter); ok {
+		_, err = lw.WriteLevel(entry.Level, serialized)
+	} else {
+		_, err = entry.Logger.Out.Write(serialized)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to write to log, %v\n", err)
 	}
 }
@@ -301,7 +363,7 @@ func (entry *Entry) write() {
 // Warning: using Log at Panic or Fatal level will not respectively Panic nor Exit.
 // For this behaviour Entry.Panic or Entry.Fatal should be used instead.
 func (entry *Entry) Log(level Level, args ...interface{}) {
-	if entry.Logger.IsLevelEnabled(level) {
+	if entry.IsLevelEnabled(level) {
 		entry.log(level, fmt.Sprint(args...))
 	}
 }
@@ -346,7 +408,7 @@ func (entry *Entry) Panic(args ...interface{}) {
 // Entry Printf family functions
 
 func (entry *Entry) Logf(level Level, format string, args ...interface{}) {
-	if entry.Logger.IsLevelEnabled(level) {
+	if entry.IsLevelEnabled(level) {
 		entry.Log(level, fmt.Sprintf(format, args...))
 	}
 }
@@ -391,7 +453,7 @@ func (entry *Entry) Panicf(format string, args ...interface{}) {
 // Entry Println family functions
 
 func (entry *Entry) Logln(level Level, args ...interface{}) {
-	if entry.Logger.IsLevelEnabled(level) {
+	if entry.IsLevelEnabled(level) {
 		entry.Log(level, entry.sprintlnn(args...))
 	}
 }
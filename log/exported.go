@@ -31,6 +31,11 @@ func SetReportCaller(include bool) {
 	std.SetReportCaller(include)
 }
 
+// SetSampler sets the standard logger sampler.
+func SetSampler(sampler Sampler) {
+	std.SetSampler(sampler)
+}
+
 // SetLevel sets the standard logger level.
 func SetLevel(level Level) {
 	std.SetLevel(level)
@@ -57,6 +62,8 @@ func WithError(err error) *Entry {
 }
 
 // WithContext creates an entry from the standard logger and adds a context to it.
+// The fields extracted from the context, such as the request ID, the user ID or
+// the trace ID, are added to the entry when it is logged.
 func WithContext(ctx context.Context) *Entry {
 	return std.WithContext(ctx)
 }
@@ -0,0 +1,67 @@
+package hooks
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ThomasNguyenGitHub/go/log"
+	"github.com/ThomasNguyenGitHub/go/util/conn"
+)
+
+// DefaultTimeout is the dial and write timeout of the connections.
+const DefaultTimeout = 5 * time.Second
+
+// ErrConnectionUnavailable is returned while waiting to reconnect after a
+// connection failure.
+var ErrConnectionUnavailable = conn.ErrConnectionUnavailable
+
+// managedConn writes to the connection of a conn.Manager, which redials it
+// with an exponential backoff after failures so that logging does not wait
+// on a down collector.
+type managedConn struct {
+	timeout time.Duration
+
+	// mu keeps the messages written in several calls together
+	mu      sync.Mutex
+	manager *conn.Manager
+}
+
+// newConn returns a managedConn to address, network being udp, tcp or tls.
+func newConn(network, address string, tlsConfig *tls.Config, timeout time.Duration) *managedConn {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	dialer := func(network, address string) (net.Conn, error) {
+		d := &net.Dialer{Timeout: timeout}
+		if network == "tls" {
+			return tls.DialWithDialer(d, "tcp", address, tlsConfig)
+		}
+		return d.Dial(network, address)
+	}
+	// the errors are returned by Fire, logging them could loop through the hook
+	manager := conn.NewManager(dialer, network, address, time.After, log.NewNopLogger())
+	return &managedConn{timeout: timeout, manager: manager}
+}
+
+// do calls fn with the connection, the connection being redialed when fn
+// fails.
+func (c *managedConn) do(fn func(net.Conn) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	nc := c.manager.Take()
+	if nc == nil {
+		return ErrConnectionUnavailable
+	}
+	nc.SetWriteDeadline(time.Now().Add(c.timeout))
+	err := fn(nc)
+	c.manager.Put(err)
+	return err
+}
+
+// Close closes the connection.
+func (c *managedConn) Close() error {
+	return c.manager.Close()
+}
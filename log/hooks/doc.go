@@ -0,0 +1,10 @@
+// Package hooks provides log.Hook implementations shipping the log entries
+// to remote collectors: a RFC 5424 syslog server, a Graylog GELF input or
+// any HTTP endpoint accepting batches of entries.
+//
+//	hook, err := hooks.NewSyslog("tcp", "logs.example.com:514")
+//	if err != nil {
+//		...
+//	}
+//	log.AddHook(hook)
+package hooks
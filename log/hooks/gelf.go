@@ -0,0 +1,205 @@
+package hooks
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/ThomasNguyenGitHub/go/log"
+)
+
+const (
+	// DefaultChunkSize is the size of the UDP chunks, fitting the MTU of
+	// most networks.
+	DefaultChunkSize = 1420
+
+	gelfChunkHeaderSize = 12
+	gelfMaxChunks       = 128
+)
+
+var gelfChunkMagic = []byte{0x1e, 0x0f}
+
+// gelfFieldPattern matches the valid additional field names
+var gelfFieldPattern = regexp.MustCompile(`[^\w.\-]`)
+
+// GELF is a Hook sending the entries to a Graylog GELF input, over UDP or
+// TCP. UDP messages are gzip compressed when Compress is set and chunked
+// when larger than ChunkSize. TCP messages are null byte delimited and can
+// not be compressed. The fields of the entries are sent as additional
+// fields, prefixed by an underscore.
+type GELF struct {
+	// Host is the host field of the messages, the host name by default
+	Host string
+	// Compress gzips the UDP messages
+	Compress bool
+	// ChunkSize is the maximum size of the UDP datagrams, DefaultChunkSize
+	// by default
+	ChunkSize int
+	// Extra are additional fields added to all the messages
+	Extra log.Fields
+	// LogLevels are the levels the hook fires on, log.AllLevels when empty
+	LogLevels []log.Level
+
+	network string
+	conn    *managedConn
+}
+
+// NewGELF returns a GELF hook sending the entries to address, network being
+// udp or tcp.
+func NewGELF(network, address string) (*GELF, error) {
+	switch network {
+	case "udp", "tcp":
+	default:
+		return nil, fmt.Errorf("gelf: unsupported network %q", network)
+	}
+	hostname, _ := os.Hostname()
+	return &GELF{
+		Host:      hostname,
+		Compress:  network == "udp",
+		ChunkSize: DefaultChunkSize,
+		network:   network,
+		conn:      newConn(network, address, nil, DefaultTimeout),
+	}, nil
+}
+
+// Levels implements log.Hook.
+func (h *GELF) Levels() []log.Level {
+	if len(h.LogLevels) == 0 {
+		return log.AllLevels
+	}
+	return h.LogLevels
+}
+
+// Fire implements log.Hook.
+func (h *GELF) Fire(entry *log.Entry) error {
+	msg, err := h.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	if h.network == "tcp" {
+		return h.conn.do(func(c net.Conn) error {
+			_, err := c.Write(append(msg, 0))
+			return err
+		})
+	}
+
+	if h.Compress {
+		var b bytes.Buffer
+		zw := gzip.NewWriter(&b)
+		zw.Write(msg)
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		msg = b.Bytes()
+	}
+	chunks, err := h.chunks(msg)
+	if err != nil {
+		return err
+	}
+	return h.conn.do(func(c net.Conn) error {
+		for _, chunk := range chunks {
+			if _, err := c.Write(chunk); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close closes the connection to the server.
+func (h *GELF) Close() error {
+	return h.conn.Close()
+}
+
+// Format returns the GELF 1.1 JSON message of entry.
+func (h *GELF) Format(entry *log.Entry) ([]byte, error) {
+	msg := map[string]interface{}{
+		"version":       "1.1",
+		"host":          h.Host,
+		"short_message": entry.Message,
+		"timestamp":     float64(entry.Time.UnixNano()/int64(time.Millisecond)) / 1000,
+		"level":         Severity(entry.Level),
+	}
+	if entry.Message == "" {
+		// short_message is required
+		msg["short_message"] = "-"
+	}
+	if entry.Caller != nil {
+		msg["_file"] = entry.Caller.File
+		msg["_line"] = entry.Caller.Line
+	}
+	for k, v := range h.Extra {
+		msg[gelfFieldName(k)] = gelfFieldValue(v)
+	}
+	for k, v := range entry.Data {
+		msg[gelfFieldName(k)] = gelfFieldValue(v)
+	}
+	return json.Marshal(msg)
+}
+
+// chunks splits msg in the UDP datagrams sent to the server.
+func (h *GELF) chunks(msg []byte) ([][]byte, error) {
+	size := h.ChunkSize
+	if size <= gelfChunkHeaderSize {
+		size = DefaultChunkSize
+	}
+	if len(msg) <= size {
+		return [][]byte{msg}, nil
+	}
+
+	size -= gelfChunkHeaderSize
+	count := int(math.Ceil(float64(len(msg)) / float64(size)))
+	if count > gelfMaxChunks {
+		return nil, fmt.Errorf("gelf: message of %d bytes exceeds %d chunks", len(msg), gelfMaxChunks)
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	chunks := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(msg) {
+			end = len(msg)
+		}
+		chunk := make([]byte, 0, gelfChunkHeaderSize+end-i*size)
+		chunk = append(chunk, gelfChunkMagic...)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, msg[i*size:end]...)
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// gelfFieldName returns the additional field name of the field k.
+func gelfFieldName(k string) string {
+	k = gelfFieldPattern.ReplaceAllString(k, "_")
+	if k == "id" {
+		// _id is reserved
+		return "_id_"
+	}
+	return "_" + k
+}
+
+// gelfFieldValue returns v as a number or a string, the only types of the
+// additional fields.
+func gelfFieldValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v
+	case bool:
+		return fmt.Sprint(v)
+	default:
+		return fieldString(v)
+	}
+}
@@ -0,0 +1,157 @@
+package hooks
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ThomasNguyenGitHub/go/log"
+)
+
+func TestGELFFormat(t *testing.T) {
+	hook, err := NewGELF("udp", "127.0.0.1:12201")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.Host = "host"
+	hook.Extra = log.Fields{"env": "test"}
+	entry := testEntry()
+	entry.Data["id"] = "42"
+	entry.Data["ok"] = true
+
+	b, err := hook.Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg map[string]interface{}
+	if err := json.Unmarshal(b, &msg); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"version":       "1.1",
+		"host":          "host",
+		"short_message": "payment failed",
+		"timestamp":     1704189600.123,
+		"level":         float64(3),
+		"_user":         "alice",
+		"_a_b":          float64(1),
+		"_id_":          "42",
+		"_ok":           "true",
+		"_env":          "test",
+		"_quote":        `say "hi" [now]`,
+	}
+	for k, v := range expected {
+		if msg[k] != v {
+			t.Errorf("Expected %s to be %v but got %v", k, v, msg[k])
+		}
+	}
+}
+
+func TestGELFUDPChunked(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	hook, _ := NewGELF("udp", pc.LocalAddr().String())
+	defer hook.Close()
+	hook.ChunkSize = 64
+	entry := testEntry()
+	entry.Message = strings.Repeat("long message ", 20)
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		payload []byte
+		count   = -1
+		id      []byte
+	)
+	buf := make([]byte, 2048)
+	for i := 0; i != count; i++ {
+		pc.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunk := buf[:n]
+		if n > 64 || !bytes.Equal(chunk[:2], gelfChunkMagic) || int(chunk[10]) != i {
+			t.Fatalf("Unexpected chunk %d: %x", i, chunk)
+		}
+		if id == nil {
+			id, count = append([]byte(nil), chunk[2:10]...), int(chunk[11])
+		} else if !bytes.Equal(id, chunk[2:10]) {
+			t.Fatalf("Expected the message ID %x but got %x", id, chunk[2:10])
+		}
+		payload = append(payload, chunk[12:]...)
+	}
+	if count < 2 {
+		t.Fatalf("Expected several chunks but got %d", count)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(zr)
+	var msg map[string]interface{}
+	if err := json.Unmarshal(b, &msg); err != nil || msg["short_message"] != entry.Message {
+		t.Errorf("Unexpected message %s, %v", b, err)
+	}
+}
+
+func TestGELFTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	msgc := make(chan []string, 1)
+	go func() {
+		var msgs []string
+		defer func() { msgc <- msgs }()
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		r := bufio.NewReader(c)
+		for len(msgs) < 2 {
+			msg, err := r.ReadString(0)
+			if err != nil {
+				return
+			}
+			msgs = append(msgs, strings.TrimSuffix(msg, "\x00"))
+		}
+	}()
+
+	hook, _ := NewGELF("tcp", l.Addr().String())
+	defer hook.Close()
+	for i := 0; i < 2; i++ {
+		if err := hook.Fire(testEntry()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case msgs := <-msgc:
+		if len(msgs) != 2 {
+			t.Fatalf("Expected 2 messages but got %q", msgs)
+		}
+		for _, msg := range msgs {
+			var m map[string]interface{}
+			if err := json.Unmarshal([]byte(msg), &m); err != nil || m["_user"] != "alice" {
+				t.Errorf("Unexpected message %q, %v", msg, err)
+			}
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the messages")
+	}
+}
@@ -0,0 +1,391 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ThomasNguyenGitHub/go/log"
+)
+
+// Defaults of the HTTP sink
+const (
+	DefaultBatchSize     = 100
+	DefaultFlushInterval = 5 * time.Second
+	DefaultMaxRetries    = 3
+	DefaultQueueSize     = 10000
+	DefaultMaxSpillBytes = 100 << 20
+)
+
+const spillExt = ".ndjson"
+
+// HTTPSinkOption sets an optional parameter of an HTTPSink.
+type HTTPSinkOption func(*HTTPSink)
+
+// HTTPSinkClient sets the client sending the batches, http.DefaultClient by
+// default.
+func HTTPSinkClient(client *http.Client) HTTPSinkOption {
+	return func(s *HTTPSink) { s.client = client }
+}
+
+// HTTPSinkHeader sets a header of the requests, such as Authorization.
+func HTTPSinkHeader(key, value string) HTTPSinkOption {
+	return func(s *HTTPSink) { s.header.Set(key, value) }
+}
+
+// HTTPSinkFormatter sets the formatter of the entries, a log.JSONFormatter
+// by default. Each formatted entry is a line of the batches.
+func HTTPSinkFormatter(formatter log.Formatter) HTTPSinkOption {
+	return func(s *HTTPSink) { s.formatter = formatter }
+}
+
+// HTTPSinkBatch sets the number of entries of the batches and the interval
+// the pending entries are sent at.
+func HTTPSinkBatch(size int, interval time.Duration) HTTPSinkOption {
+	return func(s *HTTPSink) { s.batchSize, s.flushInterval = size, interval }
+}
+
+// HTTPSinkRetry sets the number of retries of a failed batch and the
+// initial backoff between them, doubling after each retry.
+func HTTPSinkRetry(retries int, backoff time.Duration) HTTPSinkOption {
+	return func(s *HTTPSink) { s.maxRetries, s.backoff = retries, backoff }
+}
+
+// HTTPSinkSpill sets the directory the batches are spilled to when the
+// endpoint is down, and the size of the spilled batches above which the
+// oldest ones are removed. The spilled batches are sent once the endpoint
+// is back.
+func HTTPSinkSpill(dir string, maxBytes int64) HTTPSinkOption {
+	return func(s *HTTPSink) { s.spillDir, s.maxSpillBytes = dir, maxBytes }
+}
+
+// HTTPSinkLevels sets the levels the hook fires on, log.AllLevels by default.
+func HTTPSinkLevels(levels ...log.Level) HTTPSinkOption {
+	return func(s *HTTPSink) { s.levels = levels }
+}
+
+// HTTPSink is a Hook sending the entries to an HTTP endpoint in batches of
+// newline delimited entries, POSTed with the application/x-ndjson content
+// type. Batches are sent when full or every flush interval by a background
+// goroutine, failed batches being retried with an exponential backoff and
+// then spilled to disk when a spill directory is set.
+//
+// Entries are dropped when the queue of the sink is full. The pending
+// entries are sent by Flush, which is called by the exit handlers until the
+// sink is closed, and by Close.
+type HTTPSink struct {
+	url           string
+	client        *http.Client
+	header        http.Header
+	formatter     log.Formatter
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+	backoff       time.Duration
+	spillDir      string
+	maxSpillBytes int64
+	levels        []log.Level
+
+	queue   chan []byte
+	flushc  chan chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+	closing sync.Once
+
+	mu      sync.Mutex
+	dropped uint64
+}
+
+// NewHTTPSink returns an HTTPSink sending the entries to url.
+func NewHTTPSink(url string, options ...HTTPSinkOption) (*HTTPSink, error) {
+	s := &HTTPSink{
+		url:           url,
+		client:        http.DefaultClient,
+		header:        make(http.Header),
+		formatter:     &log.JSONFormatter{},
+		batchSize:     DefaultBatchSize,
+		flushInterval: DefaultFlushInterval,
+		maxRetries:    DefaultMaxRetries,
+		backoff:       500 * time.Millisecond,
+		maxSpillBytes: DefaultMaxSpillBytes,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = DefaultBatchSize
+	}
+	if s.flushInterval <= 0 {
+		s.flushInterval = DefaultFlushInterval
+	}
+	if s.spillDir != "" {
+		if err := os.MkdirAll(s.spillDir, 0755); err != nil {
+			return nil, fmt.Errorf("Unable to create spill directory: %w", err)
+		}
+	}
+	s.queue = make(chan []byte, DefaultQueueSize)
+	s.flushc = make(chan chan struct{})
+	s.done = make(chan struct{})
+	s.stopped = make(chan struct{})
+
+	go s.run()
+	log.RegisterExitFlusher(s)
+	return s, nil
+}
+
+// Levels implements log.Hook.
+func (s *HTTPSink) Levels() []log.Level {
+	if len(s.levels) == 0 {
+		return log.AllLevels
+	}
+	return s.levels
+}
+
+// Fire implements log.Hook.
+func (s *HTTPSink) Fire(entry *log.Entry) error {
+	line, err := s.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	// the formatter may return the buffer of the entry
+	line = append([]byte(nil), line...)
+	if len(line) == 0 || line[len(line)-1] != '\n' {
+		line = append(line, '\n')
+	}
+
+	select {
+	case s.queue <- line:
+	case <-s.done:
+	default:
+		s.mu.Lock()
+		s.dropped++
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// Dropped returns the number of entries dropped because the queue was full.
+func (s *HTTPSink) Dropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Flush sends the pending entries, returning once they are sent or spilled.
+func (s *HTTPSink) Flush() {
+	flushed := make(chan struct{})
+	select {
+	case s.flushc <- flushed:
+		<-flushed
+	case <-s.done:
+	}
+}
+
+// Close sends the pending entries, stops the sink and removes it from the
+// exit flushers.
+func (s *HTTPSink) Close() error {
+	s.closing.Do(func() {
+		log.UnregisterExitFlusher(s)
+		s.Flush()
+		close(s.done)
+		<-s.stopped
+	})
+	return nil
+}
+
+func (s *HTTPSink) run() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	var batch bytes.Buffer
+	n := 0
+	send := func() {
+		if n > 0 {
+			s.send(batch.Bytes())
+			batch.Reset()
+			n = 0
+		}
+	}
+	drain := func() {
+		for {
+			select {
+			case line := <-s.queue:
+				batch.Write(line)
+				if n++; n >= s.batchSize {
+					send()
+				}
+			default:
+				return
+			}
+		}
+	}
+	for {
+		select {
+		case line := <-s.queue:
+			batch.Write(line)
+			if n++; n >= s.batchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+			s.resendSpilled()
+		case flushed := <-s.flushc:
+			// drain the entries queued before the flush
+			drain()
+			send()
+			close(flushed)
+		case <-s.done:
+			// send the entries queued since the flush of Close
+			drain()
+			send()
+			return
+		}
+	}
+}
+
+// send posts the batch, retrying and then spilling it on failure.
+func (s *HTTPSink) send(batch []byte) {
+	if err := s.post(batch); err != nil {
+		if s.spillDir == "" {
+			fmt.Fprintf(os.Stderr, "Failed to send log batch, %v\n", err)
+			return
+		}
+		if err := s.spill(batch); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to spill log batch, %v\n", err)
+		}
+		return
+	}
+	s.resendSpilled()
+}
+
+// errPermanent marks the errors that are not retried.
+type errPermanent struct{ error }
+
+// post posts the batch, retrying the failures that are not permanent.
+func (s *HTTPSink) post(batch []byte) error {
+	backoff := s.backoff
+	var err error
+	for attempt := 0; attempt <= s.maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		if err = s.postOnce(batch); err == nil {
+			return nil
+		}
+		var perr errPermanent
+		if errors.As(err, &perr) {
+			// spilling would not help
+			fmt.Fprintf(os.Stderr, "Failed to send log batch, %v\n", err)
+			return nil
+		}
+	}
+	return err
+}
+
+func (s *HTTPSink) postOnce(batch []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(batch))
+	if err != nil {
+		return errPermanent{err}
+	}
+	for k, values := range s.header {
+		req.Header[k] = values
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("log sink responded %s", resp.Status)
+	default:
+		return errPermanent{fmt.Errorf("log sink responded %s", resp.Status)}
+	}
+}
+
+// spill writes the batch to the spill directory, removing the oldest
+// spilled batches beyond maxSpillBytes.
+func (s *HTTPSink) spill(batch []byte) error {
+	name := filepath.Join(s.spillDir, fmt.Sprintf("%020d%s", time.Now().UnixNano(), spillExt))
+	if err := os.WriteFile(name, batch, 0644); err != nil {
+		return err
+	}
+	if s.maxSpillBytes <= 0 {
+		return nil
+	}
+
+	files, err := s.spilled()
+	if err != nil {
+		return err
+	}
+	var total int64
+	for i := len(files) - 1; i >= 0; i-- {
+		info, err := os.Stat(files[i])
+		if err != nil {
+			continue
+		}
+		if total += info.Size(); total > s.maxSpillBytes {
+			os.Remove(files[i])
+		}
+	}
+	return nil
+}
+
+// spilled returns the spilled batches, oldest first.
+func (s *HTTPSink) spilled() ([]string, error) {
+	entries, err := os.ReadDir(s.spillDir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), spillExt) {
+			files = append(files, filepath.Join(s.spillDir, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// resendSpilled sends the spilled batches, oldest first, until one fails.
+func (s *HTTPSink) resendSpilled() {
+	if s.spillDir == "" {
+		return
+	}
+	files, err := s.spilled()
+	if err != nil {
+		return
+	}
+	for _, name := range files {
+		batch, err := os.ReadFile(name)
+		if err != nil {
+			continue
+		}
+		if err := s.postOnce(batch); err != nil {
+			var perr errPermanent
+			if !errors.As(err, &perr) {
+				return
+			}
+		}
+		os.Remove(name)
+	}
+}
@@ -0,0 +1,151 @@
+package hooks
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ThomasNguyenGitHub/go/log"
+)
+
+// sinkServer records the entries it receives, failing while down is set.
+type sinkServer struct {
+	*httptest.Server
+	down     atomic.Bool
+	mu       sync.Mutex
+	requests int
+	messages []string
+}
+
+func newSinkServer(t *testing.T) *sinkServer {
+	s := &sinkServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests++
+		if s.down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/x-ndjson" || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var entry map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				t.Errorf("Unexpected line %q: %v", scanner.Text(), err)
+			}
+			s.messages = append(s.messages, entry["msg"].(string))
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *sinkServer) received() (int, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests, append([]string(nil), s.messages...)
+}
+
+func TestHTTPSinkBatch(t *testing.T) {
+	server := newSinkServer(t)
+	sink, err := NewHTTPSink(server.URL,
+		HTTPSinkHeader("Authorization", "Bearer token"),
+		HTTPSinkBatch(2, time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	logger := log.New()
+	logger.SetLevel(log.InfoLevel)
+	logger.AddHook(sink)
+	logger.Info("one")
+	logger.Info("two")
+	logger.Info("three")
+
+	// the full batch is sent without waiting for the flush interval
+	deadline := time.Now().Add(2 * time.Second)
+	for requests, _ := server.received(); requests == 0; requests, _ = server.received() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the full batch to be sent")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	sink.Flush()
+
+	requests, messages := server.received()
+	if requests != 2 || len(messages) != 3 || messages[0] != "one" || messages[2] != "three" {
+		t.Errorf("Unexpected requests %d, %v", requests, messages)
+	}
+}
+
+func TestHTTPSinkSpill(t *testing.T) {
+	server := newSinkServer(t)
+	server.down.Store(true)
+	dir := t.TempDir()
+	sink, err := NewHTTPSink(server.URL,
+		HTTPSinkHeader("Authorization", "Bearer token"),
+		HTTPSinkRetry(2, time.Millisecond),
+		HTTPSinkSpill(dir, 0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	logger := log.New()
+	logger.SetLevel(log.InfoLevel)
+	logger.AddHook(sink)
+	logger.Info("while down")
+	sink.Flush()
+
+	requests, messages := server.received()
+	if requests != 3 || len(messages) != 0 {
+		t.Errorf("Expected 3 attempts but got %d, %v", requests, messages)
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("Expected a spilled batch but got %v", files)
+	}
+
+	server.down.Store(false)
+	logger.Info("back up")
+	sink.Flush()
+
+	if _, messages = server.received(); len(messages) != 2 || messages[0] != "back up" || messages[1] != "while down" {
+		t.Errorf("Expected the spilled batch to be sent but got %v", messages)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("Expected the spilled batch to be removed but got %v", files)
+	}
+}
+
+func TestHTTPSinkCloseDrainsQueue(t *testing.T) {
+	server := newSinkServer(t)
+	sink, err := NewHTTPSink(server.URL, HTTPSinkHeader("Authorization", "Bearer token"), HTTPSinkBatch(10, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.UnregisterExitFlusher(sink)
+
+	// an entry queued after the flush of Close and before the sink stops
+	sink.Flush()
+	sink.queue <- []byte(`{"msg":"late"}` + "\n")
+	close(sink.done)
+	<-sink.stopped
+
+	if _, messages := server.received(); len(messages) != 1 || messages[0] != "late" {
+		t.Errorf("Expected the queued entry to be sent but got %v", messages)
+	}
+}
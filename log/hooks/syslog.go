@@ -0,0 +1,240 @@
+package hooks
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ThomasNguyenGitHub/go/log"
+)
+
+// Facility is a syslog facility.
+type Facility int
+
+// Syslog facilities
+const (
+	LOG_KERN Facility = iota
+	LOG_USER
+	LOG_MAIL
+	LOG_DAEMON
+	LOG_AUTH
+	LOG_SYSLOG
+	LOG_LPR
+	LOG_NEWS
+	LOG_UUCP
+	LOG_CRON
+	LOG_AUTHPRIV
+	LOG_FTP
+	_
+	_
+	_
+	_
+	LOG_LOCAL0
+	LOG_LOCAL1
+	LOG_LOCAL2
+	LOG_LOCAL3
+	LOG_LOCAL4
+	LOG_LOCAL5
+	LOG_LOCAL6
+	LOG_LOCAL7
+)
+
+// DefaultStructuredDataID is the SD-ID of the structured data element
+// holding the fields of the entries, 32473 being the private enterprise
+// number reserved for documentation by RFC 5612.
+const DefaultStructuredDataID = "fields@32473"
+
+// syslogTimeFormat is the RFC 5424 TIMESTAMP format, with microseconds.
+const syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// Severity returns the syslog severity of level.
+func Severity(level log.Level) int {
+	switch level {
+	case log.PanicLevel:
+		return 0 // emergency
+	case log.FatalLevel:
+		return 2 // critical
+	case log.ErrorLevel:
+		return 3
+	case log.WarnLevel:
+		return 4
+	case log.InfoLevel:
+		return 6
+	default:
+		return 7 // debug
+	}
+}
+
+// Syslog is a Hook sending the entries to a syslog server in the RFC 5424
+// format, over UDP, TCP or TLS. The fields of the entries are sent as
+// structured data. Over TCP and TLS the messages are framed by octet
+// counting, as described by RFC 6587.
+type Syslog struct {
+	// Facility of the messages, LOG_USER by default
+	Facility Facility
+	// Hostname, AppName and ProcID identify the sender, they default to the
+	// host name, the name of the executable and the process ID
+	Hostname string
+	AppName  string
+	ProcID   string
+	// StructuredDataID is the SD-ID of the fields, DefaultStructuredDataID
+	// by default
+	StructuredDataID string
+	// LogLevels are the levels the hook fires on, log.AllLevels when empty
+	LogLevels []log.Level
+
+	network string
+	conn    *managedConn
+}
+
+// NewSyslog returns a Syslog hook sending the entries to address, network
+// being udp, tcp or tls.
+func NewSyslog(network, address string) (*Syslog, error) {
+	return NewSyslogTLS(network, address, nil)
+}
+
+// NewSyslogTLS returns a Syslog hook sending the entries to address, using
+// tlsConfig when network is tls.
+func NewSyslogTLS(network, address string, tlsConfig *tls.Config) (*Syslog, error) {
+	switch network {
+	case "udp", "tcp", "tls":
+	default:
+		return nil, fmt.Errorf("syslog: unsupported network %q", network)
+	}
+	hostname, _ := os.Hostname()
+	return &Syslog{
+		Facility:         LOG_USER,
+		Hostname:         hostname,
+		AppName:          filepath.Base(os.Args[0]),
+		ProcID:           fmt.Sprint(os.Getpid()),
+		StructuredDataID: DefaultStructuredDataID,
+		network:          network,
+		conn:             newConn(network, address, tlsConfig, DefaultTimeout),
+	}, nil
+}
+
+// Levels implements log.Hook.
+func (h *Syslog) Levels() []log.Level {
+	if len(h.LogLevels) == 0 {
+		return log.AllLevels
+	}
+	return h.LogLevels
+}
+
+// Fire implements log.Hook.
+func (h *Syslog) Fire(entry *log.Entry) error {
+	msg := h.Format(entry)
+	if h.network != "udp" {
+		msg = append([]byte(fmt.Sprintf("%d ", len(msg))), msg...)
+	}
+	return h.conn.do(func(c net.Conn) error {
+		_, err := c.Write(msg)
+		return err
+	})
+}
+
+// Close closes the connection to the server.
+func (h *Syslog) Close() error {
+	return h.conn.Close()
+}
+
+// Format returns the RFC 5424 message of entry.
+func (h *Syslog) Format(entry *log.Entry) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s - ",
+		int(h.Facility)*8+Severity(entry.Level),
+		entry.Time.Format(syslogTimeFormat),
+		headerField(h.Hostname, 255),
+		headerField(h.AppName, 48),
+		headerField(h.ProcID, 128),
+	)
+
+	if len(entry.Data) == 0 {
+		b.WriteByte('-')
+	} else {
+		keys := make([]string, 0, len(entry.Data))
+		for k := range entry.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		id := h.StructuredDataID
+		if id == "" {
+			id = DefaultStructuredDataID
+		}
+		fmt.Fprintf(&b, "[%s", id)
+		for _, k := range keys {
+			fmt.Fprintf(&b, ` %s="%s"`, paramName(k), paramValue(fieldString(entry.Data[k])))
+		}
+		b.WriteByte(']')
+	}
+
+	if entry.Message != "" {
+		// the BOM marks the message as UTF-8
+		b.WriteString(" \xef\xbb\xbf")
+		b.WriteString(entry.Message)
+	}
+	return b.Bytes()
+}
+
+// headerField returns s as a header field of at most max printable chars,
+// the NILVALUE when empty.
+func headerField(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, s)
+	if len(s) > max {
+		s = s[:max]
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// paramName returns k as a PARAM-NAME, of at most 32 printable chars
+// without '=', ' ', ']' and '"'.
+func paramName(k string) string {
+	k = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, k)
+	if len(k) > 32 {
+		k = k[:32]
+	}
+	if k == "" {
+		return "_"
+	}
+	return k
+}
+
+var paramValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// paramValue escapes v as a PARAM-VALUE.
+func paramValue(v string) string {
+	return paramValueReplacer.Replace(v)
+}
+
+// fieldString returns the string form of a field value.
+func fieldString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
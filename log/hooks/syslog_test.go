@@ -0,0 +1,190 @@
+package hooks
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ThomasNguyenGitHub/go/log"
+)
+
+func testEntry() *log.Entry {
+	logger := log.New()
+	entry := log.NewEntry(logger).WithFields(log.Fields{
+		"user":  "alice",
+		"quote": `say "hi" [now]`,
+		"a b":   1,
+	})
+	entry.Time = time.Date(2024, 1, 2, 10, 0, 0, 123456000, time.UTC)
+	entry.Level = log.ErrorLevel
+	entry.Message = "payment failed"
+	return entry
+}
+
+func TestSyslogFormat(t *testing.T) {
+	hook, err := NewSyslog("udp", "127.0.0.1:514")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.Hostname, hook.AppName, hook.ProcID, hook.Facility = "host", "app", "42", LOG_LOCAL0
+
+	expected := `<131>1 2024-01-02T10:00:00.123456Z host app 42 - ` +
+		`[fields@32473 a_b="1" quote="say \"hi\" [now\]" user="alice"]` +
+		" \xef\xbb\xbfpayment failed"
+	if got := string(hook.Format(testEntry())); got != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, got)
+	}
+
+	entry := testEntry()
+	entry.Data = log.Fields{}
+	entry.Level = log.DebugLevel
+	if got := string(hook.Format(entry)); !strings.HasPrefix(got, "<135>1 ") || !strings.Contains(got, " - - \xef\xbb\xbf") {
+		t.Errorf("Unexpected message without fields %q", got)
+	}
+
+	if _, err := NewSyslog("unix", "/dev/log"); err == nil {
+		t.Error("Expected an error for an unsupported network")
+	}
+}
+
+func TestSyslogUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	hook, _ := NewSyslog("udp", pc.LocalAddr().String())
+	defer hook.Close()
+	if err := hook.Fire(testEntry()); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 2048)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := string(buf[:n]); !strings.HasPrefix(msg, "<11>1 ") || !strings.HasSuffix(msg, "payment failed") {
+		t.Errorf("Unexpected message %q", msg)
+	}
+}
+
+// readFrames reads n octet counted frames from the first accepted connection.
+func readFrames(t *testing.T, l net.Listener, n int) <-chan []string {
+	t.Helper()
+	framesc := make(chan []string, 1)
+	go func() {
+		var frames []string
+		defer func() { framesc <- frames }()
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		r := bufio.NewReader(c)
+		for len(frames) < n {
+			size, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			length, _ := strconv.Atoi(strings.TrimSpace(size))
+			frame := make([]byte, length)
+			if _, err := io.ReadFull(r, frame); err != nil {
+				return
+			}
+			frames = append(frames, string(frame))
+		}
+	}()
+	return framesc
+}
+
+func TestSyslogTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(nil)
+	server.StartTLS()
+	cert := server.TLS.Certificates[0]
+	server.Close()
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	framesc := readFrames(t, l, 2)
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	hook, _ := NewSyslogTLS("tls", l.Addr().String(), &tls.Config{RootCAs: pool, ServerName: "example.com"})
+	defer hook.Close()
+
+	logger := log.New()
+	logger.SetLevel(log.InfoLevel)
+	logger.AddHook(hook)
+	logger.WithField("id", 1).Info("first")
+	logger.Warn("second")
+
+	select {
+	case frames := <-framesc:
+		pattern := regexp.MustCompile(`^<1[24]>1 \S+ \S+ \S+ \d+ - (\[fields@32473 id="1"\]|-) \x{feff}(first|second)$`)
+		if len(frames) != 2 {
+			t.Fatalf("Expected 2 frames but got %q", frames)
+		}
+		for _, frame := range frames {
+			if !pattern.MatchString(frame) {
+				t.Errorf("Unexpected frame %q", frame)
+			}
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the messages")
+	}
+}
+
+func TestSyslogReconnect(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	hook, _ := NewSyslog("tcp", addr)
+	defer hook.Close()
+	if err := hook.Fire(testEntry()); err == nil {
+		t.Fatal("Expected an error while the server is down")
+	}
+	if err := hook.Fire(testEntry()); err != ErrConnectionUnavailable {
+		t.Fatalf("Expected ErrConnectionUnavailable during the backoff but got %v", err)
+	}
+
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skip("Unable to listen again on", addr)
+	}
+	defer l.Close()
+	framesc := readFrames(t, l, 1)
+
+	// the first redial is made after a backoff of 1 to 3 seconds
+	deadline := time.Now().Add(5 * time.Second)
+	for hook.Fire(testEntry()) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the hook to reconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if frames := <-framesc; len(frames) != 1 {
+		t.Errorf("Expected a frame after reconnecting but got %q", frames)
+	}
+}
@@ -0,0 +1,219 @@
+package log
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// FieldKeyLogger is the key of the field holding the name of named loggers.
+const FieldKeyLogger = "logger"
+
+// LevelChange describes a change of the level of a named logger, the root
+// logger being named "". It is the message propagated between replicas.
+type LevelChange struct {
+	Name  string `json:"name"`
+	Level Level  `json:"level"`
+	// Reset removes the level of the named logger, which then logs at the
+	// level of its parent
+	Reset bool `json:"reset,omitempty"`
+	// RevertAfter, when positive, restores the previous level after the
+	// duration
+	RevertAfter time.Duration `json:"revert_after,omitempty"`
+}
+
+type levelRevert struct {
+	timer *time.Timer
+	level Level
+	set   bool
+}
+
+// LevelRegistry holds the levels of the named loggers of a Logger. A named
+// logger without a level logs at the level of its parent, "payment" being
+// the parent of "payment.card", and then at the level of the Logger.
+type LevelRegistry struct {
+	root *Logger
+
+	mu        sync.RWMutex
+	levels    map[string]Level
+	reverts   map[string]*levelRevert
+	listeners []func(LevelChange)
+}
+
+// Levels returns the registry of the levels of the named loggers.
+func (logger *Logger) Levels() *LevelRegistry {
+	logger.levels.mu.Lock()
+	logger.levels.root = logger
+	logger.levels.mu.Unlock()
+	return &logger.levels
+}
+
+// Named returns an entry logging at the level of the named logger, with the
+// FieldKeyLogger field set to name. Dots separate the components of
+// hierarchical names, e.g. "payment.card".
+func (logger *Logger) Named(name string) *Entry {
+	logger.Levels()
+	entry := NewEntry(logger).WithField(FieldKeyLogger, name)
+	entry.name = name
+	return entry
+}
+
+// IsLevelEnabled checks if the level of the named logger of the entry, or of
+// its Logger, is greater than the level param.
+func (entry *Entry) IsLevelEnabled(level Level) bool {
+	if entry.name != "" {
+		if l, ok := entry.Logger.levels.namedLevel(entry.name); ok {
+			return l >= level
+		}
+	}
+	return entry.Logger.IsLevelEnabled(level)
+}
+
+// namedLevel returns the level of the named logger or of its closest parent
+// with a level.
+func (r *LevelRegistry) namedLevel(name string) (Level, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for name != "" {
+		if level, ok := r.levels[name]; ok {
+			return level, true
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return 0, false
+}
+
+// Level returns the level the named logger logs at.
+func (r *LevelRegistry) Level(name string) Level {
+	if level, ok := r.namedLevel(name); ok {
+		return level
+	}
+	return r.root.GetLevel()
+}
+
+// Levels returns the levels set for the named loggers, including the root
+// logger named "".
+func (r *LevelRegistry) Levels() map[string]Level {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	levels := make(map[string]Level, len(r.levels)+1)
+	for name, level := range r.levels {
+		levels[name] = level
+	}
+	levels[""] = r.root.GetLevel()
+	return levels
+}
+
+// SetLevel sets the level of the named logger, restoring its previous level
+// after revertAfter when positive, and notifies the listeners.
+func (r *LevelRegistry) SetLevel(name string, level Level, revertAfter time.Duration) {
+	r.Change(LevelChange{Name: name, Level: level, RevertAfter: revertAfter})
+}
+
+// Reset removes the level of the named logger and notifies the listeners.
+// The root logger can not be reset.
+func (r *LevelRegistry) Reset(name string) {
+	r.Change(LevelChange{Name: name, Reset: true})
+}
+
+// Change applies the change and notifies the listeners.
+func (r *LevelRegistry) Change(change LevelChange) {
+	r.Apply(change)
+
+	r.mu.RLock()
+	listeners := r.listeners
+	r.mu.RUnlock()
+	for _, listener := range listeners {
+		listener(change)
+	}
+}
+
+// Apply applies the change without notifying the listeners, it is meant for
+// the changes received from other replicas.
+func (r *LevelRegistry) Apply(change LevelChange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous, set := r.get(change.Name)
+	if revert, ok := r.reverts[change.Name]; ok {
+		// a pending revert restores the level before the first change
+		revert.timer.Stop()
+		previous, set = revert.level, revert.set
+		delete(r.reverts, change.Name)
+	}
+
+	if change.Reset {
+		r.set(change.Name, 0, false)
+	} else {
+		r.set(change.Name, change.Level, true)
+	}
+
+	if change.RevertAfter > 0 {
+		if r.reverts == nil {
+			r.reverts = make(map[string]*levelRevert)
+		}
+		revert := &levelRevert{level: previous, set: set}
+		revert.timer = time.AfterFunc(change.RevertAfter, func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.reverts[change.Name] == revert {
+				delete(r.reverts, change.Name)
+				r.set(change.Name, revert.level, revert.set)
+			}
+		})
+		r.reverts[change.Name] = revert
+	}
+}
+
+// OnChange registers a listener called with the changes made by SetLevel,
+// Reset and Change, to propagate them to other replicas for instance.
+func (r *LevelRegistry) OnChange(listener func(LevelChange)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, listener)
+}
+
+// get returns the level set for the named logger, it must be called with mu
+// held.
+func (r *LevelRegistry) get(name string) (Level, bool) {
+	if name == "" {
+		return r.root.GetLevel(), true
+	}
+	level, ok := r.levels[name]
+	return level, ok
+}
+
+// set sets or removes the level of the named logger, it must be called with
+// mu held.
+func (r *LevelRegistry) set(name string, level Level, ok bool) {
+	if name == "" {
+		if ok {
+			r.root.SetLevel(level)
+		}
+		return
+	}
+	if !ok {
+		delete(r.levels, name)
+		return
+	}
+	if r.levels == nil {
+		r.levels = make(map[string]Level)
+	}
+	r.levels[name] = level
+}
+
+// Named returns an entry of the standard logger logging at the level of the
+// named logger, see Logger.Named.
+func Named(name string) *Entry {
+	return std.Named(name)
+}
+
+// Levels returns the registry of the levels of the named loggers of the
+// standard logger.
+func Levels() *LevelRegistry {
+	return std.Levels()
+}
@@ -0,0 +1,72 @@
+package log
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNamedLevels(t *testing.T) {
+	logger, buf := newContextTestLogger()
+	logger.Formatter = &TextFormatter{DisableTimestamp: true, DisableColors: true}
+	levels := logger.Levels()
+
+	var changes []LevelChange
+	levels.OnChange(func(change LevelChange) { changes = append(changes, change) })
+
+	payment := logger.Named("payment")
+	card := logger.Named("payment.card").WithField("id", 1)
+	other := logger.Named("other")
+
+	levels.SetLevel("payment", DebugLevel, 0)
+	payment.Debug("payment debug")
+	card.Debug("card debug")
+	other.Debug("other debug")
+	logger.Debug("root debug")
+
+	out := buf.String()
+	if !strings.Contains(out, `msg="payment debug" logger=payment`) || !strings.Contains(out, `msg="card debug" id=1 logger=payment.card`) {
+		t.Errorf("Expected the payment debug entries in %s", out)
+	}
+	if strings.Contains(out, "other debug") || strings.Contains(out, "root debug") {
+		t.Errorf("Unexpected debug entries in %s", out)
+	}
+
+	levels.SetLevel("payment.card", ErrorLevel, 0)
+	if levels.Level("payment.card") != ErrorLevel || levels.Level("payment.card.visa") != ErrorLevel || levels.Level("other") != InfoLevel {
+		t.Errorf("Unexpected levels %v", levels.Levels())
+	}
+	levels.Reset("payment.card")
+	if levels.Level("payment.card") != DebugLevel {
+		t.Errorf("Expected the parent level after reset but got %v", levels.Level("payment.card"))
+	}
+
+	if len(changes) != 3 || !changes[2].Reset {
+		t.Errorf("Unexpected changes %v", changes)
+	}
+	// applied changes are not notified
+	levels.Apply(LevelChange{Name: "other", Level: WarnLevel})
+	if len(changes) != 3 || levels.Level("other") != WarnLevel {
+		t.Errorf("Unexpected changes %v", changes)
+	}
+}
+
+func TestNamedLevelsRevert(t *testing.T) {
+	logger, _ := newContextTestLogger()
+	levels := logger.Levels()
+
+	levels.SetLevel("", DebugLevel, 20*time.Millisecond)
+	levels.SetLevel("", TraceLevel, 30*time.Millisecond)
+	levels.SetLevel("payment", DebugLevel, 20*time.Millisecond)
+	if logger.GetLevel() != TraceLevel || levels.Level("payment") != DebugLevel {
+		t.Fatalf("Unexpected levels %v", levels.Levels())
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for logger.GetLevel() != InfoLevel || len(levels.Levels()) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the levels to be reverted but got %v", levels.Levels())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -49,6 +49,14 @@ type Logger struct {
 	// buffer pool will be used.
 	BufferPool BufferPool
 	Server     string
+	// Extractors adding fields from the context of entries logged with
+	// WithContext, applied after the ones registered with
+	// RegisterContextExtractor.
+	ContextExtractors []ContextExtractor
+	// Sampler drops part of the entries when set, see RateSampler.
+	Sampler Sampler
+	// Levels of the named loggers
+	levels LevelRegistry
 }
 
 type exitFunc func(int)
@@ -106,9 +114,13 @@ func New() *Logger {
 		FullTimestamp:   showFullTime,
 		TimestampFormat: "2006-01-02 15:04:05",
 	}
+	var fmtr Formatter = formatter
+	if masking, _ := local.GetenvBool("SERVER_LOGGING_MASKING"); masking {
+		fmtr = NewMaskingFormatter(formatter)
+	}
 	log := Logger{
 		Out:          os.Stderr,
-		Formatter:    formatter,
+		Formatter:    fmtr,
 		Hooks:        make(LevelHooks),
 		Level:        logLv,
 		ExitFunc:     os.Exit,
@@ -117,6 +129,18 @@ func New() *Logger {
 	return &log
 }
 
+// NewNopLogger returns a logger discarding its entries, for the components
+// requiring a logger when their errors are of no interest.
+func NewNopLogger() *Logger {
+	return &Logger{
+		Out:       io.Discard,
+		Formatter: new(TextFormatter),
+		Hooks:     make(LevelHooks),
+		Level:     PanicLevel,
+		ExitFunc:  os.Exit,
+	}
+}
+
 func (logger *Logger) newEntry() *Entry {
 	entry, ok := logger.entryPool.Get().(*Entry)
 	if ok {
@@ -156,7 +180,9 @@ func (logger *Logger) WithError(err error) *Entry {
 	return entry.WithError(err)
 }
 
-// Add a context to the log entry.
+// Add a context to the log entry. The fields extracted from the context by the
+// context extractors, such as the request ID or the trace ID, are added to the
+// entry when it is logged.
 func (logger *Logger) WithContext(ctx context.Context) *Entry {
 	entry := logger.newEntry()
 	defer logger.releaseEntry(entry)
@@ -0,0 +1,111 @@
+// Package logtrace adds the trace and span IDs of the span held by the
+// context to the entries logged with log.WithContext. Importing it for its
+// side effects registers TraceFields as a log context extractor:
+//
+//	import _ "github.com/ThomasNguyenGitHub/go/log/logtrace"
+//
+// It is kept out of the log package so that the consumers of log do not
+// depend on the OpenCensus, Zipkin and OpenTracing libraries.
+package logtrace
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/ThomasNguyenGitHub/go/log"
+	"github.com/opentracing/opentracing-go"
+	"github.com/openzipkin/zipkin-go"
+	"go.opencensus.io/trace"
+)
+
+func init() {
+	log.RegisterContextExtractor(TraceFields)
+}
+
+// TraceFields returns the trace and span IDs of the span held by ctx, looking
+// for an OpenCensus, a Zipkin and then an OpenTracing span.
+func TraceFields(ctx context.Context) log.Fields {
+	for _, extractor := range []log.ContextExtractor{OpenCensusFields, ZipkinFields, OpenTracingFields} {
+		if fields := extractor(ctx); fields != nil {
+			return fields
+		}
+	}
+	return nil
+}
+
+// OpenCensusFields returns the trace and span IDs of the OpenCensus span held
+// by ctx.
+func OpenCensusFields(ctx context.Context) log.Fields {
+	if span := trace.FromContext(ctx); span != nil {
+		sc := span.SpanContext()
+		if sc.TraceID != (trace.TraceID{}) {
+			return log.Fields{log.FieldKeyTraceID: sc.TraceID.String(), log.FieldKeySpanID: sc.SpanID.String()}
+		}
+	}
+	return nil
+}
+
+// ZipkinFields returns the trace and span IDs of the Zipkin span held by ctx.
+func ZipkinFields(ctx context.Context) log.Fields {
+	if span := zipkin.SpanFromContext(ctx); span != nil {
+		sc := span.Context()
+		if !sc.TraceID.Empty() {
+			return log.Fields{log.FieldKeyTraceID: sc.TraceID.String(), log.FieldKeySpanID: sc.ID.String()}
+		}
+	}
+	return nil
+}
+
+// OpenTracingFields returns the trace and span IDs of the OpenTracing span
+// held by ctx.
+func OpenTracingFields(ctx context.Context) log.Fields {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		// the span context of OpenTracing is implementation specific,
+		// most expose TraceID and SpanID methods or fields
+		sc := reflect.ValueOf(span.Context())
+		if traceID := spanContextID(sc, "TraceID"); traceID != "" {
+			return log.Fields{log.FieldKeyTraceID: traceID, log.FieldKeySpanID: spanContextID(sc, "SpanID", "ID")}
+		}
+	}
+	return nil
+}
+
+// spanContextID returns the string form of the first non-zero named method
+// result or field of the span context v.
+func spanContextID(v reflect.Value, names ...string) string {
+	for _, name := range names {
+		if m := v.MethodByName(name); m.IsValid() && m.Type().NumIn() == 0 && m.Type().NumOut() > 0 {
+			if id := idString(m.Call(nil)[0]); id != "" {
+				return id
+			}
+			continue
+		}
+		s := v
+		for s.Kind() == reflect.Ptr || s.Kind() == reflect.Interface {
+			if s.IsNil() {
+				return ""
+			}
+			s = s.Elem()
+		}
+		if s.Kind() != reflect.Struct {
+			continue
+		}
+		if f := s.FieldByName(name); f.IsValid() && f.CanInterface() {
+			if id := idString(f); id != "" {
+				return id
+			}
+		}
+	}
+	return ""
+}
+
+func idString(v reflect.Value) string {
+	if v.IsZero() {
+		return ""
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
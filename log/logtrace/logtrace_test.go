@@ -0,0 +1,36 @@
+package logtrace_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ThomasNguyenGitHub/go/log"
+	"github.com/ThomasNguyenGitHub/go/log/logtrace"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.opencensus.io/trace"
+)
+
+func TestTraceFields(t *testing.T) {
+	ctx, span := trace.StartSpan(context.Background(), "test", trace.WithSampler(trace.AlwaysSample()))
+	defer span.End()
+	sc := span.SpanContext()
+	fields := logtrace.TraceFields(ctx)
+	if fields[log.FieldKeyTraceID] != sc.TraceID.String() || fields[log.FieldKeySpanID] != sc.SpanID.String() {
+		t.Errorf("OpenCensus: Unexpected fields %v", fields)
+	}
+
+	tracer := mocktracer.New()
+	otSpan := tracer.StartSpan("test")
+	defer otSpan.Finish()
+	fields = logtrace.TraceFields(opentracing.ContextWithSpan(context.Background(), otSpan))
+	mc := otSpan.Context().(mocktracer.MockSpanContext)
+	if fields[log.FieldKeyTraceID] != fmt.Sprint(mc.TraceID) || fields[log.FieldKeySpanID] != fmt.Sprint(mc.SpanID) {
+		t.Errorf("OpenTracing: Unexpected fields %v", fields)
+	}
+
+	if fields := logtrace.TraceFields(context.Background()); fields != nil {
+		t.Errorf("Expected no fields but got %v", fields)
+	}
+}
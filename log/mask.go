@@ -0,0 +1,178 @@
+package log
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ThomasNguyenGitHub/go/util"
+)
+
+// MaskFunc masks the value s, keeping noFirstChars and noLastChars of it in
+// clear. util.Mask, util.MaskNoSpace and util.MaskEmail are MaskFuncs.
+type MaskFunc func(s string, noFirstChars, noLastChars int) string
+
+// MaskRule describes values to mask and how to mask them. A rule matches
+// fields by name when Fields or MaskingFields is set, and values, including
+// substrings of the message, when Pattern is set.
+type MaskRule struct {
+	// Fields are the names of the fields whose values are masked, ignoring case
+	Fields []string
+	// MaskingFields matches the fields masked by util, the default sensitive
+	// fields and those listed in the MASKING_FIELDS environment variable
+	MaskingFields bool
+	// Pattern matches the values masked in string fields and in the message
+	Pattern *regexp.Regexp
+	// Valid, when set, filters the values matched by Pattern
+	Valid func(s string) bool
+	// Mask masks the matched values, util.MaskNoSpace when nil
+	Mask MaskFunc
+	// NoFirstChars and NoLastChars are the number of chars kept in clear
+	NoFirstChars, NoLastChars int
+}
+
+// Patterns of the default value rules
+var (
+	EmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// CardPattern matches 13 to 19 digits, optionally grouped by spaces or dashes
+	CardPattern = regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`)
+	// NationalIDPattern matches the 9 digits ID cards and 12 digits citizen identity cards
+	NationalIDPattern = regexp.MustCompile(`\b(?:\d{9}|\d{12})\b`)
+	// PhonePattern matches Vietnamese mobile numbers, in the national or the +84 form
+	PhonePattern = regexp.MustCompile(`(?:\+84|\b84|\b0)[35789]\d{8}\b`)
+)
+
+// DefaultMaskRules returns the rules masking the sensitive fields known to
+// util, emails, card numbers, national IDs and phone numbers.
+func DefaultMaskRules() []MaskRule {
+	return []MaskRule{
+		{MaskingFields: true, Mask: util.MaskNoSpace},
+		{Pattern: EmailPattern, Mask: util.MaskEmail, NoFirstChars: 2},
+		{Pattern: CardPattern, Valid: luhn, Mask: util.MaskNoSpace, NoFirstChars: 4, NoLastChars: 4},
+		{Pattern: NationalIDPattern, Mask: util.MaskNoSpace, NoLastChars: 3},
+		{Pattern: PhonePattern, Mask: util.MaskNoSpace, NoFirstChars: 3, NoLastChars: 3},
+	}
+}
+
+// MaskingFormatter masks the fields and the message of entries according to
+// Rules before formatting them with Formatter. The logged entry is left
+// unchanged, hooks see the values in clear.
+//
+//	logger.SetFormatter(log.NewMaskingFormatter(&log.JSONFormatter{}))
+type MaskingFormatter struct {
+	Formatter Formatter
+	Rules     []MaskRule
+}
+
+// NewMaskingFormatter returns a MaskingFormatter wrapping formatter, applying
+// the given rules or DefaultMaskRules when none are given.
+func NewMaskingFormatter(formatter Formatter, rules ...MaskRule) *MaskingFormatter {
+	if len(rules) == 0 {
+		rules = DefaultMaskRules()
+	}
+	return &MaskingFormatter{Formatter: formatter, Rules: rules}
+}
+
+// Format masks a copy of entry and formats it with the wrapped formatter.
+func (f *MaskingFormatter) Format(entry *Entry) ([]byte, error) {
+	masked := *entry
+	masked.Message = f.maskString(entry.Message)
+	masked.Data = f.maskFields(entry.Data)
+	return f.Formatter.Format(&masked)
+}
+
+func (f *MaskingFormatter) maskFields(fields map[string]interface{}) Fields {
+	data := make(Fields, len(fields))
+	for k, v := range fields {
+		data[k] = f.maskField(k, v)
+	}
+	return data
+}
+
+func (f *MaskingFormatter) maskField(key string, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	for _, rule := range f.Rules {
+		if rule.matchField(key) {
+			return rule.mask(fmt.Sprint(value))
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return f.maskString(v)
+	case error:
+		// errors are only replaced by their message when it holds something to mask
+		if s := f.maskString(v.Error()); s != v.Error() {
+			return s
+		}
+	case Fields:
+		return f.maskFields(v)
+	case map[string]interface{}:
+		return map[string]interface{}(f.maskFields(v))
+	case map[string]string:
+		m := make(map[string]string, len(v))
+		for k, s := range v {
+			m[k] = fmt.Sprint(f.maskField(k, s))
+		}
+		return m
+	}
+	return value
+}
+
+// maskString masks the values matched by the pattern rules in s.
+func (f *MaskingFormatter) maskString(s string) string {
+	for _, rule := range f.Rules {
+		if rule.Pattern == nil || s == "" {
+			continue
+		}
+		s = rule.Pattern.ReplaceAllStringFunc(s, func(match string) string {
+			if rule.Valid != nil && !rule.Valid(match) {
+				return match
+			}
+			return rule.mask(match)
+		})
+	}
+	return s
+}
+
+func (rule MaskRule) matchField(key string) bool {
+	if rule.MaskingFields && util.IsMaskingField(key) {
+		return true
+	}
+	for _, field := range rule.Fields {
+		if strings.EqualFold(field, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule MaskRule) mask(s string) string {
+	mask := rule.Mask
+	if mask == nil {
+		mask = util.MaskNoSpace
+	}
+	return mask(s, rule.NoFirstChars, rule.NoLastChars)
+}
+
+// luhn reports whether the digits of s pass the Luhn checksum of card numbers.
+func luhn(s string) bool {
+	var sum, n int
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
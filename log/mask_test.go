@@ -0,0 +1,67 @@
+package log
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ThomasNguyenGitHub/go/util"
+)
+
+func TestMaskingFormatter(t *testing.T) {
+	logger, buf := newContextTestLogger()
+	logger.SetFormatter(NewMaskingFormatter(logger.Formatter))
+
+	logger.WithFields(Fields{
+		"password": "s3cr3t-value",
+		"email":    "john.doe@example.com",
+		"card":     "4111 1111 1111 1111",
+		"order":    "1234567890123", // not a valid card number
+		"id":       "001099012345",
+		"err":      errors.New("no account for 0981234567"),
+		"nested":   map[string]interface{}{"otp": "123456"},
+	}).Info("call from +84981234567 failed")
+	fields := decodeEntry(t, buf)
+
+	expected := map[string]string{
+		"password": "••••",
+		"email":    "jo••••@example.com",
+		"card":     "4111••••1111",
+		"order":    "1234567890123",
+		"id":       "••••345",
+		"err":      "no account for 098••••567",
+		"msg":      "call from +84••••567 failed",
+	}
+	for k, v := range expected {
+		if fields[k] != v {
+			t.Errorf("Expected %s to be %q but got %q", k, v, fields[k])
+		}
+	}
+	if otp := fields["nested"].(map[string]interface{})["otp"]; otp != "••••" {
+		t.Errorf("Expected the nested otp to be masked but got %q", otp)
+	}
+}
+
+func TestMaskingFormatterRules(t *testing.T) {
+	logger, buf := newContextTestLogger()
+	logger.SetFormatter(NewMaskingFormatter(logger.Formatter,
+		MaskRule{Fields: []string{"Token"}, Mask: util.Mask, NoFirstChars: 2, NoLastChars: 2},
+		MaskRule{Pattern: regexp.MustCompile(`ACC\d+`), Mask: util.MaskNoSpace, NoFirstChars: 3},
+	))
+
+	entry := logger.WithFields(Fields{"token": "abcdefgh", "password": "kept"})
+	entry.Info("account ACC123456")
+	fields := decodeEntry(t, buf)
+
+	if fields["token"] != "ab •••• gh" || fields["password"] != "kept" || fields["msg"] != "account ACC••••" {
+		t.Errorf("Unexpected fields %v", fields)
+	}
+	// the logged entry is left unchanged
+	if entry.Data["token"] != "abcdefgh" {
+		t.Errorf("Expected the entry data to be unchanged but got %v", entry.Data["token"])
+	}
+	if strings.Contains(buf.String(), "abcdefgh") {
+		t.Errorf("Unexpected output %s", buf.String())
+	}
+}
@@ -0,0 +1,132 @@
+package log
+
+import (
+	"strconv"
+	"strings"
+)
+
+// otelAttributes maps the keys of the known fields to their OpenTelemetry
+// semantic convention attribute.
+var otelAttributes = map[string]string{
+	FieldKeyRequestID: "http.request.id",
+	FieldKeyUserID:    "enduser.id",
+	FieldKeyDeviceID:  "device.id",
+}
+
+// OTelFormatter formats logs into JSON log records of the OpenTelemetry log
+// data model, as exported by the OTLP file exporter.
+//
+// The level is rendered as the severity number and text, the trace and span
+// IDs of the fields as TraceId and SpanId, and the error field as the
+// exception attributes. The other fields are the attributes of the record.
+type OTelFormatter struct {
+	// ServiceName, ServiceVersion and Environment are the service.name,
+	// service.version and deployment.environment resource attributes
+	ServiceName    string
+	ServiceVersion string
+	Environment    string
+
+	// StackTrace adds the stack of the caller of the logger to the errors
+	// that do not carry a stack trace
+	StackTrace bool
+
+	// DisableHTMLEscape allows disabling html escaping in output
+	DisableHTMLEscape bool
+}
+
+// Format renders a single log entry
+func (f *OTelFormatter) Format(entry *Entry) ([]byte, error) {
+	record := map[string]interface{}{
+		"Timestamp":      strconv.FormatInt(entry.Time.UnixNano(), 10),
+		"SeverityText":   SeverityText(entry.Level),
+		"SeverityNumber": SeverityNumber(entry.Level),
+		"Body":           entry.Message,
+	}
+
+	resource := make(map[string]interface{})
+	for k, v := range map[string]string{
+		"service.name":           f.ServiceName,
+		"service.version":        f.ServiceVersion,
+		"deployment.environment": f.Environment,
+	} {
+		if v != "" {
+			resource[k] = v
+		}
+	}
+	if len(resource) > 0 {
+		record["Resource"] = resource
+	}
+
+	attributes := make(map[string]interface{})
+	if entry.HasCaller() {
+		attributes["code.filepath"] = entry.Caller.File
+		attributes["code.lineno"] = entry.Caller.Line
+		attributes["code.function"] = entry.Caller.Function
+	}
+	for k, v := range entry.Data {
+		switch k {
+		case FieldKeyTraceID:
+			record["TraceId"] = v
+			continue
+		case FieldKeySpanID:
+			record["SpanId"] = v
+			continue
+		}
+		if err, ok := v.(error); ok && k == ErrorKey {
+			typ, msg, stack := errorDetails(entry, err, f.StackTrace)
+			attributes["exception.type"] = typ
+			attributes["exception.message"] = msg
+			if stack != "" {
+				attributes["exception.stacktrace"] = stack
+			}
+			continue
+		}
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		if name, ok := otelAttributes[k]; ok {
+			k = name
+		}
+		attributes[k] = v
+	}
+	if entry.err != "" {
+		attributes[FieldKeyLogrusError] = entry.err
+	}
+	if len(attributes) > 0 {
+		record["Attributes"] = attributes
+	}
+
+	return encodeDocument(entry, record, f.DisableHTMLEscape)
+}
+
+// SeverityNumber returns the OpenTelemetry severity number of level.
+func SeverityNumber(level Level) int {
+	switch level {
+	case PanicLevel:
+		return 24 // FATAL4
+	case FatalLevel:
+		return 21
+	case ErrorLevel:
+		return 17
+	case WarnLevel:
+		return 13
+	case InfoLevel:
+		return 9
+	case DebugLevel:
+		return 5
+	default:
+		return 1 // TRACE
+	}
+}
+
+// SeverityText returns the OpenTelemetry severity text of level.
+func SeverityText(level Level) string {
+	switch level {
+	case PanicLevel, FatalLevel:
+		return "FATAL"
+	case WarnLevel:
+		return "WARN"
+	default:
+		return strings.ToUpper(level.String())
+	}
+}
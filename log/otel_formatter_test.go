@@ -0,0 +1,97 @@
+package log
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOTelFormatter(t *testing.T) {
+	logger, buf := newContextTestLogger()
+	logger.SetReportCaller(true)
+	logger.SetFormatter(&OTelFormatter{ServiceName: "payment", Environment: "staging"})
+
+	logger.WithFields(Fields{
+		ErrorKey:        errors.New("insufficient funds"),
+		FieldKeyTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		FieldKeySpanID:  "00f067aa0ba902b7",
+		FieldKeyUserID:  "42",
+		"amount":        1000,
+	}).Error("payment failed")
+	record := decodeEntry(t, buf)
+
+	expected := map[string]interface{}{
+		"Body":           "payment failed",
+		"SeverityText":   "ERROR",
+		"SeverityNumber": float64(17),
+		"TraceId":        "4bf92f3577b34da6a3ce929d0e0e4736",
+		"SpanId":         "00f067aa0ba902b7",
+	}
+	for k, v := range expected {
+		if record[k] != v {
+			t.Errorf("Expected %s to be %v but got %v", k, v, record[k])
+		}
+	}
+	if _, ok := record["Timestamp"].(string); !ok {
+		t.Errorf("Expected a Timestamp but got %v", record["Timestamp"])
+	}
+
+	resource, _ := record["Resource"].(map[string]interface{})
+	if resource["service.name"] != "payment" || resource["deployment.environment"] != "staging" {
+		t.Errorf("Unexpected resource %v", resource)
+	}
+	if _, ok := resource["service.version"]; ok {
+		t.Errorf("Expected no service.version but got %v", resource)
+	}
+
+	attributes, _ := record["Attributes"].(map[string]interface{})
+	expected = map[string]interface{}{
+		"exception.type":    "*errors.errorString",
+		"exception.message": "insufficient funds",
+		"enduser.id":        "42",
+		"amount":            float64(1000),
+	}
+	for k, v := range expected {
+		if attributes[k] != v {
+			t.Errorf("Expected attribute %s to be %v but got %v", k, v, attributes[k])
+		}
+	}
+	if file, _ := attributes["code.filepath"].(string); file == "" {
+		t.Error("Expected the caller file")
+	}
+	if _, ok := attributes[FieldKeyTraceID]; ok {
+		t.Error("Expected the trace ID not to be an attribute")
+	}
+	if _, ok := attributes["exception.stacktrace"]; ok {
+		t.Error("Expected no stack trace for an error without one")
+	}
+
+	logger.SetFormatter(&OTelFormatter{StackTrace: true})
+	logger.WithError(errors.New("boom")).Warn("retrying")
+	attributes, _ = decodeEntry(t, buf)["Attributes"].(map[string]interface{})
+	if stack, _ := attributes["exception.stacktrace"].(string); !strings.HasPrefix(stack, "testing.tRunner") {
+		t.Errorf("Expected the stack of the caller but got %q", stack)
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	for level, expected := range map[Level]struct {
+		number int
+		text   string
+	}{
+		TraceLevel: {1, "TRACE"},
+		DebugLevel: {5, "DEBUG"},
+		InfoLevel:  {9, "INFO"},
+		WarnLevel:  {13, "WARN"},
+		ErrorLevel: {17, "ERROR"},
+		FatalLevel: {21, "FATAL"},
+		PanicLevel: {24, "FATAL"},
+	} {
+		if n := SeverityNumber(level); n != expected.number {
+			t.Errorf("Expected %d for %s but got %d", expected.number, level, n)
+		}
+		if text := SeverityText(level); text != expected.text {
+			t.Errorf("Expected %s for %s but got %s", expected.text, level, text)
+		}
+	}
+}
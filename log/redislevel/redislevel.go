@@ -0,0 +1,138 @@
+// Package redislevel propagates the level changes of named loggers between
+// the replicas of a service through a Redis pub/sub channel.
+package redislevel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ThomasNguyenGitHub/go/log"
+	"github.com/ThomasNguyenGitHub/go/redis"
+	"github.com/google/uuid"
+)
+
+// DefaultChannel is the channel used when none is given.
+const DefaultChannel = "log:levels"
+
+// retryDelay is the time waited before subscribing again after an error.
+const retryDelay = time.Second
+
+// message is the payload published on the channel.
+type message struct {
+	Origin string `json:"origin"`
+	log.LevelChange
+}
+
+// Propagator publishes the changes made to a log.LevelRegistry and applies
+// the changes published by the other replicas.
+//
+//	p := redislevel.New(pool, "", log.Levels())
+//	go p.Run(ctx)
+//	http.Handle("/debug/levels", kithttp.NewLevelHandler(log.Levels()))
+type Propagator struct {
+	pool     *redis.Pool
+	channel  string
+	registry *log.LevelRegistry
+	origin   string
+}
+
+// New returns a Propagator publishing the changes of registry on channel,
+// or DefaultChannel when empty.
+func New(pool *redis.Pool, channel string, registry *log.LevelRegistry) *Propagator {
+	if channel == "" {
+		channel = DefaultChannel
+	}
+	p := &Propagator{
+		pool:     pool,
+		channel:  channel,
+		registry: registry,
+		origin:   uuid.NewString(),
+	}
+	registry.OnChange(p.publish)
+	return p
+}
+
+// Run applies the changes published by the other replicas until ctx is
+// done, subscribing again after connection errors.
+func (p *Propagator) Run(ctx context.Context) error {
+	for {
+		err := p.subscribe(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		fmt.Fprintf(os.Stderr, "Failed to receive log level changes, %v\n", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+func (p *Propagator) subscribe(ctx context.Context) error {
+	conn, err := p.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	psc := redis.PubSubConn{Conn: conn}
+	defer psc.Close()
+
+	// the goroutine is done before the connection is closed
+	done, stopped := make(chan struct{}), make(chan struct{})
+	defer func() {
+		close(done)
+		<-stopped
+	}()
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			// unblocks Receive
+			psc.Unsubscribe()
+		case <-done:
+		}
+	}()
+
+	if err := psc.Subscribe(p.channel); err != nil {
+		return err
+	}
+	for {
+		switch v := psc.Receive().(type) {
+		case redis.Message:
+			p.apply(v.Data)
+		case redis.Subscription:
+			if v.Count == 0 {
+				return ctx.Err()
+			}
+		case error:
+			return v
+		}
+	}
+}
+
+func (p *Propagator) apply(data []byte) {
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to decode log level change, %v\n", err)
+		return
+	}
+	if msg.Origin == p.origin {
+		return
+	}
+	p.registry.Apply(msg.LevelChange)
+}
+
+func (p *Propagator) publish(change log.LevelChange) {
+	data, err := json.Marshal(message{Origin: p.origin, LevelChange: change})
+	if err == nil {
+		conn := p.pool.Get()
+		_, err = conn.Do("PUBLISH", p.channel, data)
+		conn.Close()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to publish log level change, %v\n", err)
+	}
+}
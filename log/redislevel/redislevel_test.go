@@ -0,0 +1,213 @@
+package redislevel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ThomasNguyenGitHub/go/log"
+	"github.com/ThomasNguyenGitHub/go/redis"
+)
+
+// broker is an in memory Redis pub/sub server.
+type broker struct {
+	mu          sync.Mutex
+	subscribers map[string][]*fakeConn
+	published   [][]byte
+}
+
+func newBroker() *broker {
+	return &broker{subscribers: make(map[string][]*fakeConn)}
+}
+
+func (b *broker) pool() *redis.Pool {
+	return &redis.Pool{Dial: func() (redis.Conn, error) {
+		return &fakeConn{broker: b, replies: make(chan interface{}, 100)}, nil
+	}}
+}
+
+func (b *broker) publish(channel string, data []byte) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.published = append(b.published, data)
+	for _, c := range b.subscribers[channel] {
+		c.replies <- []interface{}{[]byte("message"), []byte(channel), data}
+	}
+	return int64(len(b.subscribers[channel]))
+}
+
+func (b *broker) subscriberCount(channel string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers[channel])
+}
+
+func (b *broker) messages() [][]byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([][]byte(nil), b.published...)
+}
+
+// fakeConn is a redis.Conn of a broker, the replies of the sent commands
+// being received in order.
+type fakeConn struct {
+	broker  *broker
+	replies chan interface{}
+
+	mu       sync.Mutex
+	channels []string
+	closed   bool
+}
+
+func (c *fakeConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.replies)
+	}
+	return nil
+}
+
+func (c *fakeConn) Err() error { return nil }
+
+func (c *fakeConn) Do(command string, args ...interface{}) (interface{}, error) {
+	switch command {
+	case "":
+		return nil, nil
+	case "PUBLISH":
+		return c.broker.publish(args[0].(string), args[1].([]byte)), nil
+	}
+	return nil, errors.New("unexpected command " + command)
+}
+
+func (c *fakeConn) Send(command string, args ...interface{}) error {
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch command {
+	case "SUBSCRIBE":
+		for _, arg := range args {
+			channel := arg.(string)
+			b.subscribers[channel] = append(b.subscribers[channel], c)
+			c.channels = append(c.channels, channel)
+			c.replies <- []interface{}{[]byte("subscribe"), []byte(channel), int64(len(c.channels))}
+		}
+	case "UNSUBSCRIBE":
+		for _, channel := range c.channels {
+			subscribers := b.subscribers[channel][:0]
+			for _, s := range b.subscribers[channel] {
+				if s != c {
+					subscribers = append(subscribers, s)
+				}
+			}
+			b.subscribers[channel] = subscribers
+		}
+		c.channels = nil
+		c.replies <- []interface{}{[]byte("unsubscribe"), nil, int64(0)}
+	case "PUNSUBSCRIBE":
+		c.replies <- []interface{}{[]byte("punsubscribe"), nil, int64(0)}
+	case "ECHO":
+		c.replies <- args[0]
+	default:
+		return errors.New("unexpected command " + command)
+	}
+	return nil
+}
+
+func (c *fakeConn) Flush() error { return nil }
+
+func (c *fakeConn) Receive() (interface{}, error) {
+	reply, ok := <-c.replies
+	if !ok {
+		return nil, errors.New("connection closed")
+	}
+	return reply, nil
+}
+
+// eventually fails the test when cond is not met within a second.
+func eventually(t *testing.T, cond func() bool, format string, args ...interface{}) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf(format, args...)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// replica runs a Propagator with its own logger until the end of the test.
+func replica(t *testing.T, b *broker) (*Propagator, *log.LevelRegistry) {
+	registry := log.New().Levels()
+	p := New(b.pool(), "", registry)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- p.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != context.Canceled {
+			t.Errorf("Expected Run to return the context error but got %v", err)
+		}
+	})
+	return p, registry
+}
+
+func TestPublish(t *testing.T) {
+	b := newBroker()
+	registry := log.New().Levels()
+	p := New(b.pool(), "", registry)
+
+	registry.SetLevel("payment", log.DebugLevel, time.Minute)
+	messages := b.messages()
+	if len(messages) != 1 {
+		t.Fatalf("Expected one published change but got %d", len(messages))
+	}
+	var msg message
+	if err := json.Unmarshal(messages[0], &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Origin != p.origin || msg.Name != "payment" || msg.Level != log.DebugLevel || msg.RevertAfter != time.Minute {
+		t.Errorf("Unexpected message %s", messages[0])
+	}
+
+	// the received changes are not published again
+	registry.Apply(log.LevelChange{Name: "card", Level: log.TraceLevel})
+	if len(b.messages()) != 1 {
+		t.Errorf("Expected the applied change not to be published but got %d messages", len(b.messages()))
+	}
+}
+
+func TestPropagate(t *testing.T) {
+	b := newBroker()
+	_, source := replica(t, b)
+	target, registry := replica(t, b)
+	eventually(t, func() bool { return b.subscriberCount(DefaultChannel) == 2 }, "Expected the replicas to subscribe")
+
+	source.SetLevel("payment", log.DebugLevel, 0)
+	eventually(t, func() bool { return registry.Level("payment") == log.DebugLevel }, "Expected the change to be applied")
+
+	// the messages of the replica itself are ignored, the changes being
+	// received in order
+	own, _ := json.Marshal(message{Origin: target.origin, LevelChange: log.LevelChange{Name: "card", Level: log.TraceLevel}})
+	b.publish(DefaultChannel, own)
+	source.SetLevel("report", log.WarnLevel, 0)
+	eventually(t, func() bool { return registry.Level("report") == log.WarnLevel }, "Expected the change to be applied")
+	if level := registry.Level("card"); level == log.TraceLevel {
+		t.Errorf("Expected the own change to be ignored but got %s", level)
+	}
+}
+
+func TestPropagateRevertAfter(t *testing.T) {
+	b := newBroker()
+	_, source := replica(t, b)
+	_, registry := replica(t, b)
+	eventually(t, func() bool { return b.subscriberCount(DefaultChannel) == 2 }, "Expected the replicas to subscribe")
+
+	previous := registry.Level("payment")
+	source.SetLevel("payment", log.TraceLevel, 50*time.Millisecond)
+	eventually(t, func() bool { return registry.Level("payment") == log.TraceLevel }, "Expected the change to be applied")
+	eventually(t, func() bool { return registry.Level("payment") == previous }, "Expected the level to be reverted to %s", previous)
+}
@@ -0,0 +1,408 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the format of the rotation time in the backup names.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// currentTime returns the time used to rotate files, replaced by tests.
+var currentTime = time.Now
+
+// RotatingFileOption sets an optional parameter of a RotatingFile.
+type RotatingFileOption func(*RotatingFile)
+
+// RotateSize rotates the file before a write would make it larger than
+// size bytes.
+func RotateSize(size int64) RotatingFileOption {
+	return func(f *RotatingFile) { f.maxSize = size }
+}
+
+// RotateInterval rotates the file on the first write of each interval. The
+// intervals are aligned on the zero time, UTC midnight for a day.
+func RotateInterval(interval time.Duration) RotatingFileOption {
+	return func(f *RotatingFile) { f.interval = interval }
+}
+
+// RotateMaxBackups keeps at most n rotated files, removing the oldest ones.
+func RotateMaxBackups(n int) RotatingFileOption {
+	return func(f *RotatingFile) { f.maxBackups = n }
+}
+
+// RotateMaxAge removes the rotated files older than age.
+func RotateMaxAge(age time.Duration) RotatingFileOption {
+	return func(f *RotatingFile) { f.maxAge = age }
+}
+
+// RotateCompress gzips the rotated files.
+func RotateCompress(compress bool) RotatingFileOption {
+	return func(f *RotatingFile) { f.compress = compress }
+}
+
+// RotatingFile is a Logger output writing to a file that is rotated by size
+// and by time. Rotated files are renamed with the rotation time appended to
+// the file name, e.g. app-2006-01-02T15-04-05.000.log, followed by a sequence
+// number when several rotations happen in the same millisecond, then
+// compressed and pruned in the background.
+//
+// The file is reopened when the process receives SIGHUP, after logrotate
+// moved it for instance. A RotatingFile is safe for concurrent use and may
+// be shared by several loggers or wrapped by an AsyncWriter.
+//
+//	f, err := log.OpenRotatingFile("/var/log/app.log", log.RotateSize(100<<20), log.RotateMaxBackups(7))
+//	if err != nil {
+//		...
+//	}
+//	log.SetOutput(f)
+type RotatingFile struct {
+	filename   string
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+	maxAge     time.Duration
+	compress   bool
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	period time.Time
+	refs   int
+
+	millMu sync.Mutex
+	millWG sync.WaitGroup
+}
+
+var (
+	rotatingFilesMu sync.Mutex
+	// open files by absolute path, shared by the loggers writing to them
+	rotatingFiles = map[string]*RotatingFile{}
+)
+
+// OpenRotatingFile opens, or creates, the named file for appending and
+// returns a RotatingFile writing to it. Opening a file already opened by the
+// process returns the same RotatingFile, ignoring the given options, so that
+// writes and rotations of the loggers sharing the file do not interleave.
+// Each RotatingFile returned must be closed.
+func OpenRotatingFile(filename string, options ...RotatingFileOption) (*RotatingFile, error) {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	rotatingFilesMu.Lock()
+	defer rotatingFilesMu.Unlock()
+	if f, ok := rotatingFiles[path]; ok {
+		f.mu.Lock()
+		f.refs++
+		f.mu.Unlock()
+		return f, nil
+	}
+
+	f := &RotatingFile{filename: path, refs: 1}
+	for _, option := range options {
+		option(f)
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	rotatingFiles[path] = f
+	notifyReopen()
+	return f, nil
+}
+
+// Filename returns the absolute path of the file.
+func (f *RotatingFile) Filename() string {
+	return f.filename
+}
+
+// Write writes p to the file, rotating it first when needed.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		if f.refs == 0 {
+			return 0, os.ErrClosed
+		}
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	now := currentTime()
+	if (f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize) ||
+		(f.interval > 0 && !now.Truncate(f.interval).Equal(f.period)) {
+		if err := f.rotate(now); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate rotates the file regardless of its size and age.
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rotate(currentTime())
+}
+
+// Reopen closes and reopens the file, creating it when it was moved.
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.refs == 0 {
+		return os.ErrClosed
+	}
+	if err := f.close(); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+// Close releases the RotatingFile, the file being closed when all the
+// callers of OpenRotatingFile have closed it.
+func (f *RotatingFile) Close() error {
+	rotatingFilesMu.Lock()
+	f.mu.Lock()
+	if f.refs == 0 {
+		f.mu.Unlock()
+		rotatingFilesMu.Unlock()
+		return os.ErrClosed
+	}
+	f.refs--
+	if f.refs > 0 {
+		f.mu.Unlock()
+		rotatingFilesMu.Unlock()
+		return nil
+	}
+	delete(rotatingFiles, f.filename)
+	err := f.close()
+	f.mu.Unlock()
+	rotatingFilesMu.Unlock()
+
+	f.millWG.Wait()
+	return err
+}
+
+// open opens the file, it must be called with mu held.
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.filename), 0755); err != nil {
+		return fmt.Errorf("Unable to create log directory: %w", err)
+	}
+	file, err := os.OpenFile(f.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("Unable to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("Unable to open log file: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	if f.interval > 0 {
+		// an existing file is rotated on the first write of a later interval
+		modTime := info.ModTime()
+		if f.size == 0 {
+			modTime = currentTime()
+		}
+		f.period = modTime.Truncate(f.interval)
+	}
+	return nil
+}
+
+// close closes the file, it must be called with mu held.
+func (f *RotatingFile) close() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// rotate renames the file, opens a new one and starts the compression and
+// pruning of the backups. It must be called with mu held.
+func (f *RotatingFile) rotate(now time.Time) error {
+	if err := f.close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.filename, f.backupName(now)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Unable to rotate log file: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	if f.interval > 0 {
+		f.period = now.Truncate(f.interval)
+	}
+
+	if f.compress || f.maxBackups > 0 || f.maxAge > 0 {
+		f.millWG.Add(1)
+		go func() {
+			defer f.millWG.Done()
+			if err := f.mill(now); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to clean up rotated logs, %v\n", err)
+			}
+		}()
+	}
+	return nil
+}
+
+// backupName returns the name of the file rotated at t. The files rotated at
+// the same millisecond are told apart by a sequence number, so that a backup
+// is never overwritten.
+func (f *RotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(f.filename)
+	prefix := strings.TrimSuffix(f.filename, ext) + "-" + t.Local().Format(backupTimeFormat)
+	name := prefix + ext
+	for seq := 1; backupExists(name); seq++ {
+		name = fmt.Sprintf("%s.%d%s", prefix, seq, ext)
+	}
+	return name
+}
+
+// backupExists tells whether the backup name exists, compressed or not.
+func backupExists(name string) bool {
+	for _, n := range []string{name, name + ".gz"} {
+		if _, err := os.Lstat(n); err == nil || !os.IsNotExist(err) {
+			return true
+		}
+	}
+	return false
+}
+
+type rotatedFile struct {
+	name string
+	time time.Time
+	seq  int
+}
+
+// backups returns the rotated files, newest first.
+func (f *RotatingFile) backups() ([]rotatedFile, error) {
+	dir := filepath.Dir(f.filename)
+	ext := filepath.Ext(f.filename)
+	prefix := strings.TrimSuffix(filepath.Base(f.filename), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []rotatedFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		ts := strings.TrimSuffix(strings.TrimSuffix(name[len(prefix):], ".gz"), ext)
+		if len(ts) < len(backupTimeFormat) {
+			continue
+		}
+		t, err := time.ParseInLocation(backupTimeFormat, ts[:len(backupTimeFormat)], time.Local)
+		if err != nil {
+			continue
+		}
+		seq := 0
+		if rest := ts[len(backupTimeFormat):]; rest != "" {
+			if seq, err = strconv.Atoi(strings.TrimPrefix(rest, ".")); err != nil || rest[0] != '.' {
+				continue
+			}
+		}
+		backups = append(backups, rotatedFile{name: filepath.Join(dir, name), time: t, seq: seq})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].time.Equal(backups[j].time) {
+			return backups[i].seq > backups[j].seq
+		}
+		return backups[i].time.After(backups[j].time)
+	})
+	return backups, nil
+}
+
+// mill removes the backups in excess or too old at now and compresses the others.
+func (f *RotatingFile) mill(now time.Time) error {
+	f.millMu.Lock()
+	defer f.millMu.Unlock()
+
+	backups, err := f.backups()
+	if err != nil {
+		return err
+	}
+	cutoff := now.Add(-f.maxAge)
+	for i, backup := range backups {
+		if (f.maxBackups > 0 && i >= f.maxBackups) || (f.maxAge > 0 && backup.time.Before(cutoff)) {
+			if err := os.Remove(backup.name); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if f.compress && !strings.HasSuffix(backup.name, ".gz") {
+			if err := gzipFile(backup.name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// gzipFile replaces name with its gzip compressed name.gz.
+func gzipFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode())
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(name)
+	zw.ModTime = info.ModTime()
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	src.Close()
+	return os.Remove(name)
+}
+
+// reopenRotatingFiles reopens all the open files, on SIGHUP.
+func reopenRotatingFiles() {
+	rotatingFilesMu.Lock()
+	files := make([]*RotatingFile, 0, len(rotatingFiles))
+	for _, f := range rotatingFiles {
+		files = append(files, f)
+	}
+	rotatingFilesMu.Unlock()
+
+	for _, f := range files {
+		if err := f.Reopen(); err != nil && err != os.ErrClosed {
+			fmt.Fprintf(os.Stderr, "Failed to reopen log file, %v\n", err)
+		}
+	}
+}
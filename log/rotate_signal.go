@@ -0,0 +1,26 @@
+//go:build !appengine && !js && !windows && !nacl && !plan9
+// +build !appengine,!js,!windows,!nacl,!plan9
+
+package log
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var reopenOnce sync.Once
+
+// notifyReopen starts reopening the open rotating files on SIGHUP.
+func notifyReopen() {
+	reopenOnce.Do(func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGHUP)
+		go func() {
+			for range c {
+				reopenRotatingFiles()
+			}
+		}()
+	})
+}
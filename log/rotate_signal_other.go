@@ -0,0 +1,7 @@
+//go:build appengine || js || windows || nacl || plan9
+// +build appengine js windows nacl plan9
+
+package log
+
+// notifyReopen does nothing, there is no SIGHUP on this platform.
+func notifyReopen() {}
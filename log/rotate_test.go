@@ -0,0 +1,196 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setCurrentTime(t *testing.T, now *time.Time) {
+	t.Helper()
+	currentTime = func() time.Time { return *now }
+	t.Cleanup(func() { currentTime = time.Now })
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	now := time.Date(2024, 1, 2, 10, 0, 0, 0, time.Local)
+	setCurrentTime(t, &now)
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+
+	f, err := OpenRotatingFile(name, RotateSize(10), RotateMaxBackups(2), RotateCompress(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Second)
+	}
+	f.millWG.Wait()
+
+	if got := readFile(t, name); got != "fourth\n" {
+		t.Errorf("Expected the current file to hold the last line but got %q", got)
+	}
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups but got %v", backups)
+	}
+	expected := filepath.Join(dir, "app-2024-01-02T10-00-03.000.log.gz")
+	if backups[0].name != expected {
+		t.Errorf("Expected the newest backup %s but got %s", expected, backups[0].name)
+	}
+	file, err := os.Open(backups[0].name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(zr); string(b) != "third\n" {
+		t.Errorf("Expected the newest backup to hold the third line but got %q", b)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("closed")); err != os.ErrClosed {
+		t.Errorf("Expected ErrClosed but got %v", err)
+	}
+}
+
+func TestRotatingFileInterval(t *testing.T) {
+	now := time.Date(2024, 1, 2, 23, 59, 0, 0, time.UTC)
+	setCurrentTime(t, &now)
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+
+	f, err := OpenRotatingFile(name, RotateInterval(24*time.Hour), RotateMaxAge(48*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// an expired backup, pruned on the next rotation
+	old := filepath.Join(dir, "app-"+now.Add(-72*time.Hour).In(time.Local).Format(backupTimeFormat)+".log")
+	if err := os.WriteFile(old, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f.Write([]byte("monday\n"))
+	now = now.Add(30 * time.Second)
+	f.Write([]byte("still monday\n"))
+	now = now.Add(time.Minute)
+	f.Write([]byte("tuesday\n"))
+	f.millWG.Wait()
+
+	if got := readFile(t, name); got != "tuesday\n" {
+		t.Errorf("Unexpected current file %q", got)
+	}
+	backups, _ := f.backups()
+	if len(backups) != 1 || readFile(t, backups[0].name) != "monday\nstill monday\n" {
+		t.Errorf("Unexpected backups %v", backups)
+	}
+}
+
+func TestRotatingFileShared(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+
+	f1, err := OpenRotatingFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f2, err := OpenRotatingFile(filepath.Join(dir, ".", "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f1 != f2 {
+		t.Fatal("Expected the same file to be shared")
+	}
+
+	logger1, _ := newContextTestLogger()
+	logger2, _ := newContextTestLogger()
+	logger1.SetOutput(NewAsyncWriter(f1, 0, OverflowBlock))
+	logger2.SetOutput(f2)
+	logger1.Info("async")
+	logger1.Out.(*AsyncWriter).Close()
+	logger2.Info("sync")
+
+	// logrotate moves the file then sends SIGHUP
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	}
+	reopenRotatingFiles()
+	logger2.Info("reopened")
+
+	if got := readFile(t, name+".1"); !strings.Contains(got, "async") || !strings.Contains(got, "sync") {
+		t.Errorf("Unexpected moved file %q", got)
+	}
+	if got := readFile(t, name); !strings.Contains(got, "reopened") || strings.Contains(got, "async") {
+		t.Errorf("Unexpected reopened file %q", got)
+	}
+
+	if err := f1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	logger2.Info("still open")
+	if err := f2.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(readFile(t, name), "still open") {
+		t.Error("Expected the file to stay open until closed by all its users")
+	}
+}
+
+func TestRotatingFileSameMillisecond(t *testing.T) {
+	now := time.Date(2024, 1, 2, 10, 0, 0, 0, time.Local)
+	setCurrentTime(t, &now)
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+
+	f, err := OpenRotatingFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, line := range []string{"first\n", "second\n"} {
+		f.Write([]byte(line))
+		if err := f.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups but got %v", backups)
+	}
+	if got := readFile(t, backups[0].name); got != "second\n" || !strings.HasSuffix(backups[0].name, ".000.1.log") {
+		t.Errorf("Expected the newest backup to hold the second line but got %s: %q", backups[0].name, got)
+	}
+	if got := readFile(t, backups[1].name); got != "first\n" {
+		t.Errorf("Expected the oldest backup to hold the first line but got %q", got)
+	}
+}
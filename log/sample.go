@@ -0,0 +1,235 @@
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+// Key names of the fields of the summary entries logged by RateSampler
+const (
+	FieldKeySuppressed     = "suppressed"
+	FieldKeySampledMessage = "sampled_msg"
+)
+
+// Sampler decides whether entries are logged. Sample is called before the
+// hooks are fired and returns false to drop the entry. Panic and fatal
+// entries are always logged.
+type Sampler interface {
+	Sample(entry *Entry) bool
+}
+
+// SamplingRate configures how entries with the same message and level are
+// sampled during an interval.
+type SamplingRate struct {
+	// First is the number of entries logged in each interval
+	First int
+	// Thereafter logs one in Thereafter entries after the First ones, none when 0
+	Thereafter int
+	// Disabled logs all the entries
+	Disabled bool
+}
+
+// NeverSample is the SamplingRate of entries that are never sampled, such as
+// audit entries.
+var NeverSample = SamplingRate{Disabled: true}
+
+func (r SamplingRate) sample(n int) bool {
+	if r.Disabled || n <= r.First {
+		return true
+	}
+	return r.Thereafter > 0 && (n-r.First)%r.Thereafter == 0
+}
+
+type sampleKey struct {
+	level Level
+	msg   string
+}
+
+type sampleCounter struct {
+	logger     *Logger
+	start      time.Time
+	n          int
+	suppressed int
+	// timer logs the summary at the end of the interval
+	timer *time.Timer
+}
+
+// suppressedCount is the type of the FieldKeySuppressed field of the summary
+// entries, telling them from the entries holding a field of the same name.
+type suppressedCount int
+
+// RateSampler is a Sampler logging the first entries with the same message
+// and level of each interval, then one in a given number of them. When the
+// interval of a message with suppressed entries ends, a summary entry
+// reporting their number is logged at the level of the message, with the
+// FieldKeySuppressed and FieldKeySampledMessage fields.
+//
+//	sampler := log.NewRateSampler(time.Second, 10, 100)
+//	sampler.FieldKeys["audit"] = log.NeverSample
+//	logger.SetSampler(sampler)
+type RateSampler struct {
+	// Interval is the period the entries are counted over
+	Interval time.Duration
+	// Rate is the default sampling rate
+	Rate SamplingRate
+	// Levels overrides the sampling rate of the entries of a level
+	Levels map[Level]SamplingRate
+	// FieldKeys overrides the sampling rate of the entries holding a field,
+	// taking precedence over Levels. When an entry holds several of the
+	// fields, the rate of the first field in lexical order applies.
+	FieldKeys map[string]SamplingRate
+
+	mu        sync.Mutex
+	counters  map[sampleKey]*sampleCounter
+	lastSweep time.Time
+}
+
+// NewRateSampler returns a RateSampler logging the first entries with the same
+// message and level of each interval, then one in thereafter.
+func NewRateSampler(interval time.Duration, first, thereafter int) *RateSampler {
+	return &RateSampler{
+		Interval:  interval,
+		Rate:      SamplingRate{First: first, Thereafter: thereafter},
+		Levels:    make(map[Level]SamplingRate),
+		FieldKeys: make(map[string]SamplingRate),
+	}
+}
+
+// Sample implements Sampler.
+func (s *RateSampler) Sample(entry *Entry) bool {
+	if entry.Level <= FatalLevel {
+		return true
+	}
+	if _, ok := entry.Data[FieldKeySuppressed].(suppressedCount); ok {
+		// summary entries
+		return true
+	}
+	rate := s.rate(entry)
+	if rate.Disabled {
+		return true
+	}
+
+	now := currentTime()
+	s.mu.Lock()
+	if s.counters == nil {
+		s.counters = make(map[sampleKey]*sampleCounter)
+	}
+	summaries := s.sweep(now)
+	key := sampleKey{level: entry.Level, msg: entry.Message}
+	c, ok := s.counters[key]
+	if !ok {
+		c = &sampleCounter{logger: entry.Logger, start: now}
+		s.counters[key] = c
+	}
+	c.n++
+	sampled := rate.sample(c.n)
+	if !sampled {
+		if c.suppressed++; c.timer == nil {
+			c.timer = time.AfterFunc(c.start.Add(s.Interval).Sub(now), func() { s.expire(key, c) })
+		}
+	}
+	s.mu.Unlock()
+
+	s.logSummaries(summaries)
+	return sampled
+}
+
+// Flush logs the summary entries of all the messages with suppressed entries
+// and resets the counters.
+func (s *RateSampler) Flush() {
+	s.mu.Lock()
+	summaries := make(map[sampleKey]*sampleCounter)
+	for key, c := range s.counters {
+		if c.suppressed > 0 {
+			summaries[key] = c
+		}
+		s.remove(key, c)
+	}
+	s.mu.Unlock()
+
+	s.logSummaries(summaries)
+}
+
+// expire logs the summary of the counter at the end of its interval, unless
+// it was already removed by a sweep or a flush.
+func (s *RateSampler) expire(key sampleKey, c *sampleCounter) {
+	s.mu.Lock()
+	if s.counters[key] != c {
+		s.mu.Unlock()
+		return
+	}
+	s.remove(key, c)
+	s.mu.Unlock()
+
+	s.logSummaries(map[sampleKey]*sampleCounter{key: c})
+}
+
+// remove removes the counter and stops its timer. It must be called with mu
+// held.
+func (s *RateSampler) remove(key sampleKey, c *sampleCounter) {
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	delete(s.counters, key)
+}
+
+func (s *RateSampler) rate(entry *Entry) SamplingRate {
+	var (
+		rate  SamplingRate
+		field string
+		found bool
+	)
+	for key, r := range s.FieldKeys {
+		if _, ok := entry.Data[key]; ok && (!found || key < field) {
+			rate, field, found = r, key, true
+		}
+	}
+	if found {
+		return rate
+	}
+	if rate, ok := s.Levels[entry.Level]; ok {
+		return rate
+	}
+	return s.Rate
+}
+
+// sweep removes the counters of the ended intervals, at most once per
+// interval, returning those with suppressed entries. It must be called
+// with mu held.
+func (s *RateSampler) sweep(now time.Time) map[sampleKey]*sampleCounter {
+	if now.Sub(s.lastSweep) < s.Interval {
+		return nil
+	}
+	s.lastSweep = now
+
+	var summaries map[sampleKey]*sampleCounter
+	for key, c := range s.counters {
+		if now.Sub(c.start) < s.Interval {
+			continue
+		}
+		if c.suppressed > 0 {
+			if summaries == nil {
+				summaries = make(map[sampleKey]*sampleCounter)
+			}
+			summaries[key] = c
+		}
+		s.remove(key, c)
+	}
+	return summaries
+}
+
+func (s *RateSampler) logSummaries(summaries map[sampleKey]*sampleCounter) {
+	for key, c := range summaries {
+		c.logger.WithFields(Fields{
+			FieldKeySuppressed:     suppressedCount(c.suppressed),
+			FieldKeySampledMessage: key.msg,
+		}).Logf(key.level, "%d entries suppressed by sampling", c.suppressed)
+	}
+}
+
+// SetSampler sets the logger sampler, nil to log all the entries.
+func (logger *Logger) SetSampler(sampler Sampler) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	logger.Sampler = sampler
+}
@@ -0,0 +1,120 @@
+package log
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateSampler(t *testing.T) {
+	now := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	setCurrentTime(t, &now)
+
+	logger, buf := newContextTestLogger()
+	logger.Formatter = &TextFormatter{DisableTimestamp: true, DisableColors: true}
+	logger.Level = DebugLevel
+	sampler := NewRateSampler(time.Second, 2, 3)
+	sampler.Levels[WarnLevel] = SamplingRate{First: 1}
+	sampler.FieldKeys["audit"] = NeverSample
+	logger.SetSampler(sampler)
+
+	for i := 0; i < 10; i++ {
+		logger.Error("downstream unavailable")
+		logger.Warn("slow")
+		logger.WithField("audit", true).Info("login")
+	}
+	logger.Debug("other")
+
+	out := buf.String()
+	// the first 2, then the 5th and the 8th
+	if n := strings.Count(out, "downstream unavailable"); n != 4 {
+		t.Errorf("Expected 4 errors but got %d", n)
+	}
+	if n := strings.Count(out, "msg=slow"); n != 1 {
+		t.Errorf("Expected 1 warning but got %d", n)
+	}
+	if n := strings.Count(out, "msg=login"); n != 10 {
+		t.Errorf("Expected 10 audit entries but got %d", n)
+	}
+	if strings.Contains(out, "suppressed") {
+		t.Errorf("Unexpected summary before the end of the interval: %s", out)
+	}
+
+	buf.Reset()
+	now = now.Add(time.Second)
+	logger.Error("downstream unavailable")
+	out = buf.String()
+	for _, summary := range []string{
+		`level=error msg="6 entries suppressed by sampling" sampled_msg="downstream unavailable" suppressed=6`,
+		`level=warning msg="9 entries suppressed by sampling" sampled_msg=slow suppressed=9`,
+	} {
+		if !strings.Contains(out, summary) {
+			t.Errorf("Expected the summary %q in %s", summary, out)
+		}
+	}
+	if !strings.HasSuffix(out, "level=error msg=\"downstream unavailable\"\n") {
+		t.Errorf("Expected the entry to be logged in the new interval: %s", out)
+	}
+
+	buf.Reset()
+	for i := 0; i < 3; i++ {
+		logger.Error("downstream unavailable")
+	}
+	sampler.Flush()
+	if !strings.Contains(buf.String(), "suppressed=2") {
+		t.Errorf("Expected the flushed summary in %s", buf.String())
+	}
+}
+
+// summaryHook sends the summary entries to a channel.
+type summaryHook chan *Entry
+
+func (h summaryHook) Levels() []Level { return AllLevels }
+
+func (h summaryHook) Fire(entry *Entry) error {
+	if _, ok := entry.Data[FieldKeySampledMessage]; ok {
+		h <- entry
+	}
+	return nil
+}
+
+func TestRateSamplerQuietKey(t *testing.T) {
+	logger, _ := newContextTestLogger()
+	summaries := make(summaryHook, 1)
+	logger.AddHook(summaries)
+	sampler := NewRateSampler(20*time.Millisecond, 1, 0)
+	sampler.FieldKeys["audit"] = NeverSample
+	sampler.FieldKeys["bulk"] = SamplingRate{First: 1}
+	logger.SetSampler(sampler)
+
+	for i := 0; i < 3; i++ {
+		logger.Error("downstream unavailable")
+		// the rate of the first field in lexical order applies
+		logger.WithFields(Fields{"audit": true, "bulk": true}).Info("login")
+		// a field named like the summary field is sampled as any other
+		logger.WithField(FieldKeySuppressed, 0).Warn("retrying")
+	}
+
+	// no other entry is logged, the summary is logged at the end of the interval
+	select {
+	case entry := <-summaries:
+		if entry.Data[FieldKeySampledMessage] != "downstream unavailable" && entry.Data[FieldKeySampledMessage] != "retrying" {
+			t.Errorf("Unexpected summary %v", entry.Data)
+		}
+		if entry.Data[FieldKeySuppressed] != suppressedCount(2) {
+			t.Errorf("Expected 2 suppressed entries but got %v", entry.Data[FieldKeySuppressed])
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a summary at the end of the interval")
+	}
+	select {
+	case <-summaries:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the summary of the second message")
+	}
+	select {
+	case entry := <-summaries:
+		t.Errorf("Unexpected summary %v", entry.Data)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
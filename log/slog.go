@@ -0,0 +1,254 @@
+//go:build go1.21
+// +build go1.21
+
+package log
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+)
+
+// Levels of the log/slog records matching the levels without slog equivalent
+const (
+	SlogLevelTrace = slog.LevelDebug - 4
+	SlogLevelFatal = slog.LevelError + 4
+	SlogLevelPanic = slog.LevelError + 8
+)
+
+// FromSlogLevel returns the level matching the slog level, the levels between
+// two slog levels matching the less severe one.
+func FromSlogLevel(level slog.Level) Level {
+	switch {
+	case level >= SlogLevelPanic:
+		return PanicLevel
+	case level >= SlogLevelFatal:
+		return FatalLevel
+	case level >= slog.LevelError:
+		return ErrorLevel
+	case level >= slog.LevelWarn:
+		return WarnLevel
+	case level >= slog.LevelInfo:
+		return InfoLevel
+	case level >= slog.LevelDebug:
+		return DebugLevel
+	default:
+		return TraceLevel
+	}
+}
+
+// SlogLevel returns the slog level matching the level.
+func SlogLevel(level Level) slog.Level {
+	switch level {
+	case PanicLevel:
+		return SlogLevelPanic
+	case FatalLevel:
+		return SlogLevelFatal
+	case ErrorLevel:
+		return slog.LevelError
+	case WarnLevel:
+		return slog.LevelWarn
+	case InfoLevel:
+		return slog.LevelInfo
+	case DebugLevel:
+		return slog.LevelDebug
+	default:
+		return SlogLevelTrace
+	}
+}
+
+// SlogHandler is a slog.Handler writing the records through a Logger, with
+// its level, hooks, formatter and output. Attributes become entry fields and
+// groups nested Fields, the way slog.JSONHandler nests groups.
+//
+// Records logged at the panic and fatal levels are logged without panicking
+// or exiting.
+type SlogHandler struct {
+	entry  *Entry
+	fields Fields
+	groups []string
+}
+
+// NewSlogHandler returns a SlogHandler writing through logger.
+func NewSlogHandler(logger *Logger) *SlogHandler {
+	return NewSlogEntryHandler(NewEntry(logger))
+}
+
+// NewSlogEntryHandler returns a SlogHandler writing through entry, keeping
+// its fields and named logger level.
+func NewSlogEntryHandler(entry *Entry) *SlogHandler {
+	return &SlogHandler{entry: entry}
+}
+
+// NewSlogLogger returns a slog.Logger writing through logger.
+func NewSlogLogger(logger *Logger) *slog.Logger {
+	return slog.New(NewSlogHandler(logger))
+}
+
+// Enabled implements slog.Handler.
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.entry.IsLevelEnabled(FromSlogLevel(level))
+}
+
+// Handle implements slog.Handler.
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	fields := copyFields(h.fields)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = addAttr(fields, h.groups, a)
+		return true
+	})
+
+	entry := h.entry.WithFields(fields)
+	if ctx != nil {
+		entry = entry.WithContext(ctx)
+	}
+	if !r.Time.IsZero() {
+		entry.Time = r.Time
+	}
+	level := FromSlogLevel(r.Level)
+	if !entry.IsLevelEnabled(level) {
+		return nil
+	}
+	if level <= PanicLevel {
+		// entry.log panics with the panic entries
+		defer func() { recover() }()
+	}
+	entry.log(level, r.Message)
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := copyFields(h.fields)
+	for _, a := range attrs {
+		fields = addAttr(fields, h.groups, a)
+	}
+	return &SlogHandler{entry: h.entry, fields: fields, groups: h.groups}
+}
+
+// WithGroup implements slog.Handler.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	groups := make([]string, len(h.groups), len(h.groups)+1)
+	copy(groups, h.groups)
+	return &SlogHandler{entry: h.entry, fields: h.fields, groups: append(groups, name)}
+}
+
+// copyFields returns a copy of fields, the nested groups being copied when
+// attributes are added to them.
+func copyFields(fields Fields) Fields {
+	c := make(Fields, len(fields))
+	for k, v := range fields {
+		c[k] = v
+	}
+	return c
+}
+
+// addAttr adds the attribute a to the group of fields at path, following the
+// rules of slog.Handler: empty attributes are ignored, groups without
+// attributes are ignored and groups with an empty key are inlined.
+func addAttr(fields Fields, path []string, a slog.Attr) Fields {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return fields
+		}
+		if a.Key != "" {
+			path = append(path[:len(path):len(path)], a.Key)
+		}
+		for _, ga := range attrs {
+			fields = addAttr(fields, path, ga)
+		}
+		return fields
+	}
+
+	group := fields
+	for _, name := range path {
+		nested, ok := group[name].(Fields)
+		// the groups of the handler are shared by its records, copy on write
+		c := make(Fields, len(nested)+1)
+		if ok {
+			for k, v := range nested {
+				c[k] = v
+			}
+		}
+		group[name] = c
+		group = c
+	}
+	group[a.Key] = a.Value.Any()
+	return fields
+}
+
+// SlogHook is a Hook sending the entries to a slog.Handler, so that the
+// entries of a Logger reach the output of the slog handlers. The fields of
+// the entries become attributes, nested Fields becoming groups.
+//
+// The handler must not write back to the logger firing the hook.
+type SlogHook struct {
+	Handler slog.Handler
+	// LogLevels are the levels the hook fires on, AllLevels when empty
+	LogLevels []Level
+}
+
+// NewSlogHook returns a SlogHook sending the entries of the given levels, or
+// of all levels when none is given, to handler.
+func NewSlogHook(handler slog.Handler, levels ...Level) *SlogHook {
+	return &SlogHook{Handler: handler, LogLevels: levels}
+}
+
+// Levels implements Hook.
+func (h *SlogHook) Levels() []Level {
+	if len(h.LogLevels) == 0 {
+		return AllLevels
+	}
+	return h.LogLevels
+}
+
+// Fire implements Hook.
+func (h *SlogHook) Fire(entry *Entry) error {
+	ctx := entry.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	level := SlogLevel(entry.Level)
+	if !h.Handler.Enabled(ctx, level) {
+		return nil
+	}
+
+	var pc uintptr
+	if entry.Caller != nil {
+		pc = entry.Caller.PC
+	}
+	r := slog.NewRecord(entry.Time, level, entry.Message, pc)
+	r.AddAttrs(fieldsAttrs(entry.Data)...)
+	return h.Handler.Handle(ctx, r)
+}
+
+// fieldsAttrs returns the attributes of fields, sorted by key.
+func fieldsAttrs(fields map[string]interface{}) []slog.Attr {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, k := range keys {
+		switch v := fields[k].(type) {
+		case Fields:
+			attrs = append(attrs, slog.Attr{Key: k, Value: slog.GroupValue(fieldsAttrs(v)...)})
+		case map[string]interface{}:
+			attrs = append(attrs, slog.Attr{Key: k, Value: slog.GroupValue(fieldsAttrs(v)...)})
+		default:
+			attrs = append(attrs, slog.Any(k, v))
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,93 @@
+//go:build go1.21
+// +build go1.21
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	logger, buf := newContextTestLogger()
+	var fired []*Entry
+	logger.AddHook(&testHook{fire: func(e *Entry) { fired = append(fired, e) }})
+
+	slogger := NewSlogLogger(logger).With("service", "payment").WithGroup("req").With("id", 7)
+	ctx := ContextWithField(context.Background(), FieldKeyRequestID, "req-1")
+	slogger.InfoContext(ctx, "paid",
+		slog.Group("card", slog.String("brand", "visa"), slog.Int("last4", 1111)),
+		slog.Group("empty"),
+		slog.Group("", slog.Bool("inlined", true)),
+		slog.Attr{},
+	)
+	slogger.Debug("not logged")
+
+	fields := decodeEntry(t, buf)
+	expected := map[string]interface{}{
+		"level":           "info",
+		"msg":             "paid",
+		"service":         "payment",
+		FieldKeyRequestID: "req-1",
+		"req": map[string]interface{}{
+			"id":      float64(7),
+			"inlined": true,
+			"card":    map[string]interface{}{"brand": "visa", "last4": float64(1111)},
+		},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Expected %v but got %v", expected, fields)
+	}
+	if len(fired) != 1 {
+		t.Errorf("Expected the hooks to fire once but got %d", len(fired))
+	}
+
+	// the groups of the handler are not modified by its records
+	slogger.Warn("second", "other", 1)
+	fields = decodeEntry(t, buf)
+	if req := fields["req"].(map[string]interface{}); len(req) != 2 || fields["level"] != "warning" {
+		t.Errorf("Unexpected fields %v", fields)
+	}
+
+	slogger.Log(context.Background(), SlogLevelPanic, "panic")
+	if fields := decodeEntry(t, buf); fields["level"] != "panic" {
+		t.Errorf("Unexpected fields %v", fields)
+	}
+}
+
+func TestSlogHook(t *testing.T) {
+	logger, _ := newContextTestLogger()
+	var out bytes.Buffer
+	handler := slog.NewJSONHandler(&out, &slog.HandlerOptions{
+		Level: slog.LevelWarn,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	logger.AddHook(NewSlogHook(handler))
+
+	logger.WithFields(Fields{"user": "alice", "card": Fields{"brand": "visa"}}).Error("failed")
+	logger.Info("filtered by the handler")
+
+	expected := `{"level":"ERROR","msg":"failed","card":{"brand":"visa"},"user":"alice"}` + "\n"
+	if out.String() != expected {
+		t.Errorf("Expected %s but got %s", expected, out.String())
+	}
+}
+
+type testHook struct {
+	fire func(*Entry)
+}
+
+func (h *testHook) Levels() []Level { return AllLevels }
+
+func (h *testHook) Fire(e *Entry) error {
+	h.fire(e)
+	return nil
+}
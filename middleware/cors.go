@@ -1,9 +1,337 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// CORS headers
+const (
+	HeaderOrigin                        = "Origin"
+	HeaderVary                          = "Vary"
+	HeaderAccessControlRequestMethod    = "Access-Control-Request-Method"
+	HeaderAccessControlRequestHeaders   = "Access-Control-Request-Headers"
+	HeaderAccessControlAllowOrigin      = "Access-Control-Allow-Origin"
+	HeaderAccessControlAllowMethods     = "Access-Control-Allow-Methods"
+	HeaderAccessControlAllowHeaders     = "Access-Control-Allow-Headers"
+	HeaderAccessControlAllowCredentials = "Access-Control-Allow-Credentials"
+	HeaderAccessControlExposeHeaders    = "Access-Control-Expose-Headers"
+	HeaderAccessControlMaxAge           = "Access-Control-Max-Age"
+)
+
+// Defaults of the CORS options
+var (
+	DefaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	DefaultCORSHeaders = []string{"Accept", "Accept-Language", "Content-Language", "Content-Type", "Authorization", "X-Request-Id", "Idempotency-Key"}
 )
 
+// CORS implements the cross-origin resource sharing of the HTTP handlers.
+// The preflight requests are answered without calling the handlers, and
+// the responses vary on the Origin header.
+//
+// As gorilla/mux does not run the middlewares of the routes not matching
+// the OPTIONS method, the handler of a router is wrapped with CORS, rather
+// than given as a router middleware, for the preflight requests to succeed.
+type CORS struct {
+	allowAllOrigins  bool
+	origins          map[string]bool
+	wildcards        [][2]string
+	patterns         []*regexp.Regexp
+	methods          []string
+	allowAllHeaders  bool
+	headers          map[string]bool
+	exposedHeaders   []string
+	allowCredentials bool
+	maxAge           time.Duration
+	routes           map[string]*CORS
+	routeOptions     map[string][]CORSOption
+	override         func(r *http.Request) *CORS
+}
+
+// CORSOption sets an optional parameter for CORS.
+type CORSOption func(*CORS)
+
+// CORSAllowedOrigins sets the origins allowed, exactly, as
+// https://portal.example.com, or by subdomain, as https://*.example.com,
+// which matches the subdomains of example.com, but not example.com itself.
+// The * origin allows all the origins, without credentials. No origin is
+// allowed by default.
+func CORSAllowedOrigins(origins ...string) CORSOption {
+	return func(c *CORS) {
+		for _, origin := range origins {
+			origin = strings.ToLower(origin)
+			switch {
+			case origin == "*":
+				c.allowAllOrigins = true
+			case strings.Contains(origin, "://*."):
+				prefix, suffix, _ := strings.Cut(origin, "*")
+				c.wildcards = append(c.wildcards, [2]string{prefix, suffix})
+			default:
+				c.origins[origin] = true
+			}
+		}
+	}
+}
+
+// CORSAllowedOriginPatterns allows the origins matching the patterns
+// entirely, the patterns being anchored so as not to match unwanted
+// origins, such as https://example.com.evil.net for https://example\.com.
+func CORSAllowedOriginPatterns(patterns ...*regexp.Regexp) CORSOption {
+	return func(c *CORS) {
+		for _, pattern := range patterns {
+			c.patterns = append(c.patterns, regexp.MustCompile(`^(?:`+pattern.String()+`)$`))
+		}
+	}
+}
+
+// CORSAllowedMethods sets the methods allowed, DefaultCORSMethods by default.
+func CORSAllowedMethods(methods ...string) CORSOption {
+	return func(c *CORS) {
+		c.methods = c.methods[:0:0]
+		for _, method := range methods {
+			c.methods = append(c.methods, strings.ToUpper(method))
+		}
+	}
+}
+
+// CORSAllowedHeaders sets the request headers allowed, DefaultCORSHeaders by
+// default. The * header allows all the headers.
+func CORSAllowedHeaders(headers ...string) CORSOption {
+	return func(c *CORS) {
+		c.allowAllHeaders, c.headers = false, map[string]bool{}
+		for _, header := range headers {
+			if header == "*" {
+				c.allowAllHeaders = true
+			}
+			c.headers[http.CanonicalHeaderKey(header)] = true
+		}
+	}
+}
+
+// CORSExposedHeaders sets the response headers exposed to the scripts.
+func CORSExposedHeaders(headers ...string) CORSOption {
+	return func(c *CORS) { c.exposedHeaders = headers }
+}
+
+// CORSAllowCredentials allows the requests with credentials: cookies,
+// authorization headers and client certificates. The origins must then be
+// listed, NewCORS panics when all the origins are allowed.
+func CORSAllowCredentials(allow bool) CORSOption {
+	return func(c *CORS) { c.allowCredentials = allow }
+}
+
+// CORSMaxAge sets how long the browsers may cache the preflight responses.
+// By default, their caching is left to the browsers.
+func CORSMaxAge(maxAge time.Duration) CORSOption {
+	return func(c *CORS) { c.maxAge = maxAge }
+}
+
+// CORSRoute overrides the options of a route, which has the options of the
+// CORS followed by options. The route is the path of the requests when the
+// handler of a router is wrapped with CORS, as the current route is not set
+// yet. It is the gorilla/mux path template of the matched route when the
+// Handler method is given to router.Use, in which case the routes must
+// accept the OPTIONS method for their preflight requests to reach it.
+func CORSRoute(route string, options ...CORSOption) CORSOption {
+	return func(c *CORS) { c.routeOptions[route] = options }
+}
+
+// CORSOverride sets a hook returning the CORS of the requests, the nil CORS
+// keeping the default one. It takes precedence over CORSRoute.
+func CORSOverride(override func(r *http.Request) *CORS) CORSOption {
+	return func(c *CORS) { c.override = override }
+}
+
+// NewCORS returns a CORS with the options. It panics when all the origins
+// are allowed with credentials, which would let any site make authenticated
+// requests.
+func NewCORS(options ...CORSOption) *CORS {
+	c := newCORS(options)
+	c.check("")
+	c.routes = make(map[string]*CORS, len(c.routeOptions))
+	for route, routeOptions := range c.routeOptions {
+		rc := newCORS(append(options[:len(options):len(options)], routeOptions...))
+		rc.routeOptions, rc.override = nil, nil
+		rc.check(route)
+		c.routes[route] = rc
+	}
+	return c
+}
+
+// check panics when c allows all the origins with credentials.
+func (c *CORS) check(route string) {
+	if c.allowAllOrigins && c.allowCredentials {
+		if route != "" {
+			route = " of route " + route
+		}
+		panic(fmt.Sprintf("middleware: the CORS%s allows credentials from all the origins, list the allowed origins", route))
+	}
+}
+
+func newCORS(options []CORSOption) *CORS {
+	c := &CORS{
+		origins:      map[string]bool{},
+		routeOptions: map[string][]CORSOption{},
+	}
+	CORSAllowedMethods(DefaultCORSMethods...)(c)
+	CORSAllowedHeaders(DefaultCORSHeaders...)(c)
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Handler returns an http.Handler applying c to the requests of next.
+func (c *CORS) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cors := c.route(r)
+		if r.Method == http.MethodOptions && r.Header.Get(HeaderAccessControlRequestMethod) != "" {
+			cors.preflight(w, r)
+			return
+		}
+		cors.actual(w, r)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// route returns the CORS of the request.
+func (c *CORS) route(r *http.Request) *CORS {
+	if c.override != nil {
+		if cors := c.override(r); cors != nil {
+			return cors
+		}
+	}
+	if len(c.routes) == 0 {
+		return c
+	}
+	route := r.URL.Path
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			route = template
+		}
+	}
+	if cors, ok := c.routes[route]; ok {
+		return cors
+	}
+	return c
+}
+
+// preflight answers a preflight request, setting the CORS headers when its
+// origin, method and headers are allowed.
+func (c *CORS) preflight(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Add(HeaderVary, HeaderOrigin)
+	h.Add(HeaderVary, HeaderAccessControlRequestMethod)
+	h.Add(HeaderVary, HeaderAccessControlRequestHeaders)
+	defer w.WriteHeader(http.StatusNoContent)
+
+	origin := r.Header.Get(HeaderOrigin)
+	if !c.allowedOrigin(origin) || !c.allowedMethod(r.Header.Get(HeaderAccessControlRequestMethod)) {
+		return
+	}
+	headers, ok := c.allowedHeaders(r.Header.Get(HeaderAccessControlRequestHeaders))
+	if !ok {
+		return
+	}
+
+	c.setOrigin(h, origin)
+	h.Set(HeaderAccessControlAllowMethods, strings.Join(c.methods, ", "))
+	if len(headers) > 0 {
+		h.Set(HeaderAccessControlAllowHeaders, strings.Join(headers, ", "))
+	}
+	if c.maxAge > 0 {
+		h.Set(HeaderAccessControlMaxAge, strconv.Itoa(int(c.maxAge/time.Second)))
+	}
+}
+
+// actual sets the CORS headers of an actual request.
+func (c *CORS) actual(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	if !c.allowAllOrigins {
+		// the response depends on the origin, even without Origin header
+		h.Add(HeaderVary, HeaderOrigin)
+	}
+	origin := r.Header.Get(HeaderOrigin)
+	if origin == "" || !c.allowedOrigin(origin) || !c.allowedMethod(r.Method) {
+		return
+	}
+	c.setOrigin(h, origin)
+	if len(c.exposedHeaders) > 0 {
+		h.Set(HeaderAccessControlExposeHeaders, strings.Join(c.exposedHeaders, ", "))
+	}
+}
+
+func (c *CORS) setOrigin(h http.Header, origin string) {
+	if c.allowAllOrigins {
+		h.Set(HeaderAccessControlAllowOrigin, "*")
+	} else {
+		h.Set(HeaderAccessControlAllowOrigin, origin)
+	}
+	if c.allowCredentials {
+		h.Set(HeaderAccessControlAllowCredentials, "true")
+	}
+}
+
+func (c *CORS) allowedOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if c.allowAllOrigins {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if c.origins[origin] {
+		return true
+	}
+	for _, w := range c.wildcards {
+		if len(origin) > len(w[0])+len(w[1]) && strings.HasPrefix(origin, w[0]) && strings.HasSuffix(origin, w[1]) {
+			return true
+		}
+	}
+	for _, pattern := range c.patterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORS) allowedMethod(method string) bool {
+	if method == http.MethodOptions {
+		return true
+	}
+	for _, m := range c.methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedHeaders returns the canonical requested headers, ok being false when
+// one is not allowed.
+func (c *CORS) allowedHeaders(requested string) (headers []string, ok bool) {
+	for _, header := range strings.Split(requested, ",") {
+		if header = http.CanonicalHeaderKey(strings.TrimSpace(header)); header == "" {
+			continue
+		}
+		if !c.allowAllHeaders && !c.headers[header] {
+			return nil, false
+		}
+		headers = append(headers, header)
+	}
+	return headers, true
+}
+
+// CORSMethodMiddleware allows any origin to call the handler, with the
+// methods and headers used by the clients of the services. See CORS for a
+// configurable implementation.
 func CORSMethodMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if origin := r.Header.Get("Origin"); origin != "" {
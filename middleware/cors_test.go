@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func serveCORS(c *CORS, method, path string, header map[string]string) (*httptest.ResponseRecorder, bool) {
+	var called bool
+	handler := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec, called
+}
+
+func TestCORSOrigins(t *testing.T) {
+	c := NewCORS(
+		CORSAllowedOrigins("https://portal.example.com", "https://*.example.org"),
+		CORSAllowedOriginPatterns(regexp.MustCompile(`https://pr-\d+\.preview\.example\.net`)),
+	)
+	for origin, allowed := range map[string]bool{
+		"https://portal.example.com":        true,
+		"https://PORTAL.example.com":        true,
+		"http://portal.example.com":         false,
+		"https://a.b.example.org":           true,
+		"https://example.org":               false,
+		"https://evilexample.org":           false,
+		"https://pr-42.preview.example.net": true,
+		"https://pr-x.preview.example.net":  false,
+		// the patterns are anchored
+		"https://pr-42.preview.example.net.evil.com":         false,
+		"https://evil.com/https://pr-42.preview.example.net": false,
+	} {
+		rec, called := serveCORS(c, http.MethodGet, "/", map[string]string{HeaderOrigin: origin})
+		if got := rec.Header().Get(HeaderAccessControlAllowOrigin); (got == origin) != allowed {
+			t.Errorf("%s: unexpected allowed origin %q", origin, got)
+		}
+		if !called || rec.Header().Get(HeaderVary) != HeaderOrigin {
+			t.Errorf("%s: expected the handler to be called with Vary: Origin", origin)
+		}
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	c := NewCORS(
+		CORSAllowedOrigins("https://portal.example.com"),
+		CORSAllowedMethods(http.MethodGet, http.MethodPut),
+		CORSAllowCredentials(true),
+		CORSMaxAge(10*time.Minute),
+	)
+	preflight := map[string]string{
+		HeaderOrigin:                      "https://portal.example.com",
+		HeaderAccessControlRequestMethod:  http.MethodPut,
+		HeaderAccessControlRequestHeaders: "content-type, x-request-id",
+	}
+	rec, called := serveCORS(c, http.MethodOptions, "/", preflight)
+	h := rec.Header()
+	if called || rec.Code != http.StatusNoContent {
+		t.Errorf("Expected the preflight to be short-circuited but got %d", rec.Code)
+	}
+	if h.Get(HeaderAccessControlAllowOrigin) != "https://portal.example.com" || h.Get(HeaderAccessControlAllowCredentials) != "true" ||
+		h.Get(HeaderAccessControlAllowMethods) != "GET, PUT" || h.Get(HeaderAccessControlAllowHeaders) != "Content-Type, X-Request-Id" ||
+		h.Get(HeaderAccessControlMaxAge) != "600" || len(h.Values(HeaderVary)) != 3 {
+		t.Errorf("Unexpected preflight headers %v", h)
+	}
+
+	preflight[HeaderAccessControlRequestHeaders] = "x-unknown"
+	if rec, _ = serveCORS(c, http.MethodOptions, "/", preflight); rec.Header().Get(HeaderAccessControlAllowOrigin) != "" {
+		t.Errorf("Expected the header not to be allowed but got %v", rec.Header())
+	}
+	preflight[HeaderAccessControlRequestHeaders], preflight[HeaderAccessControlRequestMethod] = "", http.MethodDelete
+	if rec, _ = serveCORS(c, http.MethodOptions, "/", preflight); rec.Header().Get(HeaderAccessControlAllowOrigin) != "" {
+		t.Errorf("Expected the method not to be allowed but got %v", rec.Header())
+	}
+}
+
+func TestCORSRoute(t *testing.T) {
+	c := NewCORS(
+		CORSAllowedOrigins("https://portal.example.com"),
+		CORSExposedHeaders("X-Request-Id"),
+		CORSRoute("/public", CORSAllowedOrigins("*")),
+	)
+	rec, _ := serveCORS(c, http.MethodGet, "/public", map[string]string{HeaderOrigin: "https://any.example.net"})
+	if rec.Header().Get(HeaderAccessControlAllowOrigin) != "*" || rec.Header().Get(HeaderAccessControlExposeHeaders) != "X-Request-Id" {
+		t.Errorf("Expected the route options but got %v", rec.Header())
+	}
+	if rec, _ = serveCORS(c, http.MethodGet, "/private", map[string]string{HeaderOrigin: "https://any.example.net"}); rec.Header().Get(HeaderAccessControlAllowOrigin) != "" {
+		t.Errorf("Expected the origin not to be allowed but got %v", rec.Header())
+	}
+}
+
+func TestCORSCredentialsFromAllOrigins(t *testing.T) {
+	for _, options := range [][]CORSOption{
+		{CORSAllowedOrigins("*"), CORSAllowCredentials(true)},
+		{CORSAllowedOrigins("https://portal.example.com"), CORSAllowCredentials(true), CORSRoute("/public", CORSAllowedOrigins("*"))},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected NewCORS to reject the credentials from all the origins")
+				}
+			}()
+			NewCORS(options...)
+		}()
+	}
+}
+
+func TestCORSRouterMiddleware(t *testing.T) {
+	c := NewCORS(
+		CORSAllowedOrigins("https://portal.example.com"),
+		CORSRoute("/accounts/{id}", CORSAllowedOrigins("https://partner.example.com")),
+	)
+	router := mux.NewRouter()
+	router.Use(c.Handler)
+	router.HandleFunc("/accounts/{id}", func(http.ResponseWriter, *http.Request) {}).Methods(http.MethodGet, http.MethodOptions)
+
+	req := httptest.NewRequest(http.MethodGet, "/accounts/123", nil)
+	req.Header.Set(HeaderOrigin, "https://partner.example.com")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Header().Get(HeaderAccessControlAllowOrigin) != "https://partner.example.com" {
+		t.Errorf("Expected the options of the route template but got %v", rec.Header())
+	}
+}
@@ -1,18 +1,27 @@
 package middleware
 
 import (
-	"github.com/ThomasNguyenGitHub/go/log"
 	"net/http"
-	"runtime/debug"
+
+	"github.com/ThomasNguyenGitHub/go/recovery"
 )
 
+// Recover recovers the panics of next, responding an
+// errors.InternalServerError after reporting them to
+// recovery.DefaultReporters. http.ErrAbortHandler is panicked again, to abort
+// the response.
 func (m *middleware) Recover(next http.Handler) http.Handler {
+	recoverer := recovery.New()
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
-			if err := recover(); err != nil {
+			if v := recover(); v != nil {
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				err := recoverer.Recover(r.Context(), v)
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
 				w.WriteHeader(http.StatusInternalServerError)
-				log.Error("err:", err, "stack-trace", string(debug.Stack()))
-
+				w.Write([]byte(err.Error()))
 			}
 		}()
 		next.ServeHTTP(w, r)
@@ -0,0 +1,187 @@
+package recovery
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ThomasNguyenGitHub/go/log"
+)
+
+// DefaultReportTimeout is the default timeout of the reports of HTTPReporter.
+const DefaultReportTimeout = 5 * time.Second
+
+// Event is the JSON body posted by HTTPReporter, in the event format of the
+// Sentry store API.
+type Event struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Message     string            `json:"message"`
+	Exception   EventExceptions   `json:"exception"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+// EventExceptions lists the exceptions of an Event.
+type EventExceptions struct {
+	Values []EventException `json:"values"`
+}
+
+// EventException is the recovered panic of an Event.
+type EventException struct {
+	Type       string          `json:"type"`
+	Value      string          `json:"value"`
+	Stacktrace EventStacktrace `json:"stacktrace"`
+}
+
+// EventStacktrace is the stack of an EventException, the innermost frame
+// last.
+type EventStacktrace struct {
+	Frames []EventFrame `json:"frames"`
+}
+
+// EventFrame is a frame of an EventStacktrace.
+type EventFrame struct {
+	Function string `json:"function"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+}
+
+// HTTPReporter is a Reporter posting the panics as Events to an HTTP sink,
+// such as the store API of Sentry.
+type HTTPReporter struct {
+	url         string
+	header      http.Header
+	client      *http.Client
+	environment string
+	release     string
+}
+
+// HTTPReporterOption sets an optional parameter for HTTP reporters.
+type HTTPReporterOption func(*HTTPReporter)
+
+// HTTPReporterClient sets the client posting the events. By default, a
+// client with a timeout of DefaultReportTimeout is used.
+func HTTPReporterClient(client *http.Client) HTTPReporterOption {
+	return func(r *HTTPReporter) { r.client = client }
+}
+
+// HTTPReporterHeader sets a header of the posted events.
+func HTTPReporterHeader(key, value string) HTTPReporterOption {
+	return func(r *HTTPReporter) { r.header.Set(key, value) }
+}
+
+// HTTPReporterEnvironment sets the environment of the events.
+func HTTPReporterEnvironment(environment string) HTTPReporterOption {
+	return func(r *HTTPReporter) { r.environment = environment }
+}
+
+// HTTPReporterRelease sets the release of the events.
+func HTTPReporterRelease(release string) HTTPReporterOption {
+	return func(r *HTTPReporter) { r.release = release }
+}
+
+// NewHTTPReporter returns an HTTPReporter posting the events to url.
+func NewHTTPReporter(url string, options ...HTTPReporterOption) *HTTPReporter {
+	r := &HTTPReporter{
+		url:    url,
+		header: http.Header{"Content-Type": {"application/json"}},
+		client: &http.Client{Timeout: DefaultReportTimeout},
+	}
+	for _, option := range options {
+		option(r)
+	}
+	return r
+}
+
+// NewSentryReporter returns an HTTPReporter posting the events to the store
+// API of the Sentry project of dsn, of the form
+// https://<key>@<host>/<project>.
+func NewSentryReporter(dsn string, options ...HTTPReporterOption) (*HTTPReporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("recovery: invalid Sentry DSN: %w", err)
+	}
+	key := u.User.Username()
+	path := strings.Trim(u.Path, "/")
+	i := strings.LastIndexByte(path, '/')
+	project := path[i+1:]
+	if key == "" || project == "" {
+		return nil, fmt.Errorf("recovery: invalid Sentry DSN %q", dsn)
+	}
+	store := fmt.Sprintf("%s://%s/%sapi/%s/store/", u.Scheme, u.Host, path[:i+1], project)
+	auth := fmt.Sprintf("Sentry sentry_version=7, sentry_client=go/1.0, sentry_key=%s", key)
+	return NewHTTPReporter(store, append([]HTTPReporterOption{HTTPReporterHeader("X-Sentry-Auth", auth)}, options...)...), nil
+}
+
+// Report implements Reporter, posting the event of p. The failures are
+// logged.
+func (r *HTTPReporter) Report(ctx context.Context, p *Panic) {
+	b, err := json.Marshal(r.Event(p))
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("recovery: encoding the event")
+		return
+	}
+	// the report outlives the request, which may be canceled
+	req, err := http.NewRequest(http.MethodPost, r.url, bytes.NewReader(b))
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("recovery: creating the report")
+		return
+	}
+	req.Header = r.header.Clone()
+	resp, err := r.client.Do(req)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Error("recovery: posting the report")
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		log.WithContext(ctx).Errorf("recovery: report rejected with status %d", resp.StatusCode)
+	}
+}
+
+// Event returns the Event of p.
+func (r *HTTPReporter) Event(p *Panic) *Event {
+	hostname, _ := os.Hostname()
+	e := &Event{
+		EventID:     newEventID(),
+		Timestamp:   p.Time.UTC().Format(time.RFC3339Nano),
+		Level:       "fatal",
+		Platform:    "go",
+		ServerName:  hostname,
+		Environment: r.environment,
+		Release:     r.release,
+		Message:     p.Error(),
+	}
+	exception := EventException{Type: fmt.Sprintf("%T", p.Value), Value: fmt.Sprint(p.Value)}
+	for i := len(p.Frames) - 1; i >= 0; i-- {
+		f := p.Frames[i]
+		exception.Stacktrace.Frames = append(exception.Stacktrace.Frames, EventFrame{Function: f.Function, AbsPath: f.File, Lineno: f.Line})
+	}
+	e.Exception.Values = []EventException{exception}
+	if p.RequestID != "" {
+		e.Tags = map[string]string{log.FieldKeyRequestID: p.RequestID}
+	}
+	return e
+}
+
+// newEventID returns a random ID of 32 hexadecimal digits.
+func newEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
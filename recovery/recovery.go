@@ -0,0 +1,155 @@
+// Package recovery recovers the panics of the endpoints and of the
+// transports, converting them to errors.InternalServerError and reporting
+// them, with their stack, to pluggable reporters.
+//
+// The endpoints are protected with Middleware, and the transports with their
+// recovery options: grpc.ServerRecovery, amqp.SubscriberRecovery,
+// nats.SubscriberRecovery and awslambda.HandlerRecovery. The recovered
+// panics are returned through the error encoder of the transport.
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/ThomasNguyenGitHub/go/correlation"
+	"github.com/ThomasNguyenGitHub/go/endpoint"
+	"github.com/ThomasNguyenGitHub/go/errors"
+	"github.com/ThomasNguyenGitHub/go/log"
+)
+
+// Panic is a recovered panic.
+type Panic struct {
+	// Value is the value passed to panic
+	Value interface{}
+	// Stack is the formatted stack of the goroutine which panicked
+	Stack []byte
+	// Frames are the frames of the stack, the innermost first
+	Frames []runtime.Frame
+	// Time is the time of the recovery
+	Time time.Time
+	// RequestID is the correlation ID of the request which panicked
+	RequestID string
+}
+
+// Error returns the message of the panic.
+func (p *Panic) Error() string {
+	return fmt.Sprintf("panic: %v", p.Value)
+}
+
+// Reporter reports the recovered panics.
+type Reporter interface {
+	Report(ctx context.Context, p *Panic)
+}
+
+// ReporterFunc is a function implementing Reporter.
+type ReporterFunc func(ctx context.Context, p *Panic)
+
+// Report implements Reporter.
+func (f ReporterFunc) Report(ctx context.Context, p *Panic) {
+	f(ctx, p)
+}
+
+// LogReporter logs the panics at error level, with their stack.
+var LogReporter Reporter = ReporterFunc(func(ctx context.Context, p *Panic) {
+	log.WithContext(ctx).WithField("stack", string(p.Stack)).Error(p.Error())
+})
+
+// DefaultReporters are the reporters of the Recoverers created without
+// reporter, LogReporter by default. They are meant to be set at
+// initialization.
+var DefaultReporters = []Reporter{LogReporter}
+
+// Recoverer reports the recovered panics and converts them to errors.
+type Recoverer struct {
+	reporters []Reporter
+}
+
+// New returns a Recoverer reporting the panics to reporters, or to
+// DefaultReporters when none is given.
+func New(reporters ...Reporter) *Recoverer {
+	return &Recoverer{reporters: reporters}
+}
+
+// Recover reports v, the value returned by recover in a deferred function,
+// and returns an errors.InternalServerError. The details of the panic are
+// not in the error, which may be sent to clients.
+func (r *Recoverer) Recover(ctx context.Context, v interface{}) error {
+	p := &Panic{
+		Value:     v,
+		Stack:     debug.Stack(),
+		Frames:    callers(),
+		Time:      time.Now(),
+		RequestID: correlation.IDFromContext(ctx),
+	}
+	reporters := r.reporters
+	if len(reporters) == 0 {
+		reporters = DefaultReporters
+	}
+	for _, reporter := range reporters {
+		report(ctx, reporter, p)
+	}
+	return errors.InternalServerError(http.StatusText(http.StatusInternalServerError))
+}
+
+// Middleware returns an endpoint.Middleware returning the panics of the
+// endpoints as errors.
+func (r *Recoverer) Middleware() endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					response, err = nil, r.Recover(ctx, v)
+				}
+			}()
+			return next(ctx, request)
+		}
+	}
+}
+
+// Middleware returns an endpoint.Middleware returning the panics of the
+// endpoints as errors, after reporting them to reporters.
+func Middleware(reporters ...Reporter) endpoint.Middleware {
+	return New(reporters...).Middleware()
+}
+
+// report reports p with reporter, a failing reporter not preventing the
+// others from reporting.
+func report(ctx context.Context, reporter Reporter, p *Panic) {
+	defer func() {
+		if v := recover(); v != nil {
+			log.WithContext(ctx).Errorf("recovery: reporter panicked: %v", v)
+		}
+	}()
+	reporter.Report(ctx, p)
+}
+
+// callers returns the frames of the panicking goroutine, from the function
+// which panicked, skipping the runtime and this package.
+func callers() []runtime.Frame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(1, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var stack []runtime.Frame
+	panicked := false
+	for {
+		frame, more := frames.Next()
+		switch {
+		case frame.Function == "runtime.gopanic":
+			panicked = true
+		case panicked && (len(stack) > 0 || !strings.HasPrefix(frame.Function, "runtime.")):
+			// the runtime frames raising the panic are skipped
+			stack = append(stack, frame)
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
@@ -0,0 +1,89 @@
+package recovery
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ThomasNguyenGitHub/go/correlation"
+	"github.com/ThomasNguyenGitHub/go/errors"
+)
+
+func panicking(context.Context, interface{}) (interface{}, error) {
+	var m map[string]int
+	m["boom"]++
+	return nil, nil
+}
+
+func TestMiddleware(t *testing.T) {
+	var reported *Panic
+	e := Middleware(ReporterFunc(func(_ context.Context, p *Panic) { reported = p }))(panicking)
+
+	ctx := correlation.ContextWithID(context.Background(), "req-1")
+	response, err := e(ctx, nil)
+	var e500 *errors.Error
+	if response != nil || !stderrors.As(err, &e500) || e500.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected an internal server error but got %v %v", response, err)
+	}
+	if strings.Contains(err.Error(), "nil map") {
+		t.Errorf("Expected the panic not to be exposed but got %v", err)
+	}
+
+	if reported == nil || !strings.Contains(reported.Error(), "assignment to entry in nil map") || reported.RequestID != "req-1" {
+		t.Fatalf("Unexpected report %+v", reported)
+	}
+	if len(reported.Frames) == 0 || !strings.HasSuffix(reported.Frames[0].Function, "recovery.panicking") {
+		t.Errorf("Expected the first frame to be the panicking function but got %+v", reported.Frames)
+	}
+	if !strings.Contains(string(reported.Stack), "panicking") {
+		t.Errorf("Expected the stack but got %s", reported.Stack)
+	}
+}
+
+func TestReporterPanic(t *testing.T) {
+	var reported bool
+	e := Middleware(
+		ReporterFunc(func(context.Context, *Panic) { panic("reporter") }),
+		ReporterFunc(func(context.Context, *Panic) { reported = true }),
+	)(panicking)
+	if _, err := e(context.Background(), nil); err == nil || !reported {
+		t.Errorf("Expected the other reporters to report but got %v %v", err, reported)
+	}
+}
+
+func TestSentryReporter(t *testing.T) {
+	var (
+		path, auth string
+		event      Event
+	)
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("X-Sentry-Auth")
+		json.NewDecoder(r.Body).Decode(&event)
+	}))
+	defer sink.Close()
+
+	reporter, err := NewSentryReporter(strings.Replace(sink.URL, "://", "://public@", 1)+"/sentry/42", HTTPReporterEnvironment("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	Middleware(reporter)(panicking)(correlation.ContextWithID(context.Background(), "req-1"), nil)
+
+	if path != "/sentry/api/42/store/" || !strings.Contains(auth, "sentry_key=public") {
+		t.Errorf("Unexpected request %s %q", path, auth)
+	}
+	if len(event.EventID) != 32 || event.Environment != "test" || event.Tags["request_id"] != "req-1" || len(event.Exception.Values) != 1 {
+		t.Fatalf("Unexpected event %+v", event)
+	}
+	frames := event.Exception.Values[0].Stacktrace.Frames
+	if len(frames) == 0 || !strings.HasSuffix(frames[len(frames)-1].Function, "recovery.panicking") {
+		t.Errorf("Expected the innermost frame last but got %+v", frames)
+	}
+
+	if _, err := NewSentryReporter("https://sentry.io/42"); err == nil {
+		t.Error("Expected an error for a DSN without key")
+	}
+}
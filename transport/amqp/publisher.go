@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/ThomasNguyenGitHub/go/correlation"
 	"github.com/ThomasNguyenGitHub/go/endpoint"
 	"github.com/streadway/amqp"
 )
@@ -92,6 +93,14 @@ func (p Publisher) Endpoint() endpoint.Endpoint {
 			// Affect only amqp.Publishing
 			ctx = f(ctx, &pub, nil)
 		}
+		if id := correlation.IDFromContext(ctx); id != "" {
+			if pub.Headers == nil {
+				pub.Headers = amqp.Table{}
+			}
+			if _, ok := pub.Headers[correlation.MetadataKeyRequestID]; !ok {
+				pub.Headers[correlation.MetadataKeyRequestID] = id
+			}
+		}
 
 		deliv, err := p.deliverer(ctx, p, &pub)
 		if err != nil {
@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/ThomasNguyenGitHub/go/correlation"
 	"github.com/ThomasNguyenGitHub/go/endpoint"
 	"github.com/ThomasNguyenGitHub/go/log"
+	"github.com/ThomasNguyenGitHub/go/recovery"
 	"github.com/ThomasNguyenGitHub/go/transport"
 	"github.com/streadway/amqp"
 )
@@ -21,6 +23,7 @@ type Subscriber struct {
 	responsePublisher ResponsePublisher
 	errorEncoder      ErrorEncoder
 	errorHandler      transport.ErrorHandler
+	recoverer         *recovery.Recoverer
 }
 
 // NewSubscriber constructs a new subscriber, which provides a handler
@@ -80,7 +83,7 @@ func SubscriberErrorEncoder(ee ErrorEncoder) SubscriberOption {
 // of error handling, including logging in more detail, should be performed in a
 // custom SubscriberErrorEncoder which has access to the context.
 // Deprecated: Use SubscriberErrorHandler instead.
-func SubscriberErrorLogger(logger log.Logger) SubscriberOption {
+func SubscriberErrorLogger(logger *log.Logger) SubscriberOption {
 	return func(s *Subscriber) { s.errorHandler = transport.NewLogErrorHandler(logger) }
 }
 
@@ -92,6 +95,14 @@ func SubscriberErrorHandler(errorHandler transport.ErrorHandler) SubscriberOptio
 	return func(s *Subscriber) { s.errorHandler = errorHandler }
 }
 
+// SubscriberRecovery recovers the panics of the subscriber, encoding them
+// with the error encoder as an errors.InternalServerError after reporting
+// them to reporters, or to recovery.DefaultReporters when none is given. By
+// default, the panics are not recovered.
+func SubscriberRecovery(reporters ...recovery.Reporter) SubscriberOption {
+	return func(s *Subscriber) { s.recoverer = recovery.New(reporters...) }
+}
+
 // ServeDelivery handles AMQP Delivery messages
 // It is strongly recommended to use *amqp.Channel as the
 // Channel interface implementation.
@@ -100,7 +111,20 @@ func (s Subscriber) ServeDelivery(ch Channel) func(deliv *amqp.Delivery) {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
-		pub := amqp.Publishing{}
+		// the response echoes the request ID
+		requestID, _ := deliv.Headers[correlation.MetadataKeyRequestID].(string)
+		ctx, requestID = correlation.Ensure(ctx, requestID)
+		pub := amqp.Publishing{Headers: amqp.Table{correlation.MetadataKeyRequestID: requestID}}
+
+		if s.recoverer != nil {
+			defer func() {
+				if v := recover(); v != nil {
+					err := s.recoverer.Recover(ctx, v)
+					s.errorHandler.Handle(ctx, err)
+					s.errorEncoder(ctx, err, deliv, ch, &pub)
+				}
+			}()
+		}
 
 		for _, f := range s.before {
 			ctx = f(ctx, &pub, deliv)
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ThomasNguyenGitHub/go/recovery"
 	amqptransport "github.com/ThomasNguyenGitHub/go/transport/amqp"
 	"github.com/streadway/amqp"
 )
@@ -432,3 +433,33 @@ var names = map[int]string{
 	436: "tusker",
 	437: "husky",
 }
+
+// TestSubscriberRecovery checks if the panics of the endpoint are replied as
+// errors.
+func TestSubscriberRecovery(t *testing.T) {
+	var reported *recovery.Panic
+	sub := amqptransport.NewSubscriber(
+		func(context.Context, interface{}) (interface{}, error) { panic("boom") },
+		func(context.Context, *amqp.Delivery) (interface{}, error) { return struct{}{}, nil },
+		func(context.Context, *amqp.Publishing, interface{}) error { return nil },
+		amqptransport.SubscriberErrorEncoder(amqptransport.ReplyErrorEncoder),
+		amqptransport.SubscriberRecovery(recovery.ReporterFunc(func(_ context.Context, p *recovery.Panic) { reported = p })),
+	)
+
+	outputChan := make(chan amqp.Publishing, 1)
+	ch := &mockChannel{f: nullFunc, c: outputChan}
+	sub.ServeDelivery(ch)(&amqp.Delivery{})
+
+	select {
+	case msg := <-outputChan:
+		var res amqptransport.DefaultErrorResponse
+		if err := json.Unmarshal(msg.Body, &res); err != nil || res.Error == "" {
+			t.Errorf("Expected the error to be replied but got %s", msg.Body)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Timed out waiting for the reply")
+	}
+	if reported == nil || reported.Value != "boom" {
+		t.Errorf("Unexpected report %+v", reported)
+	}
+}
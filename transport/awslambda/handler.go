@@ -5,6 +5,7 @@ import (
 
 	"github.com/ThomasNguyenGitHub/go/endpoint"
 	"github.com/ThomasNguyenGitHub/go/log"
+	"github.com/ThomasNguyenGitHub/go/recovery"
 	"github.com/ThomasNguyenGitHub/go/transport"
 )
 
@@ -18,6 +19,7 @@ type Handler struct {
 	errorEncoder ErrorEncoder
 	finalizer    []HandlerFinalizerFunc
 	errorHandler transport.ErrorHandler
+	recoverer    *recovery.Recoverer
 }
 
 // NewHandler constructs a new handler, which implements
@@ -59,7 +61,7 @@ func HandlerAfter(after ...HandlerResponseFunc) HandlerOption {
 // HandlerErrorLogger is used to log non-terminal errors.
 // By default, no errors are logged.
 // Deprecated: Use HandlerErrorHandler instead.
-func HandlerErrorLogger(logger log.Logger) HandlerOption {
+func HandlerErrorLogger(logger *log.Logger) HandlerOption {
 	return func(h *Handler) { h.errorHandler = transport.NewLogErrorHandler(logger) }
 }
 
@@ -80,6 +82,14 @@ func HandlerFinalizer(f ...HandlerFinalizerFunc) HandlerOption {
 	return func(h *Handler) { h.finalizer = append(h.finalizer, f...) }
 }
 
+// HandlerRecovery recovers the panics of the handler, encoding them with the
+// error encoder as an errors.InternalServerError after reporting them to
+// reporters, or to recovery.DefaultReporters when none is given. By default,
+// the panics are not recovered.
+func HandlerRecovery(reporters ...recovery.Reporter) HandlerOption {
+	return func(h *Handler) { h.recoverer = recovery.New(reporters...) }
+}
+
 // DefaultErrorEncoder defines the default behavior of encoding an error response,
 // where it returns nil, and the error itself.
 func DefaultErrorEncoder(ctx context.Context, err error) ([]byte, error) {
@@ -99,6 +109,16 @@ func (h *Handler) Invoke(
 		}()
 	}
 
+	if h.recoverer != nil {
+		defer func() {
+			if v := recover(); v != nil {
+				err = h.recoverer.Recover(ctx, v)
+				h.errorHandler.Handle(ctx, err)
+				resp, err = h.errorEncoder(ctx, err)
+			}
+		}()
+	}
+
 	for _, f := range h.before {
 		ctx = f(ctx, payload)
 	}
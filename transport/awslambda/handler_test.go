@@ -8,6 +8,7 @@ import (
 
 	"github.com/ThomasNguyenGitHub/go/endpoint"
 	"github.com/ThomasNguyenGitHub/go/log"
+	"github.com/ThomasNguyenGitHub/go/recovery"
 	"github.com/ThomasNguyenGitHub/go/transport"
 	"github.com/aws/aws-lambda-go/events"
 )
@@ -211,6 +212,28 @@ func TestInvokeFailEndpoint(t *testing.T) {
 	}
 }
 
+func TestInvokeRecovery(t *testing.T) {
+	var reported *recovery.Panic
+	helloHandler := NewHandler(
+		func(context.Context, interface{}) (interface{}, error) { panic("boom") },
+		func(context.Context, []byte) (interface{}, error) { return struct{}{}, nil },
+		encodeResponse,
+		HandlerRecovery(recovery.ReporterFunc(func(_ context.Context, p *recovery.Panic) { reported = p })),
+	)
+
+	req, _ := json.Marshal(events.APIGatewayProxyRequest{
+		Body: `{"name":"john doe"}`,
+	})
+	resp, err := helloHandler.Invoke(context.Background(), req)
+
+	if resp != nil || err == nil {
+		t.Fatalf("Expected the panic to be returned as an error, but got: %s %v", resp, err)
+	}
+	if reported == nil || reported.Value != "boom" {
+		t.Fatalf("Unexpected report %+v", reported)
+	}
+}
+
 func TestInvokeFailEncode(t *testing.T) {
 	svc := serviceTest01{}
 
@@ -14,17 +14,18 @@ type ErrorHandler interface {
 
 // LogErrorHandler is a transport error handler implementation which logs an error.
 type LogErrorHandler struct {
-	logger log.Logger
+	logger *log.Logger
 }
 
-func NewLogErrorHandler(logger log.Logger) *LogErrorHandler {
+func NewLogErrorHandler(logger *log.Logger) *LogErrorHandler {
 	return &LogErrorHandler{
 		logger: logger,
 	}
 }
 
+// Handle logs err at error level, with the fields of ctx.
 func (h *LogErrorHandler) Handle(ctx context.Context, err error) {
-	h.logger.Log(1, err)
+	h.logger.WithContext(ctx).WithError(err).Error("transport error")
 }
 
 // The ErrorHandlerFunc type is an adapter to allow the use of
@@ -1,8 +1,10 @@
 package transport_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/ThomasNguyenGitHub/go/log"
@@ -10,12 +12,12 @@ import (
 )
 
 func TestLogErrorHandler(t *testing.T) {
-	var output []interface{}
+	var output bytes.Buffer
 
-	logger := log.Logger(log.LoggerFunc(func(keyvals ...interface{}) error {
-		output = append(output, keyvals...)
-		return nil
-	}))
+	logger := log.New()
+	logger.Out = &output
+	logger.Formatter = &log.TextFormatter{DisableTimestamp: true, DisableColors: true}
+	logger.Level = log.ErrorLevel
 
 	errorHandler := transport.NewLogErrorHandler(logger)
 
@@ -23,7 +25,7 @@ func TestLogErrorHandler(t *testing.T) {
 
 	errorHandler.Handle(context.Background(), err)
 
-	if output[1] != err {
-		t.Errorf("expected an error log event: have %v, want %v", output[1], err)
+	if !strings.Contains(output.String(), "level=error") || !strings.Contains(output.String(), "error=error") {
+		t.Errorf("expected an error log event: have %q, want %v", output.String(), err)
 	}
 }
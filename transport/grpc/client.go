@@ -8,6 +8,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 
+	"github.com/ThomasNguyenGitHub/go/correlation"
 	"github.com/ThomasNguyenGitHub/go/endpoint"
 )
 
@@ -108,6 +109,9 @@ func (c Client) Endpoint() endpoint.Endpoint {
 		for _, f := range c.before {
 			ctx = f(ctx, md)
 		}
+		if id := correlation.IDFromContext(ctx); id != "" && len(md.Get(correlation.MetadataKeyRequestID)) == 0 {
+			md.Set(correlation.MetadataKeyRequestID, id)
+		}
 		ctx = metadata.NewOutgoingContext(ctx, *md)
 
 		var header, trailer metadata.MD
@@ -6,9 +6,12 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 
+	"github.com/ThomasNguyenGitHub/go/correlation"
 	"github.com/ThomasNguyenGitHub/go/endpoint"
 	"github.com/ThomasNguyenGitHub/go/log"
+	"github.com/ThomasNguyenGitHub/go/recovery"
 	"github.com/ThomasNguyenGitHub/go/transport"
+	"github.com/ThomasNguyenGitHub/go/validation"
 )
 
 // Handler which should be called from the gRPC binding of the service
@@ -27,6 +30,8 @@ type Server struct {
 	after        []ServerResponseFunc
 	finalizer    []ServerFinalizerFunc
 	errorHandler transport.ErrorHandler
+	validator    ValidateRequestFunc
+	recoverer    *recovery.Recoverer
 }
 
 // NewServer constructs a new server, which implements wraps the provided
@@ -45,6 +50,7 @@ func NewServer(
 		dec:          dec,
 		enc:          enc,
 		errorHandler: transport.NewLogErrorHandler(log.NewNopLogger()),
+		validator:    ValidateRequest,
 	}
 	for _, option := range options {
 		option(s)
@@ -70,7 +76,7 @@ func ServerAfter(after ...ServerResponseFunc) ServerOption {
 // ServerErrorLogger is used to log non-terminal errors. By default, no errors
 // are logged.
 // Deprecated: Use ServerErrorHandler instead.
-func ServerErrorLogger(logger log.Logger) ServerOption {
+func ServerErrorLogger(logger *log.Logger) ServerOption {
 	return func(s *Server) { s.errorHandler = transport.NewLogErrorHandler(logger) }
 }
 
@@ -86,6 +92,20 @@ func ServerFinalizer(f ...ServerFinalizerFunc) ServerOption {
 	return func(s *Server) { s.finalizer = append(s.finalizer, f...) }
 }
 
+// ServerValidator sets the function validating the decoded requests. By
+// default, ValidateRequest is used. A nil validator disables validation.
+func ServerValidator(v ValidateRequestFunc) ServerOption {
+	return func(s *Server) { s.validator = v }
+}
+
+// ServerRecovery recovers the panics of the server, returning them as an
+// errors.InternalServerError after reporting them to reporters, or to
+// recovery.DefaultReporters when none is given. By default, the panics are
+// not recovered.
+func ServerRecovery(reporters ...recovery.Reporter) ServerOption {
+	return func(s *Server) { s.recoverer = recovery.New(reporters...) }
+}
+
 // ServeGRPC implements the Handler interface.
 func (s Server) ServeGRPC(ctx context.Context, req interface{}) (retctx context.Context, resp interface{}, err error) {
 	// Retrieve gRPC metadata.
@@ -94,6 +114,14 @@ func (s Server) ServeGRPC(ctx context.Context, req interface{}) (retctx context.
 		md = metadata.MD{}
 	}
 
+	var requestID string
+	if ids := md.Get(correlation.MetadataKeyRequestID); len(ids) > 0 {
+		requestID = ids[0]
+	}
+	ctx, requestID = correlation.Ensure(ctx, requestID)
+	// fails only when ServeGRPC is called outside of a gRPC server
+	_ = grpc.SetHeader(ctx, metadata.Pairs(correlation.MetadataKeyRequestID, requestID))
+
 	if len(s.finalizer) > 0 {
 		defer func() {
 			for _, f := range s.finalizer {
@@ -102,6 +130,16 @@ func (s Server) ServeGRPC(ctx context.Context, req interface{}) (retctx context.
 		}()
 	}
 
+	if s.recoverer != nil {
+		defer func() {
+			if v := recover(); v != nil {
+				err = s.recoverer.Recover(ctx, v)
+				s.errorHandler.Handle(ctx, err)
+				retctx, resp = ctx, nil
+			}
+		}()
+	}
+
 	for _, f := range s.before {
 		ctx = f(ctx, md)
 	}
@@ -118,6 +156,13 @@ func (s Server) ServeGRPC(ctx context.Context, req interface{}) (retctx context.
 		return ctx, nil, err
 	}
 
+	if s.validator != nil {
+		if err = s.validator(ctx, request); err != nil {
+			s.errorHandler.Handle(ctx, err)
+			return ctx, nil, err
+		}
+	}
+
 	response, err = s.e(ctx, request)
 	if err != nil {
 		s.errorHandler.Handle(ctx, err)
@@ -156,6 +201,27 @@ func (s Server) ServeGRPC(ctx context.Context, req interface{}) (retctx context.
 // request, after the response has been written to the client.
 type ServerFinalizerFunc func(ctx context.Context, err error)
 
+// ValidateRequestFunc validates a decoded request.
+type ValidateRequestFunc func(ctx context.Context, request interface{}) error
+
+// ValidateRequest is a ValidateRequestFunc checking the rules of the
+// validate struct tags of the request with the validation package. The
+// messages of the *validation.Error, returned with the InvalidArgument
+// code, are in the language of the language metadata.
+func ValidateRequest(ctx context.Context, request interface{}) error {
+	err := validation.Validate(request)
+	if verr, ok := err.(*validation.Error); ok {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if language := md.Get(MetadataKeyLanguage); len(language) > 0 && language[0] != "" {
+			return verr.Localize(language[0])
+		}
+	}
+	return err
+}
+
+// MetadataKeyLanguage is the metadata holding the language of the requests.
+const MetadataKeyLanguage = "language"
+
 // Interceptor is a grpc UnaryInterceptor that injects the method name into
 // context so it can be consumed by Go kit gRPC middlewares. The Interceptor
 // typically is added at creation time of the grpc-go server.
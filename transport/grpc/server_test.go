@@ -0,0 +1,31 @@
+package grpc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	goerrors "github.com/ThomasNguyenGitHub/go/errors"
+	"github.com/ThomasNguyenGitHub/go/recovery"
+	grpctransport "github.com/ThomasNguyenGitHub/go/transport/grpc"
+)
+
+func TestServerRecovery(t *testing.T) {
+	var reported *recovery.Panic
+	server := grpctransport.NewServer(
+		func(context.Context, interface{}) (interface{}, error) { panic("boom") },
+		func(context.Context, interface{}) (interface{}, error) { return struct{}{}, nil },
+		func(context.Context, interface{}) (interface{}, error) { return struct{}{}, nil },
+		grpctransport.ServerRecovery(recovery.ReporterFunc(func(_ context.Context, p *recovery.Panic) { reported = p })),
+	)
+
+	_, resp, err := server.ServeGRPC(context.Background(), struct{}{})
+	var e *goerrors.Error
+	if resp != nil || !errors.As(err, &e) || e.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected an internal server error but got %v %v", resp, err)
+	}
+	if reported == nil || reported.Value != "boom" {
+		t.Errorf("Unexpected report %+v", reported)
+	}
+}
@@ -0,0 +1,299 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ThomasNguyenGitHub/go/correlation"
+	"github.com/ThomasNguyenGitHub/go/log"
+	"github.com/ThomasNguyenGitHub/go/util"
+)
+
+// Fields of the access log entries, along with log.FieldKeyRequestID and
+// log.FieldKeyUserID
+const (
+	AccessLogFieldMethod        = "http_method"
+	AccessLogFieldRoute         = "http_route"
+	AccessLogFieldStatus        = "http_status"
+	AccessLogFieldLatency       = "latency_ms"
+	AccessLogFieldResponseSize  = "response_size"
+	AccessLogFieldRequestBody   = "request_body"
+	AccessLogFieldResponseBody  = "response_body"
+	AccessLogFieldBodyTruncated = "body_truncated"
+	AccessLogFieldSlow          = "slow"
+)
+
+// AccessLogMessage is the message of the access log entries.
+const AccessLogMessage = "access"
+
+// UnparsableBody is logged in place of the bodies that cannot be masked:
+// the truncated bodies and, by default, the bodies that are not JSON
+// objects.
+const UnparsableBody = "[unparsable body]"
+
+type accessLogContextKey struct{}
+
+// RouteLogConfig overrides the sampling and the slow threshold of a route.
+type RouteLogConfig struct {
+	// SampleRate is the ratio of the requests logged, from 0 to 1. The
+	// server errors and the slow requests are always logged.
+	SampleRate float64
+	// SlowThreshold is the latency from which the requests are logged as
+	// slow, at warning level. Zero disables it.
+	SlowThreshold time.Duration
+}
+
+// AccessLog logs the requests served, with their method, route template,
+// status code, latency, response size, request ID and user ID, and their
+// masked bodies when enabled.
+//
+// The requests are logged at info level, the slow ones at warning level and
+// the server errors at error level.
+type AccessLog struct {
+	logger      *log.Logger
+	maxBodySize int
+	maskBody    func(body []byte) interface{}
+	route       func(r *http.Request) string
+	config      RouteLogConfig
+	routes      map[string]RouteLogConfig
+}
+
+// AccessLogOption sets an optional parameter for access logs.
+type AccessLogOption func(*AccessLog)
+
+// AccessLogLogger sets the logger of the entries, log.StandardLogger() by
+// default.
+func AccessLogLogger(logger *log.Logger) AccessLogOption {
+	return func(a *AccessLog) { a.logger = logger }
+}
+
+// AccessLogBodies logs the request and response bodies of up to max bytes,
+// the longer bodies being logged as UnparsableBody. The bodies are not
+// logged by default.
+func AccessLogBodies(max int) AccessLogOption {
+	return func(a *AccessLog) { a.maxBodySize = max }
+}
+
+// AccessLogMaskBody sets the function masking the logged bodies, which are
+// complete. By default, the fields of the JSON objects masked by util are
+// masked, and the other bodies are logged as UnparsableBody.
+func AccessLogMaskBody(mask func(body []byte) interface{}) AccessLogOption {
+	return func(a *AccessLog) { a.maskBody = mask }
+}
+
+// AccessLogRouteFunc sets the function returning the route of the requests,
+// by default their gorilla/mux path template, or their path when they are
+// not routed by gorilla/mux.
+func AccessLogRouteFunc(route func(r *http.Request) string) AccessLogOption {
+	return func(a *AccessLog) { a.route = route }
+}
+
+// AccessLogSampling sets the ratio of the requests logged, 1 by default.
+func AccessLogSampling(rate float64) AccessLogOption {
+	return func(a *AccessLog) { a.config.SampleRate = rate }
+}
+
+// AccessLogSlowThreshold sets the latency from which the requests are logged
+// as slow. The slow requests are not distinguished by default.
+func AccessLogSlowThreshold(d time.Duration) AccessLogOption {
+	return func(a *AccessLog) { a.config.SlowThreshold = d }
+}
+
+// AccessLogRoute overrides the sampling and the slow threshold of a route,
+// as returned by the route function.
+func AccessLogRoute(route string, config RouteLogConfig) AccessLogOption {
+	return func(a *AccessLog) { a.routes[route] = config }
+}
+
+// NewAccessLog returns an AccessLog logging all the requests, without their
+// bodies.
+func NewAccessLog(options ...AccessLogOption) *AccessLog {
+	a := &AccessLog{
+		logger:   log.StandardLogger(),
+		maskBody: MaskBody,
+		route:    RouteTemplate,
+		config:   RouteLogConfig{SampleRate: 1},
+		routes:   map[string]RouteLogConfig{},
+	}
+	for _, option := range options {
+		option(a)
+	}
+	return a
+}
+
+// accessRecord holds what the access log captures during a request.
+type accessRecord struct {
+	start    time.Time
+	request  *cappedBuffer
+	response *cappedBuffer
+}
+
+// ServerOptions returns the options logging the requests of a Server: a
+// ServerBefore capturing the start time and the request body, and a
+// ServerFinalizer logging the request.
+func (a *AccessLog) ServerOptions() []ServerOption {
+	return []ServerOption{ServerBefore(a.ServerBefore), ServerFinalizer(a.ServerFinalizer)}
+}
+
+// ServerBefore is a RequestFunc capturing the start time and the body of the
+// request, and, with ServerFinalizer, the body of the response. Without it,
+// ServerFinalizer logs no latency and no body.
+func (a *AccessLog) ServerBefore(ctx context.Context, r *http.Request) context.Context {
+	record := &accessRecord{start: time.Now()}
+	if a.maxBodySize > 0 {
+		record.request = &cappedBuffer{max: a.maxBodySize}
+		record.response = &cappedBuffer{max: a.maxBodySize}
+		if r.Body != nil {
+			r.Body = &teeReadCloser{Reader: io.TeeReader(r.Body, record.request), Closer: r.Body}
+		}
+	}
+	return context.WithValue(ctx, accessLogContextKey{}, record)
+}
+
+// ServerFinalizer is a ServerFinalizerFunc logging the request.
+func (a *AccessLog) ServerFinalizer(ctx context.Context, code int, r *http.Request) {
+	size, _ := ctx.Value(ContextKeyResponseSize).(int64)
+	record, _ := ctx.Value(accessLogContextKey{}).(*accessRecord)
+	if record == nil {
+		record = &accessRecord{}
+	}
+	a.log(ctx, r, code, size, record)
+}
+
+// Middleware returns an http.Handler logging the requests served by next.
+func (a *AccessLog) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := a.ServerBefore(r.Context(), r)
+		record := ctx.Value(accessLogContextKey{}).(*accessRecord)
+		iw := &interceptingWriter{ResponseWriter: w, code: http.StatusOK, body: record.response}
+		defer func() {
+			a.log(ctx, r, iw.code, iw.written, record)
+		}()
+		next.ServeHTTP(iw, r)
+	})
+}
+
+func (a *AccessLog) log(ctx context.Context, r *http.Request, code int, size int64, record *accessRecord) {
+	route := a.route(r)
+	config, ok := a.routes[route]
+	if !ok {
+		config = a.config
+	}
+
+	var latency time.Duration
+	if !record.start.IsZero() {
+		latency = time.Since(record.start)
+	}
+	slow := config.SlowThreshold > 0 && latency >= config.SlowThreshold
+	level := log.InfoLevel
+	switch {
+	case code >= http.StatusInternalServerError:
+		level = log.ErrorLevel
+	case slow:
+		level = log.WarnLevel
+	case config.SampleRate < 1 && rand.Float64() >= config.SampleRate:
+		return
+	}
+	if !a.logger.IsLevelEnabled(level) {
+		return
+	}
+
+	fields := log.Fields{
+		AccessLogFieldMethod:       r.Method,
+		AccessLogFieldRoute:        route,
+		AccessLogFieldStatus:       code,
+		AccessLogFieldLatency:      float64(latency) / float64(time.Millisecond),
+		AccessLogFieldResponseSize: size,
+	}
+	if id := correlation.IDFromContext(ctx); id != "" {
+		fields[log.FieldKeyRequestID] = id
+	} else if id := r.Header.Get(correlation.HeaderRequestID); id != "" {
+		fields[log.FieldKeyRequestID] = id
+	}
+	if id := GetString(ctx, ContextKeyUserID); id != "" {
+		fields[log.FieldKeyUserID] = id
+	} else if id := r.Header.Get(HeaderUserID); id != "" {
+		fields[log.FieldKeyUserID] = id
+	}
+	if slow {
+		fields[AccessLogFieldSlow] = true
+	}
+	if body := a.body(record.request); body != nil {
+		fields[AccessLogFieldRequestBody] = body
+	}
+	if body := a.body(record.response); body != nil {
+		fields[AccessLogFieldResponseBody] = body
+	}
+	if record.request.truncated() || record.response.truncated() {
+		fields[AccessLogFieldBodyTruncated] = true
+	}
+	a.logger.WithContext(ctx).WithFields(fields).Log(level, AccessLogMessage)
+}
+
+// body returns the masked body of b, or nil when there is none. The
+// truncated bodies are not masked, a partial JSON object being unparsable.
+func (a *AccessLog) body(b *cappedBuffer) interface{} {
+	switch {
+	case b == nil || b.Len() == 0:
+		return nil
+	case b.truncated():
+		return UnparsableBody
+	}
+	return a.maskBody(b.Bytes())
+}
+
+// RouteTemplate returns the gorilla/mux path template of the route of r, or
+// the path of r when it is not routed by gorilla/mux.
+func RouteTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return r.URL.Path
+}
+
+// MaskBody returns the JSON object body with the fields masked by util, or
+// UnparsableBody when it is not a complete JSON object, its secrets could
+// not be masked.
+func MaskBody(body []byte) interface{} {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '{' && json.Valid(trimmed) {
+		return util.MaskFieldsFromBytes(trimmed)
+	}
+	return UnparsableBody
+}
+
+// cappedBuffer keeps the first max bytes written to it.
+type cappedBuffer struct {
+	bytes.Buffer
+	max     int
+	dropped bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); len(p) > room {
+		b.dropped = true
+		b.Buffer.Write(p[:room])
+	} else {
+		b.Buffer.Write(p)
+	}
+	return len(p), nil
+}
+
+func (b *cappedBuffer) truncated() bool {
+	return b != nil && b.dropped
+}
+
+// teeReadCloser reads through its Reader and closes its Closer.
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
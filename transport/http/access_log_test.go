@@ -0,0 +1,156 @@
+package http_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ThomasNguyenGitHub/go/log"
+	gotransport "github.com/ThomasNguyenGitHub/go/transport/http"
+)
+
+func newAccessLogger() (*log.Logger, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	logger := log.New()
+	logger.Out = buf
+	logger.Formatter = &log.JSONFormatter{DisableTimestamp: true}
+	logger.Level = log.InfoLevel
+	return logger, buf
+}
+
+func accessEntries(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var entries []map[string]interface{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		entry := map[string]interface{}{}
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatalf("Unexpected output %q: %v", buf, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestAccessLogServer(t *testing.T) {
+	logger, buf := newAccessLogger()
+	accessLog := gotransport.NewAccessLog(gotransport.AccessLogLogger(logger), gotransport.AccessLogBodies(64))
+
+	server := gotransport.NewServer(
+		func(_ context.Context, request interface{}) (interface{}, error) { return request, nil },
+		func(_ context.Context, r *http.Request) (interface{}, error) {
+			var v map[string]interface{}
+			return v, json.NewDecoder(r.Body).Decode(&v)
+		},
+		gotransport.EncodeJSONResponse,
+		append(accessLog.ServerOptions(), gotransport.ServerBefore(gotransport.PopulateRequestContext))...,
+	)
+	router := mux.NewRouter()
+	router.Handle("/accounts/{id}", server)
+
+	req := httptest.NewRequest(http.MethodPost, "/accounts/123", strings.NewReader(`{"name":"An","password":"secret"}`))
+	req.Header.Set("X-Request-Id", "req-1")
+	req.Header.Set(gotransport.HeaderUserID, "user-1")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	entries := accessEntries(t, buf)
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry but got %v", entries)
+	}
+	entry := entries[0]
+	for k, v := range map[string]interface{}{
+		"msg":                                   gotransport.AccessLogMessage,
+		"level":                                 "info",
+		gotransport.AccessLogFieldMethod:        http.MethodPost,
+		gotransport.AccessLogFieldRoute:         "/accounts/{id}",
+		gotransport.AccessLogFieldStatus:        float64(200),
+		gotransport.AccessLogFieldResponseSize:  float64(rec.Body.Len()),
+		log.FieldKeyRequestID:                   "req-1",
+		log.FieldKeyUserID:                      "user-1",
+		gotransport.AccessLogFieldBodyTruncated: nil,
+	} {
+		if entry[k] != v {
+			t.Errorf("Expected %s %v but got %v", k, v, entry[k])
+		}
+	}
+	if _, ok := entry[gotransport.AccessLogFieldLatency].(float64); !ok {
+		t.Errorf("Expected the latency but got %v", entry)
+	}
+	for _, k := range []string{gotransport.AccessLogFieldRequestBody, gotransport.AccessLogFieldResponseBody} {
+		body, _ := entry[k].(map[string]interface{})
+		if body["name"] != "An" || body["password"] == "secret" || body["password"] == nil {
+			t.Errorf("Expected the masked %s but got %v", k, entry[k])
+		}
+	}
+}
+
+func TestAccessLogMiddleware(t *testing.T) {
+	logger, buf := newAccessLogger()
+	accessLog := gotransport.NewAccessLog(
+		gotransport.AccessLogLogger(logger),
+		gotransport.AccessLogBodies(8),
+		gotransport.AccessLogSampling(0),
+		gotransport.AccessLogRoute("/slow", gotransport.RouteLogConfig{SlowThreshold: time.Millisecond}),
+	)
+	handler := accessLog.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(5 * time.Millisecond)
+		case "/fail":
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		io.WriteString(w, "a response longer than the cap")
+	}))
+
+	for _, path := range []string{"/sampled-out", "/slow", "/fail"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, path, strings.NewReader("request body")))
+	}
+
+	entries := accessEntries(t, buf)
+	if len(entries) != 2 {
+		t.Fatalf("Expected the slow and the failed requests but got %v", entries)
+	}
+	if slow := entries[0]; slow["level"] != "warning" || slow[gotransport.AccessLogFieldSlow] != true || slow[gotransport.AccessLogFieldRoute] != "/slow" {
+		t.Errorf("Unexpected slow entry %v", slow)
+	}
+	failed := entries[1]
+	if failed["level"] != "error" || failed[gotransport.AccessLogFieldStatus] != float64(502) {
+		t.Errorf("Unexpected failed entry %v", failed)
+	}
+	if failed[gotransport.AccessLogFieldRequestBody] != gotransport.UnparsableBody || failed[gotransport.AccessLogFieldResponseBody] != gotransport.UnparsableBody ||
+		failed[gotransport.AccessLogFieldBodyTruncated] != true {
+		t.Errorf("Expected the truncated bodies not to be logged but got %v", failed)
+	}
+}
+
+func TestAccessLogUnparsableBody(t *testing.T) {
+	logger, buf := newAccessLogger()
+	accessLog := gotransport.NewAccessLog(gotransport.AccessLogLogger(logger), gotransport.AccessLogBodies(24))
+	handler := accessLog.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		io.WriteString(w, "pin=1234")
+	}))
+
+	// the JSON object truncated by the cap cannot be masked
+	body := `{"name":"An","password":"secret"}`
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	if strings.Contains(buf.String(), "secret") || strings.Contains(buf.String(), "1234") {
+		t.Errorf("Expected the secrets not to be logged but got %s", buf)
+	}
+	entries := accessEntries(t, buf)
+	if len(entries) != 1 || entries[0][gotransport.AccessLogFieldRequestBody] != gotransport.UnparsableBody ||
+		entries[0][gotransport.AccessLogFieldResponseBody] != gotransport.UnparsableBody {
+		t.Errorf("Expected the unparsable bodies but got %v", entries)
+	}
+}
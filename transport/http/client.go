@@ -116,6 +116,7 @@ func (c Client) Endpoint() endpoint.Endpoint {
 		for _, f := range c.before {
 			ctx = f(ctx, req)
 		}
+		setRequestID(ctx, req.Header)
 
 		resp, err = c.client.Do(req.WithContext(ctx))
 		if err != nil {
@@ -0,0 +1,300 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/ThomasNguyenGitHub/go/endpoint"
+	"github.com/ThomasNguyenGitHub/go/errors"
+	"github.com/ThomasNguyenGitHub/go/model"
+	"github.com/ThomasNguyenGitHub/go/validation"
+)
+
+// CodeSuccess is the business code of the successful responses.
+const CodeSuccess = "00"
+
+// MessageCatalog returns the localized messages of the business codes.
+type MessageCatalog interface {
+	Message(language, code string) (model.BaseMessage, bool)
+}
+
+// TextCatalog is a MessageCatalog looking the messages up in Catalog, then
+// in Fallback, the keys of the title and the text of a code being the code
+// prefixed by model.TitlePrefix and model.MessagePrefix.
+type TextCatalog struct {
+	Catalog  *validation.Catalog
+	Fallback *validation.Catalog
+}
+
+// Message implements MessageCatalog.
+func (c TextCatalog) Message(language, code string) (model.BaseMessage, bool) {
+	title, titleOK := c.lookup(language, model.TitlePrefix+code)
+	text, textOK := c.lookup(language, model.MessagePrefix+code)
+	return model.BaseMessage{Title: title, Text: text}, titleOK || textOK
+}
+
+func (c TextCatalog) lookup(language, key string) (string, bool) {
+	if c.Catalog != nil {
+		if message, ok := c.Catalog.Lookup(language, key); ok {
+			return message, true
+		}
+	}
+	if c.Fallback != nil {
+		return c.Fallback.Lookup(language, key)
+	}
+	return "", false
+}
+
+// codeMessages holds the messages of the success code, of the HTTP status codes
+// and of the codes of RegisterMessages.
+var codeMessages = validation.NewCatalog(statusMessages)
+
+// DefaultCatalog holds the messages of the success code, of the HTTP status
+// codes and of the codes of RegisterMessages, falling back to
+// validation.DefaultCatalog.
+var DefaultCatalog MessageCatalog = TextCatalog{Catalog: codeMessages, Fallback: validation.DefaultCatalog}
+
+// statusMessages are the messages of the success code and of the HTTP status
+// codes.
+var statusMessages = map[string]map[string]string{
+	HeaderLanguageVN: {
+		"t00": "Thành công", "m00": "Giao dịch thành công",
+		"t400": "Yêu cầu không hợp lệ", "m400": "Thông tin yêu cầu không hợp lệ, vui lòng kiểm tra lại",
+		"t401": "Chưa xác thực", "m401": "Phiên đăng nhập đã hết hạn, vui lòng đăng nhập lại",
+		"t403": "Không có quyền", "m403": "Bạn không có quyền thực hiện chức năng này",
+		"t404": "Không tìm thấy", "m404": "Không tìm thấy thông tin yêu cầu",
+		"t405": "Không hỗ trợ", "m405": "Phương thức không được hỗ trợ",
+		"t408": "Hết thời gian", "m408": "Yêu cầu đã hết thời gian xử lý, vui lòng thử lại",
+		"t409": "Xung đột", "m409": "Yêu cầu đang được xử lý hoặc đã được xử lý trước đó",
+		"t429": "Quá nhiều yêu cầu", "m429": "Bạn đã thực hiện quá nhiều yêu cầu, vui lòng thử lại sau",
+		"t500": "Lỗi hệ thống", "m500": "Hệ thống đang gián đoạn, vui lòng thử lại sau",
+		"t502": "Lỗi hệ thống", "m502": "Hệ thống đang gián đoạn, vui lòng thử lại sau",
+		"t503": "Hệ thống bận", "m503": "Hệ thống đang bận, vui lòng thử lại sau",
+		"t504": "Hết thời gian", "m504": "Hệ thống phản hồi chậm, vui lòng thử lại sau",
+	},
+	HeaderLanguageEN: {
+		"t00": "Success", "m00": "Transaction successful",
+		"t400": "Invalid request", "m400": "The request is invalid, please check the information",
+		"t401": "Unauthenticated", "m401": "Your session has expired, please log in again",
+		"t403": "Forbidden", "m403": "You are not allowed to use this function",
+		"t404": "Not found", "m404": "The requested information was not found",
+		"t405": "Not supported", "m405": "The method is not supported",
+		"t408": "Timeout", "m408": "The request timed out, please try again",
+		"t409": "Conflict", "m409": "The request is being or has already been processed",
+		"t429": "Too many requests", "m429": "You have made too many requests, please try again later",
+		"t500": "System error", "m500": "The system is unavailable, please try again later",
+		"t502": "System error", "m502": "The system is unavailable, please try again later",
+		"t503": "System busy", "m503": "The system is busy, please try again later",
+		"t504": "Timeout", "m504": "The system is responding slowly, please try again later",
+	},
+}
+
+// RegisterMessages adds the titles and texts of business codes in a
+// language to DefaultCatalog, the keys being the codes.
+func RegisterMessages(language string, messages map[string]model.BaseMessage) {
+	texts := make(map[string]string, 2*len(messages))
+	for code, message := range messages {
+		texts[model.TitlePrefix+code] = message.Title
+		texts[model.MessagePrefix+code] = message.Text
+	}
+	codeMessages.Register(language, texts)
+}
+
+// ErrorCode returns the business code and the status code of err, or of the
+// first *errors.Error or StatusCoder of its chain. The code of an
+// *errors.Error is its Id, or its Code without Id, and its status code is
+// its Code. The code of a StatusCoder is its status code. Other errors are
+// internal server errors.
+func ErrorCode(err error) (code string, status int) {
+	var (
+		e  *errors.Error
+		sc StatusCoder
+	)
+	switch {
+	case stderrors.As(err, &e):
+		status = int(e.Code)
+		if status < 400 || status > 599 {
+			status = http.StatusInternalServerError
+		}
+		if e.Id != "" {
+			return e.Id, status
+		}
+		return strconv.Itoa(int(e.Code)), status
+	case stderrors.As(err, &sc):
+		return strconv.Itoa(sc.StatusCode()), sc.StatusCode()
+	}
+	return strconv.Itoa(http.StatusInternalServerError), http.StatusInternalServerError
+}
+
+// Envelope encodes the responses and the errors in a model.BaseResponse,
+// with the messages of the business codes in the Language of the
+// RequestContext.
+type Envelope struct {
+	// Catalog holds the messages, DefaultCatalog by default
+	Catalog MessageCatalog
+	// DefaultLanguage is the language of the requests without one, or with
+	// a language without message, HeaderLanguageVN by default
+	DefaultLanguage string
+	// ErrorCode returns the business code and the status code of the
+	// errors, ErrorCode by default
+	ErrorCode func(err error) (code string, status int)
+}
+
+// DefaultEnvelope is the Envelope of EncodeEnvelopeResponse and
+// EnvelopeErrorEncoder.
+var DefaultEnvelope = &Envelope{}
+
+// EncodeEnvelopeResponse is an EncodeResponseFunc encoding the responses
+// with DefaultEnvelope.
+func EncodeEnvelopeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	return DefaultEnvelope.EncodeResponse(ctx, w, response)
+}
+
+// EnvelopeErrorEncoder is an ErrorEncoder encoding the errors with
+// DefaultEnvelope.
+func EnvelopeErrorEncoder(ctx context.Context, err error, w http.ResponseWriter) {
+	DefaultEnvelope.EncodeError(ctx, err, w)
+}
+
+// EncodeResponse is an EncodeResponseFunc wrapping the response in a
+// model.BaseResponse with the success code. The responses implementing
+// endpoint.Failer with an error are encoded with EncodeError, and the
+// model.BaseResponse responses are encoded as is. As with
+// EncodeJSONResponse, Headerer and StatusCoder responses set the headers
+// and the status code.
+func (e *Envelope) EncodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if f, ok := response.(endpoint.Failer); ok && f.Failed() != nil {
+		e.EncodeError(ctx, f.Failed(), w)
+		return nil
+	}
+
+	body, ok := response.(model.BaseResponse)
+	if !ok {
+		body = model.BaseResponse{
+			Code:    CodeSuccess,
+			Message: e.message(ctx, CodeSuccess, http.StatusOK),
+			Data:    response,
+		}
+	}
+
+	if headerer, ok := response.(Headerer); ok {
+		for k, values := range headerer.Headers() {
+			for _, v := range values {
+				w.Header().Add(k, v)
+			}
+		}
+	}
+	code := http.StatusOK
+	if sc, ok := response.(StatusCoder); ok {
+		code = sc.StatusCode()
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	if code == http.StatusNoContent {
+		return nil
+	}
+	return json.NewEncoder(w).Encode(body)
+}
+
+// EncodeError is an ErrorEncoder wrapping the error in a model.BaseResponse
+// with the code returned by ErrorCode. The message is the one of the code,
+// or of the status code when the code has none. The message text of a
+// *validation.Error, or of an error wrapping one, lists its invalid fields.
+func (e *Envelope) EncodeError(ctx context.Context, err error, w http.ResponseWriter) {
+	errorCode := e.ErrorCode
+	if errorCode == nil {
+		errorCode = ErrorCode
+	}
+	code, status := errorCode(err)
+
+	message := e.message(ctx, code, status)
+	var verr *validation.Error
+	if stderrors.As(err, &verr) {
+		message.Text = verr.Localize(e.language(ctx)).Error()
+	}
+
+	if headerer, ok := err.(Headerer); ok {
+		for k, values := range headerer.Headers() {
+			for _, v := range values {
+				w.Header().Add(k, v)
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(model.BaseResponse{Code: code, Message: message})
+}
+
+// language returns the language of the messages of the request.
+func (e *Envelope) language(ctx context.Context) string {
+	if language := GetRequestContext(ctx).Language; language != "" {
+		return language
+	}
+	if e.DefaultLanguage != "" {
+		return e.DefaultLanguage
+	}
+	return HeaderLanguageVN
+}
+
+// message returns the message of code, falling back to the message of the
+// status code and to the default language.
+func (e *Envelope) message(ctx context.Context, code string, status int) model.BaseMessage {
+	catalog := e.Catalog
+	if catalog == nil {
+		catalog = DefaultCatalog
+	}
+	defaultLanguage := e.DefaultLanguage
+	if defaultLanguage == "" {
+		defaultLanguage = HeaderLanguageVN
+	}
+	for _, language := range []string{e.language(ctx), defaultLanguage} {
+		for _, c := range []string{code, strconv.Itoa(status)} {
+			if message, ok := catalog.Message(language, c); ok {
+				return message
+			}
+		}
+	}
+	return model.BaseMessage{Title: http.StatusText(status)}
+}
+
+// DecodeEnvelopeResponse is a DecodeResponseFunc unwrapping the data of a
+// model.BaseResponse into a Resp. The responses with a code other than
+// CodeSuccess are returned as an *errors.Error, with the code as Id, the
+// status code as Code and the message text as Detail.
+func DecodeEnvelopeResponse[Resp any](_ context.Context, r *http.Response) (interface{}, error) {
+	var resp Resp
+	body := model.BaseResponse{Data: &resp}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		if err == io.EOF && r.StatusCode < 400 {
+			return resp, nil
+		}
+		if r.StatusCode >= 400 {
+			return nil, &errors.Error{
+				Id:     strconv.Itoa(r.StatusCode),
+				Code:   int32(r.StatusCode),
+				Status: http.StatusText(r.StatusCode),
+			}
+		}
+		return nil, fmt.Errorf("decoding envelope: %w", err)
+	}
+
+	if body.Code != CodeSuccess || r.StatusCode >= 400 {
+		status := r.StatusCode
+		if status < 400 {
+			// a business error with a successful status code
+			status = http.StatusInternalServerError
+		}
+		return nil, &errors.Error{
+			Id:     body.Code,
+			Code:   int32(status),
+			Detail: body.Message.Text,
+			Status: body.Message.Title,
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,162 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	goerrors "github.com/ThomasNguyenGitHub/go/errors"
+	"github.com/ThomasNguyenGitHub/go/model"
+	gotransport "github.com/ThomasNguyenGitHub/go/transport/http"
+	"github.com/ThomasNguyenGitHub/go/validation"
+)
+
+type balance struct {
+	Amount int `json:"amount"`
+}
+
+func envelopeServer(e func(context.Context, interface{}) (interface{}, error)) *gotransport.Server {
+	return gotransport.NewServer(
+		e,
+		func(context.Context, *http.Request) (interface{}, error) { return nil, nil },
+		gotransport.EncodeEnvelopeResponse,
+		gotransport.ServerBefore(gotransport.PopulateRequestContext),
+		gotransport.ServerErrorEncoder(gotransport.EnvelopeErrorEncoder),
+	)
+}
+
+func serveEnvelope(t *testing.T, server http.Handler, language string) (int, model.BaseResponse, json.RawMessage) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(gotransport.HeaderLanguage, language)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	var data json.RawMessage
+	body := model.BaseResponse{Data: &data}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Unexpected body %s: %v", rec.Body, err)
+	}
+	return rec.Code, body, data
+}
+
+func TestEnvelopeResponse(t *testing.T) {
+	server := envelopeServer(func(context.Context, interface{}) (interface{}, error) {
+		return balance{100}, nil
+	})
+
+	code, body, data := serveEnvelope(t, server, gotransport.HeaderLanguageEN)
+	if code != http.StatusOK || body.Code != gotransport.CodeSuccess || string(data) != `{"amount":100}` {
+		t.Errorf("Unexpected response %d %+v %s", code, body, data)
+	}
+	if body.Message.Title != "Success" {
+		t.Errorf("Expected the english title but got %q", body.Message.Title)
+	}
+	if _, body, _ = serveEnvelope(t, server, ""); body.Message.Title != "Thành công" {
+		t.Errorf("Expected the default vietnamese title but got %q", body.Message.Title)
+	}
+}
+
+func TestEnvelopeError(t *testing.T) {
+	gotransport.RegisterMessages(gotransport.HeaderLanguageEN, map[string]model.BaseMessage{
+		"insufficient_funds": {Title: "Payment failed", Text: "Your balance is insufficient"},
+	})
+
+	for _, tc := range []struct {
+		err                  error
+		language, code       string
+		status               int
+		expectedTitle, field string
+	}{
+		{goerrors.New("insufficient_funds", "balance 10 < 100", 422), gotransport.HeaderLanguageEN, "insufficient_funds", 422, "Payment failed", ""},
+		{goerrors.NotFound("no account"), gotransport.HeaderLanguageVN, "404", 404, "Không tìm thấy", ""},
+		{goerrors.New("unknown_code", "detail", 409), gotransport.HeaderLanguageEN, "unknown_code", 409, "Conflict", ""},
+		{&gotransport.BadRequestError{Err: errors.New("bad")}, "fr-FR", "400", 400, "Yêu cầu không hợp lệ", ""},
+		{errors.New("boom"), gotransport.HeaderLanguageEN, "500", 500, "System error", ""},
+	} {
+		server := envelopeServer(func(context.Context, interface{}) (interface{}, error) { return nil, tc.err })
+		status, body, _ := serveEnvelope(t, server, tc.language)
+		if status != tc.status || body.Code != tc.code || body.Message.Title != tc.expectedTitle {
+			t.Errorf("%v: unexpected response %d %+v", tc.err, status, body)
+		}
+		if body.Message.Text == "balance 10 < 100" || body.Message.Text == "boom" {
+			t.Errorf("Expected the error detail not to be exposed but got %q", body.Message.Text)
+		}
+	}
+}
+
+func TestEnvelopeValidationError(t *testing.T) {
+	handler := gotransport.NewServer(
+		func(context.Context, interface{}) (interface{}, error) { return nil, nil },
+		decodeTransferRequest,
+		gotransport.EncodeEnvelopeResponse,
+		gotransport.ServerBefore(gotransport.PopulateRequestContext),
+		gotransport.ServerErrorEncoder(gotransport.EnvelopeErrorEncoder),
+	)
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"account":"1"}`))
+	req.Header.Set(gotransport.HeaderLanguage, gotransport.HeaderLanguageVN)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var body model.BaseResponse
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Code != 400 || body.Code != "400" || body.Message.Text != "account phải có độ dài 10" {
+		t.Errorf("Unexpected response %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestEnvelopeWrappedValidationError(t *testing.T) {
+	verr := validation.Validate(transferRequest{Account: "1"})
+	server := envelopeServer(func(context.Context, interface{}) (interface{}, error) {
+		return nil, fmt.Errorf("transfer: %w", verr)
+	})
+	status, body, _ := serveEnvelope(t, server, gotransport.HeaderLanguageEN)
+	if status != 400 || body.Code != "400" || body.Message.Text != "account must have a length of 10" {
+		t.Errorf("Unexpected response %d %+v", status, body)
+	}
+}
+
+func TestEnvelopeCatalog(t *testing.T) {
+	if _, ok := validation.DefaultCatalog.Lookup(gotransport.HeaderLanguageEN, model.TitlePrefix+"404"); ok {
+		t.Error("Expected the envelope messages to stay out of the validation catalog")
+	}
+
+	// the messages of validation.DefaultCatalog are the fallback
+	validation.RegisterMessages(gotransport.HeaderLanguageEN, map[string]string{model.TitlePrefix + "limit_exceeded": "Limit exceeded"})
+	message, ok := gotransport.DefaultCatalog.Message(gotransport.HeaderLanguageEN, "limit_exceeded")
+	if !ok || message.Title != "Limit exceeded" {
+		t.Errorf("Expected the fallback message but got %+v", message)
+	}
+	if message, _ := gotransport.DefaultCatalog.Message(gotransport.HeaderLanguageEN, "404"); message.Title != "Not found" {
+		t.Errorf("Expected the status message but got %+v", message)
+	}
+}
+
+func TestDecodeEnvelopeResponse(t *testing.T) {
+	var fail error
+	server := httptest.NewServer(envelopeServer(func(context.Context, interface{}) (interface{}, error) {
+		return balance{42}, fail
+	}))
+	defer server.Close()
+
+	client := gotransport.NewClient(http.MethodGet, mustParse(server.URL), gotransport.EncodeJSONRequest, gotransport.DecodeEnvelopeResponse[balance])
+	response, err := client.Endpoint()(context.Background(), struct{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := response.(balance); b.Amount != 42 {
+		t.Errorf("Expected 42 but got %d", b.Amount)
+	}
+
+	fail = goerrors.New("insufficient_funds", "", 422)
+	_, err = client.Endpoint()(context.Background(), struct{}{})
+	var e *goerrors.Error
+	if !errors.As(err, &e) || e.Id != "insufficient_funds" || e.Code != 422 || e.Status != "Unprocessable Entity" {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
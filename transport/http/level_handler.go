@@ -0,0 +1,78 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ThomasNguyenGitHub/go/log"
+)
+
+// LevelsResponse is the response of the level handler, listing the level of
+// the root logger and the levels set for named loggers.
+type LevelsResponse struct {
+	Level   log.Level            `json:"level"`
+	Loggers map[string]log.Level `json:"loggers"`
+}
+
+// LevelRequest is the body of the requests changing the level of a named
+// logger, the root logger when Name is empty. RevertAfter is a duration,
+// e.g. "15m", after which the previous level is restored.
+type LevelRequest struct {
+	Name        string    `json:"name"`
+	Level       log.Level `json:"level"`
+	RevertAfter string    `json:"revert_after,omitempty"`
+}
+
+type levelHandlerError struct {
+	code int
+	err  error
+}
+
+func (e levelHandlerError) Error() string   { return e.err.Error() }
+func (e levelHandlerError) StatusCode() int { return e.code }
+
+// NewLevelHandler returns a handler listing and changing the levels of the
+// named loggers of registry at runtime:
+//
+//	GET    lists the levels as a LevelsResponse
+//	PUT    sets a level from a LevelRequest, POST is accepted as well
+//	DELETE removes the level of the logger named by the name query parameter
+//
+// The handler does not authenticate the requests, it should be mounted
+// behind an authenticating middleware or on an internal port.
+func NewLevelHandler(registry *log.LevelRegistry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var req LevelRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				DefaultErrorEncoder(r.Context(), levelHandlerError{http.StatusBadRequest, fmt.Errorf("invalid level request: %w", err)}, w)
+				return
+			}
+			var revertAfter time.Duration
+			if req.RevertAfter != "" {
+				d, err := time.ParseDuration(req.RevertAfter)
+				if err != nil || d < 0 {
+					DefaultErrorEncoder(r.Context(), levelHandlerError{http.StatusBadRequest, fmt.Errorf("invalid revert_after %q", req.RevertAfter)}, w)
+					return
+				}
+				revertAfter = d
+			}
+			registry.SetLevel(req.Name, req.Level, revertAfter)
+		case http.MethodDelete:
+			registry.Reset(r.URL.Query().Get("name"))
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+			DefaultErrorEncoder(r.Context(), levelHandlerError{http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method)}, w)
+			return
+		}
+
+		levels := registry.Levels()
+		resp := LevelsResponse{Level: levels[""], Loggers: levels}
+		delete(levels, "")
+		EncodeJSONResponse(r.Context(), w, resp)
+	})
+}
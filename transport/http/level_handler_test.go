@@ -0,0 +1,62 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ThomasNguyenGitHub/go/log"
+	gotransport "github.com/ThomasNguyenGitHub/go/transport/http"
+)
+
+func TestLevelHandler(t *testing.T) {
+	logger := log.New()
+	logger.SetLevel(log.InfoLevel)
+	server := httptest.NewServer(gotransport.NewLevelHandler(logger.Levels()))
+	defer server.Close()
+
+	do := func(method, url, body string) (int, gotransport.LevelsResponse) {
+		req, _ := http.NewRequest(method, server.URL+url, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var levels gotransport.LevelsResponse
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&levels); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode, levels
+	}
+
+	code, levels := do("PUT", "", `{"name":"payment","level":"debug","revert_after":"1h"}`)
+	if code != http.StatusOK || levels.Level != log.InfoLevel || levels.Loggers["payment"] != log.DebugLevel {
+		t.Errorf("Unexpected response %d, %v", code, levels)
+	}
+	if !logger.Named("payment").IsLevelEnabled(log.DebugLevel) {
+		t.Error("Expected debug to be enabled for payment")
+	}
+
+	if code, levels = do("POST", "", `{"level":"warning"}`); code != http.StatusOK || levels.Level != log.WarnLevel {
+		t.Errorf("Unexpected response %d, %v", code, levels)
+	}
+	if code, levels = do("DELETE", "?name=payment", ""); code != http.StatusOK || len(levels.Loggers) != 0 {
+		t.Errorf("Unexpected response %d, %v", code, levels)
+	}
+	if code, levels = do("GET", "", ""); code != http.StatusOK || levels.Level != log.WarnLevel {
+		t.Errorf("Unexpected response %d, %v", code, levels)
+	}
+
+	for _, body := range []string{`{"level":"loud"}`, `{"level":"debug","revert_after":"soon"}`} {
+		if code, _ := do("PUT", "", body); code != http.StatusBadRequest {
+			t.Errorf("%s: Expected status 400 but got %d", body, code)
+		}
+	}
+	if code, _ := do("PATCH", "", ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 but got %d", code)
+	}
+}
@@ -0,0 +1,492 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/plugin/ochttp/propagation/tracecontext"
+	"go.opencensus.io/trace/propagation"
+
+	"github.com/ThomasNguyenGitHub/go/endpoint"
+	"github.com/ThomasNguyenGitHub/go/errors"
+	"github.com/ThomasNguyenGitHub/go/metrics"
+)
+
+// Defaults of the outbound client
+const (
+	DefaultOutboundTimeout      = 30 * time.Second
+	DefaultOutboundDialTimeout  = 5 * time.Second
+	DefaultOutboundIdleConns    = 100
+	DefaultOutboundIdleTimeout  = 90 * time.Second
+	DefaultOutboundMaxErrorBody = 4 << 10
+)
+
+// IdempotencyKeyHeader marks the requests that can be retried whatever their
+// method.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// HostConfig configures the requests to a host. The zero fields take the
+// value of the defaults of the client.
+type HostConfig struct {
+	// Timeout limits each attempt of a request, including reading the
+	// response body
+	Timeout time.Duration
+	// DialTimeout, TLSHandshakeTimeout and ResponseHeaderTimeout limit the
+	// steps of the attempts
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	// MaxIdleConns, MaxConns and IdleConnTimeout set the connection pool
+	MaxIdleConns    int
+	MaxConns        int
+	IdleConnTimeout time.Duration
+	// Middleware wrap each attempt, such as the circuit breakers of the
+	// circuitbreaker package and the rate limiters of the ratelimit package.
+	// The attempts failing with a server error or a network error are
+	// returned as errors to the middleware, the other responses are not.
+	Middleware []endpoint.Middleware
+}
+
+// merge returns cfg with the zero fields set from def.
+func (cfg HostConfig) merge(def HostConfig) HostConfig {
+	durations := []struct{ v, def *time.Duration }{
+		{&cfg.Timeout, &def.Timeout},
+		{&cfg.DialTimeout, &def.DialTimeout},
+		{&cfg.TLSHandshakeTimeout, &def.TLSHandshakeTimeout},
+		{&cfg.ResponseHeaderTimeout, &def.ResponseHeaderTimeout},
+		{&cfg.IdleConnTimeout, &def.IdleConnTimeout},
+	}
+	for _, d := range durations {
+		if *d.v == 0 {
+			*d.v = *d.def
+		}
+	}
+	if cfg.MaxIdleConns == 0 {
+		cfg.MaxIdleConns = def.MaxIdleConns
+	}
+	if cfg.MaxConns == 0 {
+		cfg.MaxConns = def.MaxConns
+	}
+	if cfg.Middleware == nil {
+		cfg.Middleware = def.Middleware
+	}
+	return cfg
+}
+
+// RetryPolicy sets how the failed requests are retried. Network errors and
+// the 429, 502, 503 and 504 responses are retried, waiting for the duration
+// of their Retry-After header when set. Only the requests with an idempotent
+// method or an Idempotency-Key header are retried, unless
+// RetryNonIdempotent is set.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries, no retry when zero
+	MaxRetries int
+	// Backoff is the wait before the first retry, doubling after each
+	// retry up to MaxBackoff
+	Backoff time.Duration
+	// MaxBackoff also bounds the Retry-After waits, the responses asking
+	// for a longer wait are not retried. When zero, the Retry-After waits
+	// are bounded by the MaxBackoff of DefaultRetryPolicy.
+	MaxBackoff time.Duration
+	// RetryNonIdempotent retries the requests whatever their method
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy is the retry policy of the outbound clients.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 2,
+	Backoff:    200 * time.Millisecond,
+	MaxBackoff: 5 * time.Second,
+}
+
+// OutboundOption sets an optional parameter for outbound clients.
+type OutboundOption func(*OutboundClient)
+
+// OutboundDefaults sets the configuration of the hosts without their own.
+// The middleware of the defaults are shared by these hosts.
+func OutboundDefaults(cfg HostConfig) OutboundOption {
+	return func(c *OutboundClient) { c.defaults = cfg.merge(c.defaults) }
+}
+
+// OutboundHost sets the configuration of a host, with or without its port.
+func OutboundHost(host string, cfg HostConfig) OutboundOption {
+	return func(c *OutboundClient) { c.hosts[host] = cfg }
+}
+
+// OutboundRetry sets the retry policy, DefaultRetryPolicy by default.
+func OutboundRetry(policy RetryPolicy) OutboundOption {
+	return func(c *OutboundClient) { c.retry = policy }
+}
+
+// OutboundMaxErrorBody sets the number of bytes of the error responses
+// captured in their errors.Error, DefaultOutboundMaxErrorBody by default.
+func OutboundMaxErrorBody(n int64) OutboundOption {
+	return func(c *OutboundClient) { c.maxErrorBody = n }
+}
+
+// OutboundMetrics sets the metrics of the attempts: their count and their
+// duration in seconds, labeled by host, method and code. The code is the
+// status code of the response, or "error" without response. Either may be
+// nil.
+func OutboundMetrics(requests metrics.Counter, duration metrics.Histogram) OutboundOption {
+	return func(c *OutboundClient) { c.requests, c.duration = requests, duration }
+}
+
+// OutboundTracing sets the format the trace context of the requests is
+// propagated with, the W3C trace context by default. A client span is
+// created for each attempt with OpenCensus. Nil disables tracing.
+func OutboundTracing(format propagation.HTTPFormat) OutboundOption {
+	return func(c *OutboundClient) { c.propagation = format }
+}
+
+// OutboundBefore adds RequestFuncs applied to the requests before they are
+// sent, such as SetRequestHeader.
+func OutboundBefore(before ...RequestFunc) OutboundOption {
+	return func(c *OutboundClient) { c.before = append(c.before, before...) }
+}
+
+// OutboundClient is an HTTP client for the calls to external services, with
+// per-host timeouts, connection pools and middleware, retries, metrics and
+// trace propagation.
+//
+// Responses with a status code of 400 or more are returned as an
+// *errors.Error, with the status code as Code. Its Detail is the captured
+// body, or the detail of the body when it is itself an errors.Error.
+//
+// OutboundClient implements HTTPClient, so it can be used by a Client with
+// SetClient.
+type OutboundClient struct {
+	defaults     HostConfig
+	hosts        map[string]HostConfig
+	retry        RetryPolicy
+	maxErrorBody int64
+	requests     metrics.Counter
+	duration     metrics.Histogram
+	propagation  propagation.HTTPFormat
+	before       []RequestFunc
+
+	mu      sync.Mutex
+	clients map[string]endpoint.Endpoint
+}
+
+// NewOutboundClient returns an OutboundClient.
+func NewOutboundClient(options ...OutboundOption) *OutboundClient {
+	c := &OutboundClient{
+		defaults: HostConfig{
+			Timeout:             DefaultOutboundTimeout,
+			DialTimeout:         DefaultOutboundDialTimeout,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConns:        DefaultOutboundIdleConns,
+			IdleConnTimeout:     DefaultOutboundIdleTimeout,
+		},
+		hosts:        make(map[string]HostConfig),
+		retry:        DefaultRetryPolicy,
+		maxErrorBody: DefaultOutboundMaxErrorBody,
+		propagation:  &tracecontext.HTTPFormat{},
+		clients:      make(map[string]endpoint.Endpoint),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// DefaultOutboundClient is the OutboundClient of ExecuteHTTP.
+var DefaultOutboundClient = NewOutboundClient()
+
+// Do sends the request, retrying it according to the retry policy. The
+// context of the request bounds all the attempts.
+func (c *OutboundClient) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for _, f := range c.before {
+		ctx = f(ctx, req)
+	}
+	setRequestID(ctx, req.Header)
+	e := c.endpoint(req.URL)
+
+	retries := 0
+	if c.retry.MaxRetries > 0 && (c.retry.RetryNonIdempotent || isIdempotent(req)) {
+		retries = c.retry.MaxRetries
+		if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+			if err := bufferBody(req); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	backoff, maxWait := c.retry.Backoff, c.retry.MaxBackoff
+	if maxWait <= 0 {
+		maxWait = DefaultRetryPolicy.MaxBackoff
+	}
+	for attempt := 0; ; attempt++ {
+		r := req.WithContext(ctx)
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r.Body = body
+		}
+
+		response, err := e(ctx, r)
+		if err == nil {
+			resp := response.(*http.Response)
+			if resp.StatusCode >= 400 {
+				return nil, c.statusError(resp)
+			}
+			return resp, nil
+		}
+
+		wait, retryable := retryDelay(err, backoff, maxWait)
+		if !retryable || attempt >= retries || ctx.Err() != nil {
+			return nil, unwrapStatusError(err)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return nil, unwrapStatusError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		if backoff *= 2; c.retry.MaxBackoff > 0 && backoff > c.retry.MaxBackoff {
+			backoff = c.retry.MaxBackoff
+		}
+	}
+}
+
+// Execute sends the request with the context and decodes the JSON body of
+// the response into target, unless target is nil or the response has no
+// content.
+func (c *OutboundClient) Execute(ctx context.Context, req *http.Request, target interface{}) error {
+	resp, err := c.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if target == nil || resp.StatusCode == http.StatusNoContent {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// endpoint returns the endpoint sending the requests to the host of u.
+func (c *OutboundClient) endpoint(u *url.URL) endpoint.Endpoint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.clients[u.Host]; ok {
+		return e
+	}
+
+	cfg, ok := c.hosts[u.Host]
+	if !ok {
+		cfg, ok = c.hosts[u.Hostname()]
+	}
+	if ok {
+		cfg = cfg.merge(c.defaults)
+	} else {
+		cfg = c.defaults
+	}
+
+	var transport http.RoundTripper = &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: cfg.DialTimeout, KeepAlive: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConns,
+		MaxConnsPerHost:       cfg.MaxConns,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		ForceAttemptHTTP2:     true,
+	}
+	if c.propagation != nil {
+		transport = &ochttp.Transport{Base: transport, Propagation: c.propagation}
+	}
+	client := &http.Client{Transport: transport, Timeout: cfg.Timeout}
+
+	e := c.attempt(client, u.Host)
+	for i := len(cfg.Middleware) - 1; i >= 0; i-- {
+		e = cfg.Middleware[i](e)
+	}
+	c.clients[u.Host] = e
+	return e
+}
+
+// attempt returns the endpoint sending a request once with client. The
+// responses to retry are returned as a *statusError.
+func (c *OutboundClient) attempt(client *http.Client, host string) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*http.Request)
+		begin := time.Now()
+		resp, err := client.Do(req)
+
+		code := "error"
+		if err == nil {
+			code = strconv.Itoa(resp.StatusCode)
+		}
+		if c.requests != nil {
+			c.requests.With("host", host, "method", req.Method, "code", code).Add(1)
+		}
+		if c.duration != nil {
+			c.duration.With("host", host, "method", req.Method, "code", code).Observe(time.Since(begin).Seconds())
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			return nil, &statusError{
+				err:        c.statusError(resp),
+				retryAfter: retryAfter(resp.Header.Get("Retry-After")),
+			}
+		}
+		return resp, nil
+	}
+}
+
+// statusError returns the *errors.Error of resp, closing its body.
+func (c *OutboundClient) statusError(resp *http.Response) *errors.Error {
+	return responseError(resp, c.maxErrorBody)
+}
+
+// responseError returns the *errors.Error of resp, capturing at most max
+// bytes of its body and closing it.
+func responseError(resp *http.Response, max int64) *errors.Error {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, max))
+	io.Copy(io.Discard, resp.Body)
+
+	e := &errors.Error{
+		Id:     strconv.Itoa(resp.StatusCode),
+		Code:   int32(resp.StatusCode),
+		Detail: strings.TrimSpace(string(body)),
+		Status: http.StatusText(resp.StatusCode),
+	}
+	if p := problemError(resp.Header.Get("Content-Type"), body); p != nil {
+		if p.Id == "" {
+			p.Id = e.Id
+		}
+		if p.Code == 0 {
+			p.Code, p.Status = e.Code, e.Status
+		}
+		return p
+	}
+	// the services of this module respond errors.Error
+	var upstream errors.Error
+	if json.Unmarshal(body, &upstream) == nil && upstream.Detail != "" {
+		if upstream.Id != "" {
+			e.Id = upstream.Id
+		}
+		e.Detail = upstream.Detail
+		e.Metadata, e.Cause = upstream.Metadata, upstream.Cause
+	}
+	return e
+}
+
+// statusError is the error of the attempts responded a retryable status.
+type statusError struct {
+	err        *errors.Error
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+// unwrapStatusError returns the errors.Error of a statusError.
+func unwrapStatusError(err error) error {
+	if se, ok := err.(*statusError); ok {
+		return se.err
+	}
+	return err
+}
+
+// retryDelay returns whether err is retryable and the wait before retrying,
+// the responses asking to wait longer than maxWait not being retryable.
+func retryDelay(err error, backoff, maxWait time.Duration) (time.Duration, bool) {
+	if se, ok := err.(*statusError); ok {
+		if !isRetryableStatus(int(se.err.Code)) {
+			return 0, false
+		}
+		if se.retryAfter > 0 {
+			return se.retryAfter, se.retryAfter <= maxWait
+		}
+		return jitter(backoff), true
+	}
+	var uerr *url.Error
+	if stderrors.As(err, &uerr) {
+		return jitter(backoff), true
+	}
+	// such as the errors of the circuit breakers and the rate limiters
+	return 0, false
+}
+
+// jitter returns a random duration between half d and d.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+// retryAfter parses a Retry-After header, in seconds or as an HTTP date.
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// bufferBody reads the body of req so that it can be sent again.
+func bufferBody(req *http.Request) error {
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return nil
+}
@@ -0,0 +1,280 @@
+package http_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker"
+
+	"github.com/ThomasNguyenGitHub/go/circuitbreaker"
+	"github.com/ThomasNguyenGitHub/go/endpoint"
+	"github.com/ThomasNguyenGitHub/go/errors"
+	"github.com/ThomasNguyenGitHub/go/metrics"
+	"github.com/ThomasNguyenGitHub/go/metrics/generic"
+	"github.com/ThomasNguyenGitHub/go/ratelimit"
+	gotransport "github.com/ThomasNguyenGitHub/go/transport/http"
+)
+
+var fastRetry = gotransport.RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}
+
+func TestOutboundClientExecute(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("traceparent") == "" {
+			t.Error("Expected the trace context to be propagated")
+		}
+		w.Write([]byte(`{"name":"alice"}`))
+	}))
+	defer server.Close()
+
+	client := gotransport.NewOutboundClient()
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	var target struct{ Name string }
+	if err := client.Execute(context.Background(), req, &target); err != nil {
+		t.Fatal(err)
+	}
+	if target.Name != "alice" {
+		t.Errorf("Expected alice but got %q", target.Name)
+	}
+}
+
+func TestOutboundClientStatusError(t *testing.T) {
+	for _, tc := range []struct {
+		body           string
+		id, detail     string
+		expectedStatus string
+	}{
+		{"invalid amount\n", "400", "invalid amount", "Bad Request"},
+		{`{"id":"go.payment","code":400,"detail":"invalid amount"}`, "go.payment", "invalid amount", "Bad Request"},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, tc.body)
+		}))
+
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		err := gotransport.NewOutboundClient().Execute(context.Background(), req, nil)
+		server.Close()
+
+		e, ok := err.(*errors.Error)
+		if !ok {
+			t.Fatalf("Expected an *errors.Error but got %v", err)
+		}
+		if e.Code != 400 || e.Id != tc.id || e.Detail != tc.detail || e.Status != tc.expectedStatus {
+			t.Errorf("Unexpected error %+v", e)
+		}
+	}
+}
+
+func TestOutboundClientRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Errorf("Expected the body to be sent again but got %q", body)
+		}
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := gotransport.NewOutboundClient(gotransport.OutboundRetry(fastRetry))
+	req, _ := http.NewRequest(http.MethodPut, server.URL, io.NopCloser(strings.NewReader("payload")))
+	if err := client.Execute(context.Background(), req, nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls but got %d", calls)
+	}
+
+	// POST is retried only with an idempotency key
+	atomic.StoreInt32(&calls, 0)
+	req, _ = http.NewRequest(http.MethodPost, server.URL, strings.NewReader("payload"))
+	err := client.Execute(context.Background(), req, nil)
+	if e, ok := err.(*errors.Error); !ok || e.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected a 503 error but got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected no retry but got %d calls", calls)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	req, _ = http.NewRequest(http.MethodPost, server.URL, strings.NewReader("payload"))
+	req.Header.Set(gotransport.IdempotencyKeyHeader, "key")
+	if err := client.Execute(context.Background(), req, nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls but got %d", calls)
+	}
+}
+
+func TestOutboundClientRetryAfter(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	client := gotransport.NewOutboundClient(gotransport.OutboundRetry(fastRetry))
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	begin := time.Now()
+	if err := client.Execute(context.Background(), req, nil); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(begin); elapsed < time.Second {
+		t.Errorf("Expected to wait for Retry-After but waited %s", elapsed)
+	}
+
+	// the retry would exceed the deadline of the context
+	atomic.StoreInt32(&calls, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+	err := client.Execute(ctx, req, nil)
+	if e, ok := err.(*errors.Error); !ok || e.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected a 429 error but got %v", err)
+	}
+
+	// the server asks for a wait longer than the maximum backoff
+	atomic.StoreInt32(&calls, 0)
+	busy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer busy.Close()
+	req, _ = http.NewRequest(http.MethodGet, busy.URL, nil)
+	begin = time.Now()
+	err = client.Execute(context.Background(), req, nil)
+	if e, ok := err.(*errors.Error); !ok || e.Code != http.StatusTooManyRequests || calls != 1 {
+		t.Errorf("Expected a 429 error without retry but got %v after %d calls", err, calls)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("Expected not to wait for Retry-After but waited %s", elapsed)
+	}
+}
+
+func TestOutboundClientHostConfig(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	client := gotransport.NewOutboundClient(
+		gotransport.OutboundRetry(gotransport.RetryPolicy{}),
+		gotransport.OutboundHost(host, gotransport.HostConfig{Timeout: 10 * time.Millisecond}),
+	)
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err := client.Execute(context.Background(), req, nil); err == nil {
+		t.Error("Expected the timeout of the host to be exceeded")
+	}
+}
+
+func TestOutboundClientMiddleware(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	breaker := gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		ReadyToTrip: func(counts gobreaker.Counts) bool { return counts.ConsecutiveFailures >= 2 },
+	})
+	client := gotransport.NewOutboundClient(gotransport.OutboundDefaults(gotransport.HostConfig{
+		Middleware: []endpoint.Middleware{circuitbreaker.Gobreaker(breaker)},
+	}))
+
+	// client errors do not trip the breaker
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/missing", nil)
+		if err := client.Execute(context.Background(), req, nil); err.(*errors.Error).Code != 404 {
+			t.Fatalf("Expected a 404 error but got %v", err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		client.Execute(context.Background(), req, nil)
+	}
+	if calls != 5 {
+		t.Errorf("Expected the breaker to open after 2 failures but got %d calls", calls)
+	}
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err := client.Execute(context.Background(), req, nil); err != gobreaker.ErrOpenState {
+		t.Errorf("Expected the breaker to be open but got %v", err)
+	}
+
+	limited := gotransport.NewOutboundClient(gotransport.OutboundDefaults(gotransport.HostConfig{
+		Middleware: []endpoint.Middleware{ratelimit.NewErroringLimiter(ratelimit.AllowerFunc(func() bool { return false }))},
+	}))
+	req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+	if err := limited.Execute(context.Background(), req, nil); err != ratelimit.ErrLimited {
+		t.Errorf("Expected ErrLimited but got %v", err)
+	}
+}
+
+func TestOutboundClientMetrics(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	requests := &recordingCounter{}
+	duration := generic.NewHistogram("duration", 10)
+	client := gotransport.NewOutboundClient(
+		gotransport.OutboundRetry(fastRetry),
+		gotransport.OutboundMetrics(requests, duration),
+		gotransport.OutboundTracing(nil),
+	)
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err := client.Execute(context.Background(), req, nil); err != nil {
+		t.Fatal(err)
+	}
+	codes := []string{}
+	for _, labels := range *requests.adds {
+		if len(labels) != 6 || labels[0] != "host" || labels[2] != "method" || labels[3] != "GET" || labels[4] != "code" {
+			t.Fatalf("Unexpected labels %v", labels)
+		}
+		codes = append(codes, labels[5])
+	}
+	if strings.Join(codes, ",") != "502,200" {
+		t.Errorf("Expected the codes of the 2 attempts but got %v", codes)
+	}
+}
+
+// recordingCounter records the label values of each Add.
+type recordingCounter struct {
+	labelValues []string
+	adds        *[][]string
+}
+
+func (c *recordingCounter) With(labelValues ...string) metrics.Counter {
+	if c.adds == nil {
+		c.adds = &[][]string{}
+	}
+	return &recordingCounter{labelValues: append(c.labelValues, labelValues...), adds: c.adds}
+}
+
+func (c *recordingCounter) Add(delta float64) {
+	*c.adds = append(*c.adds, c.labelValues)
+}
@@ -0,0 +1,157 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ThomasNguyenGitHub/go/errors"
+)
+
+// ProblemContentType is the content type of the problem details of RFC 7807.
+const ProblemContentType = "application/problem+json"
+
+// ProblemTypeBase prefixes the Id of the errors to form the type of their
+// problem details. The type of the errors without Id is about:blank.
+var ProblemTypeBase = "urn:problem-type:"
+
+// Problem is the problem details of RFC 7807, extended with the Id, the
+// Metadata and, when errors.ExposeCauses is set, the cause of an
+// *errors.Error.
+type Problem struct {
+	Type     string            `json:"type,omitempty"`
+	Title    string            `json:"title,omitempty"`
+	Status   int               `json:"status,omitempty"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Id       string            `json:"id,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Cause    *errors.Error     `json:"cause,omitempty"`
+}
+
+// NewProblem returns the problem details of err, an *errors.Error being
+// the first of its chain, a StatusCoder or an internal server error. The
+// detail of the server errors other than an *errors.Error is the text of
+// their status, as their message may reveal internal details.
+func NewProblem(err error) *Problem {
+	var e *errors.Error
+	if !stderrors.As(err, &e) {
+		code := http.StatusInternalServerError
+		if sc, ok := err.(StatusCoder); ok {
+			code = sc.StatusCode()
+		}
+		detail := err.Error()
+		if code >= http.StatusInternalServerError {
+			detail = http.StatusText(code)
+		}
+		return &Problem{
+			Type:   "about:blank",
+			Title:  http.StatusText(code),
+			Status: code,
+			Detail: detail,
+		}
+	}
+
+	p := &Problem{
+		Type:     "about:blank",
+		Title:    e.Status,
+		Status:   e.StatusCode(),
+		Detail:   e.Detail,
+		Id:       e.Id,
+		Metadata: e.Metadata,
+	}
+	if e.Id != "" {
+		p.Type = ProblemTypeBase + e.Id
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if !errors.ExposeCauses {
+		return p
+	}
+	if cause, ok := e.Cause.(*errors.Error); ok {
+		p.Cause = cause
+	} else if e.Cause != nil {
+		// encoded with the rest of its chain by errors.Error
+		p.Cause = &errors.Error{Detail: e.Cause.Error(), Cause: stderrors.Unwrap(e.Cause)}
+	}
+	return p
+}
+
+// Err returns the problem details as an *errors.Error, its Id being the
+// one of the extension or of the type.
+func (p *Problem) Err() *errors.Error {
+	e := &errors.Error{
+		Id:       p.Id,
+		Code:     int32(p.Status),
+		Detail:   p.Detail,
+		Status:   p.Title,
+		Metadata: p.Metadata,
+	}
+	if e.Id == "" {
+		if id := strings.TrimPrefix(p.Type, ProblemTypeBase); id != p.Type {
+			e.Id = id
+		} else if p.Status != 0 {
+			e.Id = strconv.Itoa(p.Status)
+		}
+	}
+	if p.Cause != nil {
+		e.Cause = p.Cause
+	}
+	return e
+}
+
+// ProblemErrorEncoder is an ErrorEncoder writing the errors as problem
+// details with the application/problem+json content type, the instance being
+// the path of the request. Headerer errors set the headers.
+func ProblemErrorEncoder(ctx context.Context, err error, w http.ResponseWriter) {
+	p := NewProblem(err)
+	if path, ok := ctx.Value(ContextKeyRequestPath).(string); ok {
+		p.Instance = path
+	}
+	if headerer, ok := err.(Headerer); ok {
+		for k, values := range headerer.Headers() {
+			for _, v := range values {
+				w.Header().Add(k, v)
+			}
+		}
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// DecodeProblem returns the *errors.Error of a response with problem details
+// or an errors.Error body, and a generic *errors.Error of its status code
+// otherwise. The body of the response is consumed.
+func DecodeProblem(r *http.Response) *errors.Error {
+	return responseError(r, DefaultOutboundMaxErrorBody)
+}
+
+// ProblemResponseDecoder wraps dec, returning the errors of the responses
+// with an error status code as rebuilt by DecodeProblem, so that the typed
+// errors of the servers survive service to service calls.
+func ProblemResponseDecoder(dec DecodeResponseFunc) DecodeResponseFunc {
+	return func(ctx context.Context, r *http.Response) (interface{}, error) {
+		if r.StatusCode >= 400 {
+			return nil, DecodeProblem(r)
+		}
+		return dec(ctx, r)
+	}
+}
+
+// problemError decodes the problem details of body, returning nil when it
+// holds none.
+func problemError(contentType string, body []byte) *errors.Error {
+	if !strings.HasPrefix(contentType, ProblemContentType) {
+		return nil
+	}
+	var p Problem
+	if json.Unmarshal(body, &p) != nil {
+		return nil
+	}
+	return p.Err()
+}
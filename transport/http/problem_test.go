@@ -0,0 +1,119 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	goerrors "github.com/ThomasNguyenGitHub/go/errors"
+	gotransport "github.com/ThomasNguyenGitHub/go/transport/http"
+)
+
+var errInsufficientFunds = goerrors.New("insufficient_funds", "", 422)
+
+func problemServer(err error) *gotransport.Server {
+	return gotransport.NewServer(
+		func(context.Context, interface{}) (interface{}, error) { return nil, err },
+		func(context.Context, *http.Request) (interface{}, error) { return nil, nil },
+		gotransport.EncodeJSONResponse,
+		gotransport.ServerBefore(gotransport.PopulateRequestContext),
+		gotransport.ServerErrorEncoder(gotransport.ProblemErrorEncoder),
+	)
+}
+
+func TestProblemErrorEncoder(t *testing.T) {
+	err := goerrors.Wrap(io.ErrUnexpectedEOF, "insufficient_funds", "balance 10 < 100", 422).(*goerrors.Error).WithMetadata("account", "123")
+	req := httptest.NewRequest(http.MethodPost, "/payments", nil)
+	rec := httptest.NewRecorder()
+	problemServer(err).ServeHTTP(rec, req)
+
+	if rec.Code != 422 || rec.Header().Get("Content-Type") != gotransport.ProblemContentType {
+		t.Fatalf("Unexpected response %d %v", rec.Code, rec.Header())
+	}
+	var p gotransport.Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Type != "urn:problem-type:insufficient_funds" || p.Title != "Unprocessable Entity" || p.Status != 422 ||
+		p.Detail != "balance 10 < 100" || p.Instance != "/payments" || p.Metadata["account"] != "123" {
+		t.Errorf("Unexpected problem %s", rec.Body)
+	}
+	if p.Cause != nil {
+		t.Errorf("Expected the cause to be kept server side but got %v", p.Cause)
+	}
+
+	exposeCauses(t)
+	rec = httptest.NewRecorder()
+	problemServer(err).ServeHTTP(rec, req)
+	p = gotransport.Problem{}
+	json.Unmarshal(rec.Body.Bytes(), &p)
+	if p.Cause == nil || p.Cause.Detail != "unexpected EOF" {
+		t.Errorf("Expected the cause but got %v", p.Cause)
+	}
+
+	rec = httptest.NewRecorder()
+	problemServer(errors.New("dial tcp 10.0.0.1:5432: connection refused")).ServeHTTP(rec, req)
+	p = gotransport.Problem{}
+	if json.Unmarshal(rec.Body.Bytes(), &p); rec.Code != 500 || p.Detail != http.StatusText(500) {
+		t.Errorf("Expected the detail of the server error to be generic but got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestProblemResponseDecoder(t *testing.T) {
+	exposeCauses(t)
+	upstream := goerrors.Wrap(errors.New("core timeout"), "insufficient_funds", "balance 10 < 100", 422).(*goerrors.Error).WithMetadata("account", "123")
+	server := httptest.NewServer(problemServer(upstream))
+	defer server.Close()
+
+	client := gotransport.NewClient(
+		http.MethodGet,
+		mustParse(server.URL),
+		gotransport.EncodeJSONRequest,
+		gotransport.ProblemResponseDecoder(gotransport.DecodeJSONResponse[balance]),
+	)
+	_, err := client.Endpoint()(context.Background(), struct{}{})
+	if !errors.Is(err, errInsufficientFunds) {
+		t.Fatalf("Expected the typed error to survive but got %v", err)
+	}
+	var e *goerrors.Error
+	errors.As(err, &e)
+	if e.Code != 422 || e.Detail != "balance 10 < 100" || e.Metadata["account"] != "123" {
+		t.Errorf("Unexpected error %+v", e)
+	}
+	if cause := goerrors.Cause(err); cause.(*goerrors.Error).Detail != "core timeout" {
+		t.Errorf("Expected the cause but got %v", cause)
+	}
+}
+
+func TestDefaultErrorEncoderStatusCode(t *testing.T) {
+	// *errors.Error is a StatusCoder and a json.Marshaler: DefaultErrorEncoder
+	// responds its Code, 500 for the codes that are not error status codes,
+	// with its JSON form without cause
+	for _, tc := range []struct {
+		err    error
+		status int
+	}{
+		{goerrors.Wrap(io.ErrUnexpectedEOF, "no_account", "no account", 404), 404},
+		{goerrors.New("pending", "", 202), 500},
+	} {
+		rec := httptest.NewRecorder()
+		gotransport.DefaultErrorEncoder(context.Background(), tc.err, rec)
+		if rec.Code != tc.status || rec.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+			t.Errorf("%v: unexpected response %d %v", tc.err, rec.Code, rec.Header())
+		}
+		if strings.Contains(rec.Body.String(), "cause") {
+			t.Errorf("Expected the cause to be kept server side but got %s", rec.Body)
+		}
+	}
+}
+
+// exposeCauses sets errors.ExposeCauses until the end of the test.
+func exposeCauses(t *testing.T) {
+	goerrors.ExposeCauses = true
+	t.Cleanup(func() { goerrors.ExposeCauses = false })
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"time"
+
+	"github.com/ThomasNguyenGitHub/go/log"
 )
 
 const (
@@ -124,6 +126,33 @@ func GetRequestContext(ctx context.Context) RequestContext {
 	}
 }
 
+func init() {
+	log.RegisterContextExtractor(RequestContextFields)
+}
+
+// RequestContextFields returns the log fields of the RequestContext held by ctx:
+// the request ID, user ID, channel and device ID, when set. It is registered as
+// a log context extractor, so they are added to the entries logged with
+// log.WithContext(ctx).
+func RequestContextFields(ctx context.Context) log.Fields {
+	var fields log.Fields
+	for key, value := range map[string]string{
+		log.FieldKeyRequestID: GetString(ctx, ContextKeyRequestXRequestID),
+		log.FieldKeyUserID:    GetString(ctx, ContextKeyUserID),
+		log.FieldKeyChannel:   GetString(ctx, ContextKeyChannel),
+		log.FieldKeyDeviceID:  GetString(ctx, ContextKeyDeviceID),
+	} {
+		if value == "" {
+			continue
+		}
+		if fields == nil {
+			fields = log.Fields{}
+		}
+		fields[key] = value
+	}
+	return fields
+}
+
 func SetHeader(r *http.Request, key string, value ...string) {
 	r.Header[key] = value
 }
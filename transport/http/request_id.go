@@ -0,0 +1,28 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ThomasNguyenGitHub/go/correlation"
+)
+
+// RequestIDMiddleware returns an http.Handler serving the requests with next,
+// with the request ID of their X-Request-Id header, or a generated one, in
+// their context and echoed in the X-Request-Id header of their response.
+// The Server does the same for its requests.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, id := correlation.Ensure(r.Context(), r.Header.Get(correlation.HeaderRequestID))
+		w.Header().Set(correlation.HeaderRequestID, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// setRequestID forwards the request ID of ctx in the header h, unless it
+// already holds one.
+func setRequestID(ctx context.Context, h http.Header) {
+	if id := correlation.IDFromContext(ctx); id != "" && h.Get(correlation.HeaderRequestID) == "" {
+		h.Set(correlation.HeaderRequestID, id)
+	}
+}
@@ -0,0 +1,81 @@
+package http_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ThomasNguyenGitHub/go/correlation"
+	gotransport "github.com/ThomasNguyenGitHub/go/transport/http"
+)
+
+func TestServerRequestID(t *testing.T) {
+	var seen string
+	server := gotransport.NewServer(
+		func(ctx context.Context, _ interface{}) (interface{}, error) {
+			seen = correlation.IDFromContext(ctx)
+			return struct{}{}, nil
+		},
+		func(context.Context, *http.Request) (interface{}, error) { return nil, nil },
+		gotransport.EncodeJSONResponse,
+	)
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if seen == "" || rec.Header().Get(correlation.HeaderRequestID) != seen {
+		t.Errorf("Expected the generated ID %q to be echoed but got %v", seen, rec.Header())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(correlation.HeaderRequestID, "req-1")
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if seen != "req-1" || rec.Header().Get(correlation.HeaderRequestID) != "req-1" {
+		t.Errorf("Expected the incoming ID but got %q %v", seen, rec.Header())
+	}
+}
+
+func TestClientRequestID(t *testing.T) {
+	var forwarded string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get(correlation.HeaderRequestID)
+	}))
+	defer upstream.Close()
+
+	client := gotransport.NewClient(
+		http.MethodGet,
+		mustParse(upstream.URL),
+		gotransport.EncodeJSONRequest,
+		func(context.Context, *http.Response) (interface{}, error) { return nil, nil },
+	)
+	ctx := correlation.ContextWithID(context.Background(), "req-1")
+	if _, err := client.Endpoint()(ctx, struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if forwarded != "req-1" {
+		t.Errorf("Expected the client to forward the ID but got %q", forwarded)
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, nil)
+	resp, err := gotransport.NewOutboundClient().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if forwarded != "req-1" {
+		t.Errorf("Expected the outbound client to forward the ID but got %q", forwarded)
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var seen string
+	handler := gotransport.RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = correlation.IDFromContext(r.Context())
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if seen == "" || rec.Header().Get(correlation.HeaderRequestID) != seen {
+		t.Errorf("Expected the generated ID %q to be echoed but got %v", seen, rec.Header())
+	}
+}
@@ -2,6 +2,8 @@ package http
 
 import (
 	"context"
+	"github.com/ThomasNguyenGitHub/go/correlation"
+	"github.com/ThomasNguyenGitHub/go/idempotency"
 	"github.com/ThomasNguyenGitHub/go/util"
 	"net/http"
 )
@@ -44,11 +46,25 @@ func SetRequestHeader(key, val string) RequestFunc {
 	}
 }
 
+// PopulateIdempotencyKey is a RequestFunc storing the Idempotency-Key header
+// of the request in the context, for the idempotency middleware, scoped by
+// the user ID header.
+func PopulateIdempotencyKey(ctx context.Context, r *http.Request) context.Context {
+	if key := r.Header.Get(IdempotencyKeyHeader); key != "" {
+		ctx = idempotency.ContextWithKey(ctx, key)
+		if userID := r.Header.Get(HeaderUserID); userID != "" {
+			ctx = idempotency.ContextWithScope(ctx, userID)
+		}
+	}
+	return ctx
+}
+
 // PopulateRequestContext is a RequestFunc that populates several values into
 // the context from the HTTP request. Those values may be extracted using the
 // corresponding ContextKey type in this package.
 func PopulateRequestContext(ctx context.Context, r *http.Request) context.Context {
 	ipRequest, _ := util.GetIP(r)
+	ctx, requestID := correlation.Ensure(ctx, r.Header.Get(correlation.HeaderRequestID))
 	for k, v := range map[contextKey]string{
 		ContextKeyRequestMethod:          r.Method,
 		ContextKeyRequestURI:             r.RequestURI,
@@ -61,7 +77,7 @@ func PopulateRequestContext(ctx context.Context, r *http.Request) context.Contex
 		ContextKeyRequestAuthorization:   r.Header.Get("Authorization"),
 		ContextKeyRequestReferer:         r.Header.Get("Referer"),
 		ContextKeyRequestUserAgent:       r.Header.Get("User-Agent"),
-		ContextKeyRequestXRequestID:      r.Header.Get("X-Request-Id"),
+		ContextKeyRequestXRequestID:      requestID,
 		ContextKeyRequestAccept:          r.Header.Get("Accept"),
 		ContextKeyAccessToken:            r.Header.Get(HeaderAccessToken),
 		ContextKeyAppID:                  r.Header.Get(HeaderAppID),
@@ -146,7 +162,8 @@ const (
 	ContextKeyRequestUserAgent
 
 	// ContextKeyRequestXRequestID is populated in the context by
-	// PopulateRequestContext. Its value is r.Header.Get("X-Request-Id").
+	// PopulateRequestContext. Its value is r.Header.Get("X-Request-Id"), or
+	// the ID generated by the correlation package when it is empty.
 	ContextKeyRequestXRequestID
 
 	// ContextKeyRequestAccept is populated in the context by
@@ -2,9 +2,11 @@ package http_test
 
 import (
 	"context"
+	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/ThomasNguyenGitHub/go/idempotency"
 	gotransport "github.com/ThomasNguyenGitHub/go/transport/http"
 )
 
@@ -28,3 +30,13 @@ func TestSetContentType(t *testing.T) {
 		t.Errorf("want %q, have %q", want, have)
 	}
 }
+
+func TestPopulateIdempotencyKey(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set(gotransport.IdempotencyKeyHeader, "k1")
+	r.Header.Set(gotransport.HeaderUserID, "alice")
+	ctx := gotransport.PopulateIdempotencyKey(context.Background(), r)
+	if key, scope := idempotency.KeyFromContext(ctx), idempotency.ScopeFromContext(ctx); key != "k1" || scope != "alice" {
+		t.Errorf("Expected the key k1 of alice, have %q of %q", key, scope)
+	}
+}
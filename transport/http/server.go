@@ -14,9 +14,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ThomasNguyenGitHub/go/correlation"
 	"github.com/ThomasNguyenGitHub/go/endpoint"
 	"github.com/ThomasNguyenGitHub/go/log"
 	"github.com/ThomasNguyenGitHub/go/transport"
+	"github.com/ThomasNguyenGitHub/go/validation"
 )
 
 // Server wraps an endpoint and implements http.Handler.
@@ -29,6 +31,7 @@ type Server struct {
 	errorEncoder ErrorEncoder
 	finalizer    []ServerFinalizerFunc
 	errorHandler transport.ErrorHandler
+	validator    ValidateRequestFunc
 }
 
 // NewServer constructs a new server, which implements http.Handler and wraps
@@ -44,7 +47,8 @@ func NewServer(
 		dec:          dec,
 		enc:          enc,
 		errorEncoder: DefaultErrorEncoder,
-		errorHandler: transport.NewLogErrorHandler(log.Logger{}),
+		errorHandler: transport.NewLogErrorHandler(log.NewNopLogger()),
+		validator:    ValidateRequest,
 	}
 	for _, option := range options {
 		option(s)
@@ -81,7 +85,7 @@ func ServerErrorEncoder(ee ErrorEncoder) ServerOption {
 // custom ServerErrorEncoder or ServerFinalizer, both of which have access to
 // the context.
 // Deprecated: Use ServerErrorHandler instead.
-func ServerErrorLogger(logger log.Logger) ServerOption {
+func ServerErrorLogger(logger *log.Logger) ServerOption {
 	return func(s *Server) { s.errorHandler = transport.NewLogErrorHandler(logger) }
 }
 
@@ -100,12 +104,20 @@ func ServerFinalizer(f ...ServerFinalizerFunc) ServerOption {
 	return func(s *Server) { s.finalizer = append(s.finalizer, f...) }
 }
 
+// ServerValidator sets the function validating the decoded requests. By
+// default, ValidateRequest is used. A nil validator disables validation.
+func ServerValidator(v ValidateRequestFunc) ServerOption {
+	return func(s *Server) { s.validator = v }
+}
+
 // ServeHTTP implements http.Handler.
 func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx, requestID := correlation.Ensure(r.Context(), r.Header.Get(correlation.HeaderRequestID))
+	w.Header().Set(correlation.HeaderRequestID, requestID)
 
+	var iw *interceptingWriter
 	if len(s.finalizer) > 0 {
-		iw := &interceptingWriter{w, http.StatusOK, 0}
+		iw = &interceptingWriter{ResponseWriter: w, code: http.StatusOK}
 		defer func() {
 			ctx = context.WithValue(ctx, ContextKeyResponseHeaders, iw.Header())
 			ctx = context.WithValue(ctx, ContextKeyResponseSize, iw.written)
@@ -119,6 +131,10 @@ func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	for _, f := range s.before {
 		ctx = f(ctx, r)
 	}
+	if record, ok := ctx.Value(accessLogContextKey{}).(*accessRecord); ok && iw != nil {
+		// captures the response body for the AccessLog
+		iw.body = record.response
+	}
 
 	request, err := s.dec(ctx, r)
 	if err != nil {
@@ -127,6 +143,14 @@ func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if s.validator != nil {
+		if err := s.validator(ctx, request); err != nil {
+			s.errorHandler.Handle(ctx, err)
+			s.errorEncoder(ctx, err, w)
+			return
+		}
+	}
+
 	response, err := s.e(ctx, request)
 	if err != nil {
 		s.errorHandler.Handle(ctx, err)
@@ -158,6 +182,33 @@ type ErrorEncoder func(ctx context.Context, err error, w http.ResponseWriter)
 // provided in the context under keys with the ContextKeyResponse prefix.
 type ServerFinalizerFunc func(ctx context.Context, code int, r *http.Request)
 
+// ValidateRequestFunc validates a decoded request.
+type ValidateRequestFunc func(ctx context.Context, request interface{}) error
+
+// ValidateRequest is a ValidateRequestFunc checking the rules of the
+// validate struct tags of the request with the validation package, then
+// calling Validate when the request implements Validator. The messages of a
+// *validation.Error are in the Language of the RequestContext, and the other
+// errors of Validate are returned as a *BadRequestError.
+func ValidateRequest(ctx context.Context, request interface{}) error {
+	err := validation.Validate(request)
+	if err == nil {
+		if v, ok := validator(request); ok {
+			err = v.Validate()
+		}
+	}
+	switch verr := err.(type) {
+	case nil:
+		return nil
+	case *validation.Error:
+		if language := GetRequestContext(ctx).Language; language != "" {
+			return verr.Localize(language)
+		}
+		return verr
+	}
+	return &BadRequestError{err}
+}
+
 // NopRequestDecoder is a DecodeRequestFunc that can be used for requests that do not
 // need to be decoded, and simply returns nil, nil.
 func NopRequestDecoder(ctx context.Context, r *http.Request) (interface{}, error) {
@@ -237,6 +288,8 @@ type interceptingWriter struct {
 	http.ResponseWriter
 	code    int
 	written int64
+	// body, when set, captures the written body
+	body *cappedBuffer
 }
 
 // WriteHeader may not be explicitly called, so care must be taken to
@@ -249,33 +302,17 @@ func (w *interceptingWriter) WriteHeader(code int) {
 func (w *interceptingWriter) Write(p []byte) (int, error) {
 	n, err := w.ResponseWriter.Write(p)
 	w.written += int64(n)
-	return n, err
-}
-func httpDo(ctx context.Context, req *http.Request, f func(*http.Response, error) error) error {
-	// Run the HTTP request in a goroutine and pass the response to f.
-	c := make(chan error, 1)
-	req = req.WithContext(ctx)
-	go func() { c <- f(http.DefaultClient.Do(req)) }()
-	select {
-	case <-ctx.Done():
-		<-c // Wait for f to return.
-		return ctx.Err()
-	case err := <-c:
-		return err
+	if w.body != nil {
+		w.body.Write(p[:n])
 	}
+	return n, err
 }
+
+// ExecuteHTTP sends the request with DefaultOutboundClient and decodes the
+// JSON body of the response into target. Responses with a status code of 400
+// or more are returned as an *errors.Error.
 func ExecuteHTTP(ctx context.Context, req *http.Request, target interface{}) error {
-	err := httpDo(ctx, req, func(resp *http.Response, err error) error {
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close()
-		if err := json.NewDecoder(resp.Body).Decode(&target); err != nil {
-			return err
-		}
-		return nil
-	})
-	return err
+	return DefaultOutboundClient.Execute(ctx, req, target)
 }
 
 func GetTokenAPIm(ctx context.Context) (*APIsToken, error) {
@@ -366,6 +403,8 @@ func ExecuteClient(urlPath, method string, body io.Reader) (*http.Request, error
 	return req, nil
 }
 
+// LoggingMiddleware rejects the requests without an Authorization header of
+// at least 12 chars. It logs nothing, see AccessLog for access logging.
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		token := r.Header.Get("Authorization")
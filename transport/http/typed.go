@@ -0,0 +1,114 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+
+	"github.com/ThomasNguyenGitHub/go/endpoint"
+	"github.com/ThomasNguyenGitHub/go/validation"
+)
+
+// Validator is implemented by the requests validating themselves, whether
+// by value or by pointer. ValidateRequest calls Validate after checking the
+// rules of the tags.
+type Validator interface {
+	Validate() error
+}
+
+// BadRequestError is the error of the requests failing to be decoded or
+// validated. DefaultErrorEncoder encodes it with a 400 status code.
+type BadRequestError struct {
+	Err error
+}
+
+func (e *BadRequestError) Error() string { return e.Err.Error() }
+
+// Unwrap returns the decoding or validation error.
+func (e *BadRequestError) Unwrap() error { return e.Err }
+
+// StatusCode implements StatusCoder.
+func (e *BadRequestError) StatusCode() int { return http.StatusBadRequest }
+
+// NewTypedServer constructs a server for a typed endpoint, decoding the JSON
+// body of the requests into a Req with DecodeJSONRequest and encoding the
+// responses with EncodeJSONResponse. It panics when the validate tags of Req
+// are invalid, see validation.Check.
+func NewTypedServer[Req, Resp any](e endpoint.Typed[Req, Resp], options ...ServerOption) *Server {
+	if err := validation.Check(new(Req)); err != nil {
+		panic(err)
+	}
+	return NewServer(e.Endpoint(), DecodeJSONRequest[Req], EncodeJSONResponse, options...)
+}
+
+// DecodeJSONRequest is a DecodeRequestFunc decoding the JSON body of the
+// request into a Req, an empty body being the zero Req. Decoding errors are
+// returned as a *BadRequestError. The request is validated by the server,
+// see ServerValidator.
+func DecodeJSONRequest[Req any](_ context.Context, r *http.Request) (interface{}, error) {
+	var req Req
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			return nil, &BadRequestError{err}
+		}
+	}
+	return req, nil
+}
+
+// validator returns the request as a Validator, whether it or a pointer to
+// it implements Validator. Nil requests have no Validator.
+func validator(request interface{}) (Validator, bool) {
+	if v, ok := request.(Validator); ok {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil, false
+		}
+		return v, true
+	}
+	rv := reflect.ValueOf(request)
+	if !rv.IsValid() {
+		return nil, false
+	}
+	ptr := reflect.New(rv.Type())
+	ptr.Elem().Set(rv)
+	v, ok := ptr.Interface().(Validator)
+	return v, ok
+}
+
+// DecodeJSONResponse is a DecodeResponseFunc decoding the JSON body of the
+// response into a Resp, no content being the zero Resp. Responses with a
+// status code of 400 or more are returned as an *errors.Error.
+func DecodeJSONResponse[Resp any](_ context.Context, r *http.Response) (interface{}, error) {
+	var resp Resp
+	if r.StatusCode >= 400 {
+		return nil, responseError(r, DefaultOutboundMaxErrorBody)
+	}
+	if r.StatusCode == http.StatusNoContent {
+		return resp, nil
+	}
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// TypedClient wraps a Client with typed requests and responses.
+type TypedClient[Req, Resp any] struct {
+	*Client
+}
+
+// NewTypedClient constructs a typed client for a single remote method,
+// encoding the requests with EncodeJSONRequest and decoding the responses
+// with DecodeJSONResponse.
+func NewTypedClient[Req, Resp any](method string, tgt *url.URL, options ...ClientOption) *TypedClient[Req, Resp] {
+	return &TypedClient[Req, Resp]{
+		Client: NewClient(method, tgt, EncodeJSONRequest, DecodeJSONResponse[Resp], options...),
+	}
+}
+
+// Endpoint returns a typed endpoint calling the remote HTTP endpoint.
+func (c TypedClient[Req, Resp]) Endpoint() endpoint.Typed[Req, Resp] {
+	return endpoint.TypedEndpoint[Req, Resp](c.Client.Endpoint())
+}
@@ -0,0 +1,127 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	goerrors "github.com/ThomasNguyenGitHub/go/errors"
+	gotransport "github.com/ThomasNguyenGitHub/go/transport/http"
+)
+
+type sumRequest struct {
+	A, B int
+}
+
+func (r sumRequest) Validate() error {
+	if r.A < 0 || r.B < 0 {
+		return errors.New("negative operand")
+	}
+	return nil
+}
+
+type sumResponse struct {
+	Sum int `json:"sum"`
+}
+
+func sum(_ context.Context, req sumRequest) (sumResponse, error) {
+	return sumResponse{req.A + req.B}, nil
+}
+
+func TestTypedServer(t *testing.T) {
+	server := httptest.NewServer(gotransport.NewTypedServer(sum))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		body, expected string
+		code           int
+	}{
+		{`{"A":1,"B":2}`, `{"sum":3}`, http.StatusOK},
+		{``, `{"sum":0}`, http.StatusOK},
+		{`{"A":"1"}`, `json: cannot unmarshal`, http.StatusBadRequest},
+		{`{"A":-1}`, `negative operand`, http.StatusBadRequest},
+	} {
+		resp, err := http.Post(server.URL, "application/json", strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.code || !strings.Contains(string(body), tc.expected) {
+			t.Errorf("%s: expected %d %s but got %d %s", tc.body, tc.code, tc.expected, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestTypedClient(t *testing.T) {
+	server := httptest.NewServer(gotransport.NewTypedServer(sum))
+	defer server.Close()
+
+	client := gotransport.NewTypedClient[sumRequest, sumResponse]("POST", mustParse(server.URL))
+	resp, err := client.Endpoint()(context.Background(), sumRequest{A: 2, B: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Sum != 5 {
+		t.Errorf("Expected 5 but got %d", resp.Sum)
+	}
+
+	_, err = client.Endpoint()(context.Background(), sumRequest{A: -1})
+	var e *goerrors.Error
+	if !errors.As(err, &e) || e.Code != http.StatusBadRequest || e.Detail != "negative operand" {
+		t.Errorf("Expected a 400 error but got %v", err)
+	}
+}
+
+type transferAmount struct {
+	Amount int `json:"amount" validate:"min=1"`
+}
+
+func (r *transferAmount) Validate() error {
+	if r.Amount%1000 != 0 {
+		return errors.New("amount not a multiple of 1000")
+	}
+	return nil
+}
+
+func TestTypedServerValidation(t *testing.T) {
+	server := httptest.NewServer(gotransport.NewTypedServer(func(context.Context, transferAmount) (struct{}, error) {
+		return struct{}{}, nil
+	}))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		body, expected string
+		code           int
+	}{
+		{`{"amount":2000}`, `{}`, http.StatusOK},
+		// the tag rules are checked first, then Validate
+		{`{"amount":-1}`, `amount must be at least 1`, http.StatusBadRequest},
+		{`{"amount":1500}`, `amount not a multiple of 1000`, http.StatusBadRequest},
+	} {
+		resp, err := http.Post(server.URL, "application/json", strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.code || !strings.Contains(string(body), tc.expected) {
+			t.Errorf("%s: expected %d %s but got %d %s", tc.body, tc.code, tc.expected, resp.StatusCode, body)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected NewTypedServer to reject the invalid tags")
+		}
+	}()
+	gotransport.NewTypedServer(func(context.Context, struct {
+		N int `validate:"gte=1"`
+	}) (struct{}, error) {
+		return struct{}{}, nil
+	})
+}
@@ -0,0 +1,103 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ThomasNguyenGitHub/go/endpoint"
+	gotransport "github.com/ThomasNguyenGitHub/go/transport/http"
+	"github.com/ThomasNguyenGitHub/go/validation"
+)
+
+type transferRequest struct {
+	Account string `json:"account" validate:"required,len=10"`
+	Mobile  string `json:"mobile" validate:"phone"`
+}
+
+func decodeTransferRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req transferRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	return req, err
+}
+
+func TestServerValidation(t *testing.T) {
+	handler := gotransport.NewServer(
+		endpoint.Nop,
+		decodeTransferRequest,
+		gotransport.EncodeJSONResponse,
+		gotransport.ServerBefore(gotransport.PopulateRequestContext),
+	)
+
+	for _, tc := range []struct {
+		language, body string
+		code           int
+		expected       string
+	}{
+		{"", `{"account":"0123456789"}`, http.StatusOK, ""},
+		{"", `{"mobile":"123"}`, http.StatusBadRequest, "account is required; mobile must be a valid phone number"},
+		{gotransport.HeaderLanguageVN, `{"account":"123"}`, http.StatusBadRequest, "account phải có độ dài 10"},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+		req.Header.Set(gotransport.HeaderLanguage, tc.language)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tc.code {
+			t.Errorf("%s: expected %d but got %d", tc.body, tc.code, rec.Code)
+		}
+		if tc.expected == "" {
+			continue
+		}
+		var body struct {
+			Detail string
+			Fields []struct{ Field, Message string }
+		}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		if body.Detail != tc.expected || len(body.Fields) == 0 {
+			t.Errorf("%s: expected %q but got %s", tc.body, tc.expected, rec.Body)
+		}
+	}
+
+	disabled := gotransport.NewServer(endpoint.Nop, decodeTransferRequest, gotransport.EncodeJSONResponse, gotransport.ServerValidator(nil))
+	rec := httptest.NewRecorder()
+	disabled.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected no validation but got %d", rec.Code)
+	}
+}
+
+type referenceRequest struct {
+	// omitempty is a rule of another validator
+	Reference string `json:"reference" validate:"omitempty,len=6"`
+}
+
+func TestServerValidationInvalidTag(t *testing.T) {
+	handler := validation.InvalidTagHandler
+	var reported int
+	validation.InvalidTagHandler = func(error) { reported++ }
+	t.Cleanup(func() { validation.InvalidTagHandler = handler })
+
+	server := gotransport.NewServer(
+		endpoint.Nop,
+		func(_ context.Context, r *http.Request) (interface{}, error) {
+			var req referenceRequest
+			err := json.NewDecoder(r.Body).Decode(&req)
+			return req, err
+		},
+		gotransport.EncodeJSONResponse,
+	)
+	for body, code := range map[string]int{`{"reference":"ABC123"}`: http.StatusOK, `{"reference":"ABC"}`: http.StatusBadRequest} {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		if rec.Code != code {
+			t.Errorf("%s: expected %d but got %d %s", body, code, rec.Code, rec.Body)
+		}
+	}
+	if reported != 1 {
+		t.Errorf("Expected the invalid tag to be reported once but got %d", reported)
+	}
+}
@@ -3,8 +3,12 @@ package nats
 import (
 	"context"
 	"encoding/json"
-	"github.com/nats-io/nats.go"
 	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/ThomasNguyenGitHub/go/correlation"
+	"github.com/ThomasNguyenGitHub/go/endpoint"
 )
 
 // Publisher wraps a URL and provides a method that implements endpoint.Endpoint.
@@ -75,8 +79,14 @@ func (p Publisher) Endpoint() endpoint.Endpoint {
 		for _, f := range p.before {
 			ctx = f(ctx, &msg)
 		}
+		if id := correlation.IDFromContext(ctx); id != "" && msg.Header.Get(correlation.MetadataKeyRequestID) == "" {
+			if msg.Header == nil {
+				msg.Header = nats.Header{}
+			}
+			msg.Header.Set(correlation.MetadataKeyRequestID, id)
+		}
 
-		resp, err := p.publisher.RequestWithContext(ctx, msg.Subject, msg.Data)
+		resp, err := p.publisher.RequestMsgWithContext(ctx, &msg)
 		if err != nil {
 			return nil, err
 		}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	natstransport "github.com/ThomasNguyenGitHub/go/transport/nats"
+	"github.com/nats-io/nats.go"
 )
 
 func TestPublisher(t *testing.T) {
@@ -4,8 +4,12 @@ import (
 	"context"
 	"encoding/json"
 
+	"github.com/nats-io/nats.go"
+
+	"github.com/ThomasNguyenGitHub/go/correlation"
 	"github.com/ThomasNguyenGitHub/go/endpoint"
 	"github.com/ThomasNguyenGitHub/go/log"
+	"github.com/ThomasNguyenGitHub/go/recovery"
 	"github.com/ThomasNguyenGitHub/go/transport"
 )
 
@@ -18,6 +22,7 @@ type Subscriber struct {
 	after        []SubscriberResponseFunc
 	errorEncoder ErrorEncoder
 	finalizer    []SubscriberFinalizerFunc
+	recoverer    *recovery.Recoverer
 	errorHandler transport.ErrorHandler
 }
 
@@ -70,7 +75,7 @@ func SubscriberErrorEncoder(ee ErrorEncoder) SubscriberOption {
 // of error handling, including logging in more detail, should be performed in a
 // custom SubscriberErrorEncoder which has access to the context.
 // Deprecated: Use SubscriberErrorHandler instead.
-func SubscriberErrorLogger(logger log.Logger) SubscriberOption {
+func SubscriberErrorLogger(logger *log.Logger) SubscriberOption {
 	return func(s *Subscriber) { s.errorHandler = transport.NewLogErrorHandler(logger) }
 }
 
@@ -88,11 +93,20 @@ func SubscriberFinalizer(f ...SubscriberFinalizerFunc) SubscriberOption {
 	return func(s *Subscriber) { s.finalizer = f }
 }
 
+// SubscriberRecovery recovers the panics of the subscriber, encoding them
+// with the error encoder as an errors.InternalServerError after reporting
+// them to reporters, or to recovery.DefaultReporters when none is given. By
+// default, the panics are not recovered.
+func SubscriberRecovery(reporters ...recovery.Reporter) SubscriberOption {
+	return func(s *Subscriber) { s.recoverer = recovery.New(reporters...) }
+}
+
 // ServeMsg provides nats.MsgHandler.
 func (s Subscriber) ServeMsg(nc *nats.Conn) func(msg *nats.Msg) {
 	return func(msg *nats.Msg) {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
+		ctx, _ = correlation.Ensure(ctx, msg.Header.Get(correlation.MetadataKeyRequestID))
 
 		if len(s.finalizer) > 0 {
 			defer func() {
@@ -102,6 +116,18 @@ func (s Subscriber) ServeMsg(nc *nats.Conn) func(msg *nats.Msg) {
 			}()
 		}
 
+		if s.recoverer != nil {
+			defer func() {
+				if v := recover(); v != nil {
+					err := s.recoverer.Recover(ctx, v)
+					s.errorHandler.Handle(ctx, err)
+					if msg.Reply != "" {
+						s.errorEncoder(ctx, err, msg.Reply, nc)
+					}
+				}
+			}()
+		}
+
 		for _, f := range s.before {
 			ctx = f(ctx, msg)
 		}
@@ -161,18 +187,19 @@ func NopRequestDecoder(_ context.Context, _ *nats.Msg) (interface{}, error) {
 
 // EncodeJSONResponse is a EncodeResponseFunc that serializes the response as a
 // JSON object to the subscriber reply. Many JSON-over services can use it as
-// a sensible default.
-func EncodeJSONResponse(_ context.Context, reply string, nc *nats.Conn, response interface{}) error {
+// a sensible default. The reply echoes the request ID of ctx.
+func EncodeJSONResponse(ctx context.Context, reply string, nc *nats.Conn, response interface{}) error {
 	b, err := json.Marshal(response)
 	if err != nil {
 		return err
 	}
 
-	return nc.Publish(reply, b)
+	return nc.PublishMsg(replyMsg(ctx, reply, b))
 }
 
-// DefaultErrorEncoder writes the error to the subscriber reply.
-func DefaultErrorEncoder(_ context.Context, err error, reply string, nc *nats.Conn) {
+// DefaultErrorEncoder writes the error to the subscriber reply, echoing the
+// request ID of ctx.
+func DefaultErrorEncoder(ctx context.Context, err error, reply string, nc *nats.Conn) {
 	logger := log.NewNopLogger()
 
 	type Response struct {
@@ -185,11 +212,21 @@ func DefaultErrorEncoder(_ context.Context, err error, reply string, nc *nats.Co
 
 	b, err := json.Marshal(response)
 	if err != nil {
-		logger.Log("err", err)
+		logger.WithError(err).Error("Unable to encode the error")
 		return
 	}
 
-	if err := nc.Publish(reply, b); err != nil {
-		logger.Log("err", err)
+	if err := nc.PublishMsg(replyMsg(ctx, reply, b)); err != nil {
+		logger.WithError(err).Error("Unable to publish the error")
+	}
+}
+
+// replyMsg returns the reply Msg of data, with the request ID of ctx.
+func replyMsg(ctx context.Context, reply string, data []byte) *nats.Msg {
+	msg := &nats.Msg{Subject: reply, Data: data}
+	if id := correlation.IDFromContext(ctx); id != "" {
+		msg.Header = nats.Header{}
+		msg.Header.Set(correlation.MetadataKeyRequestID, id)
 	}
+	return msg
 }
@@ -10,8 +10,11 @@ import (
 	"time"
 
 	"github.com/ThomasNguyenGitHub/go/endpoint"
+	"github.com/ThomasNguyenGitHub/go/recovery"
+	"github.com/ThomasNguyenGitHub/go/transport"
 	natstransport "github.com/ThomasNguyenGitHub/go/transport/nats"
 	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
 )
 
 type TestResponse struct {
@@ -528,3 +531,27 @@ func testRequest(t *testing.T, nc *nats.Conn, handler *natstransport.Subscriber)
 
 	return resp
 }
+
+func TestSubscriberRecovery(t *testing.T) {
+	var (
+		reported *recovery.Panic
+		handled  error
+	)
+	handler := natstransport.NewSubscriber(
+		func(context.Context, interface{}) (interface{}, error) { panic("boom") },
+		func(context.Context, *nats.Msg) (interface{}, error) { return struct{}{}, nil },
+		func(context.Context, string, *nats.Conn, interface{}) error { return nil },
+		natstransport.SubscriberErrorHandler(transport.ErrorHandlerFunc(func(_ context.Context, err error) { handled = err })),
+		natstransport.SubscriberRecovery(recovery.ReporterFunc(func(_ context.Context, p *recovery.Panic) { reported = p })),
+	)
+
+	// without reply, the panic is recovered without connection
+	handler.ServeMsg(nil)(&nats.Msg{Subject: "natstransport.test"})
+
+	if reported == nil || reported.Value != "boom" {
+		t.Errorf("Unexpected report %+v", reported)
+	}
+	if handled == nil {
+		t.Error("Expected the recovered panic to be handled")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"math/rand"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/ThomasNguyenGitHub/go/log"
@@ -28,17 +29,21 @@ type Manager struct {
 	network string
 	address string
 	after   AfterFunc
-	logger  log.Logger
+	logger  *log.Logger
 
 	takec chan net.Conn
 	putc  chan error
+	quitc chan struct{}
+	donec chan struct{}
+	quit  sync.Once
+	err   error
 }
 
 // NewManager returns a connection manager using the passed Dialer, network, and
 // address. The AfterFunc is used to control exponential backoff and retries.
-// The logger is used to log errors; pass a log.NopLogger if you don't care to
-// receive them. For normal use, prefer NewDefaultManager.
-func NewManager(d Dialer, network, address string, after AfterFunc, logger log.Logger) *Manager {
+// The logger is used to log errors; pass log.NewNopLogger() if you don't care
+// to receive them. For normal use, prefer NewDefaultManager.
+func NewManager(d Dialer, network, address string, after AfterFunc, logger *log.Logger) *Manager {
 	m := &Manager{
 		dialer:  d,
 		network: network,
@@ -48,6 +53,8 @@ func NewManager(d Dialer, network, address string, after AfterFunc, logger log.L
 
 		takec: make(chan net.Conn),
 		putc:  make(chan error),
+		quitc: make(chan struct{}),
+		donec: make(chan struct{}),
 	}
 	go m.loop()
 	return m
@@ -55,20 +62,37 @@ func NewManager(d Dialer, network, address string, after AfterFunc, logger log.L
 
 // NewDefaultManager is a helper constructor, suitable for most normal use in
 // real (non-test) code. It uses the real net.Dial and time.After functions.
-func NewDefaultManager(network, address string, logger log.Logger) *Manager {
+func NewDefaultManager(network, address string, logger *log.Logger) *Manager {
 	return NewManager(net.Dial, network, address, time.After, logger)
 }
 
-// Take yields the current connection. It may be nil.
+// Take yields the current connection. It may be nil, and is always nil once
+// the manager is closed.
 func (m *Manager) Take() net.Conn {
-	return <-m.takec
+	select {
+	case conn := <-m.takec:
+		return conn
+	case <-m.quitc:
+		return nil
+	}
 }
 
 // Put accepts an error that came from a previously yielded connection. If the
 // error is non-nil, the manager will invalidate the current connection and try
 // to reconnect, with exponential backoff. Putting a nil error is a no-op.
 func (m *Manager) Put(err error) {
-	m.putc <- err
+	select {
+	case m.putc <- err:
+	case <-m.quitc:
+	}
+}
+
+// Close stops the manager and closes the current connection, returning the
+// error of closing it.
+func (m *Manager) Close() error {
+	m.quit.Do(func() { close(m.quitc) })
+	<-m.donec
+	return m.err
 }
 
 // Write writes the passed data to the connection in a single Take/Put cycle.
@@ -83,6 +107,7 @@ func (m *Manager) Write(b []byte) (int, error) {
 }
 
 func (m *Manager) loop() {
+	defer close(m.donec)
 	var (
 		conn       = dial(m.dialer, m.network, m.address, m.logger) // may block slightly
 		connc      = make(chan net.Conn, 1)
@@ -116,19 +141,25 @@ func (m *Manager) loop() {
 
 		case err := <-m.putc:
 			if err != nil && conn != nil {
-				m.logger.Log("err", err)
+				m.logger.WithError(err).Errorf("Connection to %s failed", m.address)
 				conn.Close()
 				conn = nil                            // connection is bad
 				reconnectc = m.after(time.Nanosecond) // trigger immediately
 			}
+
+		case <-m.quitc:
+			if conn != nil {
+				m.err = conn.Close()
+			}
+			return
 		}
 	}
 }
 
-func dial(d Dialer, network, address string, logger log.Logger) net.Conn {
+func dial(d Dialer, network, address string, logger *log.Logger) net.Conn {
 	conn, err := d(network, address)
 	if err != nil {
-		logger.Log("err", err)
+		logger.WithError(err).Errorf("Unable to dial %s", address)
 		conn = nil // just to be sure
 	}
 	return conn
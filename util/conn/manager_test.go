@@ -124,8 +124,32 @@ func TestIssue292(t *testing.T) {
 	}
 }
 
+func TestManagerClose(t *testing.T) {
+	var (
+		dialconn = &mockConn{}
+		dialer   = func(string, string) (net.Conn, error) { return dialconn, nil }
+		mgr      = NewManager(dialer, "netw", "addr", time.After, log.NewNopLogger())
+	)
+	if conn := mgr.Take(); conn == nil {
+		t.Fatal("nil conn")
+	}
+	if err := mgr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadUint64(&dialconn.closed) != 1 {
+		t.Error("Expected the connection to be closed")
+	}
+	if conn := mgr.Take(); conn != nil {
+		t.Error("Expected a nil conn once closed")
+	}
+	mgr.Put(errors.New("ignored"))
+	if err := mgr.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
 type mockConn struct {
-	rd, wr uint64
+	rd, wr, closed uint64
 }
 
 func (c *mockConn) Read(b []byte) (n int, err error) {
@@ -138,7 +162,11 @@ func (c *mockConn) Write(b []byte) (n int, err error) {
 	return len(b), nil
 }
 
-func (c *mockConn) Close() error                       { return nil }
+func (c *mockConn) Close() error {
+	atomic.AddUint64(&c.closed, 1)
+	return nil
+}
+
 func (c *mockConn) LocalAddr() net.Addr                { return nil }
 func (c *mockConn) RemoteAddr() net.Addr               { return nil }
 func (c *mockConn) SetDeadline(t time.Time) error      { return nil }
@@ -7,6 +7,7 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 const (
@@ -28,7 +29,7 @@ var (
 		"token_access":          true,
 		"authorization_app_key": true,
 	}
-	loadCustomMaskingField = false
+	loadCustomMaskingField sync.Once
 )
 
 // Mask masks something
@@ -93,12 +94,7 @@ func MaskFieldsFromBytes(b []byte) map[string]interface{} {
 		log.Printf("Invalid JSON: %s", string(b))
 		return m
 	}
-	if !loadCustomMaskingField {
-		for _, v := range strings.Split(local.Getenv("MASKING_FIELDS"), ",") {
-			maskingFields[v] = true
-		}
-		loadCustomMaskingField = true
-	}
+	loadCustomMaskingField.Do(loadMaskingFields)
 	if err := json.Unmarshal(b, &m); err != nil {
 		return m
 	}
@@ -106,6 +102,22 @@ func MaskFieldsFromBytes(b []byte) map[string]interface{} {
 	return m
 }
 
+// IsMaskingField reports whether the values of the named field are masked,
+// the field being one of the default sensitive fields or listed in the
+// comma separated MASKING_FIELDS environment variable. Case is ignored.
+func IsMaskingField(name string) bool {
+	loadCustomMaskingField.Do(loadMaskingFields)
+	return maskingFields[strings.ToLower(name)]
+}
+
+func loadMaskingFields() {
+	for _, v := range strings.Split(local.Getenv("MASKING_FIELDS"), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			maskingFields[strings.ToLower(v)] = true
+		}
+	}
+}
+
 func MaskFieldsFromInterface(v interface{}) map[string]interface{} {
 	if v == nil {
 		return make(map[string]interface{})
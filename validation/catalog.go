@@ -0,0 +1,113 @@
+package validation
+
+import (
+	"strings"
+	"sync"
+)
+
+// Languages of the built-in messages
+const (
+	LanguageVN = "vi-VN"
+	LanguageEN = "en-US"
+)
+
+// DefaultLanguage is the language of the messages when the language of the
+// request is not set or has no messages.
+var DefaultLanguage = LanguageEN
+
+// invalidRule is the key of the message of the rules without message.
+const invalidRule = "invalid"
+
+// Catalog holds localized messages by language and key. It is safe for
+// concurrent use.
+type Catalog struct {
+	mu       sync.RWMutex
+	messages map[string]map[string]string
+}
+
+// NewCatalog returns a Catalog holding messages, by language and key.
+func NewCatalog(messages map[string]map[string]string) *Catalog {
+	c := &Catalog{messages: make(map[string]map[string]string)}
+	for language, m := range messages {
+		c.Register(language, m)
+	}
+	return c
+}
+
+// Register registers the messages of a language by key, replacing the
+// existing ones.
+func (c *Catalog) Register(language string, messages map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.messages[language] == nil {
+		c.messages[language] = make(map[string]string)
+	}
+	for key, message := range messages {
+		c.messages[language][key] = message
+	}
+}
+
+// Lookup returns the message of key in language.
+func (c *Catalog) Lookup(language, key string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	message, ok := c.messages[language][key]
+	return message, ok
+}
+
+// DefaultCatalog holds the message templates of the rules, keyed by rule
+// name. The templates may contain {field} and {param}.
+var DefaultCatalog = NewCatalog(map[string]map[string]string{
+	LanguageEN: {
+		invalidRule: "{field} is invalid",
+		"required":  "{field} is required",
+		"len":       "{field} must have a length of {param}",
+		"min":       "{field} must be at least {param}",
+		"max":       "{field} must be at most {param}",
+		"regex":     "{field} has an invalid format",
+		"enum":      "{field} must be one of {param}",
+		"phone":     "{field} must be a valid phone number",
+		"email":     "{field} must be a valid email address",
+		"idcard":    "{field} must be a valid ID card number",
+	},
+	LanguageVN: {
+		invalidRule: "{field} không hợp lệ",
+		"required":  "{field} không được để trống",
+		"len":       "{field} phải có độ dài {param}",
+		"min":       "{field} phải lớn hơn hoặc bằng {param}",
+		"max":       "{field} phải nhỏ hơn hoặc bằng {param}",
+		"regex":     "{field} không đúng định dạng",
+		"enum":      "{field} phải là một trong các giá trị {param}",
+		"phone":     "{field} không phải là số điện thoại hợp lệ",
+		"email":     "{field} không phải là địa chỉ email hợp lệ",
+		"idcard":    "{field} không phải là số CMND/CCCD hợp lệ",
+	},
+})
+
+// RegisterMessages registers the message templates of rules in a language
+// in DefaultCatalog, replacing the existing ones. The templates may contain
+// {field} and {param}.
+func RegisterMessages(language string, messages map[string]string) {
+	DefaultCatalog.Register(language, messages)
+}
+
+// message returns the message of the rule failed by field in language,
+// falling back to DefaultLanguage.
+func message(language, field, rule, param string) string {
+	template := ""
+	for _, lang := range []string{language, DefaultLanguage} {
+		if template, _ = DefaultCatalog.Lookup(lang, rule); template == "" {
+			template, _ = DefaultCatalog.Lookup(lang, invalidRule)
+		}
+		if template != "" {
+			break
+		}
+	}
+	if template == "" {
+		template = "{field} is invalid"
+	}
+	return strings.NewReplacer(
+		"{field}", field,
+		"{param}", strings.ReplaceAll(param, "|", ", "),
+	).Replace(template)
+}
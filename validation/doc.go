@@ -0,0 +1,32 @@
+// Package validation validates the decoded requests with the rules of their
+// struct tags, reporting the invalid fields with localized messages.
+//
+// The rules are listed in the validate tag of the fields, separated by
+// commas:
+//
+//	type OpenAccountRequest struct {
+//		Name    string   `json:"name" validate:"required,max=50"`
+//		Mobile  string   `json:"mobile" validate:"required,phone"`
+//		Email   string   `json:"email" validate:"email"`
+//		IDCard  string   `json:"id_card" validate:"required,idcard"`
+//		Gender  string   `json:"gender" validate:"enum=M|F"`
+//		Code    string   `json:"code" validate:"regex=^[A-Z]{3}\\d{3}$"`
+//		Address *Address `json:"address" validate:"required"`
+//	}
+//
+// The built-in rules are required, len, min, max, regex, enum, phone
+// (Vietnamese mobile numbers), email and idcard (9 digits ID cards and 12
+// digits citizen identity cards). len, min and max apply to the length of
+// strings, slices and maps, and to the value of numbers. The regex rule
+// takes the rest of the tag, commas included, so it must be the last one,
+// and its backslashes are escaped as the tag is a quoted string.
+// The rules other than required are not checked on zero values. Nested
+// structs, and the structs of slices and maps, are validated too. The tags
+// are checked once per type: the unknown rules and the invalid parameters
+// are reported to InvalidTagHandler and ignored, the requests being valid
+// or not whatever the rest of their tags. Check returns them, and the typed
+// servers of transport/http panic on them when they are constructed.
+//
+// The servers of transport/http and transport/grpc validate the requests
+// after decoding them, the messages being in the language of the request.
+package validation
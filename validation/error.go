@@ -0,0 +1,101 @@
+package validation
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FieldError is a field failing a rule.
+type FieldError struct {
+	// Field is the path of the field, such as address.city or items[0].name
+	Field string
+	// Rule and Param are the failed rule and its parameter
+	Rule  string
+	Param string
+}
+
+// Message returns the message of the error in language.
+func (e *FieldError) Message(language string) string {
+	return message(language, e.Field, e.Rule, e.Param)
+}
+
+func (e *FieldError) Error() string {
+	return e.Message(DefaultLanguage)
+}
+
+// Error is the error of the invalid requests. It is encoded as an
+// errors.Error with a 400 status code and the list of the invalid fields,
+// the messages being in Language.
+type Error struct {
+	Fields   []*FieldError
+	Language string
+}
+
+// Localize returns a copy of e with the messages in language.
+func (e *Error) Localize(language string) *Error {
+	return &Error{Fields: e.Fields, Language: language}
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Message(e.Language)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// StatusCode returns the 400 status code of the invalid requests.
+func (e *Error) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// fieldJSON is the JSON form of a FieldError.
+type fieldJSON struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// MarshalJSON encodes e as an errors.Error with the invalid fields.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	fields := make([]fieldJSON, len(e.Fields))
+	for i, f := range e.Fields {
+		fields[i] = fieldJSON{f.Field, f.Rule, f.Param, f.Message(e.Language)}
+	}
+	return json.Marshal(struct {
+		Id     string      `json:"id"`
+		Code   int32       `json:"code"`
+		Detail string      `json:"detail"`
+		Status string      `json:"status"`
+		Fields []fieldJSON `json:"fields"`
+	}{
+		Id:     "400",
+		Code:   http.StatusBadRequest,
+		Detail: e.Error(),
+		Status: http.StatusText(http.StatusBadRequest),
+		Fields: fields,
+	})
+}
+
+// GRPCStatus returns the InvalidArgument status of e, with the field
+// violations as details.
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(codes.InvalidArgument, e.Error())
+	violations := make([]*errdetails.BadRequest_FieldViolation, len(e.Fields))
+	for i, f := range e.Fields {
+		violations[i] = &errdetails.BadRequest_FieldViolation{
+			Field:       f.Field,
+			Description: f.Message(e.Language),
+		}
+	}
+	if detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); err == nil {
+		return detailed
+	}
+	return st
+}
@@ -0,0 +1,404 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// TagName is the struct tag holding the rules of the fields.
+const TagName = "validate"
+
+// Formats of the phone, email and idcard rules
+var (
+	PhonePattern  = regexp.MustCompile(`^(?:\+84|84|0)(?:3|5|7|8|9)\d{8}$`)
+	EmailPattern  = regexp.MustCompile(`^[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}$`)
+	IDCardPattern = regexp.MustCompile(`^(?:\d{9}|\d{12})$`)
+)
+
+// RuleFunc reports whether the value v of a field complies with a rule,
+// param being the parameter of the rule in the tag.
+type RuleFunc func(v reflect.Value, param string) bool
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]RuleFunc{
+		"len":    compareWith(func(n, param float64) bool { return n == param }),
+		"min":    compareWith(func(n, param float64) bool { return n >= param }),
+		"max":    compareWith(func(n, param float64) bool { return n <= param }),
+		"regex":  matchRegex,
+		"enum":   matchEnum,
+		"phone":  matchPattern(PhonePattern),
+		"email":  matchPattern(EmailPattern),
+		"idcard": matchPattern(IDCardPattern),
+	}
+)
+
+// paramChecks check the parameters of the built-in rules when the tags are
+// parsed.
+var paramChecks = map[string]func(param string) error{
+	"len":   checkNumber,
+	"min":   checkNumber,
+	"max":   checkNumber,
+	"regex": checkRegex,
+}
+
+// RegisterRule registers a rule, or replaces a built-in one. The messages
+// of the rule are registered with RegisterMessages.
+func RegisterRule(name string, f RuleFunc) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules[name] = f
+	delete(paramChecks, name)
+}
+
+func lookupRule(name string) (RuleFunc, bool) {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	f, ok := rules[name]
+	return f, ok
+}
+
+// ErrInvalidTag is wrapped by the errors of the tags with an unknown rule or
+// an invalid parameter.
+var ErrInvalidTag = errors.New("validation: invalid tag")
+
+// InvalidTagHandler reports the invalid tags of a struct type, once, when
+// Validate first meets the type. The invalid rules are then ignored, as
+// they are a programming error rather than an invalid request. By default,
+// the errors are printed with the log package of the standard library.
+var InvalidTagHandler = func(err error) { log.Print(err) }
+
+// Validate validates v, a struct or a pointer to a struct, returning an
+// *Error listing the invalid fields, or nil. The messages of the error are
+// in DefaultLanguage, see Error.Localize.
+func Validate(v interface{}) error {
+	var errs []*FieldError
+	validateValue(reflect.ValueOf(v), "", &errs)
+	if len(errs) > 0 {
+		return &Error{Fields: errs, Language: DefaultLanguage}
+	}
+	return nil
+}
+
+// Check returns an error wrapping ErrInvalidTag when a tag of the type of v,
+// or of the struct types it holds, cannot be parsed. It allows reporting the
+// invalid tags of the requests when the servers are constructed.
+func Check(v interface{}) error {
+	var errs []error
+	checkType(reflect.TypeOf(v), map[reflect.Type]bool{}, &errs)
+	return errors.Join(errs...)
+}
+
+// checkType appends the errors of the tags of the struct types held by the
+// values of type t to errs.
+func checkType(t reflect.Type, seen map[reflect.Type]bool, errs *[]error) {
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct || seen[t] || t.ConvertibleTo(timeType) {
+		return
+	}
+	seen[t] = true
+	info := structFields(t)
+	if info.err != nil {
+		*errs = append(*errs, info.err)
+	}
+	for _, f := range info.fields {
+		if f.nested {
+			checkType(t.Field(f.index).Type, seen, errs)
+		}
+	}
+}
+
+// rule is a rule of a field.
+type rule struct {
+	name, param string
+}
+
+// field is a validated field of a struct.
+type field struct {
+	index int
+	name  string
+	rules []rule
+	// required is set by the required rule
+	required bool
+	// nested is set for the fields which may hold structs
+	nested bool
+	// embedded is set for the embedded structs, whose fields are at the
+	// path of the struct as with JSON
+	embedded bool
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// structInfo holds the validated fields of a struct type, and the error of
+// its invalid tags.
+type structInfo struct {
+	fields []field
+	err    error
+	// reported is set once the error is given to InvalidTagHandler
+	reported sync.Once
+}
+
+// fieldsCache caches the structInfo of the struct types.
+var fieldsCache sync.Map
+
+// structFields returns the structInfo of the struct type t, checking the
+// rules of its fields once. The rules registered after the type is first
+// met are not taken into account.
+func structFields(t reflect.Type) *structInfo {
+	if info, ok := fieldsCache.Load(t); ok {
+		return info.(*structInfo)
+	}
+
+	var (
+		fields []field
+		errs   []error
+	)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		f := field{index: i, name: fieldName(sf), nested: mayHoldStruct(sf.Type)}
+		f.embedded = sf.Anonymous && sf.Tag.Get("json") == ""
+		tag := sf.Tag.Get(TagName)
+		if tag == "-" {
+			continue
+		}
+		for tag != "" {
+			var item string
+			if strings.HasPrefix(tag, "regex=") {
+				// the pattern may contain commas
+				item, tag = tag, ""
+			} else if i := strings.IndexByte(tag, ','); i >= 0 {
+				item, tag = tag[:i], tag[i+1:]
+			} else {
+				item, tag = tag, ""
+			}
+			name, param, _ := strings.Cut(strings.TrimSpace(item), "=")
+			switch name {
+			case "":
+			case "required":
+				f.required = true
+			default:
+				if err := checkRule(name, param); err != nil {
+					errs = append(errs, fmt.Errorf("%w %q of field %s.%s: %v", ErrInvalidTag, item, t, sf.Name, err))
+					continue
+				}
+				f.rules = append(f.rules, rule{name, param})
+			}
+		}
+		if f.required || len(f.rules) > 0 || f.nested {
+			fields = append(fields, f)
+		}
+	}
+	info, _ := fieldsCache.LoadOrStore(t, &structInfo{fields: fields, err: errors.Join(errs...)})
+	return info.(*structInfo)
+}
+
+// checkRule checks that the rule name exists and that param is valid.
+func checkRule(name, param string) error {
+	rulesMu.RLock()
+	_, ok := rules[name]
+	check := paramChecks[name]
+	rulesMu.RUnlock()
+	if !ok {
+		return errors.New("unknown rule")
+	}
+	if check != nil {
+		return check(param)
+	}
+	return nil
+}
+
+func checkNumber(param string) error {
+	_, err := strconv.ParseFloat(param, 64)
+	return err
+}
+
+func checkRegex(param string) error {
+	_, err := compileRegex(param)
+	return err
+}
+
+// fieldName returns the JSON name of the field.
+func fieldName(sf reflect.StructField) string {
+	if name, _, _ := strings.Cut(sf.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return sf.Name
+}
+
+// mayHoldStruct returns whether the values of type t may hold structs.
+func mayHoldStruct(t reflect.Type) bool {
+	for {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		case reflect.Struct, reflect.Interface:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// validateValue validates the structs held by v, path being the path of v.
+func validateValue(v reflect.Value, path string, errs *[]*FieldError) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type().ConvertibleTo(timeType) {
+			return
+		}
+		info := structFields(v.Type())
+		if info.err != nil {
+			info.reported.Do(func() { InvalidTagHandler(info.err) })
+		}
+		for _, f := range info.fields {
+			fpath := path
+			if !f.embedded {
+				fpath = join(path, f.name)
+			}
+			validateField(v.Field(f.index), f, fpath, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			validateValue(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key()), errs)
+		}
+	}
+}
+
+// validateField checks the rules of the field f of value v.
+func validateField(v reflect.Value, f field, path string, errs *[]*FieldError) {
+	if v.IsZero() {
+		if f.required {
+			*errs = append(*errs, &FieldError{Field: path, Rule: "required"})
+		}
+		return
+	}
+
+	value := v
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			break
+		}
+		value = value.Elem()
+	}
+	for _, r := range f.rules {
+		// the rules are checked by structFields and never unregistered
+		check, _ := lookupRule(r.name)
+		if !check(value, r.param) {
+			*errs = append(*errs, &FieldError{Field: path, Rule: r.name, Param: r.param})
+			// the other rules would report the same field
+			break
+		}
+	}
+
+	if f.nested {
+		validateValue(v, path, errs)
+	}
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// compareWith returns a RuleFunc comparing the length or the value of v
+// with the number param, checked by structFields.
+func compareWith(cmp func(n, param float64) bool) RuleFunc {
+	return func(v reflect.Value, param string) bool {
+		n, _ := strconv.ParseFloat(param, 64)
+		return compare(v, n, cmp)
+	}
+}
+
+// compare compares the length or the value of v with n.
+func compare(v reflect.Value, n float64, cmp func(n, param float64) bool) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return cmp(float64(utf8.RuneCountInString(v.String())), n)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return cmp(float64(v.Len()), n)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp(float64(v.Int()), n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cmp(float64(v.Uint()), n)
+	case reflect.Float32, reflect.Float64:
+		return cmp(v.Float(), n)
+	}
+	return false
+}
+
+var (
+	regexpsMu sync.RWMutex
+	regexps   = map[string]*regexp.Regexp{}
+)
+
+// compileRegex compiles the pattern once.
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	regexpsMu.RLock()
+	re, ok := regexps[pattern]
+	regexpsMu.RUnlock()
+	if ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexpsMu.Lock()
+	regexps[pattern] = re
+	regexpsMu.Unlock()
+	return re, nil
+}
+
+// matchRegex matches the string v with the pattern param, compiled by
+// structFields.
+func matchRegex(v reflect.Value, param string) bool {
+	re, err := compileRegex(param)
+	return err == nil && v.Kind() == reflect.String && re.MatchString(v.String())
+}
+
+// matchEnum returns whether v is one of the values of param, separated by
+// pipes.
+func matchEnum(v reflect.Value, param string) bool {
+	// fmt prints the values of unexported fields, which Interface cannot
+	// return, such as the fields promoted from unexported embedded structs
+	s := fmt.Sprint(v)
+	for _, allowed := range strings.Split(param, "|") {
+		if s == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPattern(re *regexp.Regexp) RuleFunc {
+	return func(v reflect.Value, _ string) bool {
+		return v.Kind() == reflect.String && re.MatchString(v.String())
+	}
+}
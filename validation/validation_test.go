@@ -0,0 +1,213 @@
+package validation_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ThomasNguyenGitHub/go/validation"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+)
+
+type Address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type Base struct {
+	Channel string `json:"channel" validate:"enum=APP|WEB"`
+}
+
+type openAccountRequest struct {
+	Base
+	Name     string            `json:"name" validate:"required,min=2,max=10"`
+	Mobile   string            `json:"mobile" validate:"required,phone"`
+	Email    string            `json:"email" validate:"email"`
+	IDCard   *string           `json:"id_card" validate:"idcard"`
+	Code     string            `json:"code" validate:"len=6,regex=^[A-Z]{3}\\d{3}$|^X{1,3}$"`
+	Age      int               `json:"age" validate:"min=18"`
+	Address  *Address          `json:"address" validate:"required"`
+	Contacts []Address         `json:"contacts" validate:"max=2"`
+	Tags     map[string]string `json:"tags"`
+}
+
+func validRequest() openAccountRequest {
+	id := "001099012345"
+	return openAccountRequest{
+		Base:    Base{Channel: "APP"},
+		Name:    "Nguyễn An",
+		Mobile:  "+84981234567",
+		Email:   "an.nguyen@example.com.vn",
+		IDCard:  &id,
+		Code:    "ABC123",
+		Age:     20,
+		Address: &Address{City: "Hà Nội"},
+	}
+}
+
+func fieldErrors(err error) map[string]string {
+	rules := map[string]string{}
+	if err == nil {
+		return rules
+	}
+	for _, f := range err.(*validation.Error).Fields {
+		rules[f.Field] = f.Rule
+	}
+	return rules
+}
+
+func TestValidate(t *testing.T) {
+	req := validRequest()
+	if err := validation.Validate(&req); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if err := validation.Validate(req); err != nil {
+		t.Fatalf("Unexpected error for a struct value %v", err)
+	}
+	if err := validation.Validate(nil); err != nil {
+		t.Fatalf("Unexpected error for nil %v", err)
+	}
+
+	id := "12345"
+	req = openAccountRequest{
+		Base:     Base{Channel: "SMS"},
+		Name:     "A",
+		Mobile:   "0123456789",
+		Email:    "an.nguyen@",
+		IDCard:   &id,
+		Code:     "ABCDEF",
+		Age:      17,
+		Contacts: []Address{{City: "Huế"}, {}},
+	}
+	expected := map[string]string{
+		"channel":          "enum",
+		"name":             "min",
+		"mobile":           "phone",
+		"email":            "email",
+		"id_card":          "idcard",
+		"code":             "regex",
+		"age":              "min",
+		"address":          "required",
+		"contacts[1].city": "required",
+	}
+	if got := fieldErrors(validation.Validate(req)); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v but got %v", expected, got)
+	}
+
+	// the rules other than required are not checked on zero values
+	req = validRequest()
+	req.Email, req.IDCard, req.Age = "", nil, 0
+	req.Contacts = []Address{{"a"}, {"b"}, {"c"}}
+	if got := fieldErrors(validation.Validate(req)); !reflect.DeepEqual(got, map[string]string{"contacts": "max"}) {
+		t.Errorf("Unexpected errors %v", got)
+	}
+}
+
+func TestErrorLocalize(t *testing.T) {
+	err := validation.Validate(openAccountRequest{Name: "Nguyễn Văn An Khang", Mobile: "0981234567", Address: &Address{"Huế"}, Base: Base{"X"}}).(*validation.Error)
+
+	if msg := err.Error(); msg != "channel must be one of APP, WEB; name must be at most 10" {
+		t.Errorf("Unexpected message %q", msg)
+	}
+	vn := err.Localize(validation.LanguageVN)
+	if msg := vn.Error(); msg != "channel phải là một trong các giá trị APP, WEB; name phải nhỏ hơn hoặc bằng 10" {
+		t.Errorf("Unexpected message %q", msg)
+	}
+	if msg := err.Localize("fr-FR").Error(); msg != err.Error() {
+		t.Errorf("Expected the default language but got %q", msg)
+	}
+	if err.StatusCode() != 400 {
+		t.Errorf("Expected 400 but got %d", err.StatusCode())
+	}
+
+	b, _ := json.Marshal(vn)
+	var body struct {
+		Code   int32
+		Detail string
+		Fields []struct{ Field, Rule, Param, Message string }
+	}
+	if e := json.Unmarshal(b, &body); e != nil {
+		t.Fatal(e)
+	}
+	if body.Code != 400 || body.Detail != vn.Error() || len(body.Fields) != 2 || body.Fields[1].Param != "10" || !strings.HasPrefix(body.Fields[1].Message, "name phải") {
+		t.Errorf("Unexpected JSON %s", b)
+	}
+
+	st := vn.GRPCStatus()
+	if st.Code() != codes.InvalidArgument || len(st.Details()) != 1 {
+		t.Fatalf("Unexpected status %v", st)
+	}
+	if br := st.Details()[0].(*errdetails.BadRequest); br.FieldViolations[0].Field != "channel" {
+		t.Errorf("Unexpected details %v", br)
+	}
+}
+
+func TestRegisterRule(t *testing.T) {
+	validation.RegisterRule("even", func(v reflect.Value, _ string) bool { return v.Int()%2 == 0 })
+	validation.RegisterMessages(validation.LanguageEN, map[string]string{"even": "{field} must be even"})
+
+	err := validation.Validate(struct {
+		N int `validate:"even"`
+	}{3})
+	if err == nil || err.Error() != "N must be even" {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+type base struct {
+	Channel string `json:"channel" validate:"enum=APP|WEB"`
+}
+
+func TestValidateInvalidTag(t *testing.T) {
+	var reported []error
+	handler := validation.InvalidTagHandler
+	validation.InvalidTagHandler = func(err error) { reported = append(reported, err) }
+	t.Cleanup(func() { validation.InvalidTagHandler = handler })
+
+	type item struct {
+		S string `validate:"regex=[a-"`
+	}
+	for _, v := range []interface{}{
+		struct {
+			N int `validate:"gte=1,max=0"`
+		}{1},
+		struct {
+			S string `validate:"max=ten"`
+		}{"a"},
+		&struct {
+			Items []item `validate:"required"`
+		}{},
+	} {
+		if err := validation.Check(v); !errors.Is(err, validation.ErrInvalidTag) {
+			t.Errorf("Expected an invalid tag error but got %v", err)
+		}
+	}
+	if err := validation.Check(openAccountRequest{}); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	// the invalid rules are reported once and ignored, the other rules
+	// being checked
+	v := struct {
+		N int `validate:"gte=1,max=0"`
+	}{1}
+	for i := 0; i < 2; i++ {
+		if verr, ok := validation.Validate(v).(*validation.Error); !ok || len(verr.Fields) != 1 || verr.Fields[0].Rule != "max" {
+			t.Errorf("Expected the max rule to fail but got %v", verr)
+		}
+	}
+	if len(reported) != 1 || !errors.Is(reported[0], validation.ErrInvalidTag) {
+		t.Errorf("Expected the invalid tag to be reported once but got %v", reported)
+	}
+
+	// the fields promoted from unexported embedded structs are validated
+	err := validation.Validate(struct{ base }{base{Channel: "SMS"}})
+	if verr, ok := err.(*validation.Error); !ok || len(verr.Fields) != 1 || verr.Fields[0].Field != "channel" {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if err := validation.Validate(struct{ base }{base{Channel: "APP"}}); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+}